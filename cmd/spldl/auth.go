@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/keyring"
+)
+
+const authUsage = `Usage: spldl auth test [options]
+       spldl auth login [--profile name] [--username user]
+       spldl auth logout [--profile name]`
+
+func runAuth(args []string) {
+	if len(args) == 0 {
+		fmt.Println(authUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "test":
+		runAuthTest(args[1:])
+	case "login":
+		runAuthLogin(args[1:])
+	case "logout":
+		runAuthLogout(args[1:])
+	case "-h", "--help":
+		fmt.Println(authUsage)
+	default:
+		fmt.Printf("Unknown auth command %q\n", args[0])
+		fmt.Println(authUsage)
+		os.Exit(1)
+	}
+}
+
+// runAuthTest checks that Splunk accepts the configured credentials, exiting with the same statuses
+// as a download would so that a scheduler can run it as a preflight check
+func runAuthTest(args []string) {
+	fs := flag.NewFlagSet("auth test", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(authUsage)
+		fs.PrintDefaults()
+	}
+	conn := addConnectionFlags(fs)
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+
+	client, err := conn.newClient()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	expiry := conn.checkTokenExpiry(0)
+
+	context, err := client.GetCurrentContext()
+	if err != nil {
+		fatal("Authentication failed", err)
+	}
+
+	fmt.Printf("Authenticated as %s with roles %s\n", context.Username, strings.Join(context.Roles, ", "))
+	if !expiry.IsZero() {
+		fmt.Printf("The token expires at %s (in %s)\n", expiry.Format(time.RFC3339), time.Until(expiry).Round(time.Second))
+	}
+}
+
+// keyringFlags selects the profile whose credentials auth login and logout manage
+type keyringFlags struct {
+	conn    *connectionFlags
+	verbose *bool
+}
+
+func addKeyringFlags(fs *flag.FlagSet) *keyringFlags {
+	return &keyringFlags{
+		conn: &connectionFlags{
+			fs:         fs,
+			profile:    fs.String("profile", "", "The config file profile to store the credentials for, the file's default_profile if not set"),
+			configFile: fs.String("config", "", "The config file to load profiles from (default ~/.config/spldl/config.yaml)"),
+		},
+		verbose: fs.BoolP("verbose", "v", false, "Enable verbose logging"),
+	}
+}
+
+// profileName returns the name of the selected profile, empty without one
+func (kf *keyringFlags) profileName() string {
+	configureLogging(*kf.verbose)
+	if _, err := kf.conn.loadProfile(); err != nil {
+		fatal("Failed to load profile", err)
+	}
+	return kf.conn.profileName
+}
+
+// runAuthLogin stores a token, or a username and password, in the keyring for a profile. Later runs
+// with the profile use them when no credentials are given otherwise.
+func runAuthLogin(args []string) {
+	fs := flag.NewFlagSet("auth login", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(authUsage)
+		fs.PrintDefaults()
+	}
+	kf := addKeyringFlags(fs)
+	username := fs.String("username", "", "Store a password for this user instead of a token")
+	fs.Parse(args)
+
+	profile := kf.profileName()
+	var stored config.StoredCredentials
+	if *username == "" {
+		token, err := readSecret("Token: ")
+		if err != nil {
+			fatal("Failed to read the token", err)
+		}
+		stored.Token = token
+	} else {
+		password, err := readSecret("Password for " + *username + ": ")
+		if err != nil {
+			fatal("Failed to read the password", err)
+		}
+		stored.Username, stored.Password = *username, password
+	}
+
+	if err := storeCredentials(profile, stored); err != nil {
+		fatal("Failed to store the credentials in the keyring", err)
+	}
+	fmt.Printf("Stored the credentials for profile %s in the keyring\n", keyringAccount(profile))
+}
+
+// runAuthLogout removes the credentials of a profile from the keyring
+func runAuthLogout(args []string) {
+	fs := flag.NewFlagSet("auth logout", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(authUsage)
+		fs.PrintDefaults()
+	}
+	kf := addKeyringFlags(fs)
+	fs.Parse(args)
+
+	profile := kf.profileName()
+	err := keyring.Delete(keyringService, keyringAccount(profile))
+	if errors.Is(err, keyring.ErrNotFound) {
+		fmt.Printf("No credentials stored for profile %s\n", keyringAccount(profile))
+		return
+	}
+	if err != nil {
+		fatal("Failed to remove the credentials from the keyring", err)
+	}
+	fmt.Printf("Removed the credentials for profile %s from the keyring\n", keyringAccount(profile))
+}
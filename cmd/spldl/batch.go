@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/pipeline"
+)
+
+const batchUsage = "Usage: spldl batch [options] <manifest.yaml>"
+
+// runBatch runs the searches listed in a manifest, several at once, and reports how each went
+func runBatch(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	conn := addConnectionFlags(fs)
+	conn.addDispatchFlags()
+	conn.addNamespaceFlags()
+	concurrency := fs.Int("concurrency", 0, "How many searches run at once (default: the manifest's concurrency, or 4)")
+	failFast := fs.Bool("fail-fast", false, "Start no more searches once one fails, letting those already running finish")
+	force := fs.Bool("force", false, "Overwrite outputs that already exist, e.g. from an earlier run of the manifest")
+	strict := fs.Bool("strict", false, "Fail a search with likely mistakes, such as a missing index= or an unlimited sort, instead of warning about it")
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Usage = func() {
+		fmt.Println(batchUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	manifest, pipelines, err := pipeline.LoadManifest(fs.Arg(0))
+	if err != nil {
+		fatal("Failed to load manifest", err)
+	}
+	if fs.Changed("concurrency") {
+		if *concurrency < 1 {
+			fatal("Invalid options", fmt.Errorf("--concurrency must be at least 1"))
+		}
+		manifest.Concurrency = *concurrency
+	}
+
+	// The searches share one connection, so their jobs count against the same user's quota
+	runner := &pipelineRunner{fs: fs, conn: conn, given: make(map[string]bool), strict: *strict, force: *force, concurrent: true}
+	for _, name := range pipelineConnectionFlags {
+		runner.given[name] = fs.Changed(name)
+	}
+	setPipelineConnection(fs, manifest.Connection, runner.given)
+	client, err := conn.newClient()
+	if err != nil {
+		fatal("Failed to connect", err)
+	}
+	runner.ctx = interruptContext()
+	client = client.WithContext(runner.ctx)
+
+	results := make([]pipelineResult, len(pipelines))
+	var failed atomic.Bool
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(manifest.Concurrency, len(pipelines)) {
+		wg.Go(func() {
+			for i := range next {
+				p := pipelines[i]
+				output := p.Sinks()[0].Path
+				if runner.ctx.Err() != nil || *failFast && failed.Load() {
+					results[i] = pipelineResult{path: output, name: p.Name, skipped: true}
+					continue
+				}
+				slog.Info(fmt.Sprintf("Running search %d of %d", i+1, len(pipelines)), "name", p.Name, "output", output)
+				results[i] = runner.runWith(client, output, p)
+				if failure := results[i].failure; failure != nil {
+					presentError(failure.action+" for "+output, failure.err)
+					failed.Store(true)
+				}
+			}
+		})
+	}
+	for i := range pipelines {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	printBatchSummary(os.Stderr, results)
+	printRunID()
+	status, err := batchStatus(results)
+	heartbeat.Finish(status, err)
+	if status != 0 {
+		os.Exit(status)
+	}
+}
+
+// stageError is a failure of one stage of a pipeline, with the action and exit status fatalWithStatus
+// would report it with
+type stageError struct {
+	action string
+	err    error
+	status int
+}
+
+func (e *stageError) Error() string {
+	return e.action + ": " + e.err.Error()
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+// exitStatus is the status a run failing this way exits with, overridden like in fatalWithStatus
+func (e *stageError) exitStatus() int {
+	if s := errorExitStatus(e.err); s != 0 {
+		return s
+	}
+	return e.status
+}
+
+// pipelineResult is the outcome of one pipeline of a batch, or one search of a manifest
+type pipelineResult struct {
+	path     string
+	name     string
+	sid      string
+	rows     int
+	bytes    int64
+	duration time.Duration
+	warnings int
+	partial  bool
+	skipped  bool        // not run because an earlier pipeline failed with --fail-fast or was interrupted
+	failure  *stageError // nil when the pipeline succeeded
+
+	doneEarlier bool // completed by an earlier run of the batch, according to --state
+}
+
+func (r pipelineResult) status() string {
+	switch {
+	case r.skipped:
+		return "skipped"
+	case r.doneEarlier:
+		return "done earlier"
+	case r.failure != nil && r.failure.exitStatus() == exitInterrupted:
+		return "interrupted"
+	case r.failure != nil:
+		return "failed"
+	case r.partial:
+		return "partial"
+	default:
+		return "ok"
+	}
+}
+
+// printBatchSummary prints a table of the outcome of every pipeline of a batch
+func printBatchSummary(out io.Writer, results []pipelineResult) {
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PIPELINE\tSTATUS\tSID\tROWS\tBYTES\tDURATION\tWARNINGS")
+	for _, r := range results {
+		name := r.path
+		if r.name != "" {
+			name = r.name + " (" + r.path + ")"
+		}
+		sid := r.sid
+		if sid == "" {
+			sid = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%d\n", name, r.status(), sid, r.rows,
+			formatBytes(float64(r.bytes)), r.duration.Round(time.Second), r.warnings)
+	}
+	w.Flush()
+
+	for _, r := range results {
+		if r.failure != nil {
+			fmt.Fprintf(out, "%s: %v\n", r.path, r.failure)
+		}
+	}
+}
+
+// batchStatus returns the exit status of a batch and the error behind it: that of the first failed
+// pipeline, exitPartial when a pipeline only got part of its results, 0 when all succeeded
+func batchStatus(results []pipelineResult) (int, error) {
+	failed := 0
+	var first *stageError
+	partial := false
+	for _, r := range results {
+		if r.failure != nil {
+			failed++
+			if first == nil {
+				first = r.failure
+			}
+		}
+		partial = partial || r.partial
+	}
+	switch {
+	case first != nil:
+		return first.exitStatus(), fmt.Errorf("%d of %d pipelines failed: %w", failed, len(results), first)
+	case partial:
+		return exitPartial, nil
+	default:
+		return 0, nil
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+const bundleUsage = "Usage: spldl bundle --sid <sid> [options] <out-dir>"
+
+// How many events are requested at a time for events.ndjson
+const bundleEventsPage = 10000
+
+// bundleArtifact is a file of the bundle besides the results, written by write to path
+type bundleArtifact struct {
+	name  string
+	write func(path string) error
+}
+
+// runBundle writes everything Splunk keeps about a job into one directory: its results and events,
+// field summary, timeline, metadata and search.log. The artifacts are downloaded in parallel, and
+// those Splunk doesn't have for the job are left out with a warning.
+func runBundle(args []string) {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	sid := fs.String("sid", "", "The search ID of the finished job to bundle")
+	format := fs.String("format", "ndjson", "Format of the results file (ndjson, jsonl, csv or raw)")
+	zipBundle := fs.Bool("zip", false, "Write the bundle to <out-dir>.zip instead of a directory")
+	force := fs.Bool("force", false, "Replace the files of an earlier bundle in <out-dir>")
+	concurrency := fs.Int("max-connections", 8, "The maximum number of concurrent connections to use for downloading results")
+	conn := addConnectionFlags(fs)
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Usage = func() {
+		fmt.Println(bundleUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+	if *sid == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	outputMode, err := parseFormat(*format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	dir := filepath.Clean(fs.Arg(0))
+	if err := checkBundleTarget(dir, *zipBundle, *force); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	client, err := conn.newClient()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	client = client.WithContext(interruptContext())
+	if !fs.Changed("max-connections") && conn.settings.MaxConnections > 0 {
+		*concurrency = conn.settings.MaxConnections
+	}
+	*concurrency = conn.limitConnections(*concurrency)
+
+	// A zipped bundle is collected in a scratch directory, removed once its files are zipped
+	work := dir
+	if *zipBundle {
+		work, err = os.MkdirTemp(filepath.Dir(dir), ".spldl-bundle-")
+	} else {
+		err = os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		fatal("Failed to create the bundle directory", err)
+	}
+
+	artifacts := bundleArtifacts(client, *sid)
+	artifactErrs := make([]error, len(artifacts))
+	var wg sync.WaitGroup
+	for i, artifact := range artifacts {
+		wg.Go(func() {
+			path := filepath.Join(work, artifact.name)
+			if err := artifact.write(path); err != nil {
+				// A partial file would look like the whole artifact. Downloads leave theirs in .part files,
+				// which a directory bundle run again with --force continues, and which stay out of a zip.
+				os.Remove(path)
+				if *zipBundle {
+					parts, _ := filepath.Glob(path + ".part*")
+					for _, part := range parts {
+						os.Remove(part)
+					}
+				}
+				artifactErrs[i] = err
+			}
+		})
+	}
+
+	ext := outputMode
+	if outputMode == "raw" {
+		ext = "txt"
+	}
+	slog.Info("Downloading search results", "sid", *sid)
+	d := downloader.NewDownloader(client, config.DownloaderConfig{
+		OutputMode:     outputMode,
+		MaxConnections: *concurrency,
+		SID:            *sid,
+		Filename:       filepath.Join(work, "results."+ext),
+		MaxResults:     conn.policy.MaxResults,
+		Overwrite:      *force || *zipBundle,
+	})
+	waitForProgress := trackProgress(d)
+	err = d.DownloadSearchResults()
+	waitForProgress()
+	wg.Wait()
+
+	warnings := &report.Warnings{}
+	warnings.Extend(d.Warnings())
+	for i, artifactErr := range artifactErrs {
+		if artifactErr != nil {
+			slog.Warn("Leaving the artifact out of the bundle", "file", artifacts[i].name, "error", artifactErr)
+			warnings.Addf("bundle", "%s is missing: %v", artifacts[i].name, artifactErr)
+		}
+	}
+	printWarnings(warnings)
+	if err != nil {
+		if *zipBundle {
+			os.RemoveAll(work)
+		}
+		fatalWithStatus("Failed to download search results", err, exitDownload)
+	}
+	logTransfer(client.Transferred())
+
+	bundle := dir
+	if *zipBundle {
+		bundle = dir + ".zip"
+		err := zipDirectory(work, filepath.Base(dir), bundle)
+		os.RemoveAll(work)
+		if err != nil {
+			fatal("Failed to zip the bundle", err)
+		}
+	}
+	slog.Info("Wrote investigation bundle", "path", bundle)
+	// Scripts sharing the bundle pick up its path from stdout
+	fmt.Println(bundle)
+}
+
+// checkBundleTarget refuses to mix a bundle with the files of another unless force is set
+func checkBundleTarget(dir string, zipped, force bool) error {
+	if force {
+		return nil
+	}
+	if zipped {
+		if _, err := os.Stat(dir + ".zip"); err == nil {
+			return fmt.Errorf("%s.zip already exists, use --force to overwrite it", dir)
+		}
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s isn't empty, use --force to write the bundle into it", dir)
+}
+
+// bundleArtifacts returns the files of the bundle besides the results
+func bundleArtifacts(client *splunkclient.Client, sid string) []bundleArtifact {
+	return []bundleArtifact{
+		{"job.json", func(path string) error {
+			_, err := client.DownloadJobMetadata(sid, path)
+			return err
+		}},
+		{"summary.json", func(path string) error {
+			summaries, err := client.GetFieldSummaries(sid, 100)
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(summaries, "", "  ")
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(path, append(data, '\n'), 0o644)
+		}},
+		{"timeline.xml", func(path string) error {
+			_, err := client.DownloadJobTimeline(sid, path)
+			return err
+		}},
+		{"search.log", func(path string) error {
+			_, err := client.DownloadSearchLog(sid, path)
+			return err
+		}},
+		{"events.ndjson", func(path string) error {
+			return writeJobEvents(client, sid, path)
+		}},
+	}
+}
+
+// writeJobEvents writes the events of a job to path as ndjson, a page at a time
+func writeJobEvents(client *splunkclient.Client, sid string, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	for start := 0; ; start += bundleEventsPage {
+		page, err := client.GetJobEvents(sid, start, bundleEventsPage, "ndjson")
+		if err == nil {
+			_, err = file.WriteString(page.Data)
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if strings.Count(page.Data, "\n") < bundleEventsPage {
+			break
+		}
+	}
+	return file.Close()
+}
+
+// zipDirectory writes the files of dir into a new zip file at path, in a folder named name so that
+// unzipping the bundle gives back its directory
+func zipDirectory(dir, name, path string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	for _, entry := range entries {
+		if err = addToZip(archive, filepath.Join(dir, entry.Name()), name+"/"+entry.Name()); err != nil {
+			break
+		}
+	}
+	return errors.Join(err, archive.Close(), file.Close())
+}
+
+func addToZip(archive *zip.Writer, path, name string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, source)
+	return err
+}
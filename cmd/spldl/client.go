@@ -0,0 +1,321 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// runID identifies this run to Splunk admins, in the X-Correlation-ID header of every request and a
+// comment in dispatched searches. It's set by the first client created.
+var runID string
+
+// connectionFlags holds the flags shared by every command that talks to Splunk
+type connectionFlags struct {
+	fs         *flag.FlagSet
+	token      *string
+	username   *string
+	password   *string
+	tokenFile  *string
+	passFile   *string
+	credCmd    *string
+	host       *string
+	port       *int
+	insecure   *bool
+	caFile     *string
+	profile    *string
+	configFile *string
+	maxRetries *int
+	pollEvery  *time.Duration
+	pollMax    *time.Duration
+	waitLimit  *time.Duration
+	jobTTL     *time.Duration // nil for commands that don't dispatch searches
+	dispatch   *[]string      // key=value parameters jobs are created with
+	app        *string        // nil for commands that don't run searches
+	owner      *string
+	noCompress *bool
+	session    *bool
+	proxy      *string
+	tlsMin     *string
+	serverName *string
+
+	// Set by newClient
+	clientConfig config.ClientConfig
+	settings     config.Profile // the selected profile, for settings other than the connection's
+	profileName  string         // the name of the selected profile, empty without one
+	policy       config.Policy  // the system policy's limits for this connection
+}
+
+func addConnectionFlags(fs *flag.FlagSet) *connectionFlags {
+	return &connectionFlags{
+		fs:         fs,
+		token:      fs.String("token", "", "The Splunk token to use"),
+		username:   fs.String("username", "", "The Splunk username to use"),
+		password:   fs.String("password", "", "The Splunk password to use"),
+		tokenFile:  fs.String("token-file", "", "File to read the Splunk token from, e.g. a mounted CI secret (or set SPLUNK_TOKEN_FILE)"),
+		passFile:   fs.String("password-file", "", "File to read the Splunk password from (or set SPLUNK_PASSWORD_FILE)"),
+		credCmd:    fs.String("credential-command", "", "Command run with sh -c whose output is the Splunk token, e.g. a vault or cloud secrets CLI"),
+		session:    fs.Bool("session-auth", false, "Exchange the username and password for a session key once instead of sending them with every request"),
+		host:       fs.String("host", "", "The Splunk host to use"),
+		port:       fs.Int("port", 8089, "The Splunk port to use"),
+		insecure:   fs.BoolP("insecure", "k", false, "Set this to ignore TLS verification"),
+		caFile:     fs.String("ca-file", "", "PEM file with the CA certificates to verify Splunk with instead of the system's"),
+		tlsMin:     fs.String("tls-min-version", "", "Lowest TLS version to accept: 1.0, 1.1, 1.2 or 1.3 (default 1.2)"),
+		serverName: fs.String("tls-server-name", "", "Name to verify Splunk's certificate against, when it differs from --host (e.g. connecting by IP or through a tunnel)"),
+		profile:    fs.String("profile", "", "The config file profile to use, the file's default_profile if not set"),
+		configFile: fs.String("config", "", "The config file to load profiles from (default ~/.config/spldl/config.yaml)"),
+		maxRetries: fs.Int("max-retries", 3, "How often a request is retried when Splunk is overloaded or restarting (429, 502-504) or the connection drops"),
+		pollEvery:  fs.Duration("poll-interval", 3*time.Second, "How long to wait before checking a running search job again, doubled after every check"),
+		pollMax:    fs.Duration("max-poll-interval", time.Minute, "The longest wait between checks of a running search job"),
+		waitLimit:  fs.Duration("wait-timeout", 0, "Give up waiting for a search job that isn't done after this long, leaving it running (default: wait as long as it runs)"),
+		noCompress: fs.Bool("no-compression", false, "Ask Splunk for uncompressed responses instead of gzip, saving CPU on fast networks"),
+		proxy:      fs.String("proxy", "", "Connect through this http://, https:// or socks5:// proxy instead of the one set by HTTPS_PROXY/HTTP_PROXY"),
+	}
+}
+
+// addDispatchFlags adds the flags that set how jobs are created to the commands that dispatch searches
+func (cf *connectionFlags) addDispatchFlags() {
+	cf.jobTTL = cf.fs.Duration("ttl", time.Hour, "How long Splunk keeps the search jobs spldl dispatches after they're last accessed, e.g. 24h to download them again later")
+	cf.dispatch = cf.fs.StringArray("dispatch", nil, "Extra key=value parameter to create search jobs with, e.g. max_count=1000 or workload_pool=exports. Repeat for more parameters")
+}
+
+// keepEvents has the jobs spldl dispatches keep the events of transforming searches, which Splunk only
+// does for jobs with a timeline, as Splunk Web creates them. A status_buckets given with --dispatch wins.
+func (cf *connectionFlags) keepEvents() {
+	for _, param := range *cf.dispatch {
+		if strings.HasPrefix(param, "status_buckets=") {
+			return
+		}
+	}
+	*cf.dispatch = append(*cf.dispatch, "status_buckets=300")
+}
+
+// addNamespaceFlags adds --app and --owner to the commands that dispatch and download searches
+func (cf *connectionFlags) addNamespaceFlags() {
+	cf.app = cf.fs.String("app", "", "App whose context searches run in, so they resolve its macros, lookups and event types (default: Splunk's default context)")
+	cf.owner = cf.fs.String("owner", "", "User whose context searches run in, with --app (default nobody, the app's shared objects)")
+}
+
+// newClient merges the flags, the environment and the selected profile and builds a Splunk client
+func (cf *connectionFlags) newClient() (*splunkclient.Client, error) {
+	if err := cf.checkSecretFlags(); err != nil {
+		return nil, err
+	}
+	if *cf.pollEvery <= 0 || *cf.pollMax <= 0 {
+		return nil, errors.New("--poll-interval and --max-poll-interval must be positive")
+	}
+	if *cf.waitLimit < 0 {
+		return nil, errors.New("--wait-timeout can't be negative")
+	}
+	if cf.jobTTL != nil && *cf.jobTTL < time.Second {
+		return nil, errors.New("--ttl must be at least 1s")
+	}
+	profile, err := cf.loadProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := config.ClientFlags{
+		Host:     *cf.host,
+		Token:    *cf.token,
+		Username: *cf.username,
+		Password: *cf.password,
+		CAFile:   *cf.caFile,
+		Proxy:    *cf.proxy,
+
+		TokenFile:         *cf.tokenFile,
+		PasswordFile:      *cf.passFile,
+		CredentialCommand: *cf.credCmd,
+
+		TLSMinVersion: *cf.tlsMin,
+		TLSServerName: *cf.serverName,
+	}
+	if cf.fs.Changed("port") {
+		flags.Port = cf.port
+	}
+	if cf.fs.Changed("insecure") {
+		flags.Insecure = cf.insecure
+	}
+
+	clientConfig, err := config.LoadClientConfig(config.ClientSources{
+		Flags:   flags,
+		Getenv:  os.Getenv,
+		Profile: profile,
+		Keyring: func() (config.StoredCredentials, error) { return loadStoredCredentials(cf.profileName) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientConfig.MaxRetries = *cf.maxRetries
+	clientConfig.PollInterval = *cf.pollEvery
+	clientConfig.MaxPollInterval = *cf.pollMax
+	clientConfig.WaitTimeout = *cf.waitLimit
+	if cf.jobTTL != nil {
+		clientConfig.JobTTL = *cf.jobTTL
+		clientConfig.DispatchParams, err = splunkclient.ParseDispatchParams(*cf.dispatch)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cf.app != nil {
+		clientConfig.App, clientConfig.Owner = *cf.app, *cf.owner
+	}
+	clientConfig.DisableCompression = *cf.noCompress
+	clientConfig.SessionAuth = *cf.session
+	if runID == "" {
+		runID = splunkclient.NewCorrelationID()
+		slog.Debug("Starting run", "run_id", runID)
+	}
+	clientConfig.CorrelationID = runID
+	if clientConfig.Proxy != nil {
+		slog.Debug("Connecting through proxy", "proxy", clientConfig.Proxy.Redacted())
+	}
+
+	policy, err := loadSystemPolicy(config.SystemConfigPath, cf.profileName, clientConfig.Host)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig.MaxRequestsPerSecond = policy.MaxRequestsPerSecond
+
+	cf.clientConfig = clientConfig
+	cf.settings = profile
+	cf.policy = policy
+	return splunkclient.NewClient(clientConfig), nil
+}
+
+// checkSecretFlags rejects giving the same secret in more than one way on the command line
+func (cf *connectionFlags) checkSecretFlags() error {
+	given := func(names ...string) []string {
+		var set []string
+		for _, name := range names {
+			if cf.fs.Changed(name) {
+				set = append(set, "--"+name)
+			}
+		}
+		return set
+	}
+	for _, names := range [][]string{{"token", "token-file", "credential-command"}, {"password", "password-file"}} {
+		if set := given(names...); len(set) > 1 {
+			return fmt.Errorf("%s can't be combined", strings.Join(set, " and "))
+		}
+	}
+	return nil
+}
+
+// limitConnections caps the connections requested by the user at the system policy's maximum
+func (cf *connectionFlags) limitConnections(requested int) int {
+	allowed := cf.policy.Connections(requested)
+	if allowed < requested {
+		slog.Warn("Limiting connections to the maximum allowed by the system policy", "requested", requested, "allowed", allowed)
+	}
+	return allowed
+}
+
+// loadSystemPolicy returns the limits the system-wide config at path sets for the connection, none
+// when there's no system-wide config
+func loadSystemPolicy(path, profile, host string) (config.Policy, error) {
+	file, err := config.LoadSystemFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config.Policy{}, nil
+	}
+	if err != nil {
+		return config.Policy{}, err
+	}
+	policy := file.Policy(profile, host)
+	slog.Debug("Loaded system policy", "path", path, "max_connections", policy.MaxConnections,
+		"max_requests_per_second", policy.MaxRequestsPerSecond, "max_results", policy.MaxResults)
+	return policy, nil
+}
+
+// loadProfile returns the selected profile. Without a config file, running without a profile is fine.
+func (cf *connectionFlags) loadProfile() (config.Profile, error) {
+	path := *cf.configFile
+	if path == "" {
+		var err error
+		path, err = config.DefaultConfigPath()
+		if err != nil {
+			if *cf.profile != "" {
+				return config.Profile{}, fmt.Errorf("unable to locate the config file: %w", err)
+			}
+			return config.Profile{}, nil
+		}
+	}
+
+	file, err := config.LoadFile(path)
+	if errors.Is(err, os.ErrNotExist) && *cf.configFile == "" && *cf.profile == "" {
+		return config.Profile{}, nil
+	}
+	if err != nil {
+		return config.Profile{}, err
+	}
+
+	profile, err := file.Profile(*cf.profile)
+	if err != nil {
+		return config.Profile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if profile.HasSecrets() {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+			slog.Warn("Config file stores credentials but is readable by other users, consider chmod 600", "path", path)
+		}
+	}
+	cf.profileName = cmp.Or(*cf.profile, file.DefaultProfile)
+	slog.Debug("Loaded config file", "path", path, "profile", cf.profileName)
+	return profile, nil
+}
+
+// checkTokenExpiry exits when the token has expired or expires within minValidity, and returns
+// when it expires. The zero time is returned for tokens that don't expire or can't be decoded.
+func (cf *connectionFlags) checkTokenExpiry(minValidity time.Duration) time.Time {
+	token := cf.clientConfig.Auth.Token
+	if token == "" {
+		return time.Time{}
+	}
+	expiry, err := splunkclient.TokenExpiry(token)
+	if err != nil {
+		slog.Debug("Unable to determine token expiry", "error", err)
+		return time.Time{}
+	}
+	if expiry.IsZero() {
+		return expiry
+	}
+
+	remaining := time.Until(expiry)
+	if remaining <= 0 {
+		fatalWithStatus("Refusing to start", fmt.Errorf("the token expired at %s", expiry.Format(time.RFC3339)), exitAuth)
+	}
+	if remaining < minValidity {
+		fatalWithStatus("Refusing to start", fmt.Errorf("the token expires in %s, which is less than --token-min-validity (%s)", remaining.Round(time.Second), minValidity), exitAuth)
+	}
+	slog.Debug("Token expiry", "expires_at", expiry.Format(time.RFC3339), "remaining", remaining.Round(time.Second))
+	return expiry
+}
+
+// printRunID prints the run's correlation ID at exit, for looking the run up in Splunk's _audit index
+func printRunID() {
+	if runID != "" {
+		fmt.Fprintln(os.Stderr, "Run ID: "+runID)
+	}
+}
+
+func configureLogging(verbose bool) {
+	verboseErrors = verbose
+	// Logs never go to stdout, which may be carrying results
+	log.SetOutput(os.Stderr)
+	if verbose {
+		// Verbose mode: enable debug logging while keeping default format
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	} else {
+		// Normal mode: info level and above
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+const configUsage = "Usage: spldl config migrate [options]"
+
+func runConfig(args []string) {
+	if len(args) == 0 {
+		fmt.Println(configUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "migrate":
+		runConfigMigrate(args[1:])
+	case "-h", "--help":
+		fmt.Println(configUsage)
+	default:
+		fmt.Printf("Unknown config command %q\n", args[0])
+		fmt.Println(configUsage)
+		os.Exit(1)
+	}
+}
+
+// runConfigMigrate rewrites the config file in the layout of this spldl. Older files load without it,
+// but rewriting them lets later releases drop the migration.
+func runConfigMigrate(args []string) {
+	fs := flag.NewFlagSet("config migrate", flag.ExitOnError)
+	configFile := fs.String("config", "", "The config file to migrate (default ~/.config/spldl/config.yaml)")
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Usage = func() {
+		fmt.Println(configUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	configureLogging(*verbose)
+
+	path := *configFile
+	if path == "" {
+		var err error
+		path, err = config.DefaultConfigPath()
+		if err != nil {
+			fatal("Unable to locate the config file", err)
+		}
+	}
+
+	version, err := config.MigrateFile(path)
+	if err != nil {
+		fatal("Failed to migrate the config file", err)
+	}
+	if version == config.FileVersion {
+		fmt.Printf("%s is already at version %d\n", path, version)
+		return
+	}
+	fmt.Printf("Migrated %s from version %d to %d, the original is kept as %s.bak\n", path, version, config.FileVersion, path)
+}
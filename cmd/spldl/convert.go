@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/convert"
+)
+
+const convertUsage = "Usage: spldl convert [options] <input-file.[ndjson|jsonl|json|csv|tsv|parquet|txt]> <output-file.[ndjson|jsonl|json|csv|tsv|xlsx|parquet|txt]>"
+
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Usage = func() {
+		fmt.Println(convertUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	input, output := fs.Arg(0), fs.Arg(1)
+
+	// File formats are read and written by their extension, like the outputs of a download
+	inputMode, err := detectOutputMode(input, "", false)
+	if err != nil {
+		fmt.Println("Input file must have .ndjson, .jsonl, .json, .csv, .tsv, .parquet, or .txt extension")
+		os.Exit(1)
+	}
+	outputMode, err := detectOutputMode(output, "", false)
+	if err != nil {
+		fmt.Println("Output file must have .ndjson, .jsonl, .json, .csv, .tsv, .xlsx, .parquet, or .txt extension")
+		os.Exit(1)
+	}
+	if input == output {
+		fmt.Println("Input and output file must be different")
+		os.Exit(1)
+	}
+
+	err = convert.Convert(input, inputMode, output, outputMode)
+	if err != nil {
+		fatal("Failed to convert results", err)
+	}
+
+	slog.Info("Converted results", "input", input, "output", output)
+}
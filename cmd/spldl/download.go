@@ -0,0 +1,95 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+)
+
+// runDownload downloads the results of existing jobs. command is "download" for spldl download and
+// empty for the original form, which takes the same options.
+func runDownload(command string, args []string) {
+	fs := flag.NewFlagSet(cmp.Or(command, "spldl"), flag.ExitOnError)
+	sids := fs.StringSlice("sid", nil, "An already-completed search ID to download from. Repeat it, or give a comma-separated list, to merge the results of several jobs into one output")
+	sortTime := fs.Bool("sort-time", false, "Merge the results of several --sid jobs by _time, oldest first, instead of writing one job after another (ndjson only)")
+	singleRequest := fs.Bool("single-request", false, "Download the job's csv or raw results into the output file in one request, as Splunk sends them, continuing a dropped transfer from the last byte written")
+	conn := addConnectionFlags(fs)
+	conn.addNamespaceFlags()
+	out := addOutputFlags(fs)
+	fs.Parse(args)
+
+	configureLogging(*out.verbose)
+
+	if *out.help {
+		printDownloadUsage(command)
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	args = fs.Args()
+	if len(*sids) == 0 || command == "download" && len(args) != out.outputArgs() {
+		fmt.Println(downloadUsage)
+		os.Exit(1)
+	}
+	if len(args) == 0 && *out.kafkaTopic == "" {
+		fmt.Println("No output file specified")
+		printDownloadUsage(command)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	// Further jobs are merged into the output after the first one
+	sid, mergeSIDs := (*sids)[0], (*sids)[1:]
+	if len(mergeSIDs) > 0 && (*out.follow || *out.resume) {
+		fmt.Println("merging several --sid jobs can't be used with --follow or --resume")
+		os.Exit(1)
+	}
+	if *sortTime && len(mergeSIDs) == 0 {
+		fmt.Println("--sort-time merges the results of several jobs and needs more than one --sid")
+		os.Exit(1)
+	}
+	out.check()
+
+	client, tokenExpiry := out.connect(conn)
+	out.resolveOutput(args)
+
+	slog.Info("Downloading search results", "sid", sid)
+	heartbeat.SetPhase(report.PhaseDownloading, sid)
+
+	downloaderConfig := out.downloaderConfig(conn, tokenExpiry)
+	downloaderConfig.SID = sid
+	downloaderConfig.MergeSIDs = mergeSIDs
+	downloaderConfig.SortByTime = *sortTime
+	dl := downloader.NewDownloader(client, downloaderConfig)
+	download := dl.DownloadSearchResults
+	switch {
+	case *out.follow:
+		download = dl.FollowSearchResults
+	case *singleRequest:
+		download = dl.DownloadResultsFile
+	}
+	// The job already exists, its time range isn't known
+	run := downloadRun{jobless: *out.follow}
+	out.download(client, dl, download, run, &report.Warnings{})
+}
+
+// hasSIDFlag reports whether the options of the original form, without a subcommand, download an
+// existing job with --sid instead of running a search
+func hasSIDFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == "--sid" || strings.HasPrefix(arg, "--sid=") {
+			return true
+		}
+	}
+	return false
+}
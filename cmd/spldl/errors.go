@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// Exit statuses, so that schedulers can tell failures apart without parsing logs
+const (
+	exitFailure     = 1   // anything not covered below, including invalid usage
+	exitAuth        = 2   // Splunk rejected the credentials or the user lacks a permission
+	exitUnreachable = 3   // Splunk couldn't be reached or was unavailable
+	exitSearch      = 4   // the search job couldn't be created or didn't finish
+	exitDownload    = 5   // the results couldn't be downloaded, verified or written
+	exitPartial     = 6   // the job was finalized early by --partial-ok and its partial results were downloaded
+	exitInterrupted = 130 // stopped by Ctrl-C or SIGTERM, following the shell convention of 128 + SIGINT
+)
+
+// verboseErrors shows the complete error chain instead of just the summary, set by --verbose
+var verboseErrors bool
+
+// fatal presents err to the user with a hint on how to fix it and exits
+func fatal(action string, err error) {
+	fatalWithStatus(action, err, exitFailure)
+}
+
+// fatalWithStatus is fatal for failures of a known stage. Interruptions, authentication and
+// connection problems override status since they are what needs fixing.
+func fatalWithStatus(action string, err error, status int) {
+	presentError(action, err)
+	if s := errorExitStatus(err); s != 0 {
+		status = s
+	}
+	if status == exitInterrupted || cleanupOnFailure {
+		runInterruptCleanups()
+	}
+	printRunID()
+	heartbeat.Finish(status, fmt.Errorf("%s: %w", action, err))
+	os.Exit(status)
+}
+
+// errorExitStatus returns the exit status for authentication and connection errors, or 0 for other errors
+func errorExitStatus(err error) int {
+	var httpErr *splunkclient.HTTPError
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certErr *x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &httpErr):
+		switch httpErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return exitAuth
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return exitUnreachable
+		}
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &certErr),
+		errors.As(err, &dnsErr), errors.Is(err, context.DeadlineExceeded), errors.As(err, &opErr) && opErr.Op == "dial":
+		return exitUnreachable
+	}
+	return 0
+}
+
+func presentError(action string, err error) {
+	message, hint := describeError(err)
+	if verboseErrors {
+		message = err.Error()
+	}
+
+	fmt.Fprintln(os.Stderr, colorize(colorRed, "Error: ")+action+": "+message)
+	if hint != "" {
+		fmt.Fprintln(os.Stderr, colorize(colorYellow, "Hint: ")+hint)
+	}
+	if !verboseErrors {
+		fmt.Fprintln(os.Stderr, "Run with --verbose for more detail.")
+	}
+}
+
+// describeError maps the errors users commonly run into to a concise message and a next step
+func describeError(err error) (string, string) {
+	var httpErr *splunkclient.HTTPError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certErr *x509.CertificateInvalidError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var jobFailed *splunkclient.JobFailedError
+	var jobTimeout *splunkclient.JobTimeoutError
+	var rowCount *downloader.RowCountError
+
+	switch {
+	case errors.As(err, &jobFailed):
+		switch jobFailed.DispatchState {
+		case "PAUSED":
+			return jobFailed.Error(), "Resume the job in Splunk's job inspector, then download it with spldl download --sid " + jobFailed.SID + "."
+		case "ZOMBIE":
+			return jobFailed.Error(), "The search head may have restarted or run out of memory. Run the search again."
+		default:
+			return jobFailed.Error(), "Check the search for mistakes, e.g. by running it in Splunk's search UI."
+		}
+	case errors.As(err, &jobTimeout):
+		return jobTimeout.Error(), "The job is still running. Download it later with spldl download --sid " + jobTimeout.SID + ", or raise --wait-timeout."
+	case errors.As(err, &rowCount):
+		return "the output is incomplete: " + rowCount.Error(), "Download the job again with spldl download --sid " + rowCount.SID + ", or keep what was written with --allow-partial."
+	case errors.As(err, &httpErr):
+		switch httpErr.StatusCode {
+		case http.StatusUnauthorized:
+			return "Splunk rejected the credentials", "Check SPLUNK_TOKEN (or --token), or SPLUNK_USERNAME/SPLUNK_PASSWORD, and that the token hasn't expired."
+		case http.StatusForbidden:
+			return "the user isn't allowed to do this", "Check the roles and capabilities of the Splunk user."
+		case http.StatusNotFound:
+			return "Splunk couldn't find the resource" + splunkMessage(httpErr), "The job may have expired or the SID is wrong. Find your jobs with spldl jobs list."
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return "the search head is overloaded or unavailable" + splunkMessage(httpErr), "Try again later or lower --max-connections."
+		default:
+			return httpErr.Error(), ""
+		}
+	case errors.As(err, &unknownAuthority), errors.As(err, &certErr):
+		return "the server's TLS certificate isn't trusted", "Splunk ships with a self-signed certificate. Use --insecure (-k) to skip verification."
+	case errors.As(err, &hostnameErr):
+		return "the server's TLS certificate doesn't match the host", "Connect using a host name listed in the certificate, or use --insecure (-k)."
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("couldn't resolve host %q", dnsErr.Name), "Check the value of --host."
+	case errors.Is(err, context.DeadlineExceeded):
+		return "the request timed out", "Check that the search head is reachable from this machine."
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "couldn't connect to " + opErr.Addr.String(), "Check --host and --port. spldl talks to the management port (8089 by default), not the web UI port."
+	default:
+		return err.Error(), ""
+	}
+}
+
+func splunkMessage(httpErr *splunkclient.HTTPError) string {
+	if httpErr.Message == "" {
+		return ""
+	}
+	return " (" + httpErr.Message + ")"
+}
+
+// colorize wraps text in an ANSI color when stderr is a terminal and NO_COLOR isn't set
+func colorize(color, text string) string {
+	if os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stderr) {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+// durationFlag is a time.Duration flag that also accepts days, e.g. 7d or 1d12h
+type durationFlag time.Duration
+
+func (d *durationFlag) Set(value string) error {
+	duration, err := config.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = durationFlag(duration)
+	return nil
+}
+
+func (d *durationFlag) String() string {
+	if *d == 0 {
+		return ""
+	}
+	return time.Duration(*d).String()
+}
+
+func (d *durationFlag) Type() string {
+	return "duration"
+}
+
+// timeFlag is a point in time given as RFC 3339 (e.g. 2025-08-26T02:00:00Z) or as an epoch timestamp,
+// the formats of Splunk's _time field
+type timeFlag time.Time
+
+func (t *timeFlag) Set(value string) error {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		epoch, epochErr := strconv.ParseFloat(value, 64)
+		if epochErr != nil {
+			return fmt.Errorf("invalid time %q, use RFC 3339 (e.g. 2025-08-26T02:00:00Z) or an epoch timestamp", value)
+		}
+		parsed = time.Unix(0, int64(epoch*float64(time.Second)))
+	}
+	*t = timeFlag(parsed)
+	return nil
+}
+
+func (t *timeFlag) String() string {
+	if time.Time(*t).IsZero() {
+		return ""
+	}
+	return time.Time(*t).Format(time.RFC3339)
+}
+
+func (t *timeFlag) Type() string {
+	return "time"
+}
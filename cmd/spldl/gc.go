@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/pipeline"
+)
+
+const gcUsage = "Usage: spldl gc [options] [dir...]"
+
+// defaultStateAge is how long spldl keeps the state of incomplete downloads and batches, by default
+const defaultStateAge = 7 * 24 * time.Hour
+
+// runGC removes the state of downloads and batches that haven't been touched for a while, so hosts
+// running scheduled exports don't slowly fill up with it
+func runGC(args []string) {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	olderThan := durationFlag(defaultStateAge)
+	fs.Var(&olderThan, "older-than", "Remove state that wasn't modified for this long, e.g. 7d or 12h")
+	states := fs.StringArray("state", nil, "Batch state file of spldl run --state to drop the pipelines older than --older-than from, removing it once empty. Repeat for more files")
+	checkpoints := fs.StringArray("checkpoint", nil, "Checkpoint file of spldl search --checkpoint to remove when older than --older-than. Repeat for more files")
+	dryRun := fs.Bool("dry-run", false, "Only print the files that would be removed")
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Usage = func() {
+		fmt.Println(gcUsage)
+		fmt.Println("Removes resume sidecars, part files and checkpoints under the directories (default: the current one) and in the state directory")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+
+	if olderThan <= 0 {
+		fmt.Println("--older-than must be positive")
+		os.Exit(1)
+	}
+	before := time.Now().Add(-time.Duration(olderThan))
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	stale, err := downloader.StaleFiles(dirs, before)
+	if err != nil {
+		fatal("Failed to find stale state", err)
+	}
+	found := make(map[string]bool)
+	for _, file := range stale {
+		found[filepath.Clean(file.Path)] = true
+	}
+	for _, path := range *checkpoints {
+		if found[filepath.Clean(path)] {
+			continue
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			fatal("Failed to check checkpoint", err)
+		}
+		if info.ModTime().Before(before) {
+			stale = append(stale, downloader.StaleFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+
+	failed := 0
+	var freed int64
+	for _, file := range stale {
+		if *dryRun {
+			fmt.Printf("Would remove %s (%d bytes, modified %s)\n", file.Path, file.Size, file.ModTime.Format(time.RFC3339))
+			continue
+		}
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			presentError("Failed to remove "+file.Path, err)
+			failed++
+			continue
+		}
+		freed += file.Size
+		slog.Debug("Removed stale state", "path", file.Path, "modified", file.ModTime)
+	}
+
+	for _, path := range *states {
+		if err := pruneBatchState(path, before, *dryRun); err != nil {
+			presentError("Failed to prune batch state "+path, err)
+			failed++
+		}
+	}
+
+	if !*dryRun {
+		slog.Info("Finished removing stale state", "files", len(stale)-failed, "bytes", freed, "failed", failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// pruneBatchState drops the pipelines last updated before the given time from the batch state at
+// path, and removes the file once no pipelines are left
+func pruneBatchState(path string, before time.Time, dryRun bool) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	state, err := pipeline.LoadBatchState(path)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for name, entry := range state.Entries {
+			if entry.Updated.Before(before) {
+				fmt.Printf("Would drop %s from %s (updated %s)\n", name, path, entry.Updated.Format(time.RFC3339))
+			}
+		}
+		return nil
+	}
+	pruned, err := state.Prune(before)
+	if err != nil {
+		return err
+	}
+	if len(state.Entries) == 0 {
+		slog.Debug("Removed batch state", "path", path)
+		return state.Remove()
+	}
+	slog.Debug("Pruned batch state", "path", path, "pipelines", pruned)
+	return nil
+}
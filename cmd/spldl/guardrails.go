@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/spl"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// checkGuardrails enforces the system policy's limits on a search before it's dispatched, so that an
+// accidental all-time or all-index export never reaches the search head
+func checkGuardrails(client *splunkclient.Client, policy config.Policy, search, earliest, latest string) error {
+	indexes := spl.Indexes(search)
+	if policy.RequireIndex && len(indexes) == 0 && !strings.HasPrefix(strings.TrimSpace(search), "|") {
+		return errors.New("the system policy requires searches to select an index, add index=<name> to the search")
+	}
+
+	if maxRange := time.Duration(policy.MaxTimeRange); maxRange > 0 {
+		span, ok := spl.TimeSpan(earliest, latest, time.Now())
+		if !ok {
+			return fmt.Errorf("the system policy limits searches to %s, and the time range %s to %s can't be checked, use relative (-7d) or epoch times", maxRange, earliest, latest)
+		}
+		if span > maxRange {
+			return fmt.Errorf("the time range %s to %s covers %s, more than the %s the system policy allows", earliest, latest, span.Round(time.Minute), maxRange)
+		}
+	}
+
+	if policy.MaxEstimatedEvents > 0 {
+		estimate, err := client.EstimateEventCount(indexes, earliest, latest)
+		if err != nil {
+			return fmt.Errorf("the system policy limits the events a search may read, and estimating them failed: %w", err)
+		}
+		slog.Debug("Estimated events in the search's indexes", "events", estimate, "max", policy.MaxEstimatedEvents)
+		if estimate > policy.MaxEstimatedEvents {
+			return fmt.Errorf("the searched indexes hold about %d events in the time range, more than the %d the system policy allows, narrow the time range or the indexes", estimate, policy.MaxEstimatedEvents)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/report"
+)
+
+const heartbeatInterval = 10 * time.Second
+
+// How long the receiver of --callback-url has to answer
+const callbackTimeout = 30 * time.Second
+
+// heartbeat reports the run's progress when --heartbeat-file, --health-addr or --callback-url is set, nil otherwise
+var heartbeat *report.Heartbeat
+
+func startHeartbeat(file, addr, callbackURL string, stallTimeout time.Duration) {
+	if file == "" && addr == "" && callbackURL == "" {
+		return
+	}
+	heartbeat = report.NewHeartbeat(stallTimeout)
+
+	if callbackURL != "" {
+		callback, err := newCallback(callbackURL)
+		if err != nil {
+			fatal("Invalid --callback-url", err)
+		}
+		heartbeat.OnFinish(func(status report.Status) {
+			// Not tied to the interrupt context, the outcome of an interrupted run is reported too
+			ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+			defer cancel()
+			if err := callback.Send(ctx, status); err != nil {
+				slog.Error("Failed to send callback", "url", callbackURL, "error", err)
+				return
+			}
+			slog.Debug("Sent callback", "url", callbackURL)
+		})
+	}
+
+	if file != "" {
+		if err := heartbeat.WriteEvery(file, heartbeatInterval); err != nil {
+			fatal("Failed to write heartbeat file", err)
+		}
+	}
+
+	if addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			fatal("Failed to start health endpoint", err)
+		}
+		slog.Info("Serving health endpoints", "addr", listener.Addr().String())
+		go func() {
+			if err := http.Serve(listener, heartbeat.Handler()); err != nil {
+				slog.Error("Health endpoint stopped", "error", err)
+			}
+		}()
+	}
+}
+
+// newCallback posts to rawURL, signing with SPLDL_SIGNING_KEY when set
+func newCallback(rawURL string) (*report.Callback, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", rawURL)
+	}
+	key := os.Getenv("SPLDL_SIGNING_KEY")
+	if key == "" {
+		slog.Warn("SPLDL_SIGNING_KEY is not set, the callback will be unsigned")
+	}
+	return &report.Callback{URL: rawURL, Key: []byte(key)}, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// cancelOnInterrupt deletes the job spldl dispatched when the run is interrupted, set by --cancel-on-interrupt
+var cancelOnInterrupt bool
+
+// cleanupOnFailure deletes the job spldl dispatched when the run fails or is interrupted, set by
+// --cleanup=always
+var cleanupOnFailure bool
+
+// interruptCleanups delete the jobs spldl dispatched before it exits because it was interrupted, or
+// failed with --cleanup=always. A job's cleanup is dropped once its run is over, so that a later
+// failure doesn't delete it again.
+var interruptCleanups struct {
+	mu   sync.Mutex
+	jobs []jobCleanup
+}
+
+// jobCleanup deletes the job with sid
+type jobCleanup struct {
+	sid string
+	run func()
+}
+
+const cleanupUsage = "When to delete the search job: always, also when the run fails or is interrupted; on-success, once its results were downloaded, like --delete-when-done; or never, keeping it until it expires so a failed download can be resumed"
+
+// cleanupPolicy validates --cleanup, returning whether jobs are deleted once their results were
+// downloaded. Without --cleanup, that's deleteWhenDone.
+func cleanupPolicy(policy string, deleteWhenDone, cancelOnInterrupt bool) (bool, error) {
+	switch policy {
+	case "":
+		return deleteWhenDone, nil
+	case "always":
+		return true, nil
+	case "on-success":
+		return true, nil
+	case "never":
+		if cancelOnInterrupt {
+			return false, errors.New("--cleanup=never keeps the job and can't be combined with --cancel-on-interrupt")
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown --cleanup %q, use always, on-success or never", policy)
+	}
+}
+
+// applyCleanupPolicy makes a failing run delete the jobs it dispatched when --cleanup=always is given.
+// The policy must have been validated by cleanupPolicy.
+func applyCleanupPolicy(policy string) {
+	cleanupOnFailure = policy == "always"
+}
+
+// partialOK makes the first interrupt while waiting for a job finalize it, so the results found so far
+// are downloaded instead of thrown away, set by --partial-ok
+var partialOK bool
+
+// softInterrupt, while set, is called by the next interrupt instead of stopping spldl
+var softInterrupt struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// interruptContext returns a context that is canceled by Ctrl-C or SIGTERM. Once canceled, the next
+// signal stops spldl immediately.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for range signals {
+			softInterrupt.mu.Lock()
+			soft := softInterrupt.cancel
+			softInterrupt.cancel = nil
+			softInterrupt.mu.Unlock()
+			if soft != nil {
+				slog.Warn("Interrupted, finalizing the search job to download the results found so far. Press Ctrl-C again to stop")
+				soft()
+				continue
+			}
+
+			signal.Stop(signals)
+			cancel()
+			slog.Warn("Interrupted, stopping. Press Ctrl-C again to exit immediately")
+			return
+		}
+	}()
+	return ctx
+}
+
+// waitForJob waits for the job to be done. With --partial-ok, an interrupt finalizes the job instead,
+// and the returned bool reports that the job holds only the results found until then.
+func waitForJob(client *splunkclient.Client, sid string) (bool, error) {
+	if !partialOK {
+		return false, client.WaitUntilJobIsDone(sid)
+	}
+
+	ctx, cancel := context.WithCancel(client.Context())
+	defer cancel()
+	softInterrupt.mu.Lock()
+	softInterrupt.cancel = cancel
+	softInterrupt.mu.Unlock()
+	err := client.WithContext(ctx).WaitUntilJobIsDone(sid)
+	softInterrupt.mu.Lock()
+	softInterrupt.cancel = nil
+	softInterrupt.mu.Unlock()
+
+	if err == nil || client.Context().Err() != nil || ctx.Err() == nil {
+		return false, err
+	}
+	if err := client.FinalizeSearchJob(sid); err != nil {
+		return false, fmt.Errorf("failed to finalize job: %w", err)
+	}
+	slog.Info("Finalized search job, waiting for Splunk to wrap it up", "sid", sid)
+	return true, client.WaitUntilJobIsDone(sid)
+}
+
+// cancelJobOnInterrupt arranges for a job spldl dispatched to be deleted, which also stops it, if the
+// run is interrupted and --cancel-on-interrupt is set, or fails and --cleanup=always is set
+func cancelJobOnInterrupt(client *splunkclient.Client, sid string) {
+	if !cancelOnInterrupt && !cleanupOnFailure {
+		return
+	}
+	interruptCleanups.mu.Lock()
+	defer interruptCleanups.mu.Unlock()
+	interruptCleanups.jobs = append(interruptCleanups.jobs, jobCleanup{sid: sid, run: func() {
+		// The client's own context has been canceled
+		err := client.WithContext(context.Background()).DeleteSearchJob(sid)
+		if err != nil {
+			slog.Warn("Failed to cancel search job", "sid", sid, "error", err)
+			return
+		}
+		slog.Info("Canceled search job", "sid", sid)
+	}})
+}
+
+// forgetJobCleanup drops the cleanup of the job with sid, whose run is over
+func forgetJobCleanup(sid string) {
+	interruptCleanups.mu.Lock()
+	defer interruptCleanups.mu.Unlock()
+	interruptCleanups.jobs = slices.DeleteFunc(interruptCleanups.jobs, func(c jobCleanup) bool {
+		return c.sid == sid
+	})
+}
+
+// forgetInterruptCleanups drops the cleanups of every job dispatched so far
+func forgetInterruptCleanups() {
+	interruptCleanups.mu.Lock()
+	defer interruptCleanups.mu.Unlock()
+	interruptCleanups.jobs = nil
+}
+
+// hasInterruptCleanups reports whether a job would be deleted on interrupt or failure
+func hasInterruptCleanups() bool {
+	interruptCleanups.mu.Lock()
+	defer interruptCleanups.mu.Unlock()
+	return len(interruptCleanups.jobs) > 0
+}
+
+func runInterruptCleanups() {
+	interruptCleanups.mu.Lock()
+	jobs := interruptCleanups.jobs
+	interruptCleanups.jobs = nil
+	interruptCleanups.mu.Unlock()
+	for _, cleanup := range jobs {
+		cleanup.run()
+	}
+}
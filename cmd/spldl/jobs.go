@@ -0,0 +1,255 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+const jobsUsage = "Usage: spldl jobs <list|inspect|delete|clean|cancel|pause|unpause|finalize|touch> [options]"
+
+func runJobs(args []string) {
+	if len(args) == 0 {
+		fmt.Println(jobsUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		runJobsList(args[1:])
+	case "inspect":
+		runJobsInspect(args[1:])
+	case "delete":
+		runJobsDelete(args[1:])
+	case "clean":
+		runJobsClean(args[1:])
+	case "cancel", "pause", "unpause", "finalize", "touch":
+		runJobsControl(args[0], args[1:])
+	case "-h", "--help":
+		fmt.Println(jobsUsage)
+	default:
+		fmt.Printf("Unknown jobs command %q\n", args[0])
+		fmt.Println(jobsUsage)
+		os.Exit(1)
+	}
+}
+
+func addJobFilterFlags(fs *flag.FlagSet) *splunkclient.JobFilter {
+	var filter splunkclient.JobFilter
+	fs.StringVar(&filter.Owner, "owner", "", "Only include jobs owned by this user")
+	fs.StringVar(&filter.App, "app", "", "Only include jobs dispatched from this app")
+	fs.StringVar(&filter.DispatchState, "state", "", "Only include jobs in this dispatch state (e.g. DONE, RUNNING, FAILED)")
+	fs.StringVar(&filter.Label, "label", "", "Only include jobs dispatched with this --label")
+	fs.Var((*durationFlag)(&filter.OlderThan), "older-than", "Only include jobs dispatched at least this long ago (e.g. 24h or 7d)")
+	fs.BoolVar(&filter.Mine, "mine", false, "Only include jobs owned by the authenticated user")
+	fs.BoolVar(&filter.Running, "running", false, "Only include jobs that are still running")
+	fs.StringVar(&filter.SearchContains, "search-contains", "", "Only include jobs whose search contains this text, ignoring case")
+	return &filter
+}
+
+// parseClientFlags parses the flags of a subcommand that talks to Splunk and returns a client for it
+func parseClientFlags(fs *flag.FlagSet, args []string) *splunkclient.Client {
+	conn := addConnectionFlags(fs)
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+
+	client, err := conn.newClient()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return client
+}
+
+func runJobsList(args []string) {
+	fs := flag.NewFlagSet("jobs list", flag.ExitOnError)
+	filter := addJobFilterFlags(fs)
+	client := parseClientFlags(fs, args)
+	if filter.Mine && filter.Owner != "" {
+		fmt.Println("--mine and --owner can't be used together")
+		os.Exit(1)
+	}
+
+	jobs, err := client.ListSearchJobs(*filter)
+	if err != nil {
+		fatal("Failed to list search jobs", err)
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SID\tLABEL\tOWNER\tAPP\tSTATE\tRESULTS\tSIZE\tEXPIRES IN\tAGE\tSEARCH")
+	for _, job := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			job.Content.SID, cmp.Or(splunkclient.JobLabel(job.Content.SID), "-"), job.ACL.Owner, job.ACL.App, job.Content.DispatchState,
+			job.Content.ResultCount, formatBytes(float64(job.Content.DiskUsage)), secondsString(job.Content.TTL),
+			now.Sub(job.Published).Round(time.Second), truncateSearch(job.Name))
+	}
+	w.Flush()
+}
+
+// The longest search shown by jobs list, so that each job stays on one line
+const maxListedSearch = 60
+
+// truncateSearch shortens a search to a single line of at most maxListedSearch characters
+func truncateSearch(search string) string {
+	search = strings.Join(strings.Fields(search), " ")
+	if runes := []rune(search); len(runes) > maxListedSearch {
+		return string(runes[:maxListedSearch-3]) + "..."
+	}
+	return search
+}
+
+func runJobsInspect(args []string) {
+	fs := flag.NewFlagSet("jobs inspect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: spldl jobs inspect [options] <sid>")
+		fs.PrintDefaults()
+	}
+	client := parseClientFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	job, err := client.GetJob(fs.Arg(0))
+	if err != nil {
+		fatal("Failed to inspect search job", err)
+	}
+
+	content := job.Content
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SID:\t%s\n", content.SID)
+	fmt.Fprintf(w, "Search:\t%s\n", job.Name)
+	fmt.Fprintf(w, "Label:\t%s\n", cmp.Or(splunkclient.JobLabel(content.SID), "-"))
+	fmt.Fprintf(w, "Owner:\t%s\n", job.ACL.Owner)
+	fmt.Fprintf(w, "App:\t%s\n", job.ACL.App)
+	fmt.Fprintf(w, "State:\t%s (%.0f%%)\n", content.DispatchState, content.DoneProgress*100)
+	fmt.Fprintf(w, "Dispatched:\t%s (%s ago)\n", job.Published.Format(time.RFC3339), time.Since(job.Published).Round(time.Second))
+	if !content.EarliestTime.IsZero() || !content.LatestTime.IsZero() {
+		fmt.Fprintf(w, "Time range:\t%s to %s\n", content.EarliestTime.Format(time.RFC3339), content.LatestTime.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "Results:\t%d\n", content.ResultCount)
+	fmt.Fprintf(w, "Events:\t%d (%d available)\n", content.EventCount, content.EventAvailableCount)
+	fmt.Fprintf(w, "Scanned:\t%d\n", content.ScanCount)
+	fmt.Fprintf(w, "Run duration:\t%s\n", (time.Duration(content.RunDuration * float64(time.Second))).Round(time.Millisecond))
+	fmt.Fprintf(w, "Disk usage:\t%s\n", formatBytes(float64(content.DiskUsage)))
+	fmt.Fprintf(w, "Expires in:\t%s\n", secondsString(content.TTL))
+	w.Flush()
+}
+
+func runJobsDelete(args []string) {
+	fs := flag.NewFlagSet("jobs delete", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: spldl jobs delete [options] <sid>...")
+		fs.PrintDefaults()
+	}
+	client := parseClientFlags(fs, args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, sid := range fs.Args() {
+		err := client.DeleteSearchJob(sid)
+		if err != nil {
+			presentError("Failed to delete search job "+sid, err)
+			failed++
+			continue
+		}
+		slog.Info("Deleted search job", "sid", sid)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// jobControls are the actions of spldl jobs that control running jobs, with the client method each
+// calls and what it did
+var jobControls = map[string]struct {
+	control func(client *splunkclient.Client, sid string) error
+	done    string
+}{
+	"cancel":   {(*splunkclient.Client).CancelSearchJob, "Canceled search job"},
+	"pause":    {(*splunkclient.Client).PauseSearchJob, "Paused search job"},
+	"unpause":  {(*splunkclient.Client).UnpauseSearchJob, "Unpaused search job"},
+	"finalize": {(*splunkclient.Client).FinalizeSearchJob, "Finalized search job"},
+	"touch":    {(*splunkclient.Client).TouchSearchJob, "Reset the TTL of search job"},
+}
+
+func runJobsControl(action string, args []string) {
+	fs := flag.NewFlagSet("jobs "+action, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: spldl jobs %s [options] <sid>...\n", action)
+		fs.PrintDefaults()
+	}
+	client := parseClientFlags(fs, args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	control := jobControls[action]
+	failed := 0
+	for _, sid := range fs.Args() {
+		err := control.control(client, sid)
+		if err != nil {
+			presentError(fmt.Sprintf("Failed to %s search job %s", action, sid), err)
+			failed++
+			continue
+		}
+		slog.Info(control.done, "sid", sid)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runJobsClean(args []string) {
+	fs := flag.NewFlagSet("jobs clean", flag.ExitOnError)
+	filter := addJobFilterFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Only print the jobs that would be deleted")
+	client := parseClientFlags(fs, args)
+
+	// Refuse to reap every job on the search head by accident
+	if filter.OlderThan <= 0 {
+		fmt.Println("jobs clean requires --older-than. Use spldl jobs clean --help for more information.")
+		os.Exit(1)
+	}
+
+	jobs, err := client.ListSearchJobs(*filter)
+	if err != nil {
+		fatal("Failed to list search jobs", err)
+	}
+
+	failed := 0
+	for _, job := range jobs {
+		sid := job.Content.SID
+		if *dryRun {
+			fmt.Printf("Would delete %s (owner: %s, state: %s)\n", sid, job.ACL.Owner, job.Content.DispatchState)
+			continue
+		}
+		err := client.DeleteSearchJob(sid)
+		if err != nil {
+			presentError("Failed to delete search job "+sid, err)
+			failed++
+			continue
+		}
+		slog.Info("Deleted search job", "sid", sid, "owner", job.ACL.Owner)
+	}
+
+	slog.Info("Finished cleaning search jobs", "matched", len(jobs), "failed", failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+
+	flag "github.com/spf13/pflag"
+)
+
+const k8sTemplateUsage = "Usage: spldl k8s-template [options] -- [download options] <output-file>"
+
+// The health endpoint port inside the pod
+const k8sHealthPort = 8080
+
+var cronJobTemplate = template.Must(template.New("cronjob").Funcs(template.FuncMap{
+	"quote": func(s string) string {
+		// JSON strings are valid double-quoted YAML scalars
+		quoted, _ := json.Marshal(s)
+		return string(quoted)
+	},
+}).Parse(`# Generated by spldl k8s-template. Create the secret holding the Splunk token before applying:
+#   kubectl -n {{.Namespace}} create secret generic {{.Secret}} --from-literal=token=<splunk-token>
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+  schedule: {{quote .Schedule}}
+  concurrencyPolicy: Forbid
+  successfulJobsHistoryLimit: 3
+  failedJobsHistoryLimit: 5
+  jobTemplate:
+    spec:
+      backoffLimit: 2
+      podFailurePolicy:
+        rules:
+          # Retrying won't fix rejected credentials or missing permissions
+          - action: FailJob
+            onExitCodes:
+              containerName: spldl
+              operator: In
+              values: [{{.AuthExitCode}}]
+      template:
+        metadata:
+          labels:
+            app.kubernetes.io/name: spldl
+            app.kubernetes.io/instance: {{.Name}}
+        spec:
+          restartPolicy: Never
+          containers:
+            - name: spldl
+              image: {{quote .Image}}
+              workingDir: /exports
+              args:
+{{- range .Args}}
+                - {{quote .}}
+{{- end}}
+              env:
+                - name: SPLUNK_TOKEN
+                  valueFrom:
+                    secretKeyRef:
+                      name: {{.Secret}}
+                      key: token
+              ports:
+                - name: health
+                  containerPort: {{.HealthPort}}
+              livenessProbe:
+                httpGet:
+                  path: /healthz
+                  port: health
+                periodSeconds: 30
+                failureThreshold: 3
+              volumeMounts:
+                - name: exports
+                  mountPath: /exports
+          volumes:
+            - name: exports
+              persistentVolumeClaim:
+                claimName: {{.Claim}}
+`))
+
+type cronJobValues struct {
+	Name         string
+	Namespace    string
+	Schedule     string
+	Image        string
+	Secret       string
+	Claim        string
+	Args         []string
+	HealthPort   int
+	AuthExitCode int
+}
+
+func runK8sTemplate(args []string) {
+	fs := flag.NewFlagSet("k8s-template", flag.ExitOnError)
+	name := fs.String("name", "spldl-export", "Name of the CronJob")
+	namespace := fs.String("namespace", "default", "Namespace of the CronJob")
+	schedule := fs.String("schedule", "0 2 * * *", "Cron schedule of the export")
+	image := fs.String("image", "spldl:latest", "Container image containing the spldl binary")
+	secret := fs.String("secret", "spldl-splunk", "Secret whose token key holds the Splunk token")
+	claim := fs.String("claim", "spldl-exports", "PersistentVolumeClaim the output file is written to, mounted at /exports")
+	fs.Usage = func() {
+		fmt.Println(k8sTemplateUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("Pass the download options and output file after --, e.g.")
+		fmt.Println("  spldl k8s-template --schedule '0 * * * *' -- --host splunk.example.com --search 'index=main' --force results.ndjson")
+		os.Exit(exitFailure)
+	}
+
+	values := cronJobValues{
+		Name:         *name,
+		Namespace:    *namespace,
+		Schedule:     *schedule,
+		Image:        *image,
+		Secret:       *secret,
+		Claim:        *claim,
+		Args:         append([]string{fmt.Sprintf("--health-addr=:%d", k8sHealthPort)}, fs.Args()...),
+		HealthPort:   k8sHealthPort,
+		AuthExitCode: exitAuth,
+	}
+	if err := cronJobTemplate.Execute(os.Stdout, values); err != nil {
+		fatal("Failed to render template", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/keyring"
+)
+
+// The keyring service spldl's credentials are stored under, one item per profile
+const keyringService = "spldl"
+
+// keyringAccount names the keyring item of a profile, "default" for runs without one
+func keyringAccount(profile string) string {
+	return cmp.Or(profile, "default")
+}
+
+// loadStoredCredentials returns the credentials spldl auth login stored for the profile, none when
+// nothing is stored or the system has no keyring
+func loadStoredCredentials(profile string) (config.StoredCredentials, error) {
+	secret, err := keyring.Get(keyringService, keyringAccount(profile))
+	if errors.Is(err, keyring.ErrNotFound) || errors.Is(err, keyring.ErrUnsupported) {
+		slog.Debug("No credentials in the keyring", "profile", keyringAccount(profile), "reason", err)
+		return config.StoredCredentials{}, nil
+	}
+	if err != nil {
+		return config.StoredCredentials{}, err
+	}
+
+	var stored config.StoredCredentials
+	if err := json.Unmarshal([]byte(secret), &stored); err != nil {
+		return config.StoredCredentials{}, fmt.Errorf("invalid keyring item for profile %s: %w", keyringAccount(profile), err)
+	}
+	slog.Debug("Using credentials from the keyring", "profile", keyringAccount(profile))
+	return stored, nil
+}
+
+func storeCredentials(profile string, stored config.StoredCredentials) error {
+	secret, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(keyringService, keyringAccount(profile), string(secret))
+}
+
+// readSecret reads a line from stdin, prompting without echo when stdin is a terminal
+func readSecret(prompt string) (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, prompt)
+		if setEcho(false) == nil {
+			defer func() {
+				setEcho(true)
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		if err != nil {
+			return "", fmt.Errorf("error reading secret: %w", err)
+		}
+		return "", errors.New("no secret given")
+	}
+	return secret, nil
+}
+
+// setEcho turns the terminal's echo on or off, failing where stty isn't available
+func setEcho(on bool) error {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/spl"
+)
+
+// lintSearch warns about common export mistakes in a search before it's dispatched and returns an
+// error listing them when strict is set
+func lintSearch(search, earliest, latest string, strict bool, warnings *report.Warnings) error {
+	findings := spl.Lint(search, earliest, latest, time.Now())
+	for _, finding := range findings {
+		slog.Warn("Search may be slow or incomplete: "+finding.Message, "rule", finding.Rule, "suggestion", finding.Suggestion)
+		warnings.Add("search", finding.String())
+	}
+	if strict && len(findings) > 0 {
+		return fmt.Errorf("the search has %d problem(s), fix them or run without --strict", len(findings))
+	}
+	return nil
+}
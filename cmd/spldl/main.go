@@ -4,146 +4,217 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
+	"time"
 
-	flag "github.com/spf13/pflag"
-
-	"github.com/cschmidt0121/spldl/internal/config"
 	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/spl"
 	"github.com/cschmidt0121/spldl/internal/splunkclient"
 )
 
-func main() {
-	search := flag.String("search", "", "The search query to run")
-	sid := flag.String("sid", "", "An already-completed search ID to download from.")
-	earliest := flag.String("earliest", "-24h", "The earliest time to search from")
-	latest := flag.String("latest", "now", "The latest time to search to")
-	token := flag.String("token", "", "The Splunk token to use")
-	username := flag.String("username", "", "The Splunk username to use")
-	password := flag.String("password", "", "The Splunk password to use")
-	host := flag.String("host", "", "The Splunk host to use")
-	port := flag.Int("port", 8089, "The Splunk port to use")
-	insecure := flag.BoolP("insecure", "k", false, "Set this to ignore TLS verification")
-	deleteWhenDone := flag.BoolP("delete-when-done", "d", false, "Set this to delete the job when done downloading. Off by default")
-	concurrency := flag.Int("max-connections", 8, "The maximum number of concurrent connections to use for downloading results")
-	verbose := flag.BoolP("verbose", "v", false, "Enable verbose logging")
-	help := flag.BoolP("help", "h", false, "Show help")
-	flag.Parse()
+const (
+	searchUsage   = "Usage: spldl search [options] <query|--template file> <output-file.[ndjson|jsonl|json|csv|tsv|xlsx|parquet|txt]|->"
+	downloadUsage = "Usage: spldl download --sid <sid>[,<sid>...] [options] <output-file.[ndjson|jsonl|json|csv|tsv|xlsx|parquet|txt]|->"
+)
 
-	// Configure slog based on verbose flag
-	if *verbose {
-		// Verbose mode: enable debug logging while keeping default format
-		slog.SetLogLoggerLevel(slog.LevelDebug)
-	} else {
-		// Normal mode: info level and above
-		slog.SetLogLoggerLevel(slog.LevelInfo)
-	}
+// Defaults shared by downloads and pipelines
+const (
+	defaultTokenValidity = 15 * time.Minute
+)
 
-	args := flag.Args()
+// maxStopAfter is the most results Splunk serves from one job
+const maxStopAfter = 500000
 
-	if len(args) == 0 {
-		fmt.Println("No output file specified")
-		fmt.Println("Usage: spldl [options] <output-file.[json|csv|txt]>")
-		flag.PrintDefaults()
-		os.Exit(1)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "search":
+			runSearch(os.Args[1], os.Args[2:])
+			return
+		case "download":
+			runDownload(os.Args[1], os.Args[2:])
+			return
+		case "jobs":
+			runJobs(os.Args[2:])
+			return
+		case "auth":
+			runAuth(os.Args[2:])
+			return
+		case "convert":
+			runConvert(os.Args[2:])
+			return
+		case "run":
+			runPipeline(os.Args[2:])
+			return
+		case "batch":
+			runBatch(os.Args[2:])
+			return
+		case "whoami":
+			runWhoami(os.Args[2:])
+			return
+		case "k8s-template":
+			runK8sTemplate(os.Args[2:])
+			return
+		case "token":
+			runToken(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "config":
+			runConfig(os.Args[2:])
+			return
+		case "bundle":
+			runBundle(os.Args[2:])
+			return
+		case "gc":
+			runGC(os.Args[2:])
+			return
+		}
 	}
 
-	if *help {
-		fmt.Println("Usage: spldl [options] <output-file.[json|csv|txt]>")
-		flag.PrintDefaults()
-		os.Exit(0)
+	// Without a subcommand the options select what to do, as before subcommands existed: --sid
+	// downloads existing jobs, --search runs a search
+	if hasSIDFlag(os.Args[1:]) {
+		runDownload("", os.Args[1:])
+		return
 	}
+	runSearch("", os.Args[1:])
+}
 
-	// Load environment variables
-	if *token == "" {
-		*token = os.Getenv("SPLUNK_TOKEN")
+func printDownloadUsage(command string) {
+	switch command {
+	case "search":
+		fmt.Println(searchUsage)
+	case "download":
+		fmt.Println(downloadUsage)
+	default:
+		fmt.Println("Usage: spldl search [options] <query> <output-file>")
+		fmt.Println("       spldl download --sid <sid>[,<sid>...] [options] <output-file>")
+		fmt.Println("       spldl [options] <output-file.[ndjson|jsonl|json|csv|tsv|xlsx|parquet|txt]|->")
+		fmt.Println("       spldl jobs <list|inspect|delete|clean|cancel|pause|unpause|finalize|touch> [options]")
+		fmt.Println("       spldl auth test [options]")
+		fmt.Println("       spldl convert <input-file> <output-file>")
+		fmt.Println("       spldl run <pipeline.yaml>")
+		fmt.Println("       spldl batch [options] <manifest.yaml>")
+		fmt.Println("       spldl whoami [options]")
+		fmt.Println("       spldl k8s-template [options] -- [download options] <output-file>")
+		fmt.Println("       spldl token issue [options]")
+		fmt.Println("       spldl report <pull|runs> [options] <saved-search-name>")
+		fmt.Println("       spldl config migrate [options]")
+		fmt.Println("       spldl bundle --sid <sid> [options] <out-dir>")
+		fmt.Println("       spldl gc [--older-than 7d] [options] [dir...]")
 	}
+}
 
-	if *username == "" {
-		*username = os.Getenv("SPLUNK_USERNAME")
+// warnTruncationLimits warns about server-side limits that could silently truncate the export of the
+// search about to be dispatched, returning the limits for warnJobTruncation
+func warnTruncationLimits(client *splunkclient.Client, earliest, latest string, stopAfter int, warnings *report.Warnings) splunkclient.SearchLimits {
+	limits, err := client.GetSearchLimits()
+	if err != nil {
+		slog.Debug("Unable to check search limits", "error", err)
+		return limits
 	}
-
-	if *password == "" {
-		*password = os.Getenv("SPLUNK_PASSWORD")
+	request := downloader.TruncationRequest{StopAfter: stopAfter}
+	if span, ok := spl.TimeSpan(earliest, latest, time.Now()); ok {
+		request.Span = span
 	}
-
-	// Validate required flags
-	if *search == "" && *sid == "" {
-		fmt.Println("You must provide either a search query or a search ID. Use spldl --help for more information.")
-		os.Exit(1)
-	}
-	var auth config.AuthConfig
-	if *token != "" {
-		auth = config.AuthConfig{
-			Type:  config.AuthToken,
-			Token: *token,
-		}
-	} else if *username != "" && *password != "" {
-		auth = config.AuthConfig{
-			Type:     config.AuthHTTPBasic,
-			Username: *username,
-			Password: *password,
-		}
-	} else {
-		fmt.Println("No authentication method provided. Use spldl --help for more information.")
-		os.Exit(1)
+	for _, warning := range downloader.CheckTruncationLimits(limits, request) {
+		slog.Warn("Export may be truncated: " + warning)
+		warnings.Add("limits", "export may be truncated: "+warning)
 	}
+	return limits
+}
 
-	filename := args[0]
-	var outputMode string
-	switch ext := filepath.Ext(filename); ext {
-	case ".ndjson":
-		outputMode = "ndjson"
-	case ".csv":
-		outputMode = "csv"
-	case ".txt":
-		outputMode = "raw"
-	default:
-		fmt.Println("Output file must have .json, .csv, or .txt extension")
-		os.Exit(1)
+// warnJobTruncation warns when the job dispatched for the search ran into one of limits
+func warnJobTruncation(client *splunkclient.Client, sid string, limits splunkclient.SearchLimits, warnings *report.Warnings) {
+	if limits.SrchMaxTime == 0 && limits.MaxCount == 0 {
+		return
 	}
-	clientConfig := config.ClientConfig{
-		Host:      *host,
-		Port:      *port,
-		Auth:      auth,
-		UseTLS:    true,
-		VerifyTLS: !*insecure,
+	job, err := client.GetJobStatus(sid)
+	if err != nil {
+		slog.Debug("Unable to check the job against the search limits", "sid", sid, "error", err)
+		return
 	}
-	client := splunkclient.NewClient(clientConfig)
-
-	if *sid == "" {
-		var err error
-		*sid, err = client.NewSearchJob(*search, *earliest, *latest)
-		if err != nil {
-			slog.Error("Failed to create search job", "error", err)
-			os.Exit(1)
-		}
-		slog.Info("Created search job", "sid", *sid)
-		slog.Info("Waiting for job to be done")
-		err = client.WaitUntilJobIsDone(*sid)
-		if err != nil {
-			slog.Error("Failed while waiting for job to be done", "error", err)
-			os.Exit(1)
-		}
+	for _, warning := range downloader.CheckJobTruncation(limits, job) {
+		slog.Warn("Export is truncated: " + warning)
+		warnings.Add("limits", "export is truncated: "+warning)
 	}
+}
 
-	slog.Info("Downloading search results", "sid", *sid)
+// dispatchSearch creates a search job, or reuses the job with jobID when set, and waits for it to be
+// done, exiting on failure. With stopAfter set, the job is finalized once it has found that many
+// results. The returned bool reports whether the job was finalized early.
+func dispatchSearch(client *splunkclient.Client, search, earliest, latest, jobID string, stopAfter int) (string, bool) {
+	sid := createSearchJob(client, search, earliest, latest, jobID)
+	slog.Info("Waiting for job to be done")
+	var partial bool
+	var err error
+	if stopAfter > 0 {
+		partial, err = client.WaitForResults(sid, stopAfter)
+	} else {
+		partial, err = waitForJob(client, sid)
+	}
+	if err != nil {
+		fatalWithStatus("Failed while waiting for job to be done", err, exitSearch)
+	}
+	return sid, partial
+}
 
-	downloaderConfig := config.DownloaderConfig{
-		OutputMode:     outputMode,
-		DeleteWhenDone: *deleteWhenDone,
-		MaxConnections: *concurrency,
-		SID:            *sid,
-		Filename:       filename,
-	}
-	downloader := downloader.NewDownloader(client, downloaderConfig)
+// createSearchJob is startSearchJob, exiting on failure
+func createSearchJob(client *splunkclient.Client, search, earliest, latest, jobID string) string {
+	sid, err := startSearchJob(client, search, earliest, latest, jobID)
+	if err != nil {
+		fatalWithStatus("Failed to create search job", err, exitSearch)
+	}
+	return sid
+}
 
-	err := downloader.DownloadSearchResults()
+// startSearchJob creates a search job, or reuses the job with jobID when set
+func startSearchJob(client *splunkclient.Client, search, earliest, latest, jobID string) (string, error) {
+	heartbeat.SetPhase(report.PhaseSearching, "")
+	slog.Info("Dispatching search", "spl", client.DispatchedSearch(search), "earliest", earliest, "latest", latest)
+	var sid string
+	var reused bool
+	var err error
+	if jobID != "" {
+		sid, reused, err = client.DispatchSearchJob(search, earliest, latest, jobID)
+	} else {
+		sid, err = client.NewSearchJob(search, earliest, latest)
+	}
 	if err != nil {
-		slog.Error("Failed to download search results", "error", err)
-		os.Exit(1)
+		return "", err
+	}
+	if reused {
+		slog.Info("Reusing existing search job", "sid", sid)
+	} else {
+		slog.Info("Created search job", "sid", sid)
+		cancelJobOnInterrupt(client, sid)
 	}
+	heartbeat.SetPhase(report.PhaseSearching, sid)
+	return sid, nil
+}
 
-	slog.Info("Downloaded search results", "filename", filename)
+// labeledJobID returns the search ID to dispatch a search with: jobID if set, otherwise a new ID
+// starting with label, or an empty string to let Splunk pick one
+func labeledJobID(jobID, label string) string {
+	if jobID != "" || label == "" {
+		return jobID
+	}
+	id, err := splunkclient.LabeledJobID(label, time.Now())
+	if err != nil {
+		fatal("Invalid label", err)
+	}
+	return id
+}
 
+// printWarnings prints a summary of the non-fatal problems of a run to stderr
+func printWarnings(warnings *report.Warnings) {
+	if warnings.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Completed with %d warning(s):\n", warnings.Len())
+	for _, line := range warnings.Summary() {
+		fmt.Fprintln(os.Stderr, "  "+line)
+	}
 }
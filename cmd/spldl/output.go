@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// outputFlags holds the flags shared by spldl search and spldl download, which set how results are
+// downloaded and where they're written
+type outputFlags struct {
+	fs                 *flag.FlagSet
+	deleteWhenDone     *bool
+	cleanup            *string
+	concurrency        *int
+	reorderWindow      *int
+	resume             *bool
+	parallelWrites     *bool
+	follow             *bool
+	dedupeState        *string
+	dedupeWindow       *time.Duration
+	force              *bool
+	allowPartial       *bool
+	failOnJobErrors    *bool
+	verify             *bool
+	clipEarliest       timeFlag
+	clipLatest         timeFlag
+	bucket             durationFlag
+	tee                *[]string
+	format             *string
+	delimiter          *string
+	quoteAll           *bool
+	locale             *string
+	crlf               *bool
+	elasticsearchURL   *string
+	sinkBatchSize      *int
+	sinkFlushInterval  *time.Duration
+	sinkMaxInFlight    *int
+	sinkAttempts       *int
+	sinkBackoff        *time.Duration
+	deadLetter         *string
+	webhookHeaders     *[]string
+	kafkaBrokers       *[]string
+	kafkaTopic         *string
+	kafkaKeyField      *string
+	kafkaCompression   *string
+	kafkaTLS           *bool
+	kafkaTLSCA         *string
+	kafkaTLSCert       *string
+	kafkaTLSKey        *string
+	kafkaSASLMechanism *string
+	kafkaSASLUsername  *string
+	events             *bool
+	postFilter         *string
+	stopAfter          *int
+	fields             *[]string
+	noAnnotations      *bool
+	rawJSON            *bool
+	tokenMinValidity   *time.Duration
+	reportHTML         *string
+	heartbeatFile      *string
+	healthAddr         *string
+	callbackURL        *string
+	pprofAddr          *string
+	stallTimeout       *time.Duration
+	verbose            *bool
+	help               *bool
+
+	// Set by resolveOutput
+	filename     string
+	outputMode   string
+	csvDelimiter rune
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	of := &outputFlags{
+		fs:                 fs,
+		deleteWhenDone:     fs.BoolP("delete-when-done", "d", false, "Set this to delete the job when done downloading. Off by default"),
+		cleanup:            fs.String("cleanup", "", cleanupUsage),
+		concurrency:        fs.Int("max-connections", 8, "The maximum number of concurrent connections to use for downloading results"),
+		reorderWindow:      fs.Int("reorder-window", 64, "How many chunks may be downloaded ahead of the next chunk to be written, bounding the memory used while a connection is slow"),
+		resume:             fs.Bool("resume", false, "Continue an interrupted download to the output file from where it stopped, instead of starting over"),
+		parallelWrites:     fs.Bool("parallel-writes", false, "Write raw (.txt) chunks into the output file from every connection instead of one writer, for fast networks"),
+		follow:             fs.Bool("follow", false, "Download the results of the job while it runs, appending new results until it's done, instead of waiting for it first"),
+		dedupeState:        fs.String("dedupe-state", "", "File used to remember exported events so later runs skip them (ndjson and csv only)"),
+		dedupeWindow:       fs.Duration("dedupe-window", 7*24*time.Hour, "How long exported events are remembered by --dedupe-state"),
+		force:              fs.Bool("force", false, "Overwrite the output file, or time bucket files, if they already exist"),
+		allowPartial:       fs.Bool("allow-partial", false, "Keep the output with a warning when fewer or more rows were written than the job has results, instead of failing"),
+		failOnJobErrors:    fs.Bool("fail-on-job-errors", false, "Fail the download when Splunk reported an error for the job, such as a failing lookup, instead of warning about it"),
+		verify:             fs.Bool("verify", false, "Recount the job's results after downloading and write a verification record to <output-file>.manifest.json"),
+		tee:                fs.StringArray("tee", nil, "Also write the results to this output, e.g. - for stdout or s3://bucket/results.csv.gz, downloading them once for every output. Repeat for more outputs, which must all have the format of the output file"),
+		format:             fs.String("format", "", "Output format (ndjson, jsonl, csv or raw). Overrides detection from the output file extension"),
+		delimiter:          fs.String("delimiter", "", "Field delimiter of csv output, a single character or \\t for tabs. Defaults to a tab for .tsv files"),
+		quoteAll:           fs.Bool("quote-all", false, "Quote every field of csv output, not only those that need it"),
+		locale:             fs.String("locale", "", "Write the decimals and _time of csv output for this locale, e.g. de-DE, so spreadsheets set to it read them. A semicolon is the delimiter for locales with a decimal comma"),
+		crlf:               fs.Bool("crlf", false, "End the records of csv output with \\r\\n, as Excel does"),
+		elasticsearchURL:   fs.String("elasticsearch-url", "", "Elasticsearch or OpenSearch cluster es://<index> outputs are indexed into, e.g. https://localhost:9200. Defaults to ELASTICSEARCH_URL, with basic auth from ELASTICSEARCH_USERNAME and ELASTICSEARCH_PASSWORD"),
+		sinkBatchSize:      fs.Int("sink-batch-size", 1000, "Results per batch sent to es:// outputs, a _bulk request each, kafka:// outputs and http(s):// outputs, a POST each"),
+		sinkFlushInterval:  fs.Duration("sink-flush-interval", time.Second, "How long a batch to es://, kafka:// or http(s):// that isn't full waits for more results before it's sent (0 to send it only once full)"),
+		sinkMaxInFlight:    fs.Int("sink-max-in-flight", 2, "Batches on their way to es://, kafka:// or http(s):// at once. Further results wait, slowing down the download instead of overwhelming the destination"),
+		sinkAttempts:       fs.Int("sink-attempts", 5, "How often a batch is sent to es://, kafka:// or http(s):// before it's given up, waiting --sink-backoff, doubled every time, in between"),
+		sinkBackoff:        fs.Duration("sink-backoff", time.Second, "Delay before a failed batch is sent again, doubled after every attempt"),
+		deadLetter:         fs.String("dead-letter", "", "Append the results of batches es://, kafka:// or http(s):// outputs still refuse after every attempt to this ndjson file and carry on, instead of failing the download"),
+		webhookHeaders:     fs.StringArray("webhook-header", nil, "Header sent with every batch posted to http(s):// outputs, e.g. \"Authorization: Bearer $TOKEN\". Repeat for more headers"),
+		kafkaBrokers:       fs.StringSlice("kafka-brokers", nil, "Comma-separated host:port of the Kafka brokers kafka:// outputs are published to. Defaults to KAFKA_BROKERS"),
+		kafkaTopic:         fs.String("kafka-topic", "", "Publish each result as a message to this Kafka topic instead of writing an output file, the same as the output kafka://<topic>"),
+		kafkaKeyField:      fs.String("kafka-key-field", "", "Field whose value keys the Kafka messages, so results with the same value go to the same partition. Messages have no key by default"),
+		kafkaCompression:   fs.String("kafka-compression", "none", "Compression of the Kafka messages (none, gzip, snappy, lz4 or zstd)"),
+		kafkaTLS:           fs.Bool("kafka-tls", false, "Connect to the Kafka brokers with TLS"),
+		kafkaTLSCA:         fs.String("kafka-tls-ca", "", "PEM file with the CA certificates to verify the Kafka brokers with instead of the system's. Implies --kafka-tls"),
+		kafkaTLSCert:       fs.String("kafka-tls-cert", "", "PEM client certificate presented to the Kafka brokers, with --kafka-tls-key. Implies --kafka-tls"),
+		kafkaTLSKey:        fs.String("kafka-tls-key", "", "PEM private key of --kafka-tls-cert"),
+		kafkaSASLMechanism: fs.String("kafka-sasl-mechanism", "", "Authenticate to the Kafka brokers with SASL (plain, scram-sha-256 or scram-sha-512), with the password from KAFKA_SASL_PASSWORD"),
+		kafkaSASLUsername:  fs.String("kafka-sasl-username", "", "SASL user of --kafka-sasl-mechanism. Defaults to KAFKA_SASL_USERNAME"),
+		events:             fs.Bool("events", false, "Download the events the search read instead of its results, e.g. the raw events behind a | stats table"),
+		postFilter:         fs.String("post-filter", "", "Have Splunk filter the job's results before sending them, e.g. 'error OR warn' or '| where status>=500', without running a new search"),
+		stopAfter:          fs.Int("stop-after", 0, "Finalize the search once it has found this many results and download only those, for searches too broad to run to the end"),
+		fields:             fs.StringSlice("fields", nil, "Comma-separated fields to download and write, in this order, e.g. host,source,_time,_raw (ndjson and csv)"),
+		noAnnotations:      fs.Bool("no-annotations", false, "Drop the tag, tag::<field>, eventtype and punct fields Splunk adds to events (ndjson and csv)"),
+		rawJSON:            fs.Bool("raw-json", false, "Write each event as ndjson with only its _time and _raw, dropping the extracted fields. Implies --format ndjson"),
+		tokenMinValidity:   fs.Duration("token-min-validity", defaultTokenValidity, "Refuse to start when the token expires sooner than this"),
+		reportHTML:         fs.String("report-html", "", "Write a self-contained HTML report of the export (query, time range, counts, top fields, file checksums) to this file"),
+		heartbeatFile:      fs.String("heartbeat-file", "", "File the run's progress is written to every 10 seconds, for liveness probes"),
+		healthAddr:         fs.String("health-addr", "", "Address to serve the /healthz and /status endpoints on (e.g. :8080)"),
+		callbackURL:        fs.String("callback-url", "", "URL that receives a JSON POST with the run's outcome when it completes or fails, signed with SPLDL_SIGNING_KEY"),
+		pprofAddr:          fs.String("pprof", "", "Address to serve net/http/pprof profiles on during the run (e.g. localhost:6060), for investigating slow downloads"),
+		stallTimeout:       fs.Duration("stall-timeout", 15*time.Minute, "How long a download may go without progress before /healthz fails"),
+	}
+	fs.Var(&of.clipEarliest, "clip-earliest", "Only write events whose _time is at or after this time (RFC 3339 or epoch), e.g. to carve a window out of an existing --sid")
+	fs.Var(&of.clipLatest, "clip-latest", "Only write events whose _time is before this time (RFC 3339 or epoch)")
+	fs.Var(&of.bucket, "bucket", "Split the output into one file per time bucket of this size based on _time (e.g. 1h or 1d)")
+	fs.BoolVar(&cancelOnInterrupt, "cancel-on-interrupt", false, "Cancel and delete the search job spldl dispatched when interrupted with Ctrl-C")
+	of.verbose = fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	of.help = fs.BoolP("help", "h", false, "Show help")
+	return of
+}
+
+// outputArgs returns how many arguments name the output, none when --kafka-topic takes its place
+func (of *outputFlags) outputArgs() int {
+	if *of.kafkaTopic != "" {
+		return 0
+	}
+	return 1
+}
+
+// check rejects invalid combinations of the output flags, exiting on failure. It also applies
+// --cleanup.
+func (of *outputFlags) check() {
+	if !time.Time(of.clipEarliest).IsZero() && !time.Time(of.clipLatest).IsZero() && !time.Time(of.clipLatest).After(time.Time(of.clipEarliest)) {
+		fmt.Println("--clip-latest must be after --clip-earliest")
+		os.Exit(1)
+	}
+	if *of.follow && (*of.resume || *of.parallelWrites) {
+		fmt.Println("--follow can't be used with --resume or --parallel-writes")
+		os.Exit(1)
+	}
+	if *of.events && *of.verify {
+		fmt.Println("--events downloads the events of a job and can't be used with --verify")
+		os.Exit(1)
+	}
+	if *of.stopAfter < 0 || *of.stopAfter > maxStopAfter {
+		fmt.Printf("--stop-after must be between 1 and %d\n", maxStopAfter)
+		os.Exit(1)
+	}
+	if *of.stopAfter > 0 && *of.follow {
+		fmt.Println("--stop-after can't be used with --follow")
+		os.Exit(1)
+	}
+	if *of.sinkBatchSize < 1 || *of.sinkMaxInFlight < 1 || *of.sinkAttempts < 1 || *of.sinkBackoff < 0 || *of.sinkFlushInterval < 0 {
+		fmt.Println("--sink-batch-size, --sink-max-in-flight and --sink-attempts must be at least 1, and --sink-backoff and --sink-flush-interval can't be negative")
+		os.Exit(1)
+	}
+	if *of.cleanup == "never" && *of.deleteWhenDone {
+		fmt.Println("--cleanup=never keeps the job and can't be combined with --delete-when-done")
+		os.Exit(1)
+	}
+	deleteJob, err := cleanupPolicy(*of.cleanup, *of.deleteWhenDone, cancelOnInterrupt)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	applyCleanupPolicy(*of.cleanup)
+	*of.deleteWhenDone = deleteJob
+}
+
+// connect starts the run's heartbeat and builds the Splunk client, exiting on failure. It returns the
+// client and when its token expires.
+func (of *outputFlags) connect(conn *connectionFlags) (*splunkclient.Client, time.Time) {
+	startHeartbeat(*of.heartbeatFile, *of.healthAddr, *of.callbackURL, *of.stallTimeout)
+	startPprof(*of.pprofAddr)
+
+	client, err := conn.newClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		heartbeat.Finish(exitFailure, err)
+		os.Exit(exitFailure)
+	}
+	client = client.WithContext(interruptContext())
+
+	tokenExpiry := conn.checkTokenExpiry(*of.tokenMinValidity)
+	if !of.fs.Changed("max-connections") && conn.settings.MaxConnections > 0 {
+		*of.concurrency = conn.settings.MaxConnections
+	}
+	*of.concurrency = conn.limitConnections(*of.concurrency)
+	return client, tokenExpiry
+}
+
+// resolveOutput sets the output the results are written to from the remaining arguments and the output
+// flags, exiting on failure
+func (of *outputFlags) resolveOutput(args []string) {
+	if *of.kafkaTopic != "" {
+		if len(args) > 0 {
+			fmt.Println("--kafka-topic publishes the results instead of writing them to an output file, drop the output file")
+			os.Exit(1)
+		}
+		of.filename = "kafka://" + *of.kafkaTopic
+	} else {
+		of.filename = args[0]
+	}
+	var err error
+	of.outputMode, err = detectOutputMode(of.filename, *of.format, *of.rawJSON)
+	for _, output := range *of.tee {
+		if err != nil {
+			break
+		}
+		err = checkTeeOutput(output, of.filename, of.outputMode, *of.format, *of.rawJSON)
+	}
+	if err == nil {
+		of.csvDelimiter, err = parseDelimiter(*of.delimiter)
+	}
+	if of.csvDelimiter == 0 && of.outputMode == "csv" && outputExt(of.filename) == ".tsv" {
+		of.csvDelimiter = '\t'
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		heartbeat.Finish(exitFailure, err)
+		os.Exit(exitFailure)
+	}
+}
+
+// downloaderConfig returns the downloader configuration the output flags set. The caller adds the jobs
+// to download.
+func (of *outputFlags) downloaderConfig(conn *connectionFlags, tokenExpiry time.Time) config.DownloaderConfig {
+	return config.DownloaderConfig{
+		OutputMode:     of.outputMode,
+		DeleteWhenDone: *of.deleteWhenDone,
+		MaxConnections: *of.concurrency,
+		ReorderWindow:  *of.reorderWindow,
+		Filename:       of.filename,
+		Tee:            *of.tee,
+		DedupeState:    *of.dedupeState,
+		DedupeWindow:   *of.dedupeWindow,
+		ClipEarliest:   time.Time(of.clipEarliest),
+		ClipLatest:     time.Time(of.clipLatest),
+		Verify:         *of.verify,
+		SigningKey:     os.Getenv("SPLDL_SIGNING_KEY"),
+		BucketSize:     time.Duration(of.bucket),
+		TokenExpiry:    tokenExpiry,
+		ParallelWrites: *of.parallelWrites,
+		Resume:         *of.resume,
+		MaxResults:     conn.policy.MaxResults,
+		RawJSON:        *of.rawJSON,
+		NoAnnotations:  *of.noAnnotations,
+		Events:         *of.events,
+
+		FailOnJobErrors: *of.failOnJobErrors,
+		AllowPartial:    *of.allowPartial,
+		Overwrite:       *of.force,
+
+		CSVDelimiter: of.csvDelimiter,
+		CSVQuoteAll:  *of.quoteAll,
+		CSVCRLF:      *of.crlf,
+		CSVLocale:    *of.locale,
+
+		Fields:     trimFields(*of.fields),
+		PostFilter: *of.postFilter,
+
+		StopAfter: *of.stopAfter,
+
+		SinkBatchSize:     *of.sinkBatchSize,
+		SinkFlushInterval: *of.sinkFlushInterval,
+		SinkMaxInFlight:   *of.sinkMaxInFlight,
+		SinkAttempts:      *of.sinkAttempts,
+		SinkBackoff:       *of.sinkBackoff,
+		DeadLetterFile:    *of.deadLetter,
+
+		WebhookHeaders: *of.webhookHeaders,
+
+		ElasticsearchURL: *of.elasticsearchURL,
+
+		KafkaBrokers:       *of.kafkaBrokers,
+		KafkaKeyField:      *of.kafkaKeyField,
+		KafkaCompression:   *of.kafkaCompression,
+		KafkaTLS:           *of.kafkaTLS,
+		KafkaTLSCAFile:     *of.kafkaTLSCA,
+		KafkaTLSCertFile:   *of.kafkaTLSCert,
+		KafkaTLSKeyFile:    *of.kafkaTLSKey,
+		KafkaSASLMechanism: *of.kafkaSASLMechanism,
+		KafkaSASLUsername:  *of.kafkaSASLUsername,
+	}
+}
+
+// downloadRun describes the results a run downloads, for its summary and report
+type downloadRun struct {
+	search, earliest, latest string // empty for the results of an existing job
+	partial                  bool   // the job was finalized early
+	jobless                  bool   // the results were streamed or followed, so there's no search cost to report
+}
+
+// download runs download with dl, then reports the outcome of the run and exits when it failed or only
+// part of the results were downloaded
+func (of *outputFlags) download(client *splunkclient.Client, dl *downloader.Downloader, download func() error, run downloadRun, warnings *report.Warnings) {
+	waitForProgress := trackProgress(dl)
+	err := download()
+	waitForProgress()
+	warnings.Extend(dl.Warnings())
+	// A search finalized by --stop-after holds the results that were asked for, so it isn't a partial run
+	incomplete := run.partial && *of.stopAfter == 0
+	if incomplete {
+		warnings.Add("search", "the job was finalized early, so only part of its results were downloaded")
+	}
+	printWarnings(warnings)
+	heartbeat.Result(of.filename, dl.RowsWritten(), warnings.Summary())
+	if err != nil {
+		// A job canceled on interrupt is gone, so there's nothing left to resume
+		if dl.CanResume() && !(cancelOnInterrupt && errors.Is(err, context.Canceled)) && !(cleanupOnFailure && hasInterruptCleanups()) {
+			slog.Info("Run the same command with --resume to continue the download where it stopped")
+		}
+		fatalWithStatus("Failed to download search results", err, exitDownload)
+	}
+
+	slog.Info("Downloaded search results", "filename", of.filename)
+	logTransfer(client.Transferred())
+	if !run.jobless {
+		slog.Info("Search cost: " + dl.Cost().String())
+	}
+	if *of.reportHTML != "" {
+		err = writeHTMLReport(*of.reportHTML, client, dl, run.search, run.earliest, run.latest, warnings)
+		if err != nil {
+			fatal("Failed to write the HTML report", err)
+		}
+		slog.Info("Wrote HTML report", "filename", *of.reportHTML)
+	}
+	printRunID()
+	if incomplete {
+		heartbeat.Finish(exitPartial, nil)
+		os.Exit(exitPartial)
+	}
+	heartbeat.Finish(0, nil)
+}
+
+// detectOutputMode returns the output mode results written to filename are downloaded in: the one
+// --format names, ndjson for --raw-json, or the one the file's extension implies
+func detectOutputMode(filename, format string, rawJSON bool) (string, error) {
+	switch {
+	case format != "":
+		outputMode, err := parseFormat(format)
+		if err == nil {
+			err = checkCompression(filename)
+		}
+		if err == nil && rawJSON && outputMode != "ndjson" {
+			err = errors.New("--raw-json writes ndjson and can't be combined with --format " + format)
+		}
+		return outputMode, err
+	case rawJSON:
+		return "ndjson", checkCompression(filename)
+	case outputExt(filename) == ".tsv":
+		// Tab-separated files are csv output with a tab as delimiter
+		return "csv", nil
+	}
+	if fileFormat, ok := downloader.FileFormatFor(filename); ok {
+		// File formats such as workbooks are converted from csv or ndjson output as it's written
+		return fileFormat.Mode, checkCompression(filename)
+	}
+	return outputModeForFile(filename)
+}
+
+// checkTeeOutput checks that the --tee output takes the results downloaded for filename as they are.
+// Chunks are written to every output unchanged, so they must all have the same format.
+func checkTeeOutput(output, filename, outputMode, format string, rawJSON bool) error {
+	if output == downloader.Stdout {
+		// stdout takes the results in whatever format they're downloaded
+		return nil
+	}
+	teeMode, err := detectOutputMode(output, format, rawJSON)
+	if err != nil {
+		return fmt.Errorf("--tee %s: %w", output, err)
+	}
+	if teeMode != outputMode || (outputExt(output) == ".tsv") != (outputExt(filename) == ".tsv") {
+		return fmt.Errorf("--tee %s needs a different format than %s, write the outputs with the same format and convert them afterwards with spldl convert", output, filename)
+	}
+	return nil
+}
+
+// outputModeForFile determines the output mode from the extension of filename
+func outputModeForFile(filename string) (string, error) {
+	// Results piped to another program default to ndjson, the easiest to process line by line
+	if filename == downloader.Stdout {
+		return "ndjson", nil
+	}
+	// Results indexed into Elasticsearch, published to Kafka or posted to a webhook become one JSON
+	// document, message or line each
+	if lower := strings.ToLower(filename); strings.HasPrefix(lower, "es://") || strings.HasPrefix(lower, "kafka://") || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return "ndjson", nil
+	}
+	if err := checkCompression(filename); err != nil {
+		return "", err
+	}
+	switch outputExt(filename) {
+	case ".ndjson", ".jsonl":
+		return "ndjson", nil
+	case ".csv":
+		return "csv", nil
+	case ".txt":
+		return "raw", nil
+	default:
+		return "", errors.New("Output file must have .ndjson, .jsonl, .csv, or .txt extension, optionally followed by .gz or .zst, or use --format")
+	}
+}
+
+// outputExt returns the lowercased extension of filename. A trailing .gz or .zst only compresses the
+// file, so the extension before it is returned.
+func outputExt(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".gz" || ext == ".zst" {
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, filepath.Ext(filename))))
+	}
+	return ext
+}
+
+// checkCompression rejects compressed filenames spldl can't write
+func checkCompression(filename string) error {
+	compression := strings.ToLower(filepath.Ext(filename))
+	if compression != ".gz" && compression != ".zst" {
+		return nil
+	}
+	if fileFormat, ok := downloader.FileFormatFor(filename); ok && fileFormat.Compressed {
+		return fmt.Errorf("%s files are compressed already, drop the %s", fileFormat.Extension, compression)
+	}
+	return nil
+}
+
+// parseFormat maps the value of --format to an output mode
+func parseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "ndjson", "jsonl":
+		return "ndjson", nil
+	case "csv":
+		return "csv", nil
+	case "raw", "txt":
+		return "raw", nil
+	default:
+		return "", fmt.Errorf("Unknown format %q. Use ndjson, jsonl, csv, or raw", format)
+	}
+}
+
+// parseDelimiter maps the value of --delimiter to a rune, 0 when it's empty. \t and tab stand for a
+// tab, which is awkward to pass on the command line.
+func parseDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("the delimiter must be a single character, got %q", value)
+	}
+	return runes[0], nil
+}
+
+// trimFields drops the spaces around the fields of --fields and empty entries, so "host, _raw" works
+func trimFields(fields []string) []string {
+	var trimmed []string
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			trimmed = append(trimmed, field)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// startPprof serves the net/http/pprof profiles on addr for the rest of the run, so slow exports can
+// be profiled where they run, e.g. with go tool pprof http://localhost:6060/debug/pprof/profile
+func startPprof(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fatal("Failed to start pprof endpoint", err)
+	}
+	slog.Info("Serving pprof endpoints", "addr", listener.Addr().String())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			slog.Error("pprof endpoint stopped", "error", err)
+		}
+	}()
+}
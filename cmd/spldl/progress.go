@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// How often progress is logged when it can't be drawn as a bar
+const progressLogInterval = 10 * time.Second
+
+const progressBarWidth = 30
+
+// trackProgress reports the progress of d until its download finishes, as a live bar when stderr is a
+// terminal and as periodic log lines otherwise. It also keeps the heartbeat up to date. The returned
+// function waits for the last update to be reported.
+func trackProgress(d *downloader.Downloader) func() {
+	done := make(chan struct{})
+	interactive := isTerminal(os.Stderr)
+
+	go func() {
+		defer close(done)
+		var last downloader.Progress
+		var lastLogged time.Time
+		drawn := false
+		for progress := range d.Progress() {
+			heartbeat.Progress(progress.ChunksDone, progress.ChunksTotal)
+			heartbeat.Transfer(progress.WireBytes, progress.BytesReceived)
+			last = progress
+			if interactive {
+				fmt.Fprint(os.Stderr, "\r"+formatProgress(progress))
+				drawn = true
+			} else if time.Since(lastLogged) >= progressLogInterval {
+				logProgress(progress)
+				lastLogged = time.Now()
+			}
+		}
+		if drawn {
+			fmt.Fprintln(os.Stderr)
+		} else if !interactive && !lastLogged.IsZero() {
+			logProgress(last)
+		}
+	}()
+
+	return func() { <-done }
+}
+
+func formatProgress(p downloader.Progress) string {
+	var sb strings.Builder
+	if p.ChunksTotal > 0 {
+		filled := progressBarWidth * min(p.ChunksDone, p.ChunksTotal) / p.ChunksTotal
+		fmt.Fprintf(&sb, "[%s%s] %d/%d chunks", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), p.ChunksDone, p.ChunksTotal)
+	} else {
+		fmt.Fprintf(&sb, "%d batches", p.ChunksDone)
+	}
+	fmt.Fprintf(&sb, "  %s  %s/s", formatBytes(float64(p.BytesWritten)), formatBytes(p.Throughput()))
+	if p.WireBytes > 0 && p.WireBytes < p.BytesReceived {
+		fmt.Fprintf(&sb, "  %s over network", formatBytes(float64(p.WireBytes)))
+	}
+	if eta, ok := p.ETA(); ok {
+		fmt.Fprintf(&sb, "  ETA %s", eta.Round(time.Second))
+	}
+	// Pad to clear what's left of a longer previous line
+	return fmt.Sprintf("%-100s", sb.String())
+}
+
+func logProgress(p downloader.Progress) {
+	args := []any{"sid", p.SID, "chunks_done", p.ChunksDone, "chunks_total", p.ChunksTotal,
+		"bytes_written", p.BytesWritten, "bytes_received", p.BytesReceived, "wire_bytes", p.WireBytes,
+		"throughput", formatBytes(p.Throughput()) + "/s"}
+	if eta, ok := p.ETA(); ok {
+		args = append(args, "eta", eta.Round(time.Second))
+	}
+	slog.Info("Download progress", args...)
+}
+
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+// logTransfer logs how much data was received, so users on metered links can compare what crossed
+// the network with the size of the data
+func logTransfer(transfer splunkclient.Transfer) {
+	args := []any{"wire", formatBytes(float64(transfer.WireBytes)), "decompressed", formatBytes(float64(transfer.Bytes))}
+	if ratio := transfer.Ratio(); ratio > 1 {
+		args = append(args, "compression_ratio", fmt.Sprintf("%.1fx", ratio))
+	}
+	slog.Info("Network usage", args...)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+const reportUsage = "Usage: spldl report <pull|runs> [options] <saved-search-name>"
+
+func runReport(args []string) {
+	if len(args) == 0 {
+		fmt.Println(reportUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "pull":
+		runReportPull(args[1:])
+	case "runs":
+		runReportRuns(args[1:])
+	case "-h", "--help":
+		fmt.Println(reportUsage)
+	default:
+		fmt.Printf("Unknown report command %q\n", args[0])
+		fmt.Println(reportUsage)
+		os.Exit(1)
+	}
+}
+
+// runReportPull downloads the results of a saved search, from its latest scheduled run or a run
+// dispatched now, into a file named after the report and the run
+func runReportPull(args []string) {
+	fs := flag.NewFlagSet("report pull", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: spldl report pull [options] <saved-search-name>")
+		fs.PrintDefaults()
+	}
+	latestRun := fs.Bool("latest-run", false, "Download the newest finished run of the saved search instead of running it now")
+	run := fs.String("run", "", "Download this run of the saved search, a SID listed by spldl report runs, instead of running it now")
+	out := fs.String("out", ".", "Directory the results are written to, as <saved-search-name>_<run time>.<format>")
+	format := fs.String("format", "csv", "Output format (ndjson, jsonl, csv or raw)")
+	owner := fs.String("owner", "", "Owner of the saved search, when several users have one with the name")
+	app := fs.String("app", "", "App of the saved search, when several apps have one with the name")
+	force := fs.Bool("force", false, "Overwrite the file of a run that was pulled before")
+	concurrency := fs.Int("max-connections", 8, "The maximum number of concurrent connections to use for downloading results")
+	conn := addConnectionFlags(fs)
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *latestRun && *run != "" {
+		fmt.Println("--latest-run and --run can't be used together")
+		os.Exit(1)
+	}
+	outputMode, err := parseFormat(*format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	client, err := conn.newClient()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	client = client.WithContext(interruptContext())
+	if !fs.Changed("max-connections") && conn.settings.MaxConnections > 0 {
+		*concurrency = conn.settings.MaxConnections
+	}
+	*concurrency = conn.limitConnections(*concurrency)
+
+	saved, err := client.GetSavedSearch(fs.Arg(0), *owner, *app)
+	if err != nil {
+		fatal("Failed to find the saved search", err)
+	}
+	slog.Info("Found saved search", "name", saved.Name, "owner", saved.Owner, "app", saved.App)
+
+	var sid string
+	var ranAt time.Time
+	if *latestRun {
+		job, err := client.LatestSavedSearchRun(saved)
+		if err != nil {
+			fatalWithStatus("Failed to find the latest run of the saved search", err, exitSearch)
+		}
+		sid, ranAt = job.Content.SID, job.Published
+		slog.Info("Found latest run", "sid", sid, "dispatched", ranAt.Format(time.RFC3339))
+	} else if *run != "" {
+		job, err := client.SavedSearchRun(saved, *run)
+		if err != nil {
+			fatalWithStatus("Failed to find the run of the saved search", err, exitSearch)
+		}
+		if !job.Content.IsDone {
+			fatalWithStatus("Failed to download the run", fmt.Errorf("run %s is still going (state: %s)", *run, job.Content.DispatchState), exitSearch)
+		}
+		sid, ranAt = job.Content.SID, job.Published
+	} else {
+		ranAt = time.Now()
+		sid, err = client.DispatchSavedSearch(saved)
+		if err != nil {
+			fatalWithStatus("Failed to run the saved search", err, exitSearch)
+		}
+		cancelJobOnInterrupt(client, sid)
+		slog.Info("Waiting for job to be done", "sid", sid)
+		if err := client.WaitUntilJobIsDone(sid); err != nil {
+			fatalWithStatus("Failed while waiting for job to be done", err, exitSearch)
+		}
+	}
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fatal("Failed to create the output directory", err)
+	}
+	filename := filepath.Join(*out, reportFilename(saved.Name, ranAt, outputMode))
+
+	slog.Info("Downloading search results", "sid", sid)
+	d := downloader.NewDownloader(client, config.DownloaderConfig{
+		OutputMode:     outputMode,
+		MaxConnections: *concurrency,
+		SID:            sid,
+		Filename:       filename,
+		MaxResults:     conn.policy.MaxResults,
+		Overwrite:      *force,
+	})
+	waitForProgress := trackProgress(d)
+	err = d.DownloadSearchResults()
+	waitForProgress()
+	warnings := &report.Warnings{}
+	warnings.Extend(d.Warnings())
+	printWarnings(warnings)
+	if err != nil {
+		fatalWithStatus("Failed to download search results", err, exitDownload)
+	}
+	slog.Info("Downloaded search results", "filename", filename)
+	logTransfer(client.Transferred())
+	// Scripts archiving the report pick up the file from stdout
+	fmt.Println(filename)
+}
+
+// runReportRuns lists the runs of a saved search Splunk still keeps, newest first, so that a run to
+// download with report pull --run can be picked
+func runReportRuns(args []string) {
+	fs := flag.NewFlagSet("report runs", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: spldl report runs [options] <saved-search-name>")
+		fs.PrintDefaults()
+	}
+	owner := fs.String("owner", "", "Owner of the saved search, when several users have one with the name")
+	app := fs.String("app", "", "App of the saved search, when several apps have one with the name")
+	scheduled := fs.Bool("scheduled", false, "Only list the runs started by the scheduler, not those run on demand")
+	limit := fs.Int("limit", 20, "List at most this many runs, 0 for every run")
+	client := parseClientFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	saved, err := client.GetSavedSearch(fs.Arg(0), *owner, *app)
+	if err != nil {
+		fatal("Failed to find the saved search", err)
+	}
+	history, err := client.SavedSearchHistory(saved)
+	if err != nil {
+		fatal("Failed to list the runs of the saved search", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SID\tTRIGGER\tSTATE\tDISPATCHED\tRESULTS\tSIZE\tEXPIRES IN")
+	listed := 0
+	for _, job := range history {
+		isScheduled := splunkclient.IsScheduledRun(job.Content.SID)
+		if *scheduled && !isScheduled {
+			continue
+		}
+		if *limit > 0 && listed == *limit {
+			break
+		}
+		trigger := "manual"
+		if isScheduled {
+			trigger = "scheduled"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			job.Content.SID, trigger, job.Content.DispatchState, job.Published.Format(time.RFC3339),
+			job.Content.ResultCount, formatBytes(float64(job.Content.DiskUsage)), secondsString(job.Content.TTL))
+		listed++
+	}
+	w.Flush()
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// reportFilename names the file of a run of a saved search after the saved search and when it ran,
+// e.g. Nightly_Failed_Logins_20240102T030000Z.csv
+func reportFilename(name string, ranAt time.Time, outputMode string) string {
+	ext := outputMode
+	if outputMode == "raw" {
+		ext = "txt"
+	}
+	return fmt.Sprintf("%s_%s.%s", unsafeFilenameChars.ReplaceAllString(name, "_"), ranAt.UTC().Format("20060102T150405Z"), ext)
+}
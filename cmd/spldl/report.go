@@ -0,0 +1,68 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// How many of the most common fields, and values of each, the HTML report lists
+const (
+	reportFields      = 15
+	reportFieldValues = 3
+)
+
+// writeHTMLReport writes the evidence of a finished export to path. query, earliest and latest are
+// empty when an existing job was downloaded.
+func writeHTMLReport(path string, client *splunkclient.Client, d *downloader.Downloader, query, earliest, latest string, warnings *report.Warnings) error {
+	r := report.ExportReport{
+		GeneratedAt: time.Now(),
+		Query:       query,
+		Earliest:    earliest,
+		Latest:      latest,
+		Cost:        d.Cost(),
+		RowsWritten: d.RowsWritten(),
+		Warnings:    warnings.Summary(),
+	}
+
+	for _, file := range d.OutputFiles() {
+		checksum, err := report.ChecksumFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to checksum %s: %w", file, err)
+		}
+		r.Files = append(r.Files, checksum)
+	}
+
+	if r.Cost.SID != "" {
+		summaries, err := client.GetFieldSummaries(r.Cost.SID, reportFieldValues)
+		if err != nil {
+			// The report is still useful without the fields
+			slog.Warn("Unable to get the field summary for the report", "sid", r.Cost.SID, "error", err)
+		}
+		r.Fields = topFields(summaries)
+	}
+
+	return r.WriteHTMLFile(path)
+}
+
+// topFields returns the fields present in the most results, most common first
+func topFields(summaries map[string]splunkclient.FieldSummary) []report.FieldStat {
+	fields := make([]report.FieldStat, 0, len(summaries))
+	for name, summary := range summaries {
+		field := report.FieldStat{Name: name, Count: summary.Count, DistinctCount: summary.DistinctCount}
+		for _, mode := range summary.Modes {
+			field.TopValues = append(field.TopValues, mode.Value)
+		}
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, func(a, b report.FieldStat) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+	return fields[:min(len(fields), reportFields)]
+}
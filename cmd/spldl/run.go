@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/convert"
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/pipeline"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+const runUsage = "Usage: spldl run [options] <pipeline.yaml>..."
+
+// Connection settings a pipeline may set, unless they are given on the command line
+var pipelineConnectionFlags = []string{"profile", "host", "port", "insecure", "app", "owner"}
+
+func runPipeline(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	conn := addConnectionFlags(fs)
+	conn.addDispatchFlags()
+	conn.addNamespaceFlags()
+	fs.BoolVar(&cancelOnInterrupt, "cancel-on-interrupt", false, "Cancel and delete the search job spldl dispatched when interrupted with Ctrl-C")
+	cleanup := fs.String("cleanup", "", cleanupUsage+". Overrides the pipelines' delete_when_done")
+	fs.BoolVar(&partialOK, "partial-ok", false, "When interrupted with Ctrl-C while waiting for the search, finalize the job and download the results found so far")
+	failFast := fs.Bool("fail-fast", false, "With several pipelines, stop at the first one that fails instead of running the rest")
+	force := fs.Bool("force", false, "Overwrite sink files that already exist, e.g. from an earlier run of the pipeline")
+	strict := fs.Bool("strict", false, "Fail a pipeline whose search has likely mistakes, such as a missing index= or an unlimited sort, instead of warning about them")
+	statePath := fs.String("state", "", "File recording the batch's progress, so that running it again after a crash skips completed pipelines and picks up the jobs of the others")
+	pprofAddr := fs.String("pprof", "", "Address to serve net/http/pprof profiles on during the run (e.g. localhost:6060), for investigating slow downloads")
+	verbose := fs.BoolP("verbose", "v", false, "Enable verbose logging")
+	fs.Usage = func() {
+		fmt.Println(runUsage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureLogging(*verbose)
+	startPprof(*pprofAddr)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if _, err := cleanupPolicy(*cleanup, false, cancelOnInterrupt); err != nil {
+		fatal("Invalid options", err)
+	}
+	applyCleanupPolicy(*cleanup)
+
+	// Every pipeline is loaded up front, so that a mistake in one doesn't surface halfway through a batch
+	var pipelines []*pipeline.Pipeline
+	for _, path := range fs.Args() {
+		p, err := pipeline.Load(path)
+		if err != nil {
+			fatal("Failed to load pipeline", err)
+		}
+		pipelines = append(pipelines, p)
+	}
+
+	runner := &pipelineRunner{fs: fs, conn: conn, given: make(map[string]bool), strict: *strict, force: *force, cleanup: *cleanup}
+	for _, name := range pipelineConnectionFlags {
+		runner.given[name] = fs.Changed(name)
+	}
+	if *statePath != "" {
+		var err error
+		runner.state, err = pipeline.LoadBatchState(*statePath)
+		if err != nil {
+			fatal("Failed to load batch state", err)
+		}
+		// Pipelines not run for a while are forgotten, whether or not they're part of this run
+		if _, err := runner.state.Prune(time.Now().Add(-defaultStateAge)); err != nil {
+			fatal("Failed to prune batch state", err)
+		}
+	}
+	runner.ctx = interruptContext()
+
+	if len(pipelines) == 1 {
+		result := runner.run(fs.Arg(0), pipelines[0])
+		if result.failure != nil {
+			fatalWithStatus(result.failure.action, result.failure.err, result.failure.status)
+		}
+		runner.removeState()
+		printRunID()
+		if result.partial {
+			heartbeat.Finish(exitPartial, nil)
+			os.Exit(exitPartial)
+		}
+		return
+	}
+
+	results := make([]pipelineResult, 0, len(pipelines))
+	for i, p := range pipelines {
+		path := fs.Arg(i)
+		if entry, ok := runner.state.Entry(path, p); ok && entry.Done {
+			slog.Info("Skipping pipeline completed by an earlier run", "path", path, "sid", entry.SID)
+			results = append(results, pipelineResult{path: path, name: p.Name, sid: entry.SID, doneEarlier: true})
+			continue
+		}
+		slog.Info(fmt.Sprintf("Running pipeline %d of %d", i+1, len(pipelines)), "path", path)
+		result := runner.run(path, p)
+		results = append(results, result)
+		if result.failure != nil {
+			presentError(result.failure.action, result.failure.err)
+			if cleanupOnFailure {
+				runInterruptCleanups()
+			}
+			if *failFast || result.failure.exitStatus() == exitInterrupted {
+				break
+			}
+		}
+		// The pipeline's job is done with, so a later interrupt or failure has nothing to cancel here
+		forgetJobCleanup(result.sid)
+	}
+	for i := len(results); i < len(pipelines); i++ {
+		results = append(results, pipelineResult{path: fs.Arg(i), name: pipelines[i].Name, skipped: true})
+	}
+
+	printBatchSummary(os.Stderr, results)
+	printRunID()
+	status, err := batchStatus(results)
+	switch status {
+	case 0, exitPartial:
+		runner.removeState()
+	case exitInterrupted:
+		runInterruptCleanups()
+	}
+	heartbeat.Finish(status, err)
+	if status != 0 {
+		os.Exit(status)
+	}
+}
+
+// pipelineRunner runs the pipelines given to spldl run
+type pipelineRunner struct {
+	ctx   context.Context
+	fs    *flag.FlagSet
+	conn  *connectionFlags
+	given map[string]bool      // the connection flags given on the command line, which override the pipelines'
+	state *pipeline.BatchState // nil without --state
+
+	strict  bool   // fail pipelines whose search has likely mistakes
+	force   bool   // overwrite existing sink files
+	cleanup string // when jobs are deleted, empty for the pipelines' delete_when_done
+
+	concurrent bool // pipelines run at once, as with spldl batch
+}
+
+// run runs the pipeline loaded from path, returning its outcome instead of exiting on failure. The
+// connection flags not given on the command line are taken from the pipeline.
+func (r *pipelineRunner) run(path string, p *pipeline.Pipeline) pipelineResult {
+	setPipelineConnection(r.fs, p.Connection, r.given)
+	client, err := r.conn.newClient()
+	if err != nil {
+		return pipelineResult{path: path, name: p.Name, failure: &stageError{action: "Failed to connect", err: err, status: exitFailure}}
+	}
+	return r.runWith(client.WithContext(r.ctx), path, p)
+}
+
+// runWith runs the pipeline loaded from path with client, which spldl batch shares between the
+// pipelines it runs at once
+func (r *pipelineRunner) runWith(client *splunkclient.Client, path string, p *pipeline.Pipeline) pipelineResult {
+	started := time.Now()
+	result := pipelineResult{path: path, name: p.Name}
+	warnings := &report.Warnings{}
+	fail := func(action string, err error, status int) pipelineResult {
+		result.failure = &stageError{action: action, err: err, status: status}
+		result.duration = time.Since(started)
+		result.warnings = warnings.Len()
+		return result
+	}
+
+	downloaderConfig, err := pipelineDownloaderConfig(p)
+	if err != nil {
+		return fail("Failed to load pipeline", err, exitFailure)
+	}
+
+	downloaderConfig.TokenExpiry = r.conn.checkTokenExpiry(defaultTokenValidity)
+	downloaderConfig.MaxConnections = r.conn.limitConnections(downloaderConfig.MaxConnections)
+	downloaderConfig.MaxResults = r.conn.policy.MaxResults
+	downloaderConfig.Overwrite = r.force
+	downloaderConfig.DeleteWhenDone, _ = cleanupPolicy(r.cleanup, downloaderConfig.DeleteWhenDone, cancelOnInterrupt)
+	// The first sink is checked by the download, the others before it starts
+	if !r.force {
+		for _, sink := range p.Sinks()[1:] {
+			if _, err := os.Stat(sink.Path); err == nil {
+				return fail("Refusing to overwrite sink", fmt.Errorf("%s already exists, use --force to overwrite it", sink.Path), exitFailure)
+			}
+		}
+	}
+
+	slog.Info("Running pipeline", "name", p.Name, "steps", len(p.Steps))
+
+	downloaderConfig.SID = p.Search.SID
+	if downloaderConfig.SID == "" {
+		downloaderConfig.Search = p.Query()
+		if err := lintSearch(p.Query(), p.Search.Earliest, p.Search.Latest, r.strict, warnings); err != nil {
+			return fail("Refusing to run the search", err, exitFailure)
+		}
+		if err := checkGuardrails(client, r.conn.policy, p.Query(), p.Search.Earliest, p.Search.Latest); err != nil {
+			return fail("Refusing to run the search", err, exitFailure)
+		}
+		limits := warnTruncationLimits(client, p.Search.Earliest, p.Search.Latest, 0, warnings)
+		downloaderConfig.SID = r.interruptedJob(client, path, p)
+		if downloaderConfig.SID == "" {
+			downloaderConfig.SID, err = startSearchJob(client, p.Query(), p.Search.Earliest, p.Search.Latest, labeledJobID(p.Search.JobID, p.Search.Label))
+			if err != nil {
+				return fail("Failed to create search job", err, exitSearch)
+			}
+			r.record(r.state.Started(path, p, downloaderConfig.SID))
+		}
+		result.sid = downloaderConfig.SID
+		slog.Info("Waiting for job to be done")
+		downloaderConfig.Partial, err = waitForJob(client, downloaderConfig.SID)
+		if err != nil {
+			return fail("Failed while waiting for job to be done", err, exitSearch)
+		}
+		warnJobTruncation(client, downloaderConfig.SID, limits, warnings)
+	}
+	result.sid = downloaderConfig.SID
+	result.partial = downloaderConfig.Partial
+
+	slog.Info("Downloading search results", "sid", downloaderConfig.SID)
+	d := downloader.NewDownloader(client, downloaderConfig)
+	// Progress bars of pipelines running at once would be drawn over each other
+	waitForProgress := func() {}
+	if !r.concurrent {
+		waitForProgress = trackProgress(d)
+	}
+	err = d.DownloadSearchResults()
+	waitForProgress()
+	result.rows, result.bytes = d.RowsWritten(), d.BytesWritten()
+	warnings.Extend(d.Warnings())
+	if downloaderConfig.Partial {
+		warnings.Add("search", "the job was finalized early, so only part of its results were downloaded")
+	}
+	if err != nil {
+		printWarnings(warnings)
+		return fail("Failed to download search results", err, exitDownload)
+	}
+	slog.Info("Downloaded search results", "filename", downloaderConfig.Filename)
+	slog.Info("Search cost: " + d.Cost().String())
+	logTransfer(client.Transferred())
+
+	for _, sink := range p.Sinks()[1:] {
+		outputMode, err := sinkOutputMode(sink)
+		if err != nil {
+			printWarnings(warnings)
+			return fail("Failed to write sink", err, exitFailure)
+		}
+		err = convert.Convert(downloaderConfig.Filename, downloaderConfig.OutputMode, sink.Path, outputMode)
+		if err != nil {
+			printWarnings(warnings)
+			return fail("Failed to write sink "+sink.Path, err, exitDownload)
+		}
+		slog.Info("Wrote sink", "filename", sink.Path)
+	}
+
+	printWarnings(warnings)
+	// The job was downloaded, and deleted if asked to, so a later failure must not delete it again
+	forgetJobCleanup(downloaderConfig.SID)
+	r.record(r.state.Completed(path, p, downloaderConfig.SID))
+	result.duration = time.Since(started)
+	result.warnings = warnings.Len()
+	return result
+}
+
+// interruptedJob returns the job an earlier run of the batch dispatched for the pipeline, if it still
+// exists, so that its results are downloaded instead of running the search again
+func (r *pipelineRunner) interruptedJob(client *splunkclient.Client, path string, p *pipeline.Pipeline) string {
+	entry, ok := r.state.Entry(path, p)
+	if !ok || entry.SID == "" {
+		return ""
+	}
+	_, err := client.GetJobStatus(entry.SID)
+	var httpErr *splunkclient.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		slog.Info("The job of the interrupted run is gone, running the search again", "sid", entry.SID)
+		return ""
+	}
+	slog.Info("Picking up the job of the interrupted run", "sid", entry.SID)
+	return entry.SID
+}
+
+// record reports a failure to save the batch state, which only matters if the batch has to be run again
+func (r *pipelineRunner) record(err error) {
+	if err != nil {
+		slog.Warn("Failed to save batch state", "error", err)
+	}
+}
+
+// removeState deletes the batch state once every pipeline has completed
+func (r *pipelineRunner) removeState() {
+	if err := r.state.Remove(); err != nil {
+		slog.Warn("Failed to remove batch state", "error", err)
+	}
+}
+
+// setPipelineConnection applies the pipeline's connection settings to the connection flags not given on
+// the command line, resetting the others to their defaults so that no pipeline inherits another's
+func setPipelineConnection(fs *flag.FlagSet, c pipeline.Connection, given map[string]bool) {
+	values := map[string]string{
+		"profile": c.Profile,
+		"host":    c.Host,
+		"app":     c.App,
+		"owner":   c.Owner,
+	}
+	if c.Port != 0 {
+		values["port"] = strconv.Itoa(c.Port)
+	}
+	if c.Insecure {
+		values["insecure"] = "true"
+	}
+	for _, name := range pipelineConnectionFlags {
+		if given[name] {
+			continue
+		}
+		if value := values[name]; value != "" {
+			fs.Set(name, value)
+			continue
+		}
+		f := fs.Lookup(name)
+		f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+}
+
+// pipelineDownloaderConfig builds the configuration that downloads a pipeline's search to its first sink
+func pipelineDownloaderConfig(p *pipeline.Pipeline) (config.DownloaderConfig, error) {
+	sink := p.Sinks()[0]
+	outputMode, err := sinkOutputMode(sink)
+	if err != nil {
+		return config.DownloaderConfig{}, err
+	}
+
+	cfg := config.DownloaderConfig{
+		OutputMode:     outputMode,
+		DeleteWhenDone: p.Search.DeleteWhenDone,
+		MaxConnections: p.Search.MaxConnections,
+		Filename:       sink.Path,
+		DedupeWindow:   7 * 24 * time.Hour,
+
+		FailOnJobErrors: p.Search.FailOnJobErrors,
+		AllowPartial:    p.Search.AllowPartial,
+	}
+
+	if step, ok := p.Find(pipeline.StepRedact); ok {
+		cfg.RedactMask = step.Mask
+		cfg.RedactDrop = step.Drop
+	}
+	if step, ok := p.Find(pipeline.StepDedupe); ok {
+		cfg.DedupeState = step.State
+		if step.Window != "" {
+			cfg.DedupeWindow, err = config.ParseDuration(step.Window)
+			if err != nil {
+				return config.DownloaderConfig{}, fmt.Errorf("dedupe window: %w", err)
+			}
+		}
+	}
+	if step, ok := p.Find(pipeline.StepSplit); ok {
+		cfg.BucketSize, err = config.ParseDuration(step.Bucket)
+		if err != nil {
+			return config.DownloaderConfig{}, fmt.Errorf("split bucket: %w", err)
+		}
+	}
+	if _, ok := p.Find(pipeline.StepVerify); ok {
+		cfg.Verify = true
+		cfg.SigningKey = os.Getenv("SPLDL_SIGNING_KEY")
+	}
+	return cfg, nil
+}
+
+func sinkOutputMode(sink pipeline.Step) (string, error) {
+	if sink.Format != "" {
+		if err := checkCompression(sink.Path); err != nil {
+			return "", err
+		}
+		return parseFormat(sink.Format)
+	}
+	return detectOutputMode(sink.Path, "", false)
+}
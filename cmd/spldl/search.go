@@ -0,0 +1,217 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// runSearch runs a search and downloads its results. command is "search" for spldl search, which takes
+// the query as its first argument, and empty for the original form that takes it with --search.
+func runSearch(command string, args []string) {
+	fs := flag.NewFlagSet(cmp.Or(command, "spldl"), flag.ExitOnError)
+	search := new(string)
+	if command == "" {
+		search = fs.String("search", "", "The search query to run")
+	}
+	template := fs.String("template", "", "File holding the search to run, with $name$ parameters filled in from --param")
+	params := fs.StringArray("param", nil, "name=value filling in $name$ in the search as a quoted string, or $name|raw$ as it is. Repeat for more parameters")
+	jobID := fs.String("job-id", "", "Search ID to dispatch the search with. A job with this ID is reused instead of dispatching a duplicate, so retried runs wait on the original search")
+	label := fs.String("label", "", "Prefix for the search ID of the dispatched job, so it can be found with spldl jobs list --label")
+	earliest := fs.String("earliest", "-24h", "The earliest time to search from")
+	latest := fs.String("latest", "now", "The latest time to search to")
+	conn := addConnectionFlags(fs)
+	conn.addDispatchFlags()
+	conn.addNamespaceFlags()
+	out := addOutputFlags(fs)
+	autoSplit := fs.Bool("auto-split", false, "Re-run searches with more than 500000 results across smaller time windows and combine the results")
+	splitWindow := durationFlag(time.Hour)
+	fs.Var(&splitWindow, "split-window", "The time window size --auto-split starts with, halved while a window has too many results")
+	oneshot := fs.Bool("oneshot", false, "Run the search in a single request and write the response, without creating a job. For small searches, Splunk returns at most 50000 results")
+	interval := fs.Duration("interval", 0, "With --follow, run the search again every interval over the time since the previous run and append its results to the output, until interrupted")
+	checkpoint := fs.String("checkpoint", "", "File recording the end of the time window --interval last appended, <output-file>.checkpoint.json by default")
+	strict := fs.Bool("strict", false, "Refuse to run a search with likely mistakes, such as a missing index= or an unlimited sort, instead of warning about them")
+	export := fs.Bool("export", false, "Stream the results of the search through the export endpoint instead of running a job. Not limited to 500000 results")
+	printSPL := fs.Bool("print-spl", false, "Print the SPL spldl would dispatch for the search and exit without running it")
+	fs.BoolVar(&partialOK, "partial-ok", false, "When interrupted with Ctrl-C while waiting for the search, finalize the job and download the results found so far")
+	fs.Parse(args)
+
+	configureLogging(*out.verbose)
+
+	if *out.help {
+		printDownloadUsage(command)
+		fs.PrintDefaults()
+		os.Exit(0)
+	}
+
+	args = fs.Args()
+	if command == "search" {
+		// --template takes the place of the query
+		queryArgs := 1
+		if *template != "" {
+			queryArgs = 0
+		}
+		if len(args) != queryArgs+out.outputArgs() && !(*printSPL && len(args) == queryArgs) {
+			fmt.Println(searchUsage)
+			os.Exit(1)
+		}
+		if queryArgs == 1 {
+			*search, args = args[0], args[1:]
+		}
+	}
+
+	if *template != "" && *search != "" {
+		fmt.Println("--template can't be used with --search")
+		os.Exit(1)
+	}
+	templated, err := templatedSearch(*search, *template, *params)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	*search = templated
+
+	if *printSPL {
+		if *search == "" {
+			fmt.Println("--print-spl shows the SPL of a search query and needs --search")
+			os.Exit(1)
+		}
+		// Runs append a comment with their run ID, which a run that isn't dispatched doesn't have
+		fmt.Println(splunkclient.NormalizeSearch(*search))
+		return
+	}
+
+	if len(args) == 0 && *out.kafkaTopic == "" {
+		fmt.Println("No output file specified")
+		printDownloadUsage(command)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	if *search == "" {
+		fmt.Println("You must provide either a search query or a search ID. Use spldl --help for more information.")
+		os.Exit(1)
+	}
+
+	if *oneshot && (*export || *out.follow || *jobID != "" || *label != "" || *out.resume || *autoSplit || *out.parallelWrites) {
+		fmt.Println("--oneshot runs the search without a job and can't be used with --export, --follow, --job-id, --label, --resume, --auto-split or --parallel-writes")
+		os.Exit(1)
+	}
+	if *out.follow && (*export || *autoSplit) {
+		fmt.Println("--follow can't be used with --export or --auto-split")
+		os.Exit(1)
+	}
+	if *interval < 0 || *interval > 0 && (!*out.follow || fs.Changed("latest") || *jobID != "" || *label != "") {
+		fmt.Println("--interval runs the search again with --follow, up to the time of each run, and can't be used with --latest, --job-id or --label")
+		os.Exit(1)
+	}
+	if *checkpoint != "" && *interval == 0 {
+		fmt.Println("--checkpoint records the progress of --interval and needs it")
+		os.Exit(1)
+	}
+	if *out.events && (*export || *oneshot || *autoSplit) {
+		fmt.Println("--events downloads the events of a job and can't be used with --export, --oneshot or --auto-split")
+		os.Exit(1)
+	}
+	if *out.stopAfter > 0 && (*export || *oneshot || *autoSplit) {
+		fmt.Println("--stop-after can't be used with --export, --oneshot or --auto-split, add | head to the search instead")
+		os.Exit(1)
+	}
+	if *label != "" && (*jobID != "" || *export) {
+		fmt.Println("--label names the job spldl dispatches and can't be used with --job-id or --export")
+		os.Exit(1)
+	}
+	out.check()
+
+	if *out.events {
+		conn.keepEvents()
+	}
+	client, tokenExpiry := out.connect(conn)
+	out.resolveOutput(args)
+
+	warnings := &report.Warnings{}
+	var limits splunkclient.SearchLimits
+	// A resumed download continues the job of the interrupted run instead of dispatching the search
+	if !*out.resume || *export {
+		if err := lintSearch(*search, *earliest, *latest, *strict, warnings); err != nil {
+			fatal("Refusing to run the search", err)
+		}
+		if err := checkGuardrails(client, conn.policy, *search, *earliest, *latest); err != nil {
+			fatal("Refusing to run the search", err)
+		}
+		limits = warnTruncationLimits(client, *earliest, *latest, *out.stopAfter, warnings)
+	}
+
+	var sid string
+	var partial bool
+	switch {
+	case *out.resume && !*export:
+		// Continue downloading the job of the interrupted run instead of running the search again
+		sid, err = downloader.ResumeSID(out.filename)
+		if err != nil {
+			fatal("Unable to resume the download", err)
+		}
+	case *interval > 0:
+		// Every run of the search dispatches its own job
+	case *out.follow:
+		sid = createSearchJob(client, *search, *earliest, *latest, labeledJobID(*jobID, *label))
+	case !*export && !*oneshot:
+		sid, partial = dispatchSearch(client, *search, *earliest, *latest, labeledJobID(*jobID, *label), *out.stopAfter)
+		warnJobTruncation(client, sid, limits, warnings)
+	}
+
+	if *export {
+		slog.Info("Exporting search results", "spl", client.DispatchedSearch(*search), "earliest", *earliest, "latest", *latest)
+	} else if *oneshot {
+		slog.Info("Running oneshot search", "spl", client.DispatchedSearch(*search), "earliest", *earliest, "latest", *latest)
+	} else if *interval == 0 {
+		slog.Info("Downloading search results", "sid", sid)
+	}
+	heartbeat.SetPhase(report.PhaseDownloading, sid)
+
+	downloaderConfig := out.downloaderConfig(conn, tokenExpiry)
+	downloaderConfig.SID = sid
+	downloaderConfig.Search = *search
+	downloaderConfig.AutoSplit = *autoSplit
+	downloaderConfig.SplitWindow = time.Duration(splitWindow)
+	downloaderConfig.Partial = partial
+	if *interval > 0 {
+		err = watchSearch(client, *search, *earliest, checkpointPath(*checkpoint, out.filename), *interval, downloaderConfig)
+		if !errors.Is(err, context.Canceled) {
+			fatalWithStatus("Failed to follow the search", err, exitDownload)
+		}
+		// Interrupting is how following the search ends
+		runInterruptCleanups()
+		slog.Info("Stopped following the search", "filename", out.filename)
+		heartbeat.Finish(0, nil)
+		return
+	}
+
+	dl := downloader.NewDownloader(client, downloaderConfig)
+	download := dl.DownloadSearchResults
+	switch {
+	case *export:
+		download = func() error { return dl.ExportSearchResults(*search, *earliest, *latest) }
+	case *out.follow:
+		download = dl.FollowSearchResults
+	case *oneshot:
+		download = func() error { return dl.OneshotSearchResults(*search, *earliest, *latest) }
+	}
+	run := downloadRun{
+		search:   *search,
+		earliest: *earliest,
+		latest:   *latest,
+		partial:  partial,
+		jobless:  *export || *out.follow || *oneshot,
+	}
+	out.download(client, dl, download, run, warnings)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cschmidt0121/spldl/internal/spl"
+)
+
+// templatedSearch returns the search to run: query, or the SPL of templateFile when it's set, with
+// params filled in. A search without params or a template is returned as it is.
+func templatedSearch(query, templateFile string, params []string) (string, error) {
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the search template: %w", err)
+		}
+		query = strings.TrimSpace(string(data))
+		if query == "" {
+			return "", fmt.Errorf("search template %s is empty", templateFile)
+		}
+	}
+	if templateFile == "" && len(params) == 0 {
+		return query, nil
+	}
+	parsed, err := spl.ParseParams(params)
+	if err != nil {
+		return "", err
+	}
+	return spl.Substitute(query, parsed)
+}
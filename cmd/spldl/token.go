@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+const tokenUsage = "Usage: spldl token issue [options]"
+
+func runToken(args []string) {
+	if len(args) == 0 {
+		fmt.Println(tokenUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "issue":
+		runTokenIssue(args[1:])
+	case "-h", "--help":
+		fmt.Println(tokenUsage)
+	default:
+		fmt.Printf("Unknown token command %q\n", args[0])
+		fmt.Println(tokenUsage)
+		os.Exit(1)
+	}
+}
+
+// runTokenIssue mints a short-lived token and prints it to stdout, so a CI job can hand it to later
+// steps instead of giving them long-lived credentials. Everything else goes to stderr.
+func runTokenIssue(args []string) {
+	fs := flag.NewFlagSet("token issue", flag.ExitOnError)
+	ttl := fs.Duration("ttl", time.Hour, "How long the token is valid for")
+	user := fs.String("user", "", "The user to issue the token for, the authenticated user if not set. Use a dedicated export user to limit what the token can access")
+	audience := fs.String("audience", "spldl export", "The audience recorded with the token, shown in Splunk's token list")
+	env := fs.Bool("env", false, "Print the token as SPLUNK_TOKEN=<token>, for appending to a CI environment file")
+	fs.Usage = func() {
+		fmt.Println(tokenUsage)
+		fs.PrintDefaults()
+	}
+	client := parseClientFlags(fs, args)
+
+	if *user == "" {
+		context, err := client.GetCurrentContext()
+		if err != nil {
+			fatal("Failed to get current user", err)
+		}
+		*user = context.Username
+	}
+
+	token, err := client.IssueToken(*user, *audience, *ttl)
+	if err != nil {
+		fatalWithStatus("Failed to issue token", err, exitAuth)
+	}
+	slog.Info("Issued token", "id", token.ID, "user", *user, "expires_at", time.Now().Add(*ttl).Format(time.RFC3339))
+
+	if *env {
+		fmt.Printf("SPLUNK_TOKEN=%s\n", token.Token)
+	} else {
+		fmt.Println(token.Token)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/report"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// followCheckpoint records how far a search followed with --interval got, so that the next run, or
+// spldl started again, continues from there
+type followCheckpoint struct {
+	Search string    `json:"search"`
+	Latest time.Time `json:"latest"` // the end of the last time window appended to the output
+}
+
+// checkpointPath returns the checkpoint file of the output, unless --checkpoint names another
+func checkpointPath(checkpoint, filename string) string {
+	if checkpoint != "" {
+		return checkpoint
+	}
+	return filename + ".checkpoint.json"
+}
+
+// loadFollowCheckpoint reads the checkpoint at path, or returns false when there is none yet
+func loadFollowCheckpoint(path string) (followCheckpoint, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return followCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return followCheckpoint{}, false, err
+	}
+	var checkpoint followCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return followCheckpoint{}, false, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return checkpoint, true, nil
+}
+
+func (c followCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file first, so that a crash mid-write leaves the previous checkpoint intact
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// watchSearch runs search every interval over the time since the previous run and appends its
+// results to the output, until interrupted. The first run searches from earliest. A failed run is
+// logged and its window searched again by the next run, except for the first one, which stops
+// right away since the failure is most likely a mistake in the options.
+func watchSearch(client *splunkclient.Client, search, earliest, checkpointFile string, interval time.Duration, downloaderConfig config.DownloaderConfig) error {
+	checkpoint, ok, err := loadFollowCheckpoint(checkpointFile)
+	if err != nil {
+		return err
+	}
+	if ok && checkpoint.Search != search {
+		return fmt.Errorf("%s records the progress of another search, remove it or use --checkpoint", checkpointFile)
+	}
+	if ok {
+		slog.Info("Continuing from the checkpoint", "checkpoint", checkpointFile, "latest", checkpoint.Latest)
+	}
+
+	for succeeded := false; ; {
+		start := earliest
+		if ok {
+			start = strconv.FormatInt(checkpoint.Latest.Unix(), 10)
+		}
+		end := time.Now().Truncate(time.Second)
+		rows, err := followWindow(client, search, start, strconv.FormatInt(end.Unix(), 10), downloaderConfig)
+		if err == nil {
+			checkpoint, ok = followCheckpoint{Search: search, Latest: end.UTC()}, true
+			err = checkpoint.save(checkpointFile)
+		}
+		if ctxErr := client.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		switch {
+		case err != nil && !succeeded:
+			return err
+		case err != nil:
+			slog.Error("Run failed, the next run searches its time window again", "error", err)
+		default:
+			succeeded = true
+			// The jobs of completed runs aren't canceled on interrupt
+			forgetInterruptCleanups()
+			slog.Info("Appended new results", "filename", downloaderConfig.Filename, "rows", rows, "latest", end)
+		}
+
+		slog.Info("Waiting for the next run", "interval", interval)
+		heartbeat.SetPhase(report.PhaseSearching, "")
+		select {
+		case <-time.After(interval):
+		case <-client.Context().Done():
+			return client.Context().Err()
+		}
+	}
+}
+
+// followWindow runs search over [earliest, latest) and follows its job, appending the results to the
+// output
+func followWindow(client *splunkclient.Client, search, earliest, latest string, downloaderConfig config.DownloaderConfig) (int, error) {
+	sid, err := startSearchJob(client, search, earliest, latest, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create search job: %w", err)
+	}
+	heartbeat.SetPhase(report.PhaseDownloading, sid)
+	downloaderConfig.SID = sid
+	downloaderConfig.Append = true
+	d := downloader.NewDownloader(client, downloaderConfig)
+	err = d.FollowSearchResults()
+	printWarnings(d.Warnings())
+	return d.RowsWritten(), err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+func runWhoami(args []string) {
+	fs := flag.NewFlagSet("whoami", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: spldl whoami [options]")
+		fs.PrintDefaults()
+	}
+	client := parseClientFlags(fs, args)
+
+	context, err := client.GetCurrentContext()
+	if err != nil {
+		fatal("Failed to get current user", err)
+	}
+	roles := client.GetRoles(context.Roles)
+
+	user := context.Username
+	if context.RealName != "" {
+		user += " (" + context.RealName + ")"
+	}
+	fmt.Printf("User:         %s\n", user)
+	fmt.Printf("Default app:  %s\n", context.DefaultApp)
+	fmt.Printf("Roles:        %s\n", strings.Join(context.Roles, ", "))
+
+	if len(roles) == 0 {
+		fmt.Println("\nThe roles' search restrictions aren't readable by this user.")
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tJOBS QUOTA\tDISK QUOTA\tMAX TIME\tTIME WINDOW\tINDEXES\tDEFAULT INDEXES")
+	for _, role := range roles {
+		name := role.Name
+		if !slices.Contains(context.Roles, role.Name) {
+			name += " (imported)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name,
+			limitOrUnlimited(role.SrchJobsQuota, strconv.Itoa),
+			limitOrUnlimited(role.SrchDiskQuota, func(mb int) string { return fmt.Sprintf("%d MB", mb) }),
+			limitOrUnlimited(role.SrchMaxTime, secondsString),
+			limitOrUnlimited(role.SrchTimeWin, secondsString),
+			listOrNone(role.SrchIndexesAllowed), listOrNone(role.SrchIndexesDefault))
+	}
+	w.Flush()
+
+	// Search filters silently restrict the events a user sees, a common reason for incomplete exports
+	for _, role := range roles {
+		if role.SrchFilter != "" {
+			fmt.Printf("\nRole %s restricts searches to events matching: %s\n", role.Name, role.SrchFilter)
+		}
+	}
+}
+
+// limitOrUnlimited formats a role limit where anything <= 0 means unlimited
+func limitOrUnlimited(limit int, format func(int) string) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return format(limit)
+}
+
+func secondsString(seconds int) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
@@ -1,5 +1,11 @@
 package config
 
+import (
+	"crypto/x509"
+	"net/url"
+	"time"
+)
+
 type AuthType string
 
 const (
@@ -19,5 +25,32 @@ type ClientConfig struct {
 	Port      int
 	Auth      AuthConfig
 	UseTLS    bool
-	VerifyTLS bool // Ignored if UseTLS is false
+	VerifyTLS bool           // Ignored if UseTLS is false
+	RootCAs   *x509.CertPool // CAs to verify the server with, nil for the system's
+	Proxy     *url.URL       // proxy to connect through, nil to follow HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+
+	TLSMinVersion uint16 // lowest TLS version accepted (a tls.Version* constant), 0 for Go's default
+	TLSServerName string // name the server's certificate is verified against, empty for Host
+
+	MaxRetries   int           // how often a request failing with a temporary error is retried, 0 to fail right away
+	RetryBackoff time.Duration // delay before the first retry, doubled after every retry, 1s when 0
+
+	PollInterval    time.Duration // delay before a running job is first checked again, doubled after every check, 3s when 0
+	MaxPollInterval time.Duration // the longest delay between checks of a running job, 1m when 0
+	WaitTimeout     time.Duration // how long to wait for a job to be done, 0 for as long as it runs
+	JobTTL          time.Duration // how long Splunk keeps dispatched jobs after they're last accessed, 1h when 0
+	// Further parameters dispatched jobs are created with, e.g. max_count or workload_pool. They replace
+	// spldl's own rf and timeout.
+	DispatchParams map[string]string
+
+	// The namespace searches are dispatched and looked up in, so they resolve the app's macros, lookups
+	// and event types. Both empty for the default context, otherwise an empty App is search and an
+	// empty Owner nobody.
+	App   string
+	Owner string
+
+	SessionAuth          bool    // exchange the username and password for a session key instead of sending them with every request
+	MaxRequestsPerSecond float64 // how many requests may start per second, 0 for no limit
+	DisableCompression   bool    // ask for uncompressed responses instead of gzip
+	CorrelationID        string  // identifies the run in request headers and dispatched searches, empty to leave it out
 }
@@ -1,9 +1,76 @@
 package config
 
+import (
+	"io"
+	"time"
+)
+
 type DownloaderConfig struct {
-	OutputMode     string // raw, ndjson, csv
-	MaxConnections int    // max concurrent connections to use for downloading results
-	DeleteWhenDone bool   // delete the job when done downloading
-	SID            string // the SID of the job to download results from
-	Filename       string // the filename to save the results to
+	OutputMode     string        // raw, ndjson, csv
+	MaxConnections int           // max concurrent connections to use for downloading results
+	ReorderWindow  int           // how many chunks workers may download ahead of the next one written, 0 for the default
+	DeleteWhenDone bool          // delete the job when done downloading
+	SID            string        // the SID of the job to download results from
+	MergeSIDs      []string      // further jobs whose results are written to the same output after those of SID
+	SortByTime     bool          // merge the ndjson results of SID and MergeSIDs by _time, oldest first
+	Search         string        // the search of the job, needed by AutoSplit to re-dispatch it
+	AutoSplit      bool          // re-dispatch jobs with more results than Splunk keeps across smaller time windows
+	SplitWindow    time.Duration // the initial window size of AutoSplit, halved while a window has too many results
+	Filename       string        // the filename to save the results to
+	Tee            []string      // further outputs the results are written to at the same time, in the same output mode
+	Stdout         io.Writer     // where results written to the "-" filename go, os.Stdout when nil
+	StdoutFormat   string        // the extension of the file format results written to "-" are in, e.g. .json, empty for the output mode's own
+	DedupeState    string        // file recording events exported by previous runs, empty to disable dedupe
+	DedupeWindow   time.Duration // how long exported events are remembered for dedupe
+	ClipEarliest   time.Time     // drop events with an earlier _time, zero to keep them
+	ClipLatest     time.Time     // drop events with this or a later _time, zero to keep them
+	Verify         bool          // recount the job's results after downloading and record the outcome in the manifest
+	SigningKey     string        // key used to sign the verification record, empty to leave it unsigned
+	BucketSize     time.Duration // split the output into one file per time bucket of this size, 0 to disable
+	TokenExpiry    time.Time     // when the credentials expire, zero if they don't
+	ParallelWrites bool          // write raw chunks from the workers into their region of the file, skipping the collector
+	Resume         bool          // continue an interrupted download of Filename from its resume state
+	Partial        bool          // the job was finalized before it was done, recorded in the manifest
+	MaxResults     int           // fail rather than write more results than this, 0 for no limit
+	RawJSON        bool          // reduce ndjson events to their _time and _raw
+	NoAnnotations  bool          // drop the tag, tag::<field>, eventtype and punct fields Splunk annotates events with
+	RedactMask     []string      // fields whose values are replaced with [REDACTED]
+	RedactDrop     []string      // fields removed from the results
+	Events         bool          // download the events the job's search read instead of its results
+
+	FailOnJobErrors bool // fail when Splunk reported an ERROR or FATAL message for the job
+	AllowPartial    bool // warn instead of failing when the rows written don't add up to the job's results
+	Overwrite       bool // replace existing output files instead of failing
+	Append          bool // add the results to the end of an existing output file instead of replacing it
+
+	CSVDelimiter rune   // separates the fields of csv output, ',' when 0
+	CSVQuoteAll  bool   // quote every field of csv output, not only those that need it
+	CSVCRLF      bool   // end the records of csv output with \r\n instead of \n
+	CSVLocale    string // format numbers and _time of csv output for this locale, e.g. de-DE, empty to keep them
+
+	Fields     []string // the only fields written, in this order, empty for all of them
+	PostFilter string   // post-process search Splunk filters the job's results with before sending them, empty to send them all
+
+	StopAfter int // download only the first this many results of the job, 0 for all of them
+
+	SinkBatchSize     int           // results sent per batch to network outputs such as es://, 0 for the default
+	SinkFlushInterval time.Duration // how long a batch that isn't full waits for more results, 0 until the end
+	SinkMaxInFlight   int           // batches on their way to a network output before writes wait, 0 for the default
+	SinkAttempts      int           // attempts at sending a batch before it's given up, 0 for the default
+	SinkBackoff       time.Duration // delay before a batch is sent again, doubled after every attempt, 0 for the default
+	DeadLetterFile    string        // ndjson file batches that were given up are appended to, empty to fail the download instead
+
+	WebhookHeaders []string // "Name: value" headers sent with the batches posted to http(s):// outputs
+
+	ElasticsearchURL string // the cluster es:// outputs are indexed into, ELASTICSEARCH_URL when empty
+
+	KafkaBrokers       []string // host:port of the Kafka brokers kafka:// outputs are published to, KAFKA_BROKERS when empty
+	KafkaKeyField      string   // the field whose value keys the messages, empty for messages without a key
+	KafkaCompression   string   // none, gzip, snappy, lz4 or zstd
+	KafkaTLS           bool     // connect to the brokers with TLS
+	KafkaTLSCAFile     string   // PEM file with the CAs to verify the brokers with instead of the system's, implies TLS
+	KafkaTLSCertFile   string   // PEM client certificate presented to the brokers, implies TLS
+	KafkaTLSKeyFile    string   // the key of KafkaTLSCertFile
+	KafkaSASLMechanism string   // plain, scram-sha-256 or scram-sha-512, empty to connect without SASL
+	KafkaSASLUsername  string   // the SASL user, KAFKA_SASL_USERNAME when empty; the password is KAFKA_SASL_PASSWORD
 }
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/cschmidt0121/spldl/internal/yaml"
+)
+
+// FileVersion is the version of the config file layout spldl reads and writes. Files of an older
+// version are migrated when they're loaded, and rewritten by MigrateFile.
+const FileVersion = 1
+
+// migrations upgrade a parsed config file from version i to version i+1. Every change to the layout
+// that would reject or misread existing files bumps FileVersion and adds a migration here.
+var migrations = []func(tree map[string]any) error{
+	// Files written before the layout was versioned have no version and the layout of version 1
+	func(map[string]any) error { return nil },
+}
+
+// fileVersion returns the version of a parsed config file, 0 when it has none
+func fileVersion(tree map[string]any) (int, error) {
+	value, ok := tree["version"]
+	if !ok || value == nil {
+		return 0, nil
+	}
+	version, ok := value.(int)
+	if !ok {
+		return 0, fmt.Errorf("version must be a number, got %v", value)
+	}
+	if version < 1 {
+		return 0, fmt.Errorf("version must be a positive number, got %d", version)
+	}
+	return version, nil
+}
+
+// migrate upgrades a parsed config file to FileVersion and returns the version it had
+func migrate(tree map[string]any) (int, error) {
+	version, err := fileVersion(tree)
+	if err != nil {
+		return 0, err
+	}
+	if version > FileVersion {
+		return 0, fmt.Errorf("version %d is newer than the version %d this spldl reads, upgrade spldl", version, FileVersion)
+	}
+	for v := version; v < FileVersion; v++ {
+		if err := migrations[v](tree); err != nil {
+			return 0, fmt.Errorf("failed to migrate from version %d: %w", v, err)
+		}
+	}
+	tree["version"] = FileVersion
+	return version, nil
+}
+
+// parseFile parses a config file and migrates it to the current layout
+func parseFile(data []byte) (map[string]any, int, error) {
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, 0, err
+	}
+	if tree == nil {
+		tree = make(map[string]any)
+	}
+	version, err := migrate(tree)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tree, version, nil
+}
+
+// MigrateFile rewrites the config file at path in the current layout, keeping the original as
+// <path>.bak, and returns the version it had. A file that is already current is left untouched.
+// Comments aren't carried over to the rewritten file.
+func MigrateFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	tree, version, err := parseFile(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid config: %w", path, err)
+	}
+	if version == FileVersion {
+		return version, nil
+	}
+
+	// Refuse to write a file that wouldn't load
+	var f File
+	if err := yaml.Decode(tree, &f); err != nil {
+		return 0, fmt.Errorf("%s: invalid config: %w", path, err)
+	}
+	migrated, err := yaml.Marshal(tree)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to encode the migrated config: %w", path, err)
+	}
+
+	// The file may hold credentials, so the backup and the new file keep its permissions
+	if err := os.WriteFile(path+".bak", data, info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path+".tmp", migrated, info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return 0, err
+	}
+	slog.Debug("Migrated config file", "path", path, "from_version", version, "to_version", FileVersion)
+	return version, nil
+}
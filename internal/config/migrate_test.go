@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateFile(t *testing.T) {
+	original, err := os.ReadFile("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, original, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := MigrateFile(path)
+	if err != nil {
+		t.Fatalf("MigrateFile returned error: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("Expected the unversioned file to be version 0, got %d", version)
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != string(original) {
+		t.Errorf("Expected the original file to be kept as a backup, got %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the migrated file to keep its permissions, got %v", info.Mode().Perm())
+	}
+
+	f, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile of the migrated file returned error: %v", err)
+	}
+	prod, _ := f.Profile("prod")
+	if f.Version != FileVersion || f.DefaultProfile != "dev" || prod.Proxy != "socks5://bastion.example.com:1080" || prod.MaxConnections != 16 {
+		t.Errorf("Unexpected migrated file %+v", f)
+	}
+
+	// A current file is left alone
+	if version, err := MigrateFile(path); err != nil || version != FileVersion {
+		t.Errorf("Expected version %d and no error, got %d and %v", FileVersion, version, err)
+	}
+}
+
+func TestLoadFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("version: 99\nprofiles:\n  dev:\n    host: localhost\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadFile(path)
+	if err == nil || !strings.Contains(err.Error(), "upgrade spldl") {
+		t.Errorf("Expected an error for a newer version, got %v", err)
+	}
+
+	// Unversioned files load as before
+	f, err := LoadFile("testdata/config.yaml")
+	if err != nil || f.Version != FileVersion {
+		t.Errorf("Expected the unversioned file to be migrated, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cschmidt0121/spldl/internal/yaml"
+)
+
+// SystemConfigPath is the system-wide config installed by administrators, whose policies apply to
+// every user regardless of their own config and command line
+const SystemConfigPath = "/etc/spldl/config.yaml"
+
+// SystemFile is the system-wide config, holding the policies that cap what runs may do
+type SystemFile struct {
+	DefaultPolicy Policy            `yaml:"default_policy"` // applies to every connection
+	Policies      map[string]Policy `yaml:"policies"`       // by profile name
+}
+
+// Policy caps the load a run puts on a search head. Zero values are unlimited.
+type Policy struct {
+	Host                 string  `yaml:"host"` // also applies the policy to connections to this host, whatever the profile
+	MaxConnections       int     `yaml:"max_connections"`
+	MaxRequestsPerSecond float64 `yaml:"max_requests_per_second"`
+	MaxResults           int     `yaml:"max_results"`
+
+	// Guardrails checked before a search is dispatched
+	MaxTimeRange       Duration `yaml:"max_time_range"`       // the longest time range a search may cover
+	MaxEstimatedEvents int      `yaml:"max_estimated_events"` // the most events the indexes a search reads may hold in its time range
+	RequireIndex       bool     `yaml:"require_index"`        // searches must select an index other than index=*
+}
+
+// LoadSystemFile reads and validates a system-wide config file
+func LoadSystemFile(path string) (*SystemFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f SystemFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: invalid config: %w", path, err)
+	}
+	if !f.DefaultPolicy.valid() {
+		return nil, fmt.Errorf("%s: default_policy: limits can't be negative", path)
+	}
+	for name, p := range f.Policies {
+		if !p.valid() {
+			return nil, fmt.Errorf("%s: policy %s: limits can't be negative", path, name)
+		}
+	}
+	return &f, nil
+}
+
+// Policy returns the policy for a connection to host through the named profile, combining the
+// default policy, the profile's policy and the policies of host into the strictest of their limits
+func (f *SystemFile) Policy(profile, host string) Policy {
+	policy := f.DefaultPolicy.tighten(Policy{})
+	for name, p := range f.Policies {
+		if (name == profile && profile != "") || (p.Host != "" && p.Host == host) {
+			policy = policy.tighten(p)
+		}
+	}
+	return policy
+}
+
+// Connections returns the number of connections to use when requested are asked for
+func (p Policy) Connections(requested int) int {
+	if p.MaxConnections > 0 && requested > p.MaxConnections {
+		return p.MaxConnections
+	}
+	return requested
+}
+
+func (p Policy) valid() bool {
+	return p.MaxConnections >= 0 && p.MaxRequestsPerSecond >= 0 && p.MaxResults >= 0 &&
+		p.MaxTimeRange >= 0 && p.MaxEstimatedEvents >= 0
+}
+
+// tighten returns the lower of each limit of p and other
+func (p Policy) tighten(other Policy) Policy {
+	return Policy{
+		MaxConnections:       lowestLimit(p.MaxConnections, other.MaxConnections),
+		MaxRequestsPerSecond: lowestLimit(p.MaxRequestsPerSecond, other.MaxRequestsPerSecond),
+		MaxResults:           lowestLimit(p.MaxResults, other.MaxResults),
+		MaxTimeRange:         lowestLimit(p.MaxTimeRange, other.MaxTimeRange),
+		MaxEstimatedEvents:   lowestLimit(p.MaxEstimatedEvents, other.MaxEstimatedEvents),
+		RequireIndex:         p.RequireIndex || other.RequireIndex,
+	}
+}
+
+// lowestLimit returns the lower of two limits where 0 is unlimited
+func lowestLimit[T int | float64 | Duration](a, b T) T {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSystemFilePolicy(t *testing.T) {
+	f, err := LoadSystemFile("testdata/system.yaml")
+	if err != nil {
+		t.Fatalf("LoadSystemFile returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		profile  string
+		host     string
+		expected Policy
+	}{
+		{"default", "", "localhost", Policy{MaxConnections: 8, MaxRequestsPerSecond: 50}},
+		{"profile", "prod", "localhost", Policy{MaxConnections: 4, MaxRequestsPerSecond: 50, MaxResults: 1000000}},
+		{"host", "dev", "splunk.example.com", Policy{MaxConnections: 8, MaxRequestsPerSecond: 10}},
+		{"profile and host", "prod", "splunk.example.com", Policy{MaxConnections: 4, MaxRequestsPerSecond: 10, MaxResults: 1000000}},
+		{"guardrails", "analysts", "localhost", Policy{MaxConnections: 8, MaxRequestsPerSecond: 50,
+			MaxTimeRange: Duration(30 * 24 * time.Hour), MaxEstimatedEvents: 50000000, RequireIndex: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if policy := f.Policy(tt.profile, tt.host); policy != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, policy)
+			}
+		})
+	}
+
+	policy := f.Policy("prod", "")
+	if policy.Connections(16) != 4 || policy.Connections(2) != 2 {
+		t.Errorf("Expected connections to be capped at 4, got %d and %d", policy.Connections(16), policy.Connections(2))
+	}
+	if (Policy{}).Connections(16) != 16 {
+		t.Error("Expected no cap without a limit")
+	}
+}
+
+func TestLoadSystemFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("policies:\n  prod:\n    max_results: -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadSystemFile(path)
+	if err == nil || !strings.Contains(err.Error(), "can't be negative") {
+		t.Errorf("Expected an error for a negative limit, got %v", err)
+	}
+}
+
+func TestLoadSystemFileInvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("default_policy:\n  max_time_range: a month\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSystemFile(path); err == nil {
+		t.Error("Expected an error for an invalid max_time_range")
+	}
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cschmidt0121/spldl/internal/yaml"
+)
+
+const defaultPort = 8089
+
+// File is the spldl config file, holding named connection profiles
+type File struct {
+	Version        int                `yaml:"version"`         // the layout of the file, see FileVersion
+	DefaultProfile string             `yaml:"default_profile"` // used when no profile is selected
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a named set of connection settings
+type Profile struct {
+	Host           string `yaml:"host"`
+	Port           int    `yaml:"port"`
+	Auth           string `yaml:"auth"` // token or basic, empty to use whichever credentials are set
+	Token          string `yaml:"token"`
+	Username       string `yaml:"username"`
+	Password       string `yaml:"password"`
+	Insecure       bool   `yaml:"insecure"`        // skip TLS verification
+	CAFile         string `yaml:"ca_file"`         // PEM file with the CA certificates to trust instead of the system's
+	Proxy          string `yaml:"proxy"`           // http, https or socks5 proxy URL, overriding HTTP_PROXY and HTTPS_PROXY
+	TLSMinVersion  string `yaml:"tls_min_version"` // 1.0 to 1.3
+	TLSServerName  string `yaml:"tls_server_name"` // name to verify the certificate against instead of host
+	MaxConnections int    `yaml:"max_connections"`
+
+	TokenFile         string `yaml:"token_file"`         // file holding the token, e.g. a mounted secret
+	PasswordFile      string `yaml:"password_file"`      // file holding the password
+	CredentialCommand string `yaml:"credential_command"` // command printing the token
+}
+
+// HasSecrets reports whether the profile stores credentials
+func (p Profile) HasSecrets() bool {
+	return p.Token != "" || p.Password != ""
+}
+
+// DefaultConfigPath returns the config file location, $XDG_CONFIG_HOME/spldl/config.yaml or
+// ~/.config/spldl/config.yaml when XDG_CONFIG_HOME isn't set
+func DefaultConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "spldl", "config.yaml"), nil
+}
+
+// DefaultStateDir returns where spldl keeps the state of its runs, $XDG_STATE_HOME/spldl or
+// ~/.local/state/spldl when XDG_STATE_HOME isn't set
+func DefaultStateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "spldl"), nil
+}
+
+// LoadFile reads and validates a config file
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files of an older layout are migrated in memory, spldl config migrate rewrites them
+	tree, version, err := parseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid config: %w", path, err)
+	}
+	if version != FileVersion {
+		slog.Debug("Migrated config file of an older version", "path", path, "version", version)
+	}
+	var f File
+	if err := yaml.Decode(tree, &f); err != nil {
+		return nil, fmt.Errorf("%s: invalid config: %w", path, err)
+	}
+	for name, p := range f.Profiles {
+		if p.Auth != "" && p.Auth != "token" && p.Auth != "basic" {
+			return nil, fmt.Errorf("%s: profile %s: auth must be token or basic, got %q", path, name, p.Auth)
+		}
+	}
+	if f.DefaultProfile != "" {
+		if _, ok := f.Profiles[f.DefaultProfile]; !ok {
+			return nil, fmt.Errorf("%s: default_profile %s is not defined", path, f.DefaultProfile)
+		}
+	}
+	return &f, nil
+}
+
+// Profile returns the named profile, or the default profile when name is empty. Without a default
+// profile an empty profile is returned.
+func (f *File) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(f.Profiles))
+		for n := range f.Profiles {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return Profile{}, fmt.Errorf("profile %s is not defined (available: %s)", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// ClientFlags holds the connection settings given on the command line. Empty strings and nil
+// pointers are settings that weren't given.
+type ClientFlags struct {
+	Host     string
+	Port     *int
+	Token    string
+	Username string
+	Password string
+	Insecure *bool
+	CAFile   string
+	Proxy    string
+
+	TokenFile         string
+	PasswordFile      string
+	CredentialCommand string // run with sh -c, its stdout is the token
+
+	TLSMinVersion string
+	TLSServerName string
+}
+
+// ClientSources are the sources LoadClientConfig merges. Each setting is taken from the first
+// source that provides it, in this order:
+//
+//  1. command line flags
+//  2. the SPLUNK_TOKEN, SPLUNK_TOKEN_FILE, SPLUNK_USERNAME, SPLUNK_PASSWORD and SPLUNK_PASSWORD_FILE
+//     environment variables
+//  3. the selected profile
+//  4. the credentials stored in the keyring by spldl auth login
+//  5. the defaults: port 8089 with TLS verification
+//
+// A token takes precedence over a username and password, regardless of their sources. A profile
+// with auth set only contributes the credentials of that method. Within a source, a secret given
+// directly wins over its file, which wins over the credential command. Only the secret file or
+// credential command that is used is read or run.
+type ClientSources struct {
+	Flags   ClientFlags
+	Getenv  func(string) string // nil to ignore the environment
+	Profile Profile
+	Keyring func() (StoredCredentials, error) // nil to ignore the keyring, only called when no other source has credentials
+}
+
+// StoredCredentials are the credentials spldl auth login keeps in the keyring for a profile, either a
+// token or a username and password
+type StoredCredentials struct {
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// LoadClientConfig merges the sources into a client config
+func LoadClientConfig(src ClientSources) (ClientConfig, error) {
+	getenv := src.Getenv
+	if getenv == nil {
+		getenv = func(string) string { return "" }
+	}
+	p := src.Profile
+
+	profileToken := firstSet(secret{value: p.Token}, secret{file: p.TokenFile}, secret{command: p.CredentialCommand})
+	profilePassword := firstSet(secret{value: p.Password}, secret{file: p.PasswordFile})
+	profileUsername := p.Username
+	switch p.Auth {
+	case "token":
+		profileUsername, profilePassword = "", secret{}
+	case "basic":
+		profileToken = secret{}
+	}
+
+	cfg := ClientConfig{
+		Host:      firstSet(src.Flags.Host, p.Host),
+		Port:      firstSet(deref(src.Flags.Port), p.Port, defaultPort),
+		UseTLS:    true,
+		VerifyTLS: !p.Insecure,
+	}
+	if src.Flags.Insecure != nil {
+		cfg.VerifyTLS = !*src.Flags.Insecure
+	}
+
+	token, err := firstSet(
+		secret{value: src.Flags.Token}, secret{file: src.Flags.TokenFile}, secret{command: src.Flags.CredentialCommand},
+		secret{value: getenv("SPLUNK_TOKEN")}, secret{file: getenv("SPLUNK_TOKEN_FILE")},
+		profileToken).resolve()
+	if err != nil {
+		return ClientConfig{}, err
+	}
+	username := firstSet(src.Flags.Username, getenv("SPLUNK_USERNAME"), profileUsername)
+	password := ""
+	if token == "" && username != "" {
+		password, err = firstSet(
+			secret{value: src.Flags.Password}, secret{file: src.Flags.PasswordFile},
+			secret{value: getenv("SPLUNK_PASSWORD")}, secret{file: getenv("SPLUNK_PASSWORD_FILE")},
+			profilePassword).resolve()
+		if err != nil {
+			return ClientConfig{}, err
+		}
+	}
+	if token == "" && (username == "" || password == "") && src.Keyring != nil {
+		stored, err := src.Keyring()
+		if err != nil {
+			return ClientConfig{}, fmt.Errorf("error reading credentials from the keyring: %w", err)
+		}
+		// A stored password only applies to the user it was stored for
+		if stored.Token != "" {
+			token = stored.Token
+		} else if username == "" || username == stored.Username {
+			username, password = stored.Username, stored.Password
+		}
+	}
+	if token != "" {
+		cfg.Auth = AuthConfig{Type: AuthToken, Token: token}
+	} else if username != "" && password != "" {
+		cfg.Auth = AuthConfig{Type: AuthHTTPBasic, Username: username, Password: password}
+	} else {
+		return ClientConfig{}, errors.New("No authentication method provided. Use spldl --help for more information.")
+	}
+
+	if caFile := firstSet(src.Flags.CAFile, p.CAFile); caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return ClientConfig{}, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if version := firstSet(src.Flags.TLSMinVersion, p.TLSMinVersion); version != "" {
+		v, err := ParseTLSVersion(version)
+		if err != nil {
+			return ClientConfig{}, err
+		}
+		cfg.TLSMinVersion = v
+	}
+	cfg.TLSServerName = firstSet(src.Flags.TLSServerName, p.TLSServerName)
+
+	if proxy := firstSet(src.Flags.Proxy, p.Proxy); proxy != "" {
+		u, err := ParseProxy(proxy)
+		if err != nil {
+			return ClientConfig{}, err
+		}
+		cfg.Proxy = u
+	}
+	return cfg, nil
+}
+
+// ParseTLSVersion parses a TLS version such as 1.2 into its tls.Version* constant
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(version), "tls") {
+	case "1.0", "1":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid TLS version %q: use 1.0, 1.1, 1.2 or 1.3", version)
+	}
+}
+
+// ParseProxy parses a proxy URL. Supported schemes are http, https, socks5 and socks5h.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: the scheme must be http, https, socks5 or socks5h", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: no host", raw)
+	}
+	return u, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// firstSet returns the first non-zero value
+func firstSet[T comparable](values ...T) T {
+	var zero T
+	for _, v := range values {
+		if v != zero {
+			return v
+		}
+	}
+	return zero
+}
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	f, err := LoadFile("testdata/config.yaml")
+	if err != nil {
+		t.Fatalf("LoadFile returned error: %v", err)
+	}
+
+	dev, err := f.Profile("")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	if dev.Host != "localhost" || !dev.Insecure {
+		t.Errorf("Expected the default dev profile, got %+v", dev)
+	}
+
+	prod, err := f.Profile("prod")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	if prod.Port != 443 || prod.MaxConnections != 16 || prod.Auth != "token" || prod.Proxy != "socks5://bastion.example.com:1080" || prod.TLSMinVersion != "1.3" {
+		t.Errorf("Unexpected prod profile %+v", prod)
+	}
+
+	_, err = f.Profile("staging")
+	if err == nil || !strings.Contains(err.Error(), "available: dev, prod") {
+		t.Errorf("Expected an error listing the profiles, got %v", err)
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{"unknown field", "profiles:\n  dev:\n    hostname: x\n", "field hostname not found"},
+		{"bad auth", "profiles:\n  dev:\n    auth: saml\n", "auth must be token or basic"},
+		{"missing default", "default_profile: prod\n", "default_profile prod is not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadClientConfigPrecedence(t *testing.T) {
+	profile := Profile{
+		Host:     "profile.example.com",
+		Port:     443,
+		Auth:     "token",
+		Token:    "profile-token",
+		Username: "profile-user",
+		Password: "profile-password",
+		Insecure: true,
+	}
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	// Profile only; auth token drops the profile's username and password
+	cfg, err := LoadClientConfig(ClientSources{Getenv: getenv, Profile: profile})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Host != "profile.example.com" || cfg.Port != 443 || cfg.VerifyTLS {
+		t.Errorf("Expected the profile's connection settings, got %+v", cfg)
+	}
+	if cfg.Auth.Type != AuthToken || cfg.Auth.Token != "profile-token" {
+		t.Errorf("Expected the profile token, got %+v", cfg.Auth)
+	}
+
+	// The environment overrides the profile
+	env["SPLUNK_TOKEN"] = "env-token"
+	cfg, err = LoadClientConfig(ClientSources{Getenv: getenv, Profile: profile})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Token != "env-token" {
+		t.Errorf("Expected the environment token, got %q", cfg.Auth.Token)
+	}
+
+	// Flags override both
+	port := 8090
+	insecure := false
+	cfg, err = LoadClientConfig(ClientSources{
+		Flags:   ClientFlags{Host: "flag.example.com", Port: &port, Insecure: &insecure, Token: "flag-token"},
+		Getenv:  getenv,
+		Profile: profile,
+	})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Host != "flag.example.com" || cfg.Port != 8090 || !cfg.VerifyTLS || cfg.Auth.Token != "flag-token" {
+		t.Errorf("Expected the flag settings, got %+v", cfg)
+	}
+
+	// Defaults apply when nothing is set, and credentials are required
+	_, err = LoadClientConfig(ClientSources{})
+	if err == nil {
+		t.Error("Expected an error without credentials")
+	}
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Username: "admin", Password: "changeme"}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Port != 8089 || !cfg.VerifyTLS || cfg.Auth.Type != AuthHTTPBasic {
+		t.Errorf("Expected defaults with basic auth, got %+v", cfg)
+	}
+}
+
+func TestLoadClientConfigProxy(t *testing.T) {
+	profile := Profile{Token: "token", Proxy: "socks5://bastion.example.com:1080"}
+
+	cfg, err := LoadClientConfig(ClientSources{Profile: profile})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Proxy == nil || cfg.Proxy.String() != "socks5://bastion.example.com:1080" {
+		t.Errorf("Expected the profile's proxy, got %v", cfg.Proxy)
+	}
+
+	// The flag overrides the profile
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Proxy: "http://proxy.example.com:3128"}, Profile: profile})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Proxy == nil || cfg.Proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("Expected the flag's proxy, got %v", cfg.Proxy)
+	}
+
+	// Without a proxy, the environment's HTTP_PROXY and HTTPS_PROXY apply
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Token: "token"}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Proxy != nil {
+		t.Errorf("Expected no proxy, got %v", cfg.Proxy)
+	}
+
+	for _, invalid := range []string{"ftp://proxy.example.com", "proxy.example.com:3128", "socks5://"} {
+		_, err := LoadClientConfig(ClientSources{Flags: ClientFlags{Token: "token", Proxy: invalid}})
+		if err == nil {
+			t.Errorf("Expected an error for proxy %q", invalid)
+		}
+	}
+}
+
+func TestLoadClientConfigTLS(t *testing.T) {
+	profile := Profile{Token: "token", TLSMinVersion: "1.3", TLSServerName: "splunk.internal"}
+
+	cfg, err := LoadClientConfig(ClientSources{Profile: profile})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.TLSMinVersion != tls.VersionTLS13 || cfg.TLSServerName != "splunk.internal" {
+		t.Errorf("Expected the profile's TLS settings, got %+v", cfg)
+	}
+
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{TLSMinVersion: "tls1.2", TLSServerName: "flag.internal"}, Profile: profile})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.TLSMinVersion != tls.VersionTLS12 || cfg.TLSServerName != "flag.internal" {
+		t.Errorf("Expected the flags' TLS settings, got %+v", cfg)
+	}
+
+	_, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Token: "token", TLSMinVersion: "1.4"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid TLS version") {
+		t.Errorf("Expected an invalid TLS version error, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secret is a credential given directly, in a file or as the output of a command. At most one of
+// the fields is set, and the zero secret is one that wasn't given.
+type secret struct {
+	value   string
+	file    string // read with trailing whitespace trimmed
+	command string // run with sh -c, its stdout with trailing whitespace trimmed is the secret
+}
+
+// resolve returns the secret, reading its file or running its command
+func (s secret) resolve() (string, error) {
+	switch {
+	case s.file != "":
+		return ReadSecretFile(s.file)
+	case s.command != "":
+		return RunCredentialCommand(s.command)
+	default:
+		return s.value, nil
+	}
+}
+
+// ReadSecretFile reads a secret from a file, such as a mounted CI secret. The trailing newline most
+// editors add is dropped.
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %w", err)
+	}
+	value := strings.TrimRight(string(data), " \t\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// RunCredentialCommand runs a credential helper with sh -c and returns what it prints to stdout. Its
+// stderr goes to spldl's, so that helpers can prompt or explain failures.
+func RunCredentialCommand(command string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("credential command failed with exit code %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return "", fmt.Errorf("error running credential command: %w", err)
+	}
+	value := strings.TrimRight(stdout.String(), " \t\r\n")
+	if value == "" {
+		return "", errors.New("credential command printed nothing")
+	}
+	return value, nil
+}
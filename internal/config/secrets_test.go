@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadClientConfigSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(passwordFile, []byte("file-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	cfg, err := LoadClientConfig(ClientSources{Flags: ClientFlags{TokenFile: tokenFile}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Type != AuthToken || cfg.Auth.Token != "file-token" {
+		t.Errorf("Expected the token from the file without its newline, got %+v", cfg.Auth)
+	}
+
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Username: "admin", PasswordFile: passwordFile}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Type != AuthHTTPBasic || cfg.Auth.Password != "file-password" {
+		t.Errorf("Expected the password from the file, got %+v", cfg.Auth)
+	}
+
+	// SPLUNK_TOKEN_FILE overrides the profile, and the profile's missing file is never read
+	env["SPLUNK_TOKEN_FILE"] = tokenFile
+	cfg, err = LoadClientConfig(ClientSources{Getenv: getenv, Profile: Profile{TokenFile: filepath.Join(dir, "missing")}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Token != "file-token" {
+		t.Errorf("Expected the token from SPLUNK_TOKEN_FILE, got %q", cfg.Auth.Token)
+	}
+
+	// SPLUNK_TOKEN wins over SPLUNK_TOKEN_FILE
+	env["SPLUNK_TOKEN"] = "env-token"
+	cfg, err = LoadClientConfig(ClientSources{Getenv: getenv})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Token != "env-token" {
+		t.Errorf("Expected the token from SPLUNK_TOKEN, got %q", cfg.Auth.Token)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{empty, filepath.Join(dir, "missing")} {
+		if _, err := LoadClientConfig(ClientSources{Flags: ClientFlags{TokenFile: path}}); err == nil {
+			t.Errorf("Expected an error for token file %s", path)
+		}
+	}
+}
+
+func TestLoadClientConfigCredentialCommand(t *testing.T) {
+	cfg, err := LoadClientConfig(ClientSources{Flags: ClientFlags{CredentialCommand: "echo command-token"}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Type != AuthToken || cfg.Auth.Token != "command-token" {
+		t.Errorf("Expected the token printed by the command, got %+v", cfg.Auth)
+	}
+
+	// The profile's command only runs when nothing else provides a token
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Token: "flag-token"}, Profile: Profile{CredentialCommand: "exit 1"}})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Token != "flag-token" {
+		t.Errorf("Expected the flag token, got %q", cfg.Auth.Token)
+	}
+
+	for _, command := range []string{"exit 3", "true"} {
+		if _, err := LoadClientConfig(ClientSources{Profile: Profile{CredentialCommand: command}}); err == nil {
+			t.Errorf("Expected an error for credential command %q", command)
+		}
+	}
+}
+
+func TestLoadClientConfigKeyring(t *testing.T) {
+	calls := 0
+	stored := StoredCredentials{Username: "admin", Password: "keyring-password"}
+	keyring := func() (StoredCredentials, error) {
+		calls++
+		return stored, nil
+	}
+
+	cfg, err := LoadClientConfig(ClientSources{Keyring: keyring})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Type != AuthHTTPBasic || cfg.Auth.Username != "admin" || cfg.Auth.Password != "keyring-password" {
+		t.Errorf("Expected the stored username and password, got %+v", cfg.Auth)
+	}
+
+	// The stored password belongs to admin only
+	_, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Username: "someone"}, Keyring: keyring})
+	if err == nil {
+		t.Error("Expected an error for another user without a password")
+	}
+
+	// The keyring isn't read when credentials are given
+	calls = 0
+	cfg, err = LoadClientConfig(ClientSources{Flags: ClientFlags{Token: "flag-token"}, Keyring: keyring})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Token != "flag-token" || calls != 0 {
+		t.Errorf("Expected the flag token without reading the keyring, got %+v after %d calls", cfg.Auth, calls)
+	}
+
+	stored = StoredCredentials{Token: "keyring-token"}
+	cfg, err = LoadClientConfig(ClientSources{Profile: Profile{Username: "admin"}, Keyring: keyring})
+	if err != nil {
+		t.Fatalf("LoadClientConfig returned error: %v", err)
+	}
+	if cfg.Auth.Type != AuthToken || cfg.Auth.Token != "keyring-token" {
+		t.Errorf("Expected the stored token, got %+v", cfg.Auth)
+	}
+}
@@ -0,0 +1,408 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/cschmidt0121/spldl/internal/downloader"
+)
+
+// record is a single result with its field names in the order they were read
+type record struct {
+	fields []string
+	values map[string]any
+}
+
+type recordReader interface {
+	// next returns io.EOF once every record has been read
+	next() (record, error)
+}
+
+// chunkSize is about how much is converted before it's written to the output, which takes whole results
+const chunkSize = 1 << 20
+
+// Convert re-shapes a previously downloaded results file from one output mode (raw, ndjson, csv) to
+// another. Input and output are read and written like the outputs of a download: compressed as their
+// extension says, and in the file format it names, such as .json, .tsv, .xlsx or .parquet, which
+// decides the output mode instead of outMode.
+func Convert(inPath, inMode, outPath, outMode string) (err error) {
+	if mode, ok := downloader.ResultsFileMode(outPath); ok {
+		outMode = mode
+	}
+	slog.Debug("Converting results file", "input", inPath, "input_mode", inMode, "output", outPath, "output_mode", outMode)
+
+	var fields []string
+	if outMode == "csv" {
+		// CSV needs its header before the first row, so collect every field up front
+		fields, err = collectFields(inPath, inMode)
+		if err != nil {
+			return fmt.Errorf("failed to collect fields: %w", err)
+		}
+	}
+
+	reader, closeInput, err := openRecordReader(inPath, inMode)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	output, err := downloader.CreateResultsFile(outPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			output.Abort()
+		}
+	}()
+
+	writer, err := newRecordWriter(output, outMode, fields)
+	if err != nil {
+		return err
+	}
+
+	converted, skipped := 0, 0
+	for {
+		rec, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record %d: %w", converted+skipped+1, err)
+		}
+		if outMode == "raw" {
+			if _, ok := rec.values["_raw"]; !ok {
+				skipped++
+				continue
+			}
+		}
+		if err := writer.write(rec); err != nil {
+			return fmt.Errorf("failed to write record %d: %w", converted+skipped+1, err)
+		}
+		converted++
+	}
+
+	if skipped > 0 {
+		slog.Warn("Skipped records without a _raw field", "skipped", skipped)
+	}
+	slog.Debug("Conversion completed", "records", converted)
+
+	if err := writer.flush(); err != nil {
+		return err
+	}
+	return output.Commit()
+}
+
+func collectFields(path, mode string) ([]string, error) {
+	reader, closeInput, err := openRecordReader(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+
+	var fields []string
+	seen := make(map[string]bool)
+	for {
+		rec, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range rec.fields {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+}
+
+// openRecordReader opens a results file in the mode, or in the file format its extension names, and
+// returns a reader of its records and a function closing the file
+func openRecordReader(path, mode string) (recordReader, func() error, error) {
+	ext := downloader.ResultsExt(path)
+	if ext == ".parquet" {
+		return openParquetReader(path)
+	}
+	if format, ok := downloader.FileFormatFor(path); ok && ext != ".json" {
+		return nil, nil, fmt.Errorf("%s files can't be read, convert the results they were written from instead", format.Name)
+	}
+	file, err := downloader.OpenResultsFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reader recordReader
+	switch {
+	case ext == ".json":
+		reader = &jsonArrayReader{decoder: json.NewDecoder(file)}
+	case ext == ".tsv":
+		reader = &csvReader{reader: newCSVReader(file, '\t')}
+	case mode == "raw":
+		reader = &rawReader{scanner: newLineScanner(file)}
+	case mode == "ndjson":
+		reader = &ndjsonReader{scanner: newLineScanner(file)}
+	case mode == "csv":
+		reader = &csvReader{reader: newCSVReader(file, ',')}
+	default:
+		file.Close()
+		return nil, nil, fmt.Errorf("unsupported input format %q", mode)
+	}
+	return reader, file.Close, nil
+}
+
+// newRecordWriter returns a writer of records in the mode to output. ndjson and csv are encoded with
+// the mode's record codec.
+func newRecordWriter(output *downloader.ResultsFile, mode string, fields []string) (*recordWriter, error) {
+	w := &recordWriter{output: output, fields: fields}
+	if mode == "raw" {
+		return w, nil
+	}
+	codec, ok := downloader.LookupRecordCodec(mode)
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format %q", mode)
+	}
+	w.codec = codec
+	if fields != nil {
+		header := make([]downloader.Field, len(fields))
+		for i, field := range fields {
+			header[i] = downloader.Field{Name: field}
+		}
+		encoded, err := codec.Encode(header, true)
+		if err != nil {
+			return nil, err
+		}
+		w.chunk.WriteString(encoded)
+	}
+	return w, nil
+}
+
+func newCSVReader(r io.Reader, delimiter rune) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	return reader
+}
+
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// Events can be much larger than bufio's default 64KB line limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	return scanner
+}
+
+type rawReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *rawReader) next() (record, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return record{}, err
+		}
+		return record{}, io.EOF
+	}
+	return record{
+		fields: []string{"_raw"},
+		values: map[string]any{"_raw": r.scanner.Text()},
+	}, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *ndjsonReader) next() (record, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return decodeOrdered(line)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+// jsonArrayReader reads the results of a .json file, a JSON array of objects
+type jsonArrayReader struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func (r *jsonArrayReader) next() (record, error) {
+	if !r.started {
+		r.started = true
+		token, err := r.decoder.Token()
+		if err != nil {
+			return record{}, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return record{}, fmt.Errorf("expected a JSON array, got %v", token)
+		}
+	}
+	if !r.decoder.More() {
+		return record{}, io.EOF
+	}
+	var result json.RawMessage
+	if err := r.decoder.Decode(&result); err != nil {
+		return record{}, err
+	}
+	return decodeOrdered(result)
+}
+
+// decodeOrdered decodes a JSON object while keeping track of the order of its keys
+func decodeOrdered(data []byte) (record, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return record{}, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return record{}, fmt.Errorf("expected a JSON object, got %v", token)
+	}
+
+	rec := record{values: make(map[string]any)}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return record{}, err
+		}
+		key := token.(string)
+
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return record{}, err
+		}
+		if _, exists := rec.values[key]; !exists {
+			rec.fields = append(rec.fields, key)
+		}
+		rec.values[key] = value
+	}
+	return rec, nil
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func (r *csvReader) next() (record, error) {
+	if r.header == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			return record{}, err
+		}
+		r.header = header
+	}
+
+	row, err := r.reader.Read()
+	if err != nil {
+		return record{}, err
+	}
+
+	rec := record{values: make(map[string]any)}
+	for i, field := range r.header {
+		// Splunk leaves fields that don't exist for an event empty
+		if i >= len(row) || row[i] == "" {
+			continue
+		}
+		rec.fields = append(rec.fields, field)
+		rec.values[field] = row[i]
+	}
+	return rec, nil
+}
+
+// recordWriter writes records to the output in chunks of whole results
+type recordWriter struct {
+	output *downloader.ResultsFile
+	codec  downloader.RecordCodec // nil for raw output, which is the _raw of each record
+	fields []string               // every record's fields in the order of the header, nil to write each record's own
+	chunk  strings.Builder
+}
+
+func (w *recordWriter) write(rec record) error {
+	if w.codec == nil {
+		w.chunk.WriteString(formatValue(rec.values["_raw"]))
+		w.chunk.WriteByte('\n')
+		return w.flushFull()
+	}
+
+	fields := w.fields
+	if fields == nil {
+		fields = rec.fields
+	}
+	encoded := make([]downloader.Field, len(fields))
+	for i, field := range fields {
+		value, ok := rec.values[field]
+		if !ok {
+			encoded[i] = downloader.Field{Name: field, Absent: true}
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		encoded[i] = downloader.Field{Name: field, Value: formatValue(value), Encoded: string(raw)}
+	}
+	data, err := w.codec.Encode(encoded, false)
+	if err != nil {
+		return err
+	}
+	w.chunk.WriteString(data)
+	return w.flushFull()
+}
+
+// flushFull writes the chunk once it's grown to chunkSize
+func (w *recordWriter) flushFull() error {
+	if w.chunk.Len() < chunkSize {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes what's left of the chunk
+func (w *recordWriter) flush() error {
+	if w.chunk.Len() == 0 {
+		return nil
+	}
+	_, err := w.output.WriteString(w.chunk.String())
+	w.chunk.Reset()
+	return err
+}
+
+// formatValue renders a field value the way Splunk does in CSV output
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case []any:
+		// Splunk joins multivalue fields with newlines
+		parts := make([]string, len(v))
+		for i, part := range v {
+			parts[i] = formatValue(part)
+		}
+		return strings.Join(parts, "\n")
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
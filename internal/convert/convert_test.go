@@ -0,0 +1,206 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	ndjsonInput := `{"_time":"2025-08-26T01:47:51.000+00:00","host":"web01","_raw":"foo"}
+{"_time":"2025-08-26T01:47:52.000+00:00","host":"web02","status":404,"tag":["web","error"],"_raw":"bar"}
+`
+	csvInput := "\"_time\",host,\"_raw\"\n\"2025-08-26T01:47:51.000+00:00\",web01,foo\n\"2025-08-26T01:47:52.000+00:00\",web02,bar\n"
+
+	tests := []struct {
+		name       string
+		input      string
+		inputMode  string
+		outputMode string
+		expected   string
+	}{
+		{
+			name:       "ndjson to csv",
+			input:      ndjsonInput,
+			inputMode:  "ndjson",
+			outputMode: "csv",
+			expected:   "_time,host,_raw,status,tag\n2025-08-26T01:47:51.000+00:00,web01,foo,,\n2025-08-26T01:47:52.000+00:00,web02,bar,404,\"web\nerror\"\n",
+		},
+		{
+			name:       "ndjson to raw",
+			input:      ndjsonInput,
+			inputMode:  "ndjson",
+			outputMode: "raw",
+			expected:   "foo\nbar\n",
+		},
+		{
+			name:       "csv to ndjson",
+			input:      csvInput,
+			inputMode:  "csv",
+			outputMode: "ndjson",
+			expected:   "{\"_time\":\"2025-08-26T01:47:51.000+00:00\",\"host\":\"web01\",\"_raw\":\"foo\"}\n{\"_time\":\"2025-08-26T01:47:52.000+00:00\",\"host\":\"web02\",\"_raw\":\"bar\"}\n",
+		},
+		{
+			name:       "raw to ndjson",
+			input:      "foo\nbar\n",
+			inputMode:  "raw",
+			outputMode: "ndjson",
+			expected:   "{\"_raw\":\"foo\"}\n{\"_raw\":\"bar\"}\n",
+		},
+		{
+			name:       "ndjson to ndjson keeps field order",
+			input:      ndjsonInput,
+			inputMode:  "ndjson",
+			outputMode: "ndjson",
+			expected:   "{\"_time\":\"2025-08-26T01:47:51.000+00:00\",\"host\":\"web01\",\"_raw\":\"foo\"}\n{\"_time\":\"2025-08-26T01:47:52.000+00:00\",\"host\":\"web02\",\"status\":404,\"tag\":[\"web\",\"error\"],\"_raw\":\"bar\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inPath := filepath.Join(dir, "input")
+			outPath := filepath.Join(dir, "output")
+
+			if err := os.WriteFile(inPath, []byte(tt.input), 0o644); err != nil {
+				t.Fatalf("Failed to write input file: %v", err)
+			}
+
+			err := Convert(inPath, tt.inputMode, outPath, tt.outputMode)
+			if err != nil {
+				t.Fatalf("Convert returned an error: %v", err)
+			}
+
+			output, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(output) != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, string(output))
+			}
+		})
+	}
+}
+
+func TestConvertUnsupportedFormat(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.ndjson")
+	if err := os.WriteFile(inPath, []byte("{}\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+
+	err := Convert(inPath, "ndjson", filepath.Join(dir, "output.xml"), "xml")
+	if err == nil {
+		t.Error("Expected an error for an unsupported output format, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "output.xml.part")); !os.IsNotExist(err) {
+		t.Errorf("Expected the part file to be removed, got %v", err)
+	}
+}
+
+func TestConvertFailureRemovesPartFile(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.ndjson")
+	outPath := filepath.Join(dir, "output.csv")
+	if err := os.WriteFile(inPath, []byte("{\"_raw\":\"foo\"}\nnot json\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+	if err := Convert(inPath, "ndjson", filepath.Join(dir, "output.ndjson"), "ndjson"); err == nil {
+		t.Fatal("Expected an error for malformed input")
+	}
+	if err := Convert(inPath, "ndjson", outPath, "csv"); err == nil {
+		t.Fatal("Expected an error for malformed input")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the input to be left, got %v", entries)
+	}
+}
+
+func TestConvertFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.ndjson")
+	input := "{\"_time\":\"2025-08-26T01:47:51.000+00:00\",\"host\":\"web01\",\"_raw\":\"foo\"}\n{\"_time\":\"2025-08-26T01:47:52.000+00:00\",\"status\":\"404\",\"_raw\":\"bar\"}\n"
+	if err := os.WriteFile(inPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+
+	// Every format is written and read back to ndjson, which has the fields in the order of the
+	// intermediate file
+	tests := []struct {
+		name     string
+		filename string
+		expected string
+	}{
+		{"JSON array", "output.json", input},
+		{"tsv", "output.tsv", "{\"_time\":\"2025-08-26T01:47:51.000+00:00\",\"host\":\"web01\",\"_raw\":\"foo\"}\n{\"_time\":\"2025-08-26T01:47:52.000+00:00\",\"_raw\":\"bar\",\"status\":\"404\"}\n"},
+		{"Parquet", "output.parquet", "{\"_raw\":\"foo\",\"_time\":\"2025-08-26T01:47:51.000+00:00\",\"host\":\"web01\"}\n{\"_raw\":\"bar\",\"_time\":\"2025-08-26T01:47:52.000+00:00\",\"status\":\"404\"}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := filepath.Join(dir, tt.filename)
+			if err := Convert(inPath, "ndjson", formatted, "ndjson"); err != nil {
+				t.Fatalf("Convert to %s returned an error: %v", tt.filename, err)
+			}
+			back := formatted + ".ndjson"
+			if err := Convert(formatted, "ndjson", back, "ndjson"); err != nil {
+				t.Fatalf("Convert from %s returned an error: %v", tt.filename, err)
+			}
+			output, err := os.ReadFile(back)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if string(output) != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, string(output))
+			}
+		})
+	}
+
+	tsv, err := os.ReadFile(filepath.Join(dir, "output.tsv"))
+	if err != nil || string(tsv) != "_time\thost\t_raw\tstatus\n2025-08-26T01:47:51.000+00:00\tweb01\tfoo\t\n2025-08-26T01:47:52.000+00:00\t\tbar\t404\n" {
+		t.Errorf("Unexpected tsv %q, %v", tsv, err)
+	}
+
+	// Workbooks are written but can't be read
+	xlsx := filepath.Join(dir, "output.xlsx")
+	if err := Convert(inPath, "ndjson", xlsx, "csv"); err != nil {
+		t.Fatalf("Convert to xlsx returned an error: %v", err)
+	}
+	if err := Convert(xlsx, "csv", filepath.Join(dir, "back.csv"), "csv"); err == nil {
+		t.Error("Expected an error reading a workbook")
+	}
+}
+
+func TestConvertGzip(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.csv")
+	gzPath := filepath.Join(dir, "output.ndjson.gz")
+	outPath := filepath.Join(dir, "output.csv")
+	input := "host,_raw\nweb01,foo\n"
+	if err := os.WriteFile(inPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+
+	// Compressed on the way out and decompressed on the way back in
+	if err := Convert(inPath, "csv", gzPath, "ndjson"); err != nil {
+		t.Fatalf("Convert to gzip returned an error: %v", err)
+	}
+	if err := Convert(gzPath, "ndjson", outPath, "csv"); err != nil {
+		t.Fatalf("Convert from gzip returned an error: %v", err)
+	}
+
+	compressed, err := os.ReadFile(gzPath)
+	if err != nil {
+		t.Fatalf("Failed to read compressed file: %v", err)
+	}
+	if len(compressed) < 2 || compressed[0] != 0x1f || compressed[1] != 0x8b {
+		t.Errorf("Expected gzip output, got %q", compressed)
+	}
+	output, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if string(output) != input {
+		t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", input, string(output))
+	}
+}
@@ -0,0 +1,72 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetReader reads the rows of a Parquet file with flat columns, such as those spldl writes
+type parquetReader struct {
+	reader  *parquet.Reader
+	columns []string
+	rows    []parquet.Row
+}
+
+// openParquetReader opens a Parquet file, whose columns must not be nested
+func openParquetReader(path string) (recordReader, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := parquet.NewReader(file)
+	r := &parquetReader{reader: reader, rows: make([]parquet.Row, 1)}
+	for _, field := range reader.Schema().Fields() {
+		if !field.Leaf() || field.Repeated() {
+			reader.Close()
+			file.Close()
+			return nil, nil, fmt.Errorf("%s: nested and repeated Parquet columns such as %s aren't supported", path, field.Name())
+		}
+		r.columns = append(r.columns, field.Name())
+	}
+	closeFile := func() error {
+		reader.Close()
+		return file.Close()
+	}
+	return r, closeFile, nil
+}
+
+func (r *parquetReader) next() (record, error) {
+	n, err := r.reader.ReadRows(r.rows)
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return record{}, err
+	}
+	rec := record{values: make(map[string]any)}
+	for _, value := range r.rows[0] {
+		if value.IsNull() || value.Column() >= len(r.columns) {
+			continue
+		}
+		field := r.columns[value.Column()]
+		rec.fields = append(rec.fields, field)
+		rec.values[field] = parquetValue(value)
+	}
+	return rec, nil
+}
+
+// parquetValue returns a value as JSON would have it: numbers as numbers and bytes as strings
+func parquetValue(value parquet.Value) any {
+	switch value.Kind() {
+	case parquet.Boolean:
+		return value.Boolean()
+	case parquet.Int32, parquet.Int64, parquet.Float, parquet.Double:
+		return json.Number(value.String())
+	default:
+		return value.String()
+	}
+}
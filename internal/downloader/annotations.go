@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"slices"
+	"strings"
+)
+
+// isAnnotation reports whether a field is one of the annotations Splunk adds to events rather than
+// extracts from them: tags (tag and tag::<field>), event types and the punctuation pattern
+func isAnnotation(field string) bool {
+	return field == "tag" || strings.HasPrefix(field, "tag::") || field == "eventtype" || field == "punct"
+}
+
+// annotationFilter drops the annotation fields from events, keeping the rest of each event as it was
+type annotationFilter struct {
+	recordReader
+}
+
+// filter removes the annotation fields from a chunk of output
+func (a *annotationFilter) filter(data string, outputMode string) (string, error) {
+	records, err := a.records(data, outputMode, "dropping annotations")
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, record := range records {
+		// Most events carry no annotations at all and are copied as is
+		if !slices.ContainsFunc(record.Fields, func(field Field) bool { return isAnnotation(field.Name) }) {
+			sb.WriteString(record.Text)
+			continue
+		}
+		kept := slices.DeleteFunc(record.Fields, func(field Field) bool { return isAnnotation(field.Name) })
+		encoded, err := a.codec.Encode(kept, record.Header)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(encoded)
+	}
+	return sb.String(), nil
+}
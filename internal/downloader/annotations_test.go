@@ -0,0 +1,51 @@
+package downloader
+
+import "testing"
+
+func TestAnnotationFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		outputMode string
+		chunks     []string
+		expected   string
+	}{
+		{
+			name:       "ndjson keeps field order and values",
+			outputMode: "ndjson",
+			chunks: []string{
+				`{"_time":"2025-08-26T02:00:00.000+00:00","tag":["web","error"],"host":"web01","punct":"--_::","_raw":"a <b>"}` + "\n" +
+					`{"_time":"2025-08-26T02:00:01.000+00:00","host":"web02","_raw":"no annotations"}` + "\n",
+				`{"eventtype":"failed_login","tag::eventtype":"auth","user":"bob","tagline":"kept"}` + "\n",
+			},
+			expected: `{"_time":"2025-08-26T02:00:00.000+00:00","host":"web01","_raw":"a <b>"}` + "\n" +
+				`{"_time":"2025-08-26T02:00:01.000+00:00","host":"web02","_raw":"no annotations"}` + "\n" +
+				`{"user":"bob","tagline":"kept"}` + "\n",
+		},
+		{
+			name:       "csv drops the columns of every chunk",
+			outputMode: "csv",
+			chunks: []string{
+				"\"_time\",host,tag,punct,\"_raw\"\n2025-08-26T02:00:00.000+00:00,web01,\"web\nerror\",--_::,\"a, b\"\n",
+				"2025-08-26T02:00:01.000+00:00,web02,,,c\n",
+			},
+			expected: "_time,host,_raw\n2025-08-26T02:00:00.000+00:00,web01,\"a, b\"\n2025-08-26T02:00:01.000+00:00,web02,c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &annotationFilter{}
+			var output string
+			for _, chunk := range tt.chunks {
+				filtered, err := filter.filter(chunk, tt.outputMode)
+				if err != nil {
+					t.Fatalf("filter returned error: %v", err)
+				}
+				output += filtered
+			}
+			if output != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, output)
+			}
+		})
+	}
+}
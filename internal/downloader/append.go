@@ -0,0 +1,70 @@
+package downloader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// appendPart adds the part file to the end of the output and removes it. The CSV header of the part
+// is dropped when the output has one already, which it must match.
+func (f *fileDestination) appendPart() error {
+	part, err := os.Open(partPath(f.filename))
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	reader := bufio.NewReader(part)
+	if f.csv {
+		existing, err := firstLine(f.filename)
+		if err != nil {
+			return fmt.Errorf("failed to read the header of %s: %w", f.filename, err)
+		}
+		header, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		switch {
+		case existing == "":
+			// The output is new, so it gets the header
+			reader = bufio.NewReader(io.MultiReader(strings.NewReader(header), reader))
+		case header != "" && header != existing:
+			return fmt.Errorf("can't append results with columns %s to %s, which has columns %s",
+				strings.TrimSpace(header), f.filename, strings.TrimSpace(existing))
+		}
+	}
+
+	output, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(output, reader); err != nil {
+		output.Close()
+		return fmt.Errorf("failed to append the results to %s: %w", f.filename, err)
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("failed to append the results to %s: %w", f.filename, err)
+	}
+	return os.Remove(partPath(f.filename))
+}
+
+// firstLine returns the first line of a file including its newline, empty when the file is empty
+// or doesn't exist
+func firstLine(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return line, err
+}
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestAppend(t *testing.T) {
+	const sid = "1756172871.1180"
+	const results = "_time,host\n2025-08-26T02:00:00.000+00:00,web02\n"
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write([]byte(`{"entry":[{"content":{"sid":"` + sid + `","dispatchState":"DONE","isDone":true,"resultCount":1}}]}`))
+		case "/services/search/v2/jobs/" + sid + "/results":
+			w.Write([]byte(results))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name     string
+		existing string // the output before the download, none when empty
+		expected string
+		err      string
+	}{
+		{name: "new output", expected: results},
+		{
+			name:     "existing output",
+			existing: "_time,host\n2025-08-26T01:00:00.000+00:00,web01\n",
+			expected: "_time,host\n2025-08-26T01:00:00.000+00:00,web01\n2025-08-26T02:00:00.000+00:00,web02\n",
+		},
+		{name: "other columns", existing: "_time,source\n2025-08-26T01:00:00.000+00:00,syslog\n", err: "columns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := t.TempDir() + "/results.csv"
+			if tt.existing != "" {
+				if err := os.WriteFile(filename, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			downloader := NewDownloader(createTestClient(testServer.URL, "csv"), config.DownloaderConfig{
+				OutputMode:     "csv",
+				MaxConnections: 1,
+				SID:            sid,
+				Filename:       filename,
+				Append:         true,
+			})
+			err := downloader.DownloadSearchResults()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error about %s, got %v", tt.err, err)
+				}
+				if output, _ := os.ReadFile(filename); string(output) != tt.existing {
+					t.Errorf("Expected the output to be left alone, got %q", output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DownloadSearchResults returned error: %v", err)
+			}
+			output, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, output)
+			}
+			if _, err := os.Stat(partPath(filename)); !os.IsNotExist(err) {
+				t.Errorf("Expected the part file to be removed, got %v", err)
+			}
+		})
+	}
+
+	downloader := NewDownloader(nil, config.DownloaderConfig{OutputMode: "csv", Filename: "results.csv.gz", Append: true})
+	if err := downloader.prepareOutput(); err == nil {
+		t.Error("Expected appending to a compressed output to be rejected")
+	}
+}
@@ -0,0 +1,210 @@
+package downloader
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Azure rejects block blobs with more blocks than this
+	azureMaxBlocks = 50000
+	azureVersion   = "2021-08-06"
+)
+
+// azureClient sends requests to the blob service of a storage account, authorized by the account
+// key or a shared access signature
+type azureClient struct {
+	endpoint *url.URL // the account's blob service
+	account  string
+	key      []byte     // nil when a shared access signature authorizes the requests
+	sas      url.Values // the shared access signature added to every request
+}
+
+// azureClientFromEnv configures the client from the environment variables the Azure CLI reads: a
+// connection string, or the account and its key or a shared access signature
+func azureClientFromEnv() (*azureClient, error) {
+	settings := map[string]string{
+		"AccountName":           os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		"AccountKey":            os.Getenv("AZURE_STORAGE_KEY"),
+		"SharedAccessSignature": os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+	}
+	if connectionString := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); connectionString != "" {
+		settings = make(map[string]string)
+		for setting := range strings.SplitSeq(connectionString, ";") {
+			// Keys end in = padding, so only the first = separates the name
+			name, value, _ := strings.Cut(setting, "=")
+			settings[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	c := &azureClient{account: settings["AccountName"]}
+	if c.account == "" {
+		return nil, fmt.Errorf("azblob:// outputs need AZURE_STORAGE_CONNECTION_STRING, or AZURE_STORAGE_ACCOUNT with AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN, to be set")
+	}
+	switch {
+	case settings["AccountKey"] != "":
+		key, err := base64.StdEncoding.DecodeString(settings["AccountKey"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Azure storage account key: %w", err)
+		}
+		c.key = key
+	case settings["SharedAccessSignature"] != "":
+		sas, err := url.ParseQuery(strings.TrimPrefix(settings["SharedAccessSignature"], "?"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Azure shared access signature: %w", err)
+		}
+		c.sas = sas
+	default:
+		return nil, fmt.Errorf("azblob:// outputs need the key or a shared access signature of storage account %s", c.account)
+	}
+
+	endpoint := settings["BlobEndpoint"]
+	if endpoint == "" {
+		protocol := cmp.Or(settings["DefaultEndpointsProtocol"], "https")
+		endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, c.account, cmp.Or(settings["EndpointSuffix"], "core.windows.net"))
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Azure blob endpoint %q", endpoint)
+	}
+	c.endpoint = u
+	return c, nil
+}
+
+// blobURL returns the URL of a blob
+func (c *azureClient) blobURL(container, blob string) *url.URL {
+	u := &url.URL{Scheme: c.endpoint.Scheme, Host: c.endpoint.Host, Path: strings.TrimSuffix(c.endpoint.Path, "/") + "/" + container + "/" + blob}
+	u.RawPath = uriEscapePath(u.Path)
+	return u
+}
+
+// do sends an authorized request for the blob at u and returns the response's headers and body
+func (c *azureClient) do(ctx context.Context, method string, u *url.URL, query url.Values, header http.Header, body []byte) (http.Header, []byte, error) {
+	return sendStorageRequest(ctx, func() (*http.Request, error) {
+		params := url.Values{}
+		maps.Copy(params, query)
+		maps.Copy(params, c.sas)
+		target := *u
+		target.RawQuery = params.Encode()
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(req.Header, header)
+		req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+		req.Header.Set("X-Ms-Version", azureVersion)
+		if c.key != nil {
+			c.sign(req, query)
+		}
+		return req, nil
+	})
+}
+
+// sign adds the Shared Key authorization to req, whose own query parameters are query
+func (c *azureClient) sign(req *http.Request, query url.Values) {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	var sb strings.Builder
+	// The standard headers spldl never sends are left empty
+	sb.WriteString(req.Method + "\n\n\n" + contentLength + "\n\n" + req.Header.Get("Content-Type") + "\n\n\n\n\n\n\n")
+
+	var msHeaders []string
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name)
+		}
+	}
+	slices.Sort(msHeaders)
+	for _, name := range msHeaders {
+		sb.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	sb.WriteString("/" + c.account + req.URL.EscapedPath())
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		values := slices.Sorted(slices.Values(query[name]))
+		sb.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	signature := base64.StdEncoding.EncodeToString(hmacSHA256(c.key, sb.String()))
+	req.Header.Set("Authorization", "SharedKey "+c.account+":"+signature)
+}
+
+// azureBlob is a block blob in Azure. Parts are uploaded as blocks, which become the blob once the
+// list of them is committed.
+type azureBlob struct {
+	client *azureClient
+	url    *url.URL
+	blocks []string // the IDs of the uploaded blocks
+}
+
+func newAzureBlob(uri string) (objectStore, error) {
+	container, blob, err := parseObjectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := azureClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &azureBlob{client: client, url: client.blobURL(container, blob)}, nil
+}
+
+func (b *azureBlob) exists(ctx context.Context) (bool, error) {
+	_, _, err := b.client.do(ctx, http.MethodHead, b.url, nil, nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *azureBlob) put(ctx context.Context, data []byte) error {
+	_, _, err := b.client.do(ctx, http.MethodPut, b.url, nil, http.Header{"X-Ms-Blob-Type": {"BlockBlob"}}, data)
+	return err
+}
+
+func (b *azureBlob) uploadPart(ctx context.Context, number int, data []byte) error {
+	// Every block ID of a blob must have the same length
+	id := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "spldl-%06d", number))
+	_, _, err := b.client.do(ctx, http.MethodPut, b.url, url.Values{"comp": {"block"}, "blockid": {id}}, nil, data)
+	if err != nil {
+		return err
+	}
+	b.blocks = append(b.blocks, id)
+	return nil
+}
+
+func (b *azureBlob) complete(ctx context.Context) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: b.blocks})
+	if err != nil {
+		return err
+	}
+	_, _, err = b.client.do(ctx, http.MethodPut, b.url, url.Values{"comp": {"blocklist"}}, nil, body)
+	return err
+}
+
+// abort leaves the blocks to Azure, which discards uncommitted blocks after a week and has no request
+// to drop them sooner
+func (b *azureBlob) abort(ctx context.Context) error {
+	return nil
+}
+
+func (b *azureBlob) maxParts() int {
+	return azureMaxBlocks
+}
@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAzure keeps the blobs and uncommitted blocks sent to it
+type fakeAzure struct {
+	mu     sync.Mutex
+	sas    bool
+	blobs  map[string]string
+	blocks map[string]string
+	calls  []string
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query := r.URL.Query()
+	authorized := strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey devstoreaccount1:")
+	if f.sas {
+		authorized = query.Get("sig") == "c2lnbmF0dXJl" && r.Header.Get("Authorization") == ""
+	}
+	if !authorized || r.Header.Get("X-Ms-Version") == "" || r.Header.Get("X-Ms-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodHead:
+		f.calls = append(f.calls, "HEAD")
+		if _, ok := f.blobs[r.URL.Path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		f.calls = append(f.calls, "BLOCK")
+		f.blocks[query.Get("blockid")] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		f.calls = append(f.calls, "BLOCKLIST")
+		var blob strings.Builder
+		for _, latest := range strings.Split(string(body), "<Latest>")[1:] {
+			id, _, _ := strings.Cut(latest, "</Latest>")
+			blob.WriteString(f.blocks[id])
+		}
+		f.blobs[r.URL.Path] = blob.String()
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("X-Ms-Blob-Type") == "BlockBlob":
+		f.calls = append(f.calls, "PUT")
+		f.blobs[r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestAzureBlobOutput(t *testing.T) {
+	large := strings.Repeat("x", uploadPartSize+100)
+	tests := []struct {
+		name  string
+		sas   bool
+		data  string
+		calls []string
+	}{
+		{name: "single put", data: "a,b\n1,2\n", calls: []string{"HEAD", "PUT"}},
+		{name: "blocks", data: large, calls: []string{"HEAD", "BLOCK", "BLOCK", "BLOCKLIST"}},
+		{name: "shared access signature", sas: true, data: "a,b\n1,2\n", calls: []string{"HEAD", "PUT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			azure := &fakeAzure{sas: tt.sas, blobs: make(map[string]string), blocks: make(map[string]string)}
+			server := httptest.NewServer(azure)
+			defer server.Close()
+			credential := "AccountKey=" + base64.StdEncoding.EncodeToString([]byte("key"))
+			if tt.sas {
+				credential = "SharedAccessSignature=sv=2021-08-06&sig=c2lnbmF0dXJl"
+			}
+			t.Setenv("AZURE_STORAGE_CONNECTION_STRING", "AccountName=devstoreaccount1;"+credential+";BlobEndpoint="+server.URL+"/devstoreaccount1")
+
+			store, err := openObjectStore("azblob://exports/2025/results.csv")
+			if err != nil {
+				t.Fatalf("openObjectStore returned error: %v", err)
+			}
+			if exists, err := store.exists(context.Background()); err != nil || exists {
+				t.Fatalf("Expected the blob not to exist, got %t, %v", exists, err)
+			}
+			upload := &partUpload{ctx: context.Background(), store: store, uri: "azblob://exports/2025/results.csv"}
+			if _, err := io.WriteString(upload, tt.data); err != nil {
+				t.Fatalf("Write returned error: %v", err)
+			}
+			if err := upload.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := upload.Commit(); err != nil {
+				t.Fatalf("Commit returned error: %v", err)
+			}
+
+			if strings.Join(azure.calls, ",") != strings.Join(tt.calls, ",") {
+				t.Errorf("Expected Azure calls %v, got %v", tt.calls, azure.calls)
+			}
+			if blob := azure.blobs["/devstoreaccount1/exports/2025/results.csv"]; blob != tt.data {
+				t.Errorf("Expected a blob of %d bytes, got %d", len(tt.data), len(blob))
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Buckets whose files are closed when this many are open, so long time ranges don't run out of file descriptors
+const maxOpenBuckets = 64
+
+// bucketOutput routes events into one file per time bucket based on their _time field, e.g. results.ndjson
+// with hourly buckets becomes results_2024-06-01T13.ndjson, results_2024-06-01T14.ndjson, ...
+type bucketOutput struct {
+	recordReader
+	filename   string
+	outputMode string
+	size       time.Duration
+	open       map[string]*fileOutput
+	created    map[string]bool
+	header     string      // the header line, repeated at the top of every bucket
+	overwrite  bool        // replace bucket files left over from earlier runs instead of failing
+	dialect    *csvDialect // re-encodes csv records, nil to keep them as Splunk sent them
+}
+
+func newBucketOutput(filename, outputMode string, size time.Duration, overwrite bool, dialect *csvDialect) *bucketOutput {
+	return &bucketOutput{
+		filename:   filename,
+		outputMode: outputMode,
+		size:       size,
+		overwrite:  overwrite,
+		dialect:    dialect,
+		open:       make(map[string]*fileOutput),
+		created:    make(map[string]bool),
+	}
+}
+
+// bucketLabel names the bucket an event with the given _time falls into
+func bucketLabel(eventTime string, size time.Duration) string {
+	t, ok := parseEventTime(eventTime)
+	if !ok {
+		return "unknown"
+	}
+	t = t.UTC().Truncate(size)
+
+	switch {
+	case size >= 24*time.Hour:
+		return t.Format("2006-01-02")
+	case size >= time.Hour:
+		return t.Format("2006-01-02T15")
+	default:
+		return t.Format("2006-01-02T15-04")
+	}
+}
+
+func (b *bucketOutput) bucketPath(label string) string {
+	base, ext := splitExt(b.filename)
+	return base + "_" + label + ext
+}
+
+func (b *bucketOutput) WriteString(data string) (int, error) {
+	records, err := b.records(data, b.outputMode, "time buckets")
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		// Keep the original bytes of the record so quoting is untouched, unless a dialect re-encodes it
+		text := record.Text
+		if b.dialect != nil {
+			row := make([]string, len(record.Fields))
+			for i, field := range record.Fields {
+				row[i] = field.Value
+			}
+			text = b.dialect.encode(row, record.Text)
+		}
+		if record.Header {
+			b.header = text
+			continue
+		}
+		eventTime, _ := record.Get("_time")
+		if err := b.writeToBucket(bucketLabel(eventTime, b.size), text); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (b *bucketOutput) writeToBucket(label, data string) error {
+	output, ok := b.open[label]
+	if !ok {
+		var err error
+		output, err = b.openBucket(label)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := output.WriteString(data)
+	return err
+}
+
+func (b *bucketOutput) openBucket(label string) (*fileOutput, error) {
+	if len(b.open) >= maxOpenBuckets {
+		slog.Debug("Too many open buckets, closing them", "open_buckets", len(b.open))
+		if err := b.closeOpen(); err != nil {
+			return nil, err
+		}
+	}
+
+	path := b.bucketPath(label)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !b.created[path] {
+		if err := checkOverwrite(path, b.overwrite); err != nil {
+			return nil, err
+		}
+		// Replace part files left over from failed runs, but append when reopening a bucket of this run
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(partPath(path), flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Reopened .gz and .zst buckets get another gzip member or zstd frame, which readers decompress as
+	// one stream
+	output := newFileOutputFrom(file, path)
+
+	if !b.created[path] {
+		slog.Debug("Created bucket file", "filename", path)
+		b.created[path] = true
+		if b.header != "" {
+			output.WriteString(b.header)
+		}
+	}
+	b.open[label] = output
+	return output, nil
+}
+
+func (b *bucketOutput) closeOpen() error {
+	var errs []error
+	for label, output := range b.open {
+		errs = append(errs, output.Close())
+		delete(b.open, label)
+	}
+	return errors.Join(errs...)
+}
+
+func (b *bucketOutput) Close() error {
+	slog.Info("Wrote time buckets", "files", len(b.created))
+	return b.closeOpen()
+}
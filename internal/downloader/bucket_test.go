@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBucketOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		outputMode string
+		filename   string
+		chunks     []string
+		size       time.Duration
+		expected   map[string]string
+	}{
+		{
+			name:       "hourly ndjson buckets",
+			outputMode: "ndjson",
+			filename:   "results.ndjson",
+			size:       time.Hour,
+			chunks: []string{
+				"{\"_raw\":\"foo\",\"_time\":\"2024-06-01T14:05:00.000+00:00\"}\n{\"_raw\":\"bar\",\"_time\":\"2024-06-01T15:30:00.000+02:00\"}\n",
+				"{\"_raw\":\"baz\",\"_time\":\"2024-06-01T14:59:59.999+00:00\"}\n{\"_raw\":\"qux\"}\n",
+			},
+			expected: map[string]string{
+				"results_2024-06-01T14.ndjson": "{\"_raw\":\"foo\",\"_time\":\"2024-06-01T14:05:00.000+00:00\"}\n{\"_raw\":\"baz\",\"_time\":\"2024-06-01T14:59:59.999+00:00\"}\n",
+				"results_2024-06-01T13.ndjson": "{\"_raw\":\"bar\",\"_time\":\"2024-06-01T15:30:00.000+02:00\"}\n",
+				"results_unknown.ndjson":       "{\"_raw\":\"qux\"}\n",
+			},
+		},
+		{
+			name:       "daily csv buckets repeat the header",
+			outputMode: "csv",
+			filename:   "results.csv",
+			size:       24 * time.Hour,
+			chunks: []string{
+				"\"_time\",\"_raw\"\n\"2024-06-01T23:00:00.000+00:00\",\"multi\nline\"\n",
+				"\"2024-06-02T01:00:00.000+00:00\",bar\n",
+			},
+			expected: map[string]string{
+				"results_2024-06-01.csv": "\"_time\",\"_raw\"\n\"2024-06-01T23:00:00.000+00:00\",\"multi\nline\"\n",
+				"results_2024-06-02.csv": "\"_time\",\"_raw\"\n\"2024-06-02T01:00:00.000+00:00\",bar\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := newBucketOutput(filepath.Join(dir, tt.filename), tt.outputMode, tt.size, false, nil)
+			for _, chunk := range tt.chunks {
+				if _, err := output.WriteString(chunk); err != nil {
+					t.Fatalf("WriteString returned an error: %v", err)
+				}
+			}
+			if err := output.Close(); err != nil {
+				t.Fatalf("Close returned an error: %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("Failed to list output directory: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Errorf("Expected %d bucket files, got %d", len(tt.expected), len(entries))
+			}
+			for name, expected := range tt.expected {
+				// Buckets are written to part files until the download moves them into place
+				content, err := os.ReadFile(partPath(filepath.Join(dir, name)))
+				if err != nil {
+					t.Errorf("Failed to read bucket %s: %v", name, err)
+					continue
+				}
+				if string(content) != expected {
+					t.Errorf("Bucket %s mismatch:\nExpected: %q\nGot:      %q", name, expected, string(content))
+				}
+			}
+		})
+	}
+}
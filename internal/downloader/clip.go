@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeClip drops events whose _time is outside [earliest, latest), carving a narrower window out of a
+// job's results without running the search again. Events without a readable _time are dropped too.
+type timeClip struct {
+	recordReader
+	earliest time.Time // zero for no lower bound
+	latest   time.Time // zero for no upper bound
+	dropped  int
+	untimed  int // dropped events whose _time couldn't be read
+}
+
+func newTimeClip(earliest, latest time.Time) *timeClip {
+	return &timeClip{earliest: earliest, latest: latest}
+}
+
+// parseEventTime parses the _time of an event, which is RFC 3339 unless the search formatted it as an
+// epoch timestamp itself
+func parseEventTime(eventTime string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, eventTime)
+	if err == nil {
+		return t, true
+	}
+	epoch, err := strconv.ParseFloat(eventTime, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(epoch*float64(time.Second))), true
+}
+
+// keeps reports whether an event with the given _time is inside the window
+func (c *timeClip) keeps(eventTime string) bool {
+	t, ok := parseEventTime(eventTime)
+	switch {
+	case !ok:
+		c.untimed++
+	case !c.earliest.IsZero() && t.Before(c.earliest), !c.latest.IsZero() && !t.Before(c.latest):
+	default:
+		return true
+	}
+	c.dropped++
+	return false
+}
+
+// filter removes the events outside the window from a chunk of output
+func (c *timeClip) filter(data string, outputMode string) (string, error) {
+	records, err := c.records(data, outputMode, "clipping")
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, record := range records {
+		if record.Header {
+			if !slices.ContainsFunc(record.Fields, func(field Field) bool { return field.Name == "_time" }) {
+				return "", errors.New("clipping needs a _time column, add _time to your | table")
+			}
+			sb.WriteString(record.Text)
+			continue
+		}
+		eventTime, _ := record.Get("_time")
+		if c.keeps(eventTime) {
+			sb.WriteString(record.Text)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeClip(t *testing.T) {
+	earliest := time.Date(2025, 8, 26, 2, 0, 0, 0, time.UTC)
+	latest := time.Date(2025, 8, 26, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		outputMode string
+		chunks     []string
+		expected   string
+		dropped    int
+		untimed    int
+	}{
+		{
+			name:       "ndjson",
+			outputMode: "ndjson",
+			chunks: []string{
+				`{"_time":"2025-08-26T01:59:59.000+00:00","host":"web01"}` + "\n" +
+					`{"_time":"2025-08-26T02:00:00.000+00:00","host":"web02"}` + "\n",
+				// 02:30 as an epoch timestamp, then the end of the window, which is excluded
+				`{"_time":"1756175400","host":"web03"}` + "\n" +
+					`{"_time":"2025-08-26T05:00:00.000+02:00","host":"web04"}` + "\n" +
+					`{"host":"web05"}` + "\n",
+			},
+			expected: `{"_time":"2025-08-26T02:00:00.000+00:00","host":"web02"}` + "\n" +
+				`{"_time":"1756175400","host":"web03"}` + "\n",
+			dropped: 3,
+			untimed: 1,
+		},
+		{
+			name:       "csv keeps the header and quoting",
+			outputMode: "csv",
+			chunks: []string{
+				"host,_time,message\nweb01,2025-08-26T02:15:00.000+00:00,\"a, b\"\n",
+				"web02,2025-08-26T03:15:00.000+00:00,c\nweb03,2025-08-26T02:45:00.000+00:00,\"d\ne\"\n",
+			},
+			expected: "host,_time,message\nweb01,2025-08-26T02:15:00.000+00:00,\"a, b\"\nweb03,2025-08-26T02:45:00.000+00:00,\"d\ne\"\n",
+			dropped:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip := newTimeClip(earliest, latest)
+			var written string
+			for _, chunk := range tt.chunks {
+				data, err := clip.filter(chunk, tt.outputMode)
+				if err != nil {
+					t.Fatalf("filter returned error: %v", err)
+				}
+				written += data
+			}
+			if written != tt.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.expected, written)
+			}
+			if clip.dropped != tt.dropped || clip.untimed != tt.untimed {
+				t.Errorf("Expected %d dropped and %d untimed, got %d and %d", tt.dropped, tt.untimed, clip.dropped, clip.untimed)
+			}
+		})
+	}
+
+	_, err := newTimeClip(earliest, time.Time{}).filter("host,message\nweb01,a\n", "csv")
+	if err == nil {
+		t.Error("Expected an error for CSV without a _time column")
+	}
+}
@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const dedupeStateMagic = "spldldd1"
+
+// The most event signatures a dedupe state file keeps, 160MB of them. High-volume exports can
+// otherwise grow the file without bound within the window, so the oldest are forgotten first.
+const maxDedupeSignatures = 10_000_000
+
+// Fields that differ between jobs even when they return the same event
+var volatileFields = []string{"_serial"}
+
+// eventDeduper drops events that were already exported by a previous run. Only signatures loaded from the
+// state file are matched against, so identical rows within a single job (e.g. from | table) are kept.
+type eventDeduper struct {
+	recordReader
+	path     string
+	window   time.Duration
+	limit    int              // most signatures saved
+	previous map[uint64]int64 // event signature -> unix time it was first exported
+	current  map[uint64]int64
+	dropped  int
+}
+
+func loadEventDeduper(path string, window time.Duration) (*eventDeduper, error) {
+	e := &eventDeduper{
+		path:     path,
+		window:   window,
+		limit:    maxDedupeSignatures,
+		previous: make(map[uint64]int64),
+		current:  make(map[uint64]int64),
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Debug("No dedupe state found, starting fresh", "path", path)
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(dedupeStateMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != dedupeStateMagic {
+		return nil, fmt.Errorf("%s is not a dedupe state file", path)
+	}
+
+	cutoff := time.Now().Add(-window).Unix()
+	var entry [16]byte
+	for {
+		_, err := io.ReadFull(reader, entry[:])
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dedupe state: %w", err)
+		}
+		signature := binary.LittleEndian.Uint64(entry[:8])
+		exported := int64(binary.LittleEndian.Uint64(entry[8:]))
+		if exported >= cutoff {
+			e.previous[signature] = exported
+		}
+	}
+
+	slog.Debug("Loaded dedupe state", "path", path, "signatures", len(e.previous))
+	return e, nil
+}
+
+// filter removes previously exported events from a chunk of output
+func (e *eventDeduper) filter(data string, outputMode string) (string, error) {
+	records, err := e.records(data, outputMode, "dedupe")
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, record := range records {
+		if record.Header {
+			sb.WriteString(record.Text)
+			continue
+		}
+		normalized, err := signatureFields(record)
+		if err != nil {
+			return "", err
+		}
+		if e.seen(normalized) {
+			continue
+		}
+		sb.WriteString(record.Text)
+	}
+	return sb.String(), nil
+}
+
+// signatureFields encodes the fields of an event that identify it, whatever their order and the output
+// mode. Values keep their JSON type when the mode encodes them as JSON.
+func signatureFields(record Record) ([]byte, error) {
+	event := make(map[string]any, len(record.Fields))
+	for _, field := range record.Fields {
+		if field.Absent || slices.Contains(volatileFields, field.Name) {
+			continue
+		}
+		if field.Encoded == "" {
+			event[field.Name] = field.Value
+			continue
+		}
+		var value any
+		if err := json.Unmarshal([]byte(field.Encoded), &value); err != nil {
+			return nil, fmt.Errorf("failed to parse field %s: %w", field.Name, err)
+		}
+		event[field.Name] = value
+	}
+	// Marshalling a map sorts its keys, which makes the signature independent of field order
+	return json.Marshal(event)
+}
+
+// seen records the signature of an event and reports whether a previous run already exported it
+func (e *eventDeduper) seen(normalized []byte) bool {
+	sum := sha256.Sum256(normalized)
+	signature := binary.LittleEndian.Uint64(sum[:8])
+	if _, ok := e.previous[signature]; ok {
+		e.dropped++
+		return true
+	}
+	if _, ok := e.current[signature]; !ok {
+		e.current[signature] = time.Now().Unix()
+	}
+	return false
+}
+
+// save writes the signatures of this and previous runs that are still within the window, up to the
+// newest limit of them
+func (e *eventDeduper) save() error {
+	type signatureEntry struct {
+		signature uint64
+		exported  int64
+	}
+	entries := make([]signatureEntry, 0, len(e.previous)+len(e.current))
+	for _, signatures := range []map[uint64]int64{e.previous, e.current} {
+		for signature, exported := range signatures {
+			entries = append(entries, signatureEntry{signature, exported})
+		}
+	}
+	if len(entries) > e.limit {
+		slices.SortFunc(entries, func(a, b signatureEntry) int {
+			return cmp.Compare(b.exported, a.exported)
+		})
+		slog.Warn("Dedupe state is full, forgetting the oldest exported events", "path", e.path, "forgotten", len(entries)-e.limit)
+		entries = entries[:e.limit]
+	}
+
+	tempPath := e.path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	writer.WriteString(dedupeStateMagic)
+	var entry [16]byte
+	for _, saved := range entries {
+		binary.LittleEndian.PutUint64(entry[:8], saved.signature)
+		binary.LittleEndian.PutUint64(entry[8:], uint64(saved.exported))
+		writer.Write(entry[:])
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	slog.Debug("Saved dedupe state", "path", e.path, "signatures", len(entries))
+	return os.Rename(tempPath, e.path)
+}
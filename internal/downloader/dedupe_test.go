@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventDeduper(t *testing.T) {
+	tests := []struct {
+		name       string
+		outputMode string
+		firstRun   string
+		secondRun  string
+		expected   string
+	}{
+		{
+			name:       "ndjson ignores _serial and field order",
+			outputMode: "ndjson",
+			firstRun:   "{\"_raw\":\"foo\",\"_serial\":\"0\",\"host\":\"web01\"}\n{\"_raw\":\"bar\",\"_serial\":\"1\",\"host\":\"web01\"}\n",
+			secondRun:  "{\"host\":\"web01\",\"_raw\":\"bar\",\"_serial\":\"0\"}\n{\"_raw\":\"baz\",\"_serial\":\"1\",\"host\":\"web01\"}\n{\"_raw\":\"baz\",\"_serial\":\"2\",\"host\":\"web01\"}\n",
+			expected:   "{\"_raw\":\"baz\",\"_serial\":\"1\",\"host\":\"web01\"}\n{\"_raw\":\"baz\",\"_serial\":\"2\",\"host\":\"web01\"}\n",
+		},
+		{
+			name:       "csv keeps header and original quoting",
+			outputMode: "csv",
+			firstRun:   "\"_raw\",\"_serial\",host\nfoo,0,web01\nbar,1,web01\n",
+			secondRun:  "\"_raw\",\"_serial\",host\n\"bar\",0,web01\n\"multi\nline\",1,web01\n",
+			expected:   "\"_raw\",\"_serial\",host\n\"multi\nline\",1,web01\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statePath := filepath.Join(t.TempDir(), "dedupe.state")
+
+			first, err := loadEventDeduper(statePath, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to load empty dedupe state: %v", err)
+			}
+			output, err := first.filter(tt.firstRun, tt.outputMode)
+			if err != nil {
+				t.Fatalf("First filter returned an error: %v", err)
+			}
+			if output != tt.firstRun {
+				t.Errorf("Expected first run to keep every event, got %q", output)
+			}
+			if err := first.save(); err != nil {
+				t.Fatalf("Failed to save dedupe state: %v", err)
+			}
+
+			second, err := loadEventDeduper(statePath, time.Hour)
+			if err != nil {
+				t.Fatalf("Failed to load dedupe state: %v", err)
+			}
+			output, err = second.filter(tt.secondRun, tt.outputMode)
+			if err != nil {
+				t.Fatalf("Second filter returned an error: %v", err)
+			}
+			if output != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, output)
+			}
+			if second.dropped != 1 {
+				t.Errorf("Expected 1 dropped event, got %d", second.dropped)
+			}
+		})
+	}
+}
+
+func TestEventDeduperLimit(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "dedupe.state")
+
+	first, err := loadEventDeduper(statePath, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to load empty dedupe state: %v", err)
+	}
+	if _, err := first.filter("{\"_raw\":\"old\"}\n", "ndjson"); err != nil {
+		t.Fatalf("Filter returned an error: %v", err)
+	}
+	for signature := range first.current {
+		first.current[signature] = time.Now().Add(-time.Minute).Unix()
+	}
+	if _, err := first.filter("{\"_raw\":\"new\"}\n", "ndjson"); err != nil {
+		t.Fatalf("Filter returned an error: %v", err)
+	}
+	first.limit = 1
+	if err := first.save(); err != nil {
+		t.Fatalf("Failed to save dedupe state: %v", err)
+	}
+
+	// Only the newest event is remembered
+	second, err := loadEventDeduper(statePath, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to load dedupe state: %v", err)
+	}
+	output, err := second.filter("{\"_raw\":\"old\"}\n{\"_raw\":\"new\"}\n", "ndjson")
+	if err != nil {
+		t.Fatalf("Filter returned an error: %v", err)
+	}
+	if output != "{\"_raw\":\"old\"}\n" {
+		t.Errorf("Expected only the forgotten event to be exported again, got %q", output)
+	}
+}
@@ -0,0 +1,390 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Destination is where the output of a download goes: a local file, standard output, an object in
+// cloud storage or a file on an SSH server, an Elasticsearch index or a Kafka topic, chosen by the scheme of the output's name.
+// What's written only becomes the output once it's committed, so a failed download never leaves an
+// incomplete output under its name, except in an index or a topic, which can't hold back the results
+// sent to them.
+type Destination interface {
+	io.Writer
+	// Close ends the output without committing it
+	Close() error
+	// Commit makes the closed output appear under its name
+	Commit() error
+	// Abort discards an output that wasn't committed
+	Abort()
+}
+
+// fileDestination writes to the part file of a local output and renames it once committed, or adds it
+// to the end of the output when appending
+type fileDestination struct {
+	*os.File
+	filename string
+	append   bool
+	csv      bool // the output is csv, whose header is only written once when appending
+}
+
+func (f *fileDestination) Commit() error {
+	if f.append {
+		return f.appendPart()
+	}
+	if err := os.Rename(partPath(f.filename), f.filename); err != nil {
+		return fmt.Errorf("failed to move the output into place: %w", err)
+	}
+	return nil
+}
+
+// Abort keeps the part file, which --resume continues from
+func (f *fileDestination) Abort() {}
+
+// streamDestination writes to a stream such as standard output, where everything written is visible
+// right away
+type streamDestination struct {
+	io.Writer
+}
+
+// Close leaves the stream open for whatever the process writes next
+func (streamDestination) Close() error  { return nil }
+func (streamDestination) Commit() error { return nil }
+func (streamDestination) Abort()        {}
+
+// lineSplitter passes the results written to a destination on a line at a time, however the writes
+// split them
+type lineSplitter struct {
+	partial []byte // the start of a result whose end wasn't written yet
+}
+
+// write calls result with every line p completes
+func (s *lineSplitter) write(p []byte, result func(line []byte) error) error {
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := s.partial[:i]
+		s.partial = s.partial[i+1:]
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := result(line); err != nil {
+			return err
+		}
+	}
+	// Keep the partial result in a buffer of its own instead of the tail of a growing one
+	s.partial = append([]byte(nil), s.partial...)
+	return nil
+}
+
+// rest returns a final result that didn't end in a newline, nil if there's none
+func (s *lineSplitter) rest() []byte {
+	rest := s.partial
+	s.partial = nil
+	if len(bytes.TrimSpace(rest)) == 0 {
+		return nil
+	}
+	return rest
+}
+
+// Outputs in cloud storage are named by a URI whose scheme picks the store, e.g. s3://bucket/key
+var outputSchemePattern = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*)://`)
+
+// outputScheme returns the lowercased scheme of an output in cloud storage, or "" for local files
+func outputScheme(filename string) string {
+	match := outputSchemePattern.FindStringSubmatch(filename)
+	if match == nil {
+		return ""
+	}
+	return strings.ToLower(match[1])
+}
+
+// isRemote reports whether filename names an object in cloud storage rather than a local file
+func isRemote(filename string) bool {
+	return outputScheme(filename) != ""
+}
+
+// isSink reports whether filename names a network output that takes the results in batches, such as
+// an Elasticsearch index, rather than a file
+func isSink(filename string) bool {
+	switch outputScheme(filename) {
+	case "es", "kafka", "http", "https":
+		return true
+	}
+	return false
+}
+
+// objectStore is an object in cloud storage that outputs are uploaded to, in numbered parts or with
+// a single request when they're smaller than a part
+type objectStore interface {
+	// exists reports whether the object is already there
+	exists(ctx context.Context) (bool, error)
+	// put uploads the whole object
+	put(ctx context.Context, data []byte) error
+	// uploadPart uploads part number of the object, counting from 1, without making it visible
+	uploadPart(ctx context.Context, number int, data []byte) error
+	// complete makes the object out of the uploaded parts appear
+	complete(ctx context.Context) error
+	// abort discards the uploaded parts
+	abort(ctx context.Context) error
+	// maxParts is the most parts an object is uploaded in
+	maxParts() int
+}
+
+// objectStores open the objects of each cloud storage scheme. They read their credentials from the
+// environment and send no requests until the object is written.
+var objectStores = map[string]func(uri string) (objectStore, error){
+	"s3":     newS3Object,
+	"gs":     newGCSObject,
+	"azblob": newAzureBlob,
+	"sftp":   newSFTPFile,
+}
+
+// openObjectStore returns the object uri names
+func openObjectStore(uri string) (objectStore, error) {
+	scheme := outputScheme(uri)
+	open, ok := objectStores[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output %s://, use a local file, s3://, gs://, azblob://, sftp://, es://, kafka:// or http(s)://", scheme)
+	}
+	return open(uri)
+}
+
+// parseObjectURI splits a scheme://bucket/key URI into its bucket, or container, and key
+func parseObjectURI(uri string) (string, string, error) {
+	_, path, _ := strings.Cut(uri, "://")
+	bucket, key, _ := strings.Cut(path, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid output %s, expected %s://bucket/key", uri, outputScheme(uri))
+	}
+	return bucket, key, nil
+}
+
+// Parts are held in memory until they're uploaded, so the output never touches the local disk
+const uploadPartSize = 8 << 20
+
+// partUpload streams the output into an object in cloud storage. Outputs smaller than a part are sent
+// with a single request once committed; larger ones are uploaded a part at a time as they're written
+// and assembled once committed, so the object only ever appears whole.
+type partUpload struct {
+	ctx   context.Context
+	store objectStore
+	uri   string
+	buf   bytes.Buffer
+	parts int
+	done  bool
+}
+
+// Write buffers p and uploads every full part
+func (u *partUpload) Write(p []byte) (int, error) {
+	u.buf.Write(p)
+	for u.buf.Len() >= uploadPartSize {
+		if err := u.uploadPart(u.buf.Next(uploadPartSize)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (u *partUpload) uploadPart(data []byte) error {
+	if u.parts == u.store.maxParts() {
+		return fmt.Errorf("the output is larger than the %d parts of %d MiB an upload to %s holds", u.store.maxParts(), uploadPartSize>>20, outputScheme(u.uri))
+	}
+	if err := u.store.uploadPart(u.ctx, u.parts+1, data); err != nil {
+		return fmt.Errorf("failed to upload part %d to %s: %w", u.parts+1, u.uri, err)
+	}
+	u.parts++
+	slog.Debug("Uploaded part", "uri", u.uri, "part", u.parts, "size", len(data))
+	return nil
+}
+
+// Close keeps the last part buffered for Commit, which sends small outputs with a single request
+func (u *partUpload) Close() error {
+	return nil
+}
+
+func (u *partUpload) Commit() error {
+	if u.parts == 0 {
+		if err := u.store.put(u.ctx, u.buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", u.uri, err)
+		}
+	} else {
+		if u.buf.Len() > 0 {
+			if err := u.uploadPart(u.buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		if err := u.store.complete(u.ctx); err != nil {
+			return fmt.Errorf("failed to complete the upload to %s: %w", u.uri, err)
+		}
+	}
+	u.done = true
+	slog.Info("Uploaded the output", "uri", u.uri, "parts", max(u.parts, 1))
+	return nil
+}
+
+// Abort discards the parts of an upload that wasn't committed, which the store would otherwise keep
+func (u *partUpload) Abort() {
+	if u.done || u.parts == 0 {
+		return
+	}
+	// The download may have stopped because it was interrupted, which mustn't keep the parts around
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(u.ctx), time.Minute)
+	defer cancel()
+	if err := u.store.abort(ctx); err != nil {
+		slog.Warn("Failed to abort the upload, its parts stay in the bucket until a lifecycle rule removes them", "uri", u.uri, "error", err)
+		return
+	}
+	u.done = true
+	slog.Debug("Aborted upload", "uri", u.uri)
+}
+
+// storageError is an error response of a cloud store
+type storageError struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *storageError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("storage returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("storage returned %s: %s", e.Code, e.Message)
+}
+
+// Requests failing with a network error or a 5xx response are sent again this often
+const storageAttempts = 3
+
+var storageHTTPClient = &http.Client{Timeout: 5 * time.Minute}
+
+// sendStorageRequest sends the request newRequest builds, again after network errors and 5xx
+// responses, and returns the response's headers and body. Requests are built for every attempt so
+// their signatures stay fresh.
+func sendStorageRequest(ctx context.Context, newRequest func() (*http.Request, error)) (http.Header, []byte, error) {
+	var err error
+	for attempt := 1; attempt <= storageAttempts; attempt++ {
+		if attempt > 1 {
+			slog.Warn("Retrying storage request", "attempt", attempt, "error", err)
+			select {
+			case <-time.After(time.Duration(attempt-1) * time.Second):
+			case <-ctx.Done():
+				return nil, nil, errors.Join(err, ctx.Err())
+			}
+		}
+		var header http.Header
+		var body []byte
+		header, body, err = sendStorageRequestOnce(newRequest)
+		var storageErr *storageError
+		if err == nil || ctx.Err() != nil || (errors.As(err, &storageErr) && storageErr.StatusCode < 500) {
+			return header, body, err
+		}
+	}
+	return nil, nil, err
+}
+
+func sendStorageRequestOnce(newRequest func() (*http.Request, error)) (http.Header, []byte, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := storageHTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 300 {
+		storageErr := &storageError{}
+		xml.Unmarshal(body, storageErr)
+		storageErr.StatusCode = resp.StatusCode
+		return nil, nil, storageErr
+	}
+	return resp.Header, body, nil
+}
+
+// isNotFound reports whether err is a store's response to a request for an object that isn't there
+func isNotFound(err error) bool {
+	var storageErr *storageError
+	return errors.As(err, &storageErr) && storageErr.StatusCode == http.StatusNotFound
+}
+
+// uriEscape percent-encodes everything but the characters URIs leave unreserved
+func uriEscape(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-._~", b) >= 0 {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// uriEscapePath percent-encodes each segment of path
+func uriEscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// openRemote starts uploading the output to the object uri names, or indexing it into Elasticsearch,
+// publishing it to Kafka or posting it to a webhook
+func (d *Downloader) openRemote(uri string) (*fileOutput, error) {
+	switch outputScheme(uri) {
+	case "es":
+		return d.openElasticsearch(uri)
+	case "kafka":
+		return d.openKafka(uri)
+	case "http", "https":
+		return d.openWebhook(uri)
+	}
+	store, err := openObjectStore(uri)
+	if err != nil {
+		return nil, err
+	}
+	return newOutputTo(&partUpload{ctx: d.client.Context(), store: store, uri: uri}, uri), nil
+}
+
+// checkRemoteOverwrite refuses to replace an existing object unless Overwrite is set
+func (d *Downloader) checkRemoteOverwrite(uri string) error {
+	store, err := openObjectStore(uri)
+	if err != nil || d.overwrite {
+		return err
+	}
+	exists, err := store.exists(d.client.Context())
+	if err != nil {
+		return fmt.Errorf("failed to check for %s: %w", uri, err)
+	}
+	if exists {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", uri)
+	}
+	return nil
+}
+
+// abortOutput discards the outputs of a download that failed
+func (d *Downloader) abortOutput() {
+	for _, dest := range d.destinations {
+		dest.Abort()
+	}
+}
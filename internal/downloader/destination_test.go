@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenObjectStore(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("GCS_HMAC_ACCESS_ID", "GOOG1ID")
+	t.Setenv("GCS_HMAC_SECRET", "secret")
+	t.Setenv("STORAGE_EMULATOR_HOST", "localhost:4443")
+
+	tests := []struct {
+		uri      string
+		expected string // the object's URL, or the start of the error
+	}{
+		{uri: "s3://exports/firewall/2025-08-26.ndjson.gz", expected: "https://exports.s3.us-east-1.amazonaws.com/firewall/2025-08-26.ndjson.gz"},
+		{uri: "S3://exports/a b+c.csv", expected: "https://exports.s3.us-east-1.amazonaws.com/a%20b%2Bc.csv"},
+		{uri: "gs://exports/results.csv", expected: "http://localhost:4443/exports/results.csv"},
+		{uri: "s3://exports", expected: "invalid output s3://exports, expected s3://bucket/key"},
+		{uri: "sftp://host", expected: "invalid output sftp://host, expected sftp://[user@]host[:port]/path"},
+		{uri: "ftp://host/results.csv", expected: "unsupported output ftp://"},
+	}
+	for _, tt := range tests {
+		store, err := openObjectStore(tt.uri)
+		if err != nil {
+			if !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("%s: expected %q, got error %v", tt.uri, tt.expected, err)
+			}
+			continue
+		}
+		if got := store.(*s3Object).url.String(); got != tt.expected {
+			t.Errorf("%s: expected URL %s, got %s", tt.uri, tt.expected, got)
+		}
+	}
+
+	for filename, expected := range map[string]bool{"s3://bucket/key": true, "results.csv": false, "-": false, "C:\\exports\\results.csv": false, "./s3:/bucket": false} {
+		if isRemote(filename) != expected {
+			t.Errorf("Expected isRemote(%q) to be %t", filename, expected)
+		}
+	}
+}
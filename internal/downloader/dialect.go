@@ -0,0 +1,136 @@
+package downloader
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+// csvDialect rewrites the CSV Splunk sends with another delimiter, quoting, line ending or locale, for
+// tools that expect tab-separated or Excel-flavored files
+type csvDialect struct {
+	delimiter rune
+	quoteAll  bool // quote every field, not only those that need it
+	crlf      bool // end records with \r\n instead of \n
+
+	localeTag  string
+	locale     *csvLocale // nil to keep numbers and times as Splunk wrote them
+	headerSeen bool
+	timeColumn int // the column of _time, -1 if there is none
+}
+
+// newCSVDialect returns the dialect configured for csv output, nil for the CSV Splunk sends
+func newCSVDialect(cfg config.DownloaderConfig) *csvDialect {
+	var locale *csvLocale
+	if l, ok := lookupLocale(cfg.CSVLocale); ok {
+		locale = &l
+	}
+	delimiter := cfg.CSVDelimiter
+	if delimiter == 0 && locale != nil && locale.decimal == ',' {
+		delimiter = ';'
+	}
+	delimiter = cmp.Or(delimiter, ',')
+	if delimiter == ',' && !cfg.CSVQuoteAll && !cfg.CSVCRLF && cfg.CSVLocale == "" {
+		return nil
+	}
+	return &csvDialect{
+		delimiter:  delimiter,
+		quoteAll:   cfg.CSVQuoteAll,
+		crlf:       cfg.CSVCRLF,
+		localeTag:  cfg.CSVLocale,
+		locale:     locale,
+		timeColumn: -1,
+	}
+}
+
+// validate rejects delimiters that can't separate fields and unknown locales
+func (c *csvDialect) validate() error {
+	if c.delimiter == '"' || c.delimiter == '\r' || c.delimiter == '\n' || c.delimiter == utf8.RuneError {
+		return fmt.Errorf("%q can't be used as the delimiter", c.delimiter)
+	}
+	if c.localeTag != "" && c.locale == nil {
+		return fmt.Errorf("unknown locale %q, use one of %s", c.localeTag, strings.Join(Locales(), ", "))
+	}
+	if c.locale != nil && c.locale.decimal == byte(c.delimiter) {
+		return fmt.Errorf("the delimiter can't be the decimal separator of locale %s", c.localeTag)
+	}
+	return nil
+}
+
+// reformat re-encodes a chunk of CSV records in the dialect
+func (c *csvDialect) reformat(data string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse row: %w", err)
+		}
+		c.writeRecord(&sb, row)
+	}
+}
+
+// encode returns a record in the dialect, or original, its bytes as Splunk sent them, without one
+func (c *csvDialect) encode(row []string, original string) string {
+	if c == nil {
+		return original
+	}
+	var sb strings.Builder
+	c.writeRecord(&sb, row)
+	return sb.String()
+}
+
+// writeRecord writes a record in the dialect. The first record is the header, which tells the
+// locale where _time is.
+func (c *csvDialect) writeRecord(sb *strings.Builder, row []string) {
+	header := !c.headerSeen
+	if header {
+		c.headerSeen = true
+		c.timeColumn = slices.Index(row, "_time")
+	}
+	for i, field := range row {
+		if i > 0 {
+			sb.WriteRune(c.delimiter)
+		}
+		if c.locale != nil && !header {
+			field = c.locale.format(field, i == c.timeColumn)
+		}
+		if !c.quoteAll && !c.needsQuotes(field) {
+			sb.WriteString(field)
+			continue
+		}
+		sb.WriteByte('"')
+		sb.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		sb.WriteByte('"')
+	}
+	if c.crlf {
+		sb.WriteString("\r\n")
+	} else {
+		sb.WriteByte('\n')
+	}
+}
+
+// needsQuotes reports whether a field has to be quoted to be read back, the same rule encoding/csv
+// follows, plus leading tabs that spreadsheets would otherwise strip
+func (c *csvDialect) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, c.delimiter) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	return field[0] == ' ' || field[0] == '\t'
+}
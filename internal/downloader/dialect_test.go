@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestCSVDialect(t *testing.T) {
+	const data = "\"_time\",host,\"_raw\"\n2025-08-26T02:00:00.000+00:00,web01,\"a, b\tc\"\n2025-08-26T02:00:01.000+00:00,,\"say \"\"hi\"\"\"\n"
+
+	tests := []struct {
+		name     string
+		config   config.DownloaderConfig
+		expected string
+	}{
+		{
+			name:     "tab-separated",
+			config:   config.DownloaderConfig{CSVDelimiter: '\t'},
+			expected: "_time\thost\t_raw\n2025-08-26T02:00:00.000+00:00\tweb01\t\"a, b\tc\"\n2025-08-26T02:00:01.000+00:00\t\t\"say \"\"hi\"\"\"\n",
+		},
+		{
+			name:     "quote all with crlf",
+			config:   config.DownloaderConfig{CSVQuoteAll: true, CSVCRLF: true},
+			expected: "\"_time\",\"host\",\"_raw\"\r\n\"2025-08-26T02:00:00.000+00:00\",\"web01\",\"a, b\tc\"\r\n\"2025-08-26T02:00:01.000+00:00\",\"\",\"say \"\"hi\"\"\"\r\n",
+		},
+		{
+			name:     "semicolon",
+			config:   config.DownloaderConfig{CSVDelimiter: ';'},
+			expected: "_time;host;_raw\n2025-08-26T02:00:00.000+00:00;web01;a, b\tc\n2025-08-26T02:00:01.000+00:00;;\"say \"\"hi\"\"\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect := newCSVDialect(tt.config)
+			output, err := dialect.reformat(data)
+			if err != nil {
+				t.Fatalf("reformat returned error: %v", err)
+			}
+			if output != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, output)
+			}
+		})
+	}
+
+	if newCSVDialect(config.DownloaderConfig{CSVDelimiter: ','}) != nil {
+		t.Error("Expected no dialect for the CSV Splunk sends")
+	}
+	if err := newCSVDialect(config.DownloaderConfig{CSVDelimiter: '"'}).validate(); err == nil {
+		t.Error("Expected an error for a quote as delimiter")
+	}
+}
+
+func TestCSVLocale(t *testing.T) {
+	const data = "\"_time\",host,bytes,ratio,version\n2025-08-26T02:00:00.000+02:00,web01,1024,0.75,1.2.3\n2025-08-26T14:30:05.000+02:00,web02,-7,-12.5,\"a;b\"\n"
+
+	dialect := newCSVDialect(config.DownloaderConfig{CSVLocale: "de_DE"})
+	if err := dialect.validate(); err != nil {
+		t.Fatalf("validate returned error: %v", err)
+	}
+	output, err := dialect.reformat(data)
+	if err != nil {
+		t.Fatalf("reformat returned error: %v", err)
+	}
+	expected := "_time;host;bytes;ratio;version\n26.08.2025 02:00:00;web01;1024;0,75;1.2.3\n26.08.2025 14:30:05;web02;-7;-12,5;\"a;b\"\n"
+	if output != expected {
+		t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", expected, output)
+	}
+
+	if err := newCSVDialect(config.DownloaderConfig{CSVLocale: "xx-XX"}).validate(); err == nil {
+		t.Error("Expected an error for an unknown locale")
+	}
+	if err := newCSVDialect(config.DownloaderConfig{CSVLocale: "fr-FR", CSVDelimiter: ','}).validate(); err == nil {
+		t.Error("Expected an error for a delimiter that is the decimal separator")
+	}
+}
@@ -1,18 +1,40 @@
 package downloader
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"net/http"
 	"os"
+	"runtime/pprof"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/report"
 	"github.com/cschmidt0121/spldl/internal/splunkclient"
 )
 
 const chunkSize = 10000
 
+// Splunk keeps at most this many results of a job
+const maxJobResults = 500000
+
+// How often a chunk that came back as malformed JSON is re-requested before salvaging what's readable
+const malformedChunkRetries = 2
+
+// How many chunks workers may download ahead of the next chunk to be written by default. This bounds
+// the out-of-order chunks held in memory however much slower one worker is than the others.
+const defaultReorderWindow = 64
+
+// Retries of a failed chunk wait at most this long
+const maxRetryBackoff = 30 * time.Second
+
 // eventChunk represents a downloaded chunk of events
 type eventChunk struct {
 	offset int
@@ -23,27 +45,296 @@ type Downloader struct {
 	client         *splunkclient.Client
 	outputMode     string
 	maxConnections int
+	reorderWindow  int
+	window         *reorderWindow // the chunks workers may download, set while a job is downloaded
 	deleteWhenDone bool
 	sid            string
+	mergeSIDs      []string // further jobs written to the output after sid
+	sortByTime     bool
 	filename       string
+	stdout         io.Writer
+	stdoutFormat   string
+	dedupeState    string
+	dedupeWindow   time.Duration
+	deduper        *eventDeduper
+	clip           *timeClip
+	rawJSON        bool
+	annotations    *annotationFilter
+	redactor       *redactor
+	buckets        *bucketOutput // the time buckets written, if any
+	verify         bool
+	signingKey     []byte
+	resultCount    int
+	rowsWritten    int
+	maxResults     int         // the most results the system policy allows writing, 0 for no limit
+	rowCodec       RecordCodec // counts the rows written, nil until the first chunk or for modes without records
+	pageCodec      RecordCodec // counts the results of the pages of a followed job or a oneshot search
+	bucketSize     time.Duration
+	progress       chan Progress
+	progressOnce   sync.Once
+	bytesWritten   int64
+	tokenExpiry    time.Time
+	search         string
+	autoSplit      bool
+	splitWindow    time.Duration
+	header         string // the header of the first job, repeated headers of later jobs are dropped
+	startedAt      time.Time
+	expiryWarned   bool
+	failedMu       sync.Mutex
+	failedChunks   int
+	chunkErr       error // the first chunk that could not be downloaded
+	totalChunks    int
+	cost           report.SearchCost
+	warnings       *report.Warnings
+	messagesMu     sync.Mutex
+	seenMessages   map[splunkclient.ResultsMessage]bool
+	parallelWrites bool
+	writeMu        sync.Mutex // guards the counters below while workers write in place
+	chunksWritten  int
+	resume         bool
+	firstChunk     int         // chunks of the job written by an interrupted download
+	checkpoint     *fileOutput // the output whose progress is recorded in the resume state, if any
+	partial        bool
+	followInterval time.Duration
+
+	failOnJobErrors bool
+	allowPartial    bool
+	overwrite       bool
+	append          bool
+	expectedRows    int // the results of the jobs downloaded, which the rows written should add up to
+	csvDialect      *csvDialect
+	fields          *fieldSelection // nil to write every field
+	postFilter      string
+	events          bool // download the job's events instead of its results
+
+	stopAfter int // the most results downloaded from a job, 0 for all of them
+
+	sinkBatchSize      int
+	sinkFlushInterval  time.Duration
+	sinkMaxInFlight    int
+	sinkAttempts       int
+	sinkBackoff        time.Duration
+	deadLetter         *deadLetter // nil to fail the download when a batch is given up
+	webhookHeaders     []string
+	elasticsearchURL   string // the cluster es:// outputs are indexed into
+	kafkaBrokers       []string
+	kafkaKeyField      string
+	kafkaCompression   string
+	kafkaTLS           bool
+	kafkaTLSCAFile     string
+	kafkaTLSCertFile   string
+	kafkaTLSKeyFile    string
+	kafkaSASLMechanism string
+	kafkaSASLUsername  string
+
+	tee          []string      // further outputs written alongside filename
+	destinations []Destination // where the outputs go, none when writing time buckets
 }
 
 func NewDownloader(client *splunkclient.Client, config config.DownloaderConfig) *Downloader {
+	stdout := config.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	var clip *timeClip
+	if !config.ClipEarliest.IsZero() || !config.ClipLatest.IsZero() {
+		clip = newTimeClip(config.ClipEarliest, config.ClipLatest)
+	}
+	var annotations *annotationFilter
+	if config.NoAnnotations {
+		annotations = &annotationFilter{}
+	}
+	var redact *redactor
+	if len(config.RedactMask) > 0 || len(config.RedactDrop) > 0 {
+		redact = &redactor{mask: config.RedactMask, drop: config.RedactDrop}
+	}
+	var fields *fieldSelection
+	if len(config.Fields) > 0 {
+		fields = &fieldSelection{fields: config.Fields}
+	}
 	return &Downloader{
 		client:         client,
 		outputMode:     config.OutputMode,
 		maxConnections: config.MaxConnections,
+		reorderWindow:  config.ReorderWindow,
 		deleteWhenDone: config.DeleteWhenDone,
 		sid:            config.SID,
+		mergeSIDs:      config.MergeSIDs,
+		sortByTime:     config.SortByTime,
 		filename:       config.Filename,
+		stdout:         stdout,
+		stdoutFormat:   config.StdoutFormat,
+		dedupeState:    config.DedupeState,
+		dedupeWindow:   config.DedupeWindow,
+		clip:           clip,
+		rawJSON:        config.RawJSON,
+		annotations:    annotations,
+		redactor:       redact,
+		verify:         config.Verify,
+		signingKey:     []byte(config.SigningKey),
+		bucketSize:     config.BucketSize,
+		progress:       make(chan Progress, 1),
+		tokenExpiry:    config.TokenExpiry,
+		search:         config.Search,
+		autoSplit:      config.AutoSplit,
+		splitWindow:    config.SplitWindow,
+		warnings:       &report.Warnings{},
+		seenMessages:   make(map[splunkclient.ResultsMessage]bool),
+		parallelWrites: config.ParallelWrites,
+		resume:         config.Resume,
+		partial:        config.Partial,
+		followInterval: defaultFollowInterval,
+		maxResults:     config.MaxResults,
+
+		failOnJobErrors: config.FailOnJobErrors,
+		allowPartial:    config.AllowPartial,
+		overwrite:       config.Overwrite,
+		append:          config.Append,
+		csvDialect:      newCSVDialect(config),
+		fields:          fields,
+		postFilter:      config.PostFilter,
+		events:          config.Events,
+
+		stopAfter: config.StopAfter,
+
+		tee: config.Tee,
+
+		sinkBatchSize:      config.SinkBatchSize,
+		sinkFlushInterval:  config.SinkFlushInterval,
+		sinkMaxInFlight:    config.SinkMaxInFlight,
+		sinkAttempts:       config.SinkAttempts,
+		sinkBackoff:        config.SinkBackoff,
+		deadLetter:         newDeadLetter(config.DeadLetterFile),
+		webhookHeaders:     config.WebhookHeaders,
+		elasticsearchURL:   config.ElasticsearchURL,
+		kafkaBrokers:       config.KafkaBrokers,
+		kafkaKeyField:      config.KafkaKeyField,
+		kafkaCompression:   config.KafkaCompression,
+		kafkaTLS:           config.KafkaTLS,
+		kafkaTLSCAFile:     config.KafkaTLSCAFile,
+		kafkaTLSCertFile:   config.KafkaTLSCertFile,
+		kafkaTLSKeyFile:    config.KafkaTLSKeyFile,
+		kafkaSASLMechanism: config.KafkaSASLMechanism,
+		kafkaSASLUsername:  config.KafkaSASLUsername,
 	}
 }
 
+// RowsWritten returns the number of results written to the output
+func (d *Downloader) RowsWritten() int {
+	return d.rowsWritten
+}
+
+// BytesWritten returns the number of bytes written to the output, before compression
+func (d *Downloader) BytesWritten() int64 {
+	return d.bytesWritten
+}
+
+// OutputFiles returns the files the results were written to: the output files or the time buckets,
+// not including stdout or cloud storage
+func (d *Downloader) OutputFiles() []string {
+	if d.buckets != nil {
+		return slices.Sorted(maps.Keys(d.buckets.created))
+	}
+	var files []string
+	for _, filename := range d.outputs() {
+		if filename != Stdout && !isRemote(filename) {
+			files = append(files, filename)
+		}
+	}
+	return files
+}
+
+// outputs returns the names of every output the results are written to
+func (d *Downloader) outputs() []string {
+	return append([]string{d.filename}, d.tee...)
+}
+
+// Cost returns the load the downloaded job put on the cluster
+func (d *Downloader) Cost() report.SearchCost {
+	return d.cost
+}
+
+func (d *Downloader) searchCost(jobStatus splunkclient.SearchJobContent) report.SearchCost {
+	cost := report.SearchCost{
+		SID:         d.sid,
+		ScanCount:   jobStatus.ScanCount,
+		EventCount:  jobStatus.EventCount,
+		ResultCount: jobStatus.ResultCount,
+		RunDuration: time.Duration(jobStatus.RunDuration * float64(time.Second)),
+		DiskUsage:   jobStatus.DiskUsage,
+	}
+
+	summary, err := d.client.GetFieldSummary(d.sid, "index")
+	if err != nil {
+		slog.Debug("Unable to determine indexes searched", "error", err)
+		return cost
+	}
+	for _, mode := range summary.Modes {
+		cost.Indexes = append(cost.Indexes, mode.Value)
+	}
+	return cost
+}
+
+// Warnings returns the non-fatal problems encountered while downloading
+func (d *Downloader) Warnings() *report.Warnings {
+	return d.warnings
+}
+
+// TruncationRequest describes the search CheckTruncationLimits checks the limits against
+type TruncationRequest struct {
+	Span      time.Duration // time range the search covers, 0 if it can't be resolved
+	StopAfter int           // results asked for with --stop-after, 0 for all of them
+}
+
+// CheckTruncationLimits returns a warning for every server-side limit that would silently truncate the
+// export of the search about to be dispatched
+func CheckTruncationLimits(limits splunkclient.SearchLimits, request TruncationRequest) []string {
+	var warnings []string
+
+	if limits.MaxResultRows > 0 && limits.MaxResultRows < chunkSize {
+		warnings = append(warnings, fmt.Sprintf("the search head returns at most %d rows per request (limits.conf [restapi] maxresultrows) but spldl requests %d, so results will be missing", limits.MaxResultRows, chunkSize))
+	}
+	if limits.SrchTimeWin > 0 && request.Span > limits.SrchTimeWin {
+		warnings = append(warnings, fmt.Sprintf("the search covers %s but searches by roles %v may only cover %s (srchTimeWin), so events outside that window are excluded", request.Span, limits.Roles, limits.SrchTimeWin))
+	}
+	if limits.MaxCount > 0 && request.StopAfter > limits.MaxCount {
+		warnings = append(warnings, fmt.Sprintf("%d results were asked for but jobs keep at most %d (max_count), so the rest will be missing", request.StopAfter, limits.MaxCount))
+	}
+
+	return warnings
+}
+
+// CheckJobTruncation returns a warning for every server-side limit the finished job ran into
+func CheckJobTruncation(limits splunkclient.SearchLimits, job splunkclient.SearchJobContent) []string {
+	var warnings []string
+
+	if limits.SrchMaxTime > 0 && job.RunDuration >= limits.SrchMaxTime.Seconds() {
+		warnings = append(warnings, fmt.Sprintf("the job ran for %s, as long as searches by roles %v may run (srchMaxTime), so it was finalized with partial results", limits.SrchMaxTime, limits.Roles))
+	}
+	if limits.MaxCount > 0 && job.ResultCount >= limits.MaxCount {
+		warnings = append(warnings, fmt.Sprintf("the job kept %d results, as many as it may (max_count), so the rest are missing", job.ResultCount))
+	}
+
+	return warnings
+}
+
 func (d *Downloader) DownloadSearchResults() error {
+	defer d.closeProgress()
+	defer d.abortOutput()
 	slog.Debug("Starting download process", "sid", d.sid, "output_mode", d.outputMode, "max_connections", d.maxConnections)
+	if len(d.mergeSIDs) > 0 {
+		return d.downloadMergedJobs()
+	}
+	if d.sortByTime {
+		return fmt.Errorf("sorting by _time merges the results of several jobs, give more than one SID")
+	}
 
 	// Get job status to determine total result count
 	jobStatus, err := d.client.GetJobStatus(d.sid)
+	var httpErr *splunkclient.HTTPError
+	if d.resume && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("job %s no longer exists, it may have expired. Start the download again without --resume: %w", d.sid, err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get job status: %w", err)
 	}
@@ -58,17 +349,91 @@ func (d *Downloader) DownloadSearchResults() error {
 	if jobStatus.IsFailed {
 		return fmt.Errorf("job %s has failed", d.sid)
 	}
+	if err := d.checkJobMessages(d.sid, jobStatus); err != nil {
+		return err
+	}
+	if d.events {
+		if err := countEvents(d.sid, &jobStatus); err != nil {
+			return err
+		}
+	}
+
+	if d.stopAfter > maxJobResults {
+		return fmt.Errorf("at most the first %d results of a job can be downloaded", maxJobResults)
+	}
+	if d.stopAfter > 0 && jobStatus.ResultCount > d.stopAfter {
+		// The chunks, row count and manifest all cover only the results that are downloaded
+		slog.Info("Downloading only the first results of the job", "sid", d.sid, "result_count", jobStatus.ResultCount, "stop_after", d.stopAfter)
+		jobStatus.ResultCount = d.stopAfter
+	}
+
+	if d.maxResults > 0 && jobStatus.ResultCount > d.maxResults {
+		return fmt.Errorf("job %s has %d results, more than the %d allowed by the system policy", d.sid, jobStatus.ResultCount, d.maxResults)
+	}
 
-	if jobStatus.ResultCount > 500000 {
-		return fmt.Errorf("job %s has more than 500000 results. Split your search into multiple jobs.", d.sid)
+	if jobStatus.ResultCount > maxJobResults {
+		if d.events {
+			return fmt.Errorf("job %s has more than %d events, which can't be split into several jobs. Narrow the time range of its search", d.sid, maxJobResults)
+		}
+		if !d.autoSplit {
+			return fmt.Errorf("job %s has more than %d results. Split your search into multiple jobs or use --auto-split.", d.sid, maxJobResults)
+		}
+		return d.downloadSplitSearch(jobStatus)
 	}
 
-	totalChunks := (jobStatus.ResultCount / 10000) + 1
-	slog.Info("Starting download", "total_chunks", totalChunks, "chunk_size", chunkSize, "max_connections", d.maxConnections)
+	if d.verify && !hasRecords(d.outputMode) {
+		return fmt.Errorf("verification is not supported for %s output since events may span multiple lines", d.outputMode)
+	}
+	if d.bucketSize > 0 && d.verify {
+		return fmt.Errorf("verification is not supported when writing time buckets")
+	}
+	if d.postFilter != "" && d.verify {
+		return fmt.Errorf("verification is not supported with a post filter since fewer rows are written than the job has")
+	}
+	if d.events && d.verify {
+		return fmt.Errorf("verification recounts the job's results and is not supported when downloading its events")
+	}
+	if d.stopAfter > 0 && d.verify {
+		return fmt.Errorf("verification is not supported when downloading only the first results of a job since fewer rows are written than it has")
+	}
 
-	err = d.downloadJobChunks(totalChunks)
+	err = d.prepareOutput()
 	if err != nil {
-		return fmt.Errorf("failed to download job: %w", err)
+		return err
+	}
+
+	writer, err := d.openCheckpointedOutput(totalChunks(jobStatus.ResultCount))
+	if err != nil {
+		return err
+	}
+	err = d.downloadJob(writer, jobStatus)
+	if err != nil {
+		return errors.Join(err, writer.Close())
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	d.removeCheckpoint()
+	if err := d.checkRowCount(); err != nil {
+		return err
+	}
+
+	if d.verify {
+		err = d.verifyDownload()
+		if err != nil {
+			return err
+		}
+	} else if d.partial && d.filename != Stdout && !isRemote(d.filename) {
+		err = writeManifest(d.filename, Manifest{SID: d.sid, Filename: d.filename, OutputMode: d.outputMode, Search: d.dispatchedSearch(), Partial: true})
+		if err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+
+	err = d.finishOutput()
+	if err != nil {
+		return err
 	}
 
 	if d.deleteWhenDone {
@@ -84,26 +449,337 @@ func (d *Downloader) DownloadSearchResults() error {
 	return nil
 }
 
-func (d *Downloader) downloadJobChunks(totalChunks int) error {
+// totalChunks returns the number of chunks the results of a job are requested in
+func totalChunks(resultCount int) int {
+	return resultCount/chunkSize + 1
+}
+
+// profiled runs f with a pprof label naming its role in the download, so CPU profiles taken with
+// --pprof attribute time to the chunk workers, the collector or the in-place writers
+func profiled(role string, f func()) {
+	pprof.Do(context.Background(), pprof.Labels("spldl", role), func(context.Context) { f() })
+}
+
+// downloadJob downloads the results of the finished job d.sid to writer
+func (d *Downloader) downloadJob(writer chunkOutput, jobStatus splunkclient.SearchJobContent) error {
+	d.resultCount = jobStatus.ResultCount
+	d.expectedRows += jobStatus.ResultCount
+	d.cost.Add(d.searchCost(jobStatus))
+	d.totalChunks = totalChunks(jobStatus.ResultCount)
+	d.failedChunks, d.chunkErr = 0, nil
+	slog.Info("Starting download", "sid", d.sid, "total_chunks", d.totalChunks, "chunk_size", chunkSize, "max_connections", d.maxConnections)
+
+	stopKeepAlive := d.keepJobAlive(d.sid, jobStatus.TTL)
+	defer stopKeepAlive()
+
+	d.startedAt = time.Now()
+	err := d.downloadJobChunks(writer, d.totalChunks)
+	if err != nil {
+		return fmt.Errorf("failed to download job: %w", err)
+	}
+	return nil
+}
+
+// ExportSearchResults runs a search through Splunk's export endpoint and writes the results as they
+// stream in. Unlike DownloadSearchResults it isn't limited to 500000 results and needs no job.
+func (d *Downloader) ExportSearchResults(search, earliest, latest string) error {
+	defer d.closeProgress()
+	defer d.abortOutput()
+	slog.Debug("Starting export", "output_mode", d.outputMode)
+	d.startedAt = time.Now()
+
+	if d.verify {
+		return fmt.Errorf("verification is not supported for exports since they have no job to recount")
+	}
+	if d.postFilter != "" {
+		return fmt.Errorf("post filters are not supported for exports since they have no job, filter the search instead")
+	}
+	if d.events {
+		return fmt.Errorf("events are downloaded from a job and are not supported for exports")
+	}
+	if d.stopAfter > 0 {
+		return fmt.Errorf("downloading only the first results is not supported for exports since they have no job, add | head to the search instead")
+	}
+	err := d.prepareOutput()
+	if err != nil {
+		return err
+	}
+
+	stream, err := d.client.ExportSearch(search, earliest, latest, d.outputMode)
+	if err != nil {
+		return fmt.Errorf("failed to start export: %w", err)
+	}
+	defer stream.Close()
+
+	writer, err := d.openOutput()
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; ; offset++ {
+		page, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to export results: %w", err), writer.Close())
+		}
+
+		for _, warning := range page.Warnings {
+			d.warnings.Addf("export", "%s", warning)
+		}
+		d.reportMessages(page.Messages)
+
+		err = d.writeChunk(writer, eventChunk{offset: offset, data: page.Data})
+		if err != nil {
+			return errors.Join(err, writer.Close())
+		}
+		// The number of batches isn't known until the search is done, so totalChunks stays 0
+		d.sendProgress(offset + 1)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	err = d.finishOutput()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Export completed successfully", "rows", d.rowsWritten, "filename", d.filename)
+	return nil
+}
+
+// prepareOutput checks that the output options work together and loads the dedupe state
+func (d *Downloader) prepareOutput() error {
+	if d.filename == Stdout && d.bucketSize > 0 {
+		return fmt.Errorf("time buckets are written to separate files and can't be written to stdout")
+	}
+	if d.resume && (d.filename == Stdout || d.bucketSize > 0 || d.parallelWrites || d.dedupeState != "" || d.clip != nil) {
+		return fmt.Errorf("resuming is not supported for stdout, time buckets, parallel writes, dedupe or clipping")
+	}
+	if d.parallelWrites && (d.outputMode != "raw" || d.filename == Stdout) {
+		return fmt.Errorf("parallel writes are only supported for raw output to a file")
+	}
+	if isCompressedFile(d.filename) && (d.resume || d.parallelWrites) {
+		return fmt.Errorf("resuming and parallel writes are not supported for compressed output")
+	}
+	if len(d.tee) > 0 {
+		if d.bucketSize > 0 || d.resume || d.parallelWrites {
+			return fmt.Errorf("time buckets, resuming and parallel writes are not supported with more than one output")
+		}
+		seen := make(map[string]bool)
+		for _, filename := range d.outputs() {
+			if seen[filename] {
+				return fmt.Errorf("%s is given as an output more than once", filename)
+			}
+			seen[filename] = true
+		}
+	}
+	for _, filename := range d.outputs() {
+		if err := d.checkTarget(filename); err != nil {
+			return err
+		}
+	}
+	if d.append {
+		// Formats such as .xlsx and .json wrap the results, so more can't be added to the end
+		_, formatted := d.fileFormatOf(d.filename)
+		if d.filename == Stdout || isRemote(d.filename) || isCompressedFile(d.filename) || formatted || len(d.tee) > 0 || d.bucketSize > 0 || d.resume || d.parallelWrites || d.verify {
+			return fmt.Errorf("appending is only supported for a single uncompressed ndjson, csv or raw file, without time buckets, resuming, parallel writes or verification")
+		}
+	}
+	if d.filename == Stdout && d.verify {
+		return fmt.Errorf("verification rereads the output file and is not supported when writing to stdout")
+	}
+	if d.annotations != nil && (!hasRecords(d.outputMode) || d.resume) {
+		return fmt.Errorf("dropping annotations is not supported for %s output or when resuming", d.outputMode)
+	}
+	if d.redactor != nil && (!hasRecords(d.outputMode) || d.resume) {
+		return fmt.Errorf("redacting fields is not supported for %s output or when resuming", d.outputMode)
+	}
+	if d.rawJSON && d.outputMode != "ndjson" {
+		return fmt.Errorf("_raw as JSON is only supported for ndjson output")
+	}
+	if d.bucketSize > 0 && !hasRecords(d.outputMode) {
+		return fmt.Errorf("time buckets are not supported for %s output since it has no _time field", d.outputMode)
+	}
+	if d.fields != nil {
+		if !hasRecords(d.outputMode) || d.rawJSON || d.resume {
+			return fmt.Errorf("selecting fields is not supported for %s output, with _raw as JSON or when resuming", d.outputMode)
+		}
+		if d.bucketSize > 0 && !slices.Contains(d.fields.fields, "_time") {
+			return fmt.Errorf("time buckets need _time among the selected fields")
+		}
+	}
+	if d.csvDialect != nil {
+		if d.outputMode != "csv" {
+			return fmt.Errorf("the delimiter, quoting, line endings and locale can only be changed for csv output")
+		}
+		if err := d.csvDialect.validate(); err != nil {
+			return err
+		}
+		if d.csvDialect.localeTag != "" && d.resume {
+			return fmt.Errorf("locales are not supported when resuming since they need the header the interrupted run wrote")
+		}
+	}
+	for _, filename := range d.outputs() {
+		if err := d.checkTargetOverwrite(filename); err != nil {
+			return err
+		}
+	}
+
+	if d.clip != nil {
+		if !hasRecords(d.outputMode) {
+			return fmt.Errorf("clipping is not supported for %s output since it has no _time field", d.outputMode)
+		}
+		if d.verify {
+			return fmt.Errorf("verification is not supported when clipping since fewer rows are written than the job has")
+		}
+		if d.parallelWrites {
+			return fmt.Errorf("clipping is not supported with parallel writes")
+		}
+	}
+
+	if d.dedupeState != "" {
+		if !hasRecords(d.outputMode) {
+			return fmt.Errorf("dedupe is not supported for %s output", d.outputMode)
+		}
+		var err error
+		d.deduper, err = loadEventDeduper(d.dedupeState, d.dedupeWindow)
+		if err != nil {
+			return fmt.Errorf("failed to load dedupe state: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkTarget checks that an output of the download can take the results
+func (d *Downloader) checkTarget(filename string) error {
+	if filename == Stdout && d.stdoutFormat != "" {
+		format, ok := d.fileFormatOf(filename)
+		if !ok {
+			return fmt.Errorf("unknown file format %s", d.stdoutFormat)
+		}
+		if d.outputMode != format.Mode || d.csvDialect != nil {
+			return fmt.Errorf("%s is written from %s output and can't change its delimiter, quoting, line endings or locale", format.Name, format.Mode)
+		}
+	}
+	if format, ok := FileFormatFor(filename); ok && filename != Stdout {
+		if d.outputMode != format.Mode || d.csvDialect != nil {
+			return fmt.Errorf("%s files are written from %s output and can't change its delimiter, quoting, line endings or locale", format.Extension, format.Mode)
+		}
+		if format.Compressed && isCompressedFile(filename) {
+			return fmt.Errorf("%s files are compressed already, drop the %s", format.Extension, compressionOf(filename))
+		}
+		if d.resume || d.bucketSize > 0 || d.parallelWrites {
+			return fmt.Errorf("resuming, time buckets and parallel writes are not supported for %s output", format.Name)
+		}
+	}
+	if isRemote(filename) && (d.bucketSize > 0 || d.parallelWrites || d.verify && filename == d.filename) {
+		return fmt.Errorf("time buckets, parallel writes and verification are not supported for outputs in cloud storage or on the network")
+	}
+	if isRemote(filename) && !isSink(filename) && d.resume {
+		return fmt.Errorf("resuming is not supported for outputs in cloud storage")
+	}
+	switch outputScheme(filename) {
+	case "es":
+		if d.outputMode != "ndjson" || isCompressedFile(filename) {
+			return fmt.Errorf("es:// outputs index uncompressed ndjson results, one document per result")
+		}
+		if _, err := parseElasticsearchURI(filename); err != nil {
+			return err
+		}
+		if _, err := elasticsearchClientFromEnv(d.elasticsearchURL); err != nil {
+			return err
+		}
+	case "kafka":
+		if d.outputMode != "ndjson" || isCompressedFile(filename) {
+			return fmt.Errorf("kafka:// outputs publish uncompressed ndjson results, one message per result; use --kafka-compression to compress the messages")
+		}
+		if _, _, err := d.kafkaConfig(filename); err != nil {
+			return err
+		}
+	case "http", "https":
+		if d.outputMode != "ndjson" || isCompressedFile(filename) {
+			return fmt.Errorf("http(s):// outputs post uncompressed ndjson results, a batch per request")
+		}
+		if _, err := newWebhook(filename, d.webhookHeaders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTargetOverwrite refuses to replace an existing output unless Overwrite is set. Time buckets are
+// checked as they're created.
+func (d *Downloader) checkTargetOverwrite(filename string) error {
+	switch {
+	case isSink(filename):
+		// Results are added to the index, topic or receiver, replacing nothing
+		return nil
+	case isRemote(filename):
+		return d.checkRemoteOverwrite(filename)
+	case filename != Stdout && d.bucketSize == 0:
+		return checkOverwrite(filename, d.overwrite || d.append)
+	}
+	return nil
+}
+
+// finishOutput moves the output into place and saves the dedupe state once the output is complete
+func (d *Downloader) finishOutput() error {
+	if err := d.publishOutput(); err != nil {
+		return err
+	}
+	if d.clip != nil {
+		slog.Info("Dropped events outside the clip window", "dropped", d.clip.dropped)
+		if d.clip.untimed > 0 {
+			d.warnings.Addf("clip", "%d events without a readable _time were dropped", d.clip.untimed)
+		}
+	}
+	if d.deduper == nil {
+		return nil
+	}
+	slog.Info("Dropped events exported by a previous run", "dropped", d.deduper.dropped)
+	err := d.deduper.save()
+	if err != nil {
+		return fmt.Errorf("failed to save dedupe state: %w", err)
+	}
+	return nil
+}
+
+// downloadJobChunks downloads every chunk of the job d.sid and writes them to writer in order
+func (d *Downloader) downloadJobChunks(writer chunkOutput, totalChunks int) error {
 	slog.Debug("Initializing chunk download", "total_chunks", totalChunks)
+	if output, ok := d.writesInPlace(writer); ok {
+		return d.downloadJobChunksInPlace(output, totalChunks)
+	}
+
 	offsetChan := make(chan int, 100)
 	chunkChan := make(chan eventChunk, 100)
+	windowSize := d.reorderWindow
+	if windowSize <= 0 {
+		windowSize = defaultReorderWindow
+	}
+	// A window smaller than the number of workers would leave connections idle
+	d.window = newReorderWindow(d.firstChunk, max(windowSize, d.maxConnections))
 
 	// Start chunk workers
 	var workerWg sync.WaitGroup
 	slog.Debug("Starting worker goroutines", "worker_count", d.maxConnections)
 	for range d.maxConnections {
-		workerWg.Go(func() { d.chunkWorker(chunkChan, offsetChan) })
+		workerWg.Go(func() { profiled("chunk_worker", func() { d.chunkWorker(chunkChan, offsetChan) }) })
 	}
 
 	// Start collector
 	var collectorWg sync.WaitGroup
+	var collectorErr error
 	slog.Debug("Starting collector goroutine")
-	collectorWg.Go(func() { d.eventChunkCollector(chunkChan) })
+	collectorWg.Go(func() { profiled("collector", func() { collectorErr = d.eventChunkCollector(writer, chunkChan) }) })
 
 	// Send offsets to workers
 	slog.Debug("Dispatching chunk offsets to workers")
-	for i := 0; i < totalChunks; i++ {
+	for i := d.firstChunk; i < totalChunks; i++ {
 		offsetChan <- i
 	}
 	close(offsetChan)
@@ -120,69 +796,449 @@ func (d *Downloader) downloadJobChunks(totalChunks int) error {
 	collectorWg.Wait()
 	slog.Debug("Collector finished")
 
-	return nil
+	if err := d.client.Context().Err(); err != nil {
+		return errors.Join(d.interrupted(err), collectorErr)
+	}
+	if d.chunkErr != nil {
+		return errors.Join(fmt.Errorf("%d chunk(s) could not be downloaded, first failure: %w", d.failedChunks, d.chunkErr), collectorErr)
+	}
+	return collectorErr
+}
+
+// interrupted describes a download stopped by the cancellation of the client's context. The chunks
+// before the first missing one have been written, in order.
+func (d *Downloader) interrupted(err error) error {
+	return fmt.Errorf("download interrupted after %d of %d chunks (%d rows written): %w", d.chunksWritten, d.totalChunks, d.rowsWritten, err)
 }
 
 func (d *Downloader) chunkWorker(chunkChan chan eventChunk, offsetChan chan int) {
 	for offset := range offsetChan {
+		if d.chunkFailed() {
+			// The download is going to fail anyway, don't keep loading the search head
+			continue
+		}
+		if !d.window.wait(offset) {
+			continue
+		}
 		d.getEventChunk(chunkChan, offset)
 	}
 }
 
 func (d *Downloader) getEventChunk(chunkChan chan eventChunk, offset int) {
-	response, err := d.client.GetJobResults(d.sid, chunkSize, offset, d.outputMode)
-	if err != nil {
-		slog.Error("Error getting event chunk", "error", err, "offset", offset)
+	chunk, ok := d.fetchEventChunk(offset)
+	if !ok {
+		d.window.abort()
 		return
 	}
+	chunkChan <- chunk
+}
 
-	chunkChan <- eventChunk{
+// fetchEventChunk downloads a chunk and reports problems with its results. Chunks that couldn't be
+// downloaded are recorded as failed and false is returned.
+func (d *Downloader) fetchEventChunk(offset int) (eventChunk, bool) {
+	page, err := d.fetchChunk(offset)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			slog.Debug("Chunk download canceled", "offset", offset)
+		} else {
+			slog.Error("Error getting event chunk", "error", err, "offset", offset)
+		}
+		d.failedMu.Lock()
+		d.failedChunks++
+		if d.chunkErr == nil {
+			d.chunkErr = fmt.Errorf("chunk %d: %w", offset, err)
+		}
+		d.failedMu.Unlock()
+		return eventChunk{}, false
+	}
+
+	for attempt := 1; page.Malformed && attempt <= malformedChunkRetries; attempt++ {
+		slog.Warn("Received malformed results, retrying chunk", "offset", offset, "attempt", attempt)
+		retried, err := d.fetchChunk(offset)
+		if err != nil {
+			slog.Debug("Retrying malformed chunk failed", "offset", offset, "error", err)
+			continue
+		}
+		if !retried.Malformed || strings.Count(retried.Data, "\n") > strings.Count(page.Data, "\n") {
+			page = retried
+		}
+	}
+	if page.Malformed || page.Skipped > 0 {
+		expected := min(chunkSize, d.resultCount-offset*chunkSize)
+		lost := max(expected-strings.Count(page.Data, "\n"), page.Skipped)
+		slog.Error("Events were lost to malformed results", "offset", offset, "lost", lost, "expected", expected)
+		d.warnings.Addf("chunk", "chunk %d: %d of %d events were lost to malformed results", offset, lost, expected)
+	}
+	for _, warning := range page.Warnings {
+		d.warnings.Addf("chunk", "chunk %d: %s", offset, warning)
+	}
+	d.reportMessages(page.Messages)
+
+	return eventChunk{
 		offset: offset,
-		data:   response,
+		data:   page.Data,
+	}, true
+}
+
+// fetchChunk requests a chunk of results. The client retries requests that failed for a reason that
+// may be temporary, so a chunk that still fails fails the download.
+func (d *Downloader) fetchChunk(offset int) (splunkclient.ResultsPage, error) {
+	// The chunk holding the last result that is downloaded is cut short, and any after it are empty
+	count := chunkSize
+	if d.stopAfter > 0 {
+		count = min(chunkSize, d.stopAfter-offset*chunkSize)
+		if count <= 0 {
+			return splunkclient.ResultsPage{}, nil
+		}
+	}
+	if count == chunkSize {
+		return d.client.GetJobResults(d.sid, chunkSize, offset, d.outputMode, d.resultsFilter())
 	}
+	return d.client.GetJobResultsFrom(d.sid, offset*chunkSize, count, d.outputMode, false, d.resultsFilter())
 }
 
-func (d *Downloader) eventChunkCollector(chunkChannel chan eventChunk) {
-	slog.Debug("Starting chunk collector", "filename", d.filename)
-	chunkBuf := make(map[int]eventChunk)
+// resultsFilter returns what Splunk is asked to narrow the results down to. Clipping needs _time even
+// when it isn't among the selected fields.
+func (d *Downloader) resultsFilter() splunkclient.ResultsFilter {
+	filter := splunkclient.ResultsFilter{Search: d.postFilter, Events: d.events}
+	if d.fields != nil {
+		filter.Fields = d.fields.requested(d.clip != nil)
+	}
+	return filter
+}
 
-	outputFile, err := os.Create(d.filename)
-	if err != nil {
-		slog.Error("Error creating output file", "error", err, "filename", d.filename)
-		return
+// countEvents has a job's events counted as its results, for downloading them. Splunk keeps the events
+// of a transforming search only when the job has a timeline, as jobs of Splunk Web do.
+func countEvents(sid string, status *splunkclient.SearchJobContent) error {
+	if status.EventAvailableCount == 0 && status.EventCount > 0 {
+		return fmt.Errorf("job %s kept none of its %d events, run its search with --dispatch status_buckets=300 to keep them", sid, status.EventCount)
+	}
+	status.ResultCount = status.EventAvailableCount
+	return nil
+}
+
+func (d *Downloader) chunkFailed() bool {
+	d.failedMu.Lock()
+	defer d.failedMu.Unlock()
+	return d.chunkErr != nil
+}
+
+// reportMessages surfaces Splunk's warnings and errors about the results. Every page of a job
+// usually repeats the same messages, so each distinct message is only reported once.
+func (d *Downloader) reportMessages(messages []splunkclient.ResultsMessage) {
+	d.messagesMu.Lock()
+	defer d.messagesMu.Unlock()
+
+	for _, message := range messages {
+		if d.seenMessages[message] {
+			continue
+		}
+		d.seenMessages[message] = true
+		slog.Warn("Splunk reported a problem with the results", "type", message.Type, "message", message.Text)
+		d.warnings.Addf("splunk", "%s: %s", message.Type, message.Text)
+	}
+}
+
+// checkJobMessages surfaces the warnings and errors Splunk attached to a job, such as a search
+// auto-finalized at its time limit or a failing lookup. With FailOnJobErrors, an ERROR or FATAL
+// message fails the download.
+func (d *Downloader) checkJobMessages(sid string, status splunkclient.SearchJobContent) error {
+	var problems, errs []splunkclient.ResultsMessage
+	for _, message := range status.Messages {
+		// Auto-finalized searches aren't always reported as warnings, though their results are incomplete
+		if message.IsProblem() || strings.Contains(message.Text, "auto-finalized") {
+			problems = append(problems, message)
+		}
+		if message.Type == "ERROR" || message.Type == "FATAL" {
+			errs = append(errs, message)
+		}
+	}
+	d.reportMessages(problems)
+
+	if !d.failOnJobErrors || len(errs) == 0 {
+		return nil
+	}
+	texts := make([]string, len(errs))
+	for i, message := range errs {
+		texts[i] = message.Text
 	}
-	defer outputFile.Close()
+	return fmt.Errorf("Splunk reported errors for job %s: %s", sid, strings.Join(texts, "; "))
+}
 
-	writer := bufio.NewWriter(outputFile)
-	defer writer.Flush()
+func (d *Downloader) eventChunkCollector(writer chunkOutput, chunkChannel chan eventChunk) error {
+	slog.Debug("Starting chunk collector", "filename", d.filename)
+	chunkBuf := make(map[int]bufferedChunk)
 
-	nextOffset := 0
-	chunksWritten := 0
+	// A resumed download continues after the chunks written before
+	nextOffset := d.firstChunk
+	chunksWritten := d.firstChunk
+	var writeErr error
 
 	for chunk := range chunkChannel {
 		slog.Debug("Received chunk", "offset", chunk.offset, "expected_offset", nextOffset, "buffered_chunks", len(chunkBuf))
 
 		if chunk.offset == nextOffset {
 			// Write the chunk we need next
-			writer.WriteString(chunk.data)
+			if writeErr == nil {
+				writeErr = d.writeChunk(writer, chunk)
+			}
 			nextOffset++
 			chunksWritten++
+			if writeErr == nil {
+				writeErr = d.checkpointChunks(chunksWritten)
+			}
+			d.sendProgress(chunksWritten)
 			slog.Debug("Wrote chunk in order", "offset", chunk.offset, "chunks_written", chunksWritten)
 		} else {
-			// Buffer chunks that arrive out of order
-			chunkBuf[chunk.offset] = chunk
-			slog.Debug("Buffered out-of-order chunk", "offset", chunk.offset, "expected_offset", nextOffset)
+			// Buffer chunks that arrive out of order, compressed to keep memory usage down
+			buffered, err := compressChunk(chunk)
+			if err != nil && writeErr == nil {
+				writeErr = fmt.Errorf("failed to buffer chunk %d: %w", chunk.offset, err)
+			}
+			chunkBuf[chunk.offset] = buffered
+			slog.Debug("Buffered out-of-order chunk", "offset", chunk.offset, "expected_offset", nextOffset, "size", buffered.size, "compressed_size", len(buffered.compressed))
 		}
 
 		// Write any buffered chunks that are now in order
 		for bufferedChunk, exists := chunkBuf[nextOffset]; exists; bufferedChunk, exists = chunkBuf[nextOffset] {
 			delete(chunkBuf, nextOffset)
-			writer.WriteString(bufferedChunk.data)
+			if writeErr == nil {
+				var chunk eventChunk
+				chunk, writeErr = bufferedChunk.decompress()
+				if writeErr == nil {
+					writeErr = d.writeChunk(writer, chunk)
+				}
+			}
 			nextOffset++
 			chunksWritten++
+			if writeErr == nil {
+				writeErr = d.checkpointChunks(chunksWritten)
+			}
+			d.sendProgress(chunksWritten)
 			slog.Debug("Wrote buffered chunk", "offset", bufferedChunk.offset, "chunks_written", chunksWritten)
 		}
+		d.window.advance(nextOffset)
 	}
 
+	d.chunksWritten = chunksWritten
 	slog.Debug("Chunk collector completed", "total_chunks_written", chunksWritten, "filename", d.filename)
+	return writeErr
+}
+
+// dropRepeatedHeader removes the header from the first chunk of every job after the first, so that
+// jobs of a split search form a single file. Only modes whose records start with a header have one.
+func (d *Downloader) dropRepeatedHeader(data string) (string, error) {
+	codec, ok := newRecordCodec(d.outputMode)
+	if !ok {
+		return data, nil
+	}
+	line, rest, _ := strings.Cut(data, "\n")
+	if line == "" {
+		return data, nil
+	}
+	records, err := codec.Records(line + "\n")
+	if err != nil || len(records) == 0 || !records[0].Header {
+		return data, nil
+	}
+	if d.header == "" {
+		d.header = line
+		return data, nil
+	}
+	if line != d.header {
+		return "", fmt.Errorf("job %s has columns %s instead of %s, use | table to fix the columns of a split search", d.sid, line, d.header)
+	}
+	return rest, nil
+}
+
+// checkTokenExpiry warns once when the download, at its current rate, is predicted to finish after the token expires
+func (d *Downloader) checkTokenExpiry(chunksWritten int, now time.Time) {
+	if d.tokenExpiry.IsZero() || d.expiryWarned || chunksWritten == 0 || chunksWritten >= d.totalChunks {
+		return
+	}
+
+	elapsed := now.Sub(d.startedAt)
+	predictedEnd := now.Add(elapsed * time.Duration(d.totalChunks-chunksWritten) / time.Duration(chunksWritten))
+	if predictedEnd.Before(d.tokenExpiry) {
+		return
+	}
+
+	d.expiryWarned = true
+	slog.Warn("Download is predicted to outlive the token", "predicted_end", predictedEnd.Format(time.RFC3339), "token_expiry", d.tokenExpiry.Format(time.RFC3339))
+	d.warnings.Addf("token", "the download was predicted to finish at %s, after the token expires at %s", predictedEnd.Format(time.RFC3339), d.tokenExpiry.Format(time.RFC3339))
+}
+
+// writeChunk writes a chunk to the output, dropping events that a previous run already exported
+func (d *Downloader) writeChunk(writer chunkOutput, chunk eventChunk) error {
+	data := chunk.data
+	if chunk.offset == 0 {
+		var err error
+		data, err = d.dropRepeatedHeader(data)
+		if err != nil {
+			return err
+		}
+	}
+	if d.clip != nil {
+		var err error
+		data, err = d.clip.filter(data, d.outputMode)
+		if err != nil {
+			return fmt.Errorf("failed to clip chunk %d: %w", chunk.offset, err)
+		}
+	}
+	if d.redactor != nil {
+		var err error
+		data, err = d.redactor.filter(data, d.outputMode)
+		if err != nil {
+			return fmt.Errorf("failed to redact chunk %d: %w", chunk.offset, err)
+		}
+	}
+	if d.deduper != nil {
+		var err error
+		data, err = d.deduper.filter(data, d.outputMode)
+		if err != nil {
+			return fmt.Errorf("failed to dedupe chunk %d: %w", chunk.offset, err)
+		}
+	}
+	if d.annotations != nil {
+		var err error
+		data, err = d.annotations.filter(data, d.outputMode)
+		if err != nil {
+			return fmt.Errorf("failed to drop annotations from chunk %d: %w", chunk.offset, err)
+		}
+	}
+	if d.fields != nil {
+		var err error
+		data, err = d.fields.filter(data, d.outputMode)
+		if err != nil {
+			return fmt.Errorf("failed to select the fields of chunk %d: %w", chunk.offset, err)
+		}
+	}
+	if d.rawJSON {
+		var err error
+		data, err = projectRawJSON(data)
+		if err != nil {
+			return fmt.Errorf("failed to reduce chunk %d to _raw: %w", chunk.offset, err)
+		}
+	}
+	rows, err := d.countRows(data)
+	if err != nil {
+		return fmt.Errorf("failed to count rows of chunk %d: %w", chunk.offset, err)
+	}
+	if d.maxResults > 0 && d.rowsWritten+rows > d.maxResults {
+		return fmt.Errorf("the search returned more than the %d results allowed by the system policy", d.maxResults)
+	}
+	d.rowsWritten += rows
+	// Time buckets parse the CSV Splunk sent and re-encode each record themselves
+	if d.csvDialect != nil && d.buckets == nil {
+		data, err = d.csvDialect.reformat(data)
+		if err != nil {
+			return fmt.Errorf("failed to reformat chunk %d: %w", chunk.offset, err)
+		}
+	}
+
+	n, err := writer.WriteString(data)
+	d.bytesWritten += int64(n)
+	return err
+}
+
+// RowCountError is returned when the rows written don't add up to the results of the job, e.g.
+// because a chunk was truncated or lost to malformed results
+type RowCountError struct {
+	SID      string
+	Expected int // the job's results
+	Written  int // the rows written, counting those clip and dedupe dropped on purpose
+}
+
+func (e *RowCountError) Error() string {
+	return fmt.Sprintf("job %s has %d results but %d rows were written", e.SID, e.Expected, e.Written)
+}
+
+// checkRowCount compares the rows written to the results of the jobs downloaded. A mismatch fails the
+// download unless AllowPartial is set, in which case it's a warning. Raw events may span several
+// lines, so modes without records such as raw aren't checked, and a post filter leaves out results on
+// purpose.
+func (d *Downloader) checkRowCount() error {
+	if !hasRecords(d.outputMode) || d.postFilter != "" {
+		return nil
+	}
+	written := d.rowsWritten
+	if d.clip != nil {
+		written += d.clip.dropped
+	}
+	if d.deduper != nil {
+		written += d.deduper.dropped
+	}
+	if written == d.expectedRows {
+		slog.Debug("Row count matches the job's results", "rows", written)
+		return nil
+	}
+
+	err := &RowCountError{SID: d.sid, Expected: d.expectedRows, Written: written}
+	if !d.allowPartial {
+		return err
+	}
+	slog.Warn("The output is incomplete", "expected", d.expectedRows, "written", written)
+	d.warnings.Addf("rows", "%v, the output is incomplete", err)
+	return nil
+}
+
+// countRows counts the results in a chunk of output, not including a header. Raw events may span
+// lines, so for modes without records it counts lines.
+func (d *Downloader) countRows(data string) (int, error) {
+	if d.rowCodec == nil {
+		codec, ok := newRecordCodec(d.outputMode)
+		if !ok {
+			return strings.Count(data, "\n"), nil
+		}
+		d.rowCodec = codec
+	}
+	return d.rowCodec.Count(data)
+}
+
+// verifyDownload recounts the job's results server-side, compares them to the rows written and
+// records the outcome in the manifest
+func (d *Downloader) verifyDownload() error {
+	slog.Info("Verifying download", "sid", d.sid)
+
+	expected, err := d.client.CountJobResults(d.sid)
+	if err != nil {
+		return fmt.Errorf("failed to recount job results: %w", err)
+	}
+
+	// The output is only moved into place once the download succeeded
+	checksum, err := fileSHA256(partPath(d.filename))
+	if err != nil {
+		return fmt.Errorf("failed to checksum output file: %w", err)
+	}
+
+	verification := &Verification{
+		VerifiedAt:   time.Now().UTC(),
+		ExpectedRows: expected,
+		WrittenRows:  d.rowsWritten,
+		SHA256:       checksum,
+		// Rows dropped by dedupe still count towards the job's results
+		Passed: d.rowsWritten == expected || (d.deduper != nil && d.rowsWritten+d.deduper.dropped == expected),
+	}
+	if len(d.signingKey) > 0 {
+		if err := verification.sign(d.signingKey); err != nil {
+			return fmt.Errorf("failed to sign verification record: %w", err)
+		}
+	}
+
+	err = writeManifest(d.filename, Manifest{
+		SID:          d.sid,
+		Filename:     d.filename,
+		OutputMode:   d.outputMode,
+		Search:       d.dispatchedSearch(),
+		Partial:      d.partial,
+		Verification: verification,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if !verification.Passed {
+		return fmt.Errorf("verification failed: job %s has %d results but %d rows were written", d.sid, expected, d.rowsWritten)
+	}
+	slog.Info("Download verified", "rows", d.rowsWritten, "sha256", checksum, "manifest", manifestPath(d.filename))
+	return nil
 }
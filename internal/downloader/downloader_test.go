@@ -4,19 +4,30 @@ import (
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cschmidt0121/spldl/internal/config"
 	"github.com/cschmidt0121/spldl/internal/splunkclient"
 )
 
 func createTestClient(testServerURL string, outputMode string) *splunkclient.Client {
+	return splunkclient.NewClient(testClientConfig(testServerURL))
+}
+
+// testClientConfig configures a client for the test server
+func testClientConfig(testServerURL string) config.ClientConfig {
 	testURL, _ := url.Parse(testServerURL)
 
 	host := strings.Split(testURL.Host, ":")[0]
@@ -39,7 +50,7 @@ func createTestClient(testServerURL string, outputMode string) *splunkclient.Cli
 		},
 	}
 
-	return splunkclient.NewClient(testConfig)
+	return testConfig
 }
 
 func TestDownloadSearchResults(t *testing.T) {
@@ -76,8 +87,8 @@ func TestDownloadSearchResults(t *testing.T) {
 		expectedError      string
 	}{
 		{
-			name:           "successful download with JSON output",
-			outputMode:     "json",
+			name:           "successful download with NDJSON output",
+			outputMode:     "ndjson",
 			expectedData:   jsonData,
 			sid:            "1756172871.1180",
 			deleteWhenDone: false,
@@ -101,7 +112,7 @@ func TestDownloadSearchResults(t *testing.T) {
 		},
 		{
 			name:           "successful download with delete when done",
-			outputMode:     "json",
+			outputMode:     "ndjson",
 			expectedData:   jsonData,
 			sid:            "1756172871.1180",
 			deleteWhenDone: true,
@@ -109,7 +120,7 @@ func TestDownloadSearchResults(t *testing.T) {
 		},
 		{
 			name:               "job not complete error",
-			outputMode:         "json",
+			outputMode:         "ndjson",
 			sid:                "1756172871.1180",
 			simulateIncomplete: true,
 			shouldError:        true,
@@ -117,7 +128,7 @@ func TestDownloadSearchResults(t *testing.T) {
 		},
 		{
 			name:           "failed job error",
-			outputMode:     "json",
+			outputMode:     "ndjson",
 			sid:            "1756172871.1180",
 			simulateFailed: true,
 			shouldError:    true,
@@ -125,7 +136,7 @@ func TestDownloadSearchResults(t *testing.T) {
 		},
 		{
 			name:            "too many results error",
-			outputMode:      "json",
+			outputMode:      "ndjson",
 			sid:             "1756172871.1180",
 			simulateTooMany: true,
 			shouldError:     true,
@@ -192,9 +203,14 @@ func TestDownloadSearchResults(t *testing.T) {
 					resultsCalled = true
 					resultCallCount++
 
+					// Splunk has no ndjson output mode, so ndjson downloads request json
+					expectedMode := tt.outputMode
+					if expectedMode == "ndjson" {
+						expectedMode = "json"
+					}
 					outputMode := r.URL.Query().Get("output_mode")
-					if outputMode != tt.outputMode {
-						t.Errorf("Expected output_mode=%s, got %s", tt.outputMode, outputMode)
+					if outputMode != expectedMode {
+						t.Errorf("Expected output_mode=%s, got %s", expectedMode, outputMode)
 					}
 
 					var mockResponse []byte
@@ -214,6 +230,13 @@ func TestDownloadSearchResults(t *testing.T) {
 					return
 				}
 
+				// Handle field summary requests used for the cost report
+				if r.URL.Path == "/services/search/v2/jobs/"+tt.sid+"/summary" {
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"fields":{"index":{"count":10,"distinct_count":1,"modes":[{"value":"main","count":10}]}}}`))
+					return
+				}
+
 				// Everything else
 				t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
 				w.WriteHeader(http.StatusNotFound)
@@ -228,6 +251,7 @@ func TestDownloadSearchResults(t *testing.T) {
 				MaxConnections: 8,
 				SID:            tt.sid,
 				Filename:       tempFile.Name(),
+				Overwrite:      true, // the temp file already exists
 			})
 			err = downloader.DownloadSearchResults()
 
@@ -249,6 +273,11 @@ func TestDownloadSearchResults(t *testing.T) {
 				t.Error("Expected job status to be called")
 			}
 
+			cost := downloader.Cost()
+			if cost.ResultCount != 2 || len(cost.Indexes) != 1 || cost.Indexes[0] != "main" {
+				t.Errorf("Unexpected search cost %+v", cost)
+			}
+
 			if !tt.shouldError {
 				if !resultsCalled {
 					t.Error("Expected results to be called")
@@ -273,8 +302,8 @@ func TestDownloadSearchResults(t *testing.T) {
 						t.Error("Expected output file to have content")
 					}
 
-					// For JSON mode, verify it contains processed JSON lines
-					if tt.outputMode == "json" {
+					// For NDJSON mode, verify it contains processed JSON lines
+					if tt.outputMode == "ndjson" {
 						var js interface{}
 						for _, line := range strings.Split(string(fileContent), "\n") {
 							if line == "" {
@@ -284,6 +313,18 @@ func TestDownloadSearchResults(t *testing.T) {
 								t.Errorf("Output file content is not valid JSON: %v\nContent: %s", err, line)
 							}
 						}
+
+						// Splunk's WARN message should be surfaced while INFO is not
+						var splunkWarnings []string
+						for _, warning := range downloader.Warnings().List() {
+							if warning.Source == "splunk" {
+								splunkWarnings = append(splunkWarnings, warning.Message)
+							}
+						}
+						expectedWarnings := []string{"WARN: The lookup table 'user_info' does not exist or is not available."}
+						if !reflect.DeepEqual(splunkWarnings, expectedWarnings) {
+							t.Errorf("Expected Splunk warnings %q, got %q", expectedWarnings, splunkWarnings)
+						}
 					}
 					// For CSV mode, verify it is a proper CSV file
 					if tt.outputMode == "csv" {
@@ -306,3 +347,433 @@ func TestDownloadSearchResults(t *testing.T) {
 		})
 	}
 }
+
+func TestChunkRetries(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	csvData, err := os.ReadFile("testdata/results.csv")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+
+	tests := []struct {
+		name          string
+		failures      int // requests for results that fail before they succeed
+		status        int
+		expectedCalls int
+		expectedError string
+	}{
+		{name: "recovers from temporary failures", failures: 2, status: http.StatusServiceUnavailable, expectedCalls: 3},
+		{name: "fails after the last attempt", failures: 10, status: http.StatusBadGateway, expectedCalls: 4, expectedError: "1 chunk(s) could not be downloaded"},
+		{name: "doesn't retry client errors", failures: 10, status: http.StatusNotFound, expectedCalls: 1, expectedError: "HTTP 404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resultCalls := 0
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/services/search/v2/jobs/" + sid:
+					w.Write(jobStatusData)
+				case "/services/search/v2/jobs/" + sid + "/results":
+					resultCalls++
+					if resultCalls <= tt.failures {
+						w.WriteHeader(tt.status)
+						return
+					}
+					w.Write(csvData)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer testServer.Close()
+
+			filename := t.TempDir() + "/results.csv"
+			clientConfig := testClientConfig(testServer.URL)
+			clientConfig.MaxRetries = 3
+			clientConfig.RetryBackoff = time.Millisecond
+			downloader := NewDownloader(splunkclient.NewClient(clientConfig), config.DownloaderConfig{
+				OutputMode:     "csv",
+				MaxConnections: 1,
+				SID:            sid,
+				Filename:       filename,
+			})
+			err := downloader.DownloadSearchResults()
+
+			if resultCalls != tt.expectedCalls {
+				t.Errorf("Expected %d requests for results, got %d", tt.expectedCalls, resultCalls)
+			}
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				written, _ := os.ReadFile(filename)
+				if !bytes.Equal(written, csvData) {
+					t.Errorf("Output doesn't match the results")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestCheckTokenExpiry(t *testing.T) {
+	start := time.Date(2025, 8, 26, 1, 0, 0, 0, time.UTC)
+	d := NewDownloader(nil, config.DownloaderConfig{TokenExpiry: start.Add(time.Hour)})
+	d.startedAt = start
+	d.totalChunks = 10
+
+	// 2 of 10 chunks in 10 minutes finishes at 01:50, before the token expires
+	d.checkTokenExpiry(2, start.Add(10*time.Minute))
+	if d.warnings.Len() != 0 {
+		t.Errorf("Expected no warning, got %v", d.warnings.Summary())
+	}
+
+	// 2 of 10 chunks in 20 minutes finishes at 02:40, after the token expires
+	d.checkTokenExpiry(2, start.Add(20*time.Minute))
+	d.checkTokenExpiry(3, start.Add(30*time.Minute))
+	if d.warnings.Len() != 1 {
+		t.Errorf("Expected a single warning, got %v", d.warnings.Summary())
+	}
+}
+
+func TestCheckTruncationLimits(t *testing.T) {
+	limits := splunkclient.SearchLimits{Roles: []string{"analyst"}, MaxResultRows: 50000, SrchMaxTime: time.Hour, SrchTimeWin: 24 * time.Hour, MaxCount: 100000}
+
+	// A search within the role's time window, asking for fewer results than a job keeps
+	warnings := CheckTruncationLimits(limits, TruncationRequest{Span: time.Hour, StopAfter: 1000})
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+
+	warnings = CheckTruncationLimits(limits, TruncationRequest{Span: 7 * 24 * time.Hour, StopAfter: 200000})
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "srchTimeWin") || !strings.Contains(warnings[1], "max_count") {
+		t.Errorf("Expected srchTimeWin and max_count warnings, got %v", warnings)
+	}
+
+	// Only a job that ran into a limit is reported
+	warnings = CheckJobTruncation(limits, splunkclient.SearchJobContent{RunDuration: 120, ResultCount: 5000})
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+	warnings = CheckJobTruncation(limits, splunkclient.SearchJobContent{RunDuration: 3600.4, ResultCount: 100000})
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "srchMaxTime") || !strings.Contains(warnings[1], "max_count") {
+		t.Errorf("Expected srchMaxTime and max_count warnings, got %v", warnings)
+	}
+}
+
+func TestParallelWrites(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+	// Four chunks, the last one partial
+	jobStatusData = bytes.Replace(jobStatusData, []byte(`"resultCount": 2,`), []byte(`"resultCount": 35000,`), 1)
+
+	chunkData := func(offset int) string {
+		var b strings.Builder
+		for i := range 3 + offset {
+			b.WriteString("event " + strconv.Itoa(offset) + "-" + strconv.Itoa(i) + "\n")
+		}
+		return b.String()
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			chunk := offset / 10000
+			// Finish the chunks in reverse order so later regions are written first
+			time.Sleep(time.Duration(4-chunk) * 10 * time.Millisecond)
+			w.Write([]byte(chunkData(chunk)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.txt"
+	downloader := NewDownloader(createTestClient(testServer.URL, "raw"), config.DownloaderConfig{
+		OutputMode:     "raw",
+		MaxConnections: 4,
+		SID:            sid,
+		Filename:       filename,
+		ParallelWrites: true,
+	})
+	err = downloader.DownloadSearchResults()
+	if err != nil {
+		t.Fatalf("DownloadSearchResults returned error: %v", err)
+	}
+
+	expected := chunkData(0) + chunkData(1) + chunkData(2) + chunkData(3)
+	written, _ := os.ReadFile(filename)
+	if string(written) != expected {
+		t.Errorf("Expected %q, got %q", expected, written)
+	}
+	if downloader.rowsWritten != 18 || downloader.bytesWritten != int64(len(expected)) {
+		t.Errorf("Unexpected counters: %d rows, %d bytes", downloader.rowsWritten, downloader.bytesWritten)
+	}
+}
+
+func TestJobMessages(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	jobStatusData = bytes.Replace(jobStatusData, []byte(`"messages": []`), []byte(`"messages": [
+		{"type": "INFO", "text": "Search auto-finalized after time limit (30 seconds) reached."},
+		{"type": "DEBUG", "text": "Configuration initialization took 0.01 seconds."},
+		{"type": "ERROR", "text": "The lookup table 'users' does not exist."}
+	]`), 1)
+	csvData, err := os.ReadFile("testdata/results.csv")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			w.Write(csvData)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	for _, failOnJobErrors := range []bool{false, true} {
+		filename := t.TempDir() + "/results.csv"
+		downloader := NewDownloader(createTestClient(testServer.URL, "csv"), config.DownloaderConfig{
+			OutputMode:      "csv",
+			MaxConnections:  1,
+			SID:             sid,
+			Filename:        filename,
+			FailOnJobErrors: failOnJobErrors,
+		})
+		err := downloader.DownloadSearchResults()
+
+		if failOnJobErrors {
+			if err == nil || !strings.Contains(err.Error(), "The lookup table 'users' does not exist.") {
+				t.Errorf("Expected the job's error to fail the download, got %v", err)
+			}
+			if _, statErr := os.Stat(filename); !os.IsNotExist(statErr) {
+				t.Errorf("Expected no output file, got %v", statErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		summary := strings.Join(downloader.Warnings().Summary(), "\n")
+		if !strings.Contains(summary, "auto-finalized") || !strings.Contains(summary, "lookup table") || strings.Contains(summary, "Configuration") {
+			t.Errorf("Expected the auto-finalize and lookup messages as warnings, got %q", summary)
+		}
+	}
+}
+
+func TestRowCountMismatch(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	// The job claims more results than the results response holds, as with a truncated response
+	jobStatusData = bytes.Replace(jobStatusData, []byte(`"resultCount": 2,`), []byte(`"resultCount": 5,`), 1)
+	csvData, err := os.ReadFile("testdata/results.csv")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			w.Write(csvData)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	for _, allowPartial := range []bool{false, true} {
+		downloader := NewDownloader(createTestClient(testServer.URL, "csv"), config.DownloaderConfig{
+			OutputMode:     "csv",
+			MaxConnections: 1,
+			SID:            sid,
+			Filename:       t.TempDir() + "/results.csv",
+			AllowPartial:   allowPartial,
+		})
+		err := downloader.DownloadSearchResults()
+
+		if !allowPartial {
+			var rowErr *RowCountError
+			if !errors.As(err, &rowErr) || rowErr.Expected != 5 || rowErr.Written != 2 {
+				t.Errorf("Expected a row count error for 5 results and 2 rows, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error with AllowPartial, got %v", err)
+		}
+		if summary := strings.Join(downloader.Warnings().Summary(), "\n"); !strings.Contains(summary, "5 results but 2 rows were written") {
+			t.Errorf("Expected a warning about the incomplete output, got %q", summary)
+		}
+	}
+}
+
+func TestPostFilter(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+	const filtered = "_time,host\n2025-08-26T02:00:00.000+00:00,web01\n"
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			if search := r.URL.Query().Get("search"); search != "search error OR warn" {
+				t.Errorf("Expected the post filter as search parameter, got %q", search)
+			}
+			// Only one of the job's results passes the filter
+			w.Write([]byte(filtered))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.csv"
+	downloader := NewDownloader(createTestClient(testServer.URL, "csv"), config.DownloaderConfig{
+		OutputMode:     "csv",
+		MaxConnections: 1,
+		SID:            sid,
+		Filename:       filename,
+		PostFilter:     "error OR warn",
+	})
+	// Fewer rows than the job's results are expected, so they don't fail the row count check
+	if err := downloader.DownloadSearchResults(); err != nil {
+		t.Fatalf("DownloadSearchResults returned error: %v", err)
+	}
+	output, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != filtered {
+		t.Errorf("Expected %q, got %q", filtered, output)
+	}
+}
+
+func TestStopAfter(t *testing.T) {
+	const sid = "1756172871.1180"
+	var mu sync.Mutex
+	var requested []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write([]byte(`{"entry":[{"content":{"sid":"` + sid + `","dispatchState":"DONE","isDone":true,"resultCount":25000}}]}`))
+		case "/services/search/v2/jobs/" + sid + "/results":
+			query := r.URL.Query()
+			mu.Lock()
+			requested = append(requested, query.Get("offset")+"+"+query.Get("count"))
+			mu.Unlock()
+			count, _ := strconv.Atoi(query.Get("count"))
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			var sb strings.Builder
+			sb.WriteString("n\n")
+			for i := range count {
+				fmt.Fprintf(&sb, "%d\n", offset+i)
+			}
+			w.Write([]byte(sb.String()))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.csv"
+	downloader := NewDownloader(createTestClient(testServer.URL, "csv"), config.DownloaderConfig{
+		OutputMode:     "csv",
+		MaxConnections: 2,
+		SID:            sid,
+		Filename:       filename,
+		StopAfter:      12000,
+	})
+	if err := downloader.DownloadSearchResults(); err != nil {
+		t.Fatalf("DownloadSearchResults returned error: %v", err)
+	}
+	if downloader.RowsWritten() != 12000 {
+		t.Errorf("Expected 12000 rows, got %d", downloader.RowsWritten())
+	}
+	slices.Sort(requested)
+	if !slices.Equal(requested, []string{"0+10000", "10000+2000"}) {
+		t.Errorf("Expected the second chunk to be cut short, got requests %v", requested)
+	}
+	output, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(output), "\n11999\n") {
+		t.Errorf("Expected the output to end with result 11999, got %q", output[len(output)-20:])
+	}
+}
+
+func TestEvents(t *testing.T) {
+	const sid = "1756172871.1180"
+	const events = "_time,_raw\n2025-08-26T03:00:00.000+00:00,c\n2025-08-26T02:00:00.000+00:00,b\n2025-08-26T01:00:00.000+00:00,a\n"
+	available := 3
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			// A transforming search with a single result aggregating three events
+			fmt.Fprintf(w, `{"entry":[{"content":{"sid":"%s","dispatchState":"DONE","isDone":true,"resultCount":1,"eventCount":3,"eventAvailableCount":%d}}]}`, sid, available)
+		case "/services/search/v2/jobs/" + sid + "/events":
+			w.Write([]byte(events))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/events.csv"
+	downloaderConfig := config.DownloaderConfig{
+		OutputMode:     "csv",
+		MaxConnections: 1,
+		SID:            sid,
+		Filename:       filename,
+		Events:         true,
+	}
+	downloader := NewDownloader(createTestClient(testServer.URL, "csv"), downloaderConfig)
+	if err := downloader.DownloadSearchResults(); err != nil {
+		t.Fatalf("DownloadSearchResults returned error: %v", err)
+	}
+	output, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != events || downloader.RowsWritten() != 3 {
+		t.Errorf("Expected the job's 3 events, got %d rows: %q", downloader.RowsWritten(), output)
+	}
+
+	// The job of a transforming search dispatched without a timeline keeps no events
+	available = 0
+	downloaderConfig.Overwrite = true
+	downloader = NewDownloader(createTestClient(testServer.URL, "csv"), downloaderConfig)
+	if err := downloader.DownloadSearchResults(); err == nil || !strings.Contains(err.Error(), "status_buckets") {
+		t.Errorf("Expected an error about status_buckets, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package downloader
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// elasticsearchClient sends requests to an Elasticsearch or OpenSearch cluster, authorized with basic
+// auth when a user is set
+type elasticsearchClient struct {
+	endpoint *url.URL
+	username string
+	password string
+}
+
+// elasticsearchClientFromEnv configures the client for the cluster at rawURL, ELASTICSEARCH_URL when
+// it's empty. The user and password are taken from the URL or else from ELASTICSEARCH_USERNAME and
+// ELASTICSEARCH_PASSWORD, so they needn't be on the command line.
+func elasticsearchClientFromEnv(rawURL string) (*elasticsearchClient, error) {
+	rawURL = cmp.Or(rawURL, os.Getenv("ELASTICSEARCH_URL"))
+	if rawURL == "" {
+		return nil, fmt.Errorf("es:// outputs need --elasticsearch-url or ELASTICSEARCH_URL to be set")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid Elasticsearch URL %q, expected e.g. https://localhost:9200", rawURL)
+	}
+	c := &elasticsearchClient{
+		username: os.Getenv("ELASTICSEARCH_USERNAME"),
+		password: os.Getenv("ELASTICSEARCH_PASSWORD"),
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	c.endpoint = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(u.Path, "/")}
+	return c, nil
+}
+
+// bulk indexes the documents of an ndjson body of actions and sources and returns the response. The
+// request is sent once, the batch it carries is retried as a whole.
+func (c *elasticsearchClient) bulk(ctx context.Context, index string, body []byte) ([]byte, error) {
+	u := *c.endpoint
+	u.Path += "/" + index + "/_bulk"
+	u.RawPath = uriEscapePath(u.Path)
+	_, response, err := sendStorageRequestOnce(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		return req, nil
+	})
+	return response, err
+}
+
+// elasticsearchIndex indexes ndjson results into an Elasticsearch or OpenSearch index with the _bulk
+// API, one document per result. Unlike objects in cloud storage, documents are searchable as soon as
+// their batch is sent, so a failed download leaves the batches indexed before it failed.
+type elasticsearchIndex struct {
+	client *elasticsearchClient
+	index  string
+}
+
+// parseElasticsearchURI returns the index an es://index output names
+func parseElasticsearchURI(uri string) (string, error) {
+	_, index, _ := strings.Cut(uri, "://")
+	if index == "" || strings.Contains(index, "/") || index != strings.ToLower(index) {
+		return "", fmt.Errorf("invalid output %s, expected es://index with a lowercase index name", uri)
+	}
+	return index, nil
+}
+
+// bulkAction is the action line of a document in a _bulk request
+type bulkAction struct {
+	ID string `json:"_id,omitempty"` // the index picks one when empty
+}
+
+// bulkResponse is the part of a _bulk response telling which documents failed
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// send indexes a batch. Documents rejected because the cluster is overloaded are sent again, those
+// rejected as invalid fail the batch for good.
+func (e *elasticsearchIndex) send(ctx context.Context, batch *resultBatch) error {
+	var body bytes.Buffer
+	for i, result := range batch.results {
+		// A document sent again, by a retry or a resumed download, replaces itself
+		action, _ := json.Marshal(map[string]bulkAction{"index": {ID: batch.resultID(i)}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(result.data)
+		body.WriteByte('\n')
+	}
+	response, err := e.client.bulk(ctx, e.index, body.Bytes())
+	if isPermanentStatus(err) {
+		return &permanentError{err}
+	}
+	if err != nil {
+		return err
+	}
+	var bulk bulkResponse
+	if err := json.Unmarshal(response, &bulk); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	if !bulk.Errors {
+		return nil
+	}
+	var rejected []sinkResult
+	var reason string
+	permanent := false
+	for i, item := range bulk.Items {
+		for _, result := range item {
+			if result.Status < 300 || i >= len(batch.results) {
+				continue
+			}
+			rejected = append(rejected, batch.results[i])
+			if reason == "" {
+				reason = result.Error.Type + ": " + result.Error.Reason
+			}
+			if result.Status != http.StatusTooManyRequests {
+				permanent = true
+			}
+		}
+	}
+	total := len(batch.results)
+	// The documents that were indexed aren't sent again
+	batch.results = rejected
+	err = fmt.Errorf("%d of %d results were rejected by index %s, the first with %s", len(rejected), total, e.index, reason)
+	if permanent {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// close has nothing to release, the requests don't share a connection
+func (e *elasticsearchIndex) close() {}
+
+// openElasticsearch starts indexing the output into the index uri names
+func (d *Downloader) openElasticsearch(uri string) (*fileOutput, error) {
+	index, err := parseElasticsearchURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := elasticsearchClientFromEnv(d.elasticsearchURL)
+	if err != nil {
+		return nil, err
+	}
+	dest := &elasticsearchIndex{client: client, index: index}
+	return newOutputTo(newBatchSink(d.client.Context(), uri, d.sinkOptions(), dest), uri), nil
+}
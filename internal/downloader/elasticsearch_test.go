@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/report"
+)
+
+func TestElasticsearchIndex(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "elastic" || password != "changeme" || r.URL.Path != "/logs-splunk/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		if strings.Contains(string(body), "rejected") {
+			io.WriteString(w, `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [bytes]"}}}]}`)
+			return
+		}
+		io.WriteString(w, `{"errors":false,"items":[]}`)
+	}))
+	defer server.Close()
+	t.Setenv("ELASTICSEARCH_USERNAME", "elastic")
+	t.Setenv("ELASTICSEARCH_PASSWORD", "changeme")
+
+	client, err := elasticsearchClientFromEnv(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := sinkOptions{batchSize: 2, maxInFlight: 1}
+	index := newBatchSink(context.Background(), "es://logs-splunk", options, &elasticsearchIndex{client: client, index: "logs-splunk"})
+	// Results are split across writes, and the last one has no newline
+	for _, data := range []string{`{"a":1}` + "\n" + `{"a"`, `:2}` + "\n\n", `{"a":3}`} {
+		if _, err := io.WriteString(index, data); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := index.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	expected := []string{
+		"{\"index\":{}}\n{\"a\":1}\n{\"index\":{}}\n{\"a\":2}\n",
+		"{\"index\":{}}\n{\"a\":3}\n",
+	}
+	if strings.Join(requests, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected bulk requests %q, got %q", expected, requests)
+	}
+
+	// The documents of a job's results are identified by its sid and their position, so sending them
+	// again replaces them
+	requests = nil
+	index = newBatchSink(context.Background(), "es://logs-splunk", sinkOptions{batchSize: 2, maxInFlight: 1, sid: "1700000000.1"}, &elasticsearchIndex{client: client, index: "logs-splunk"})
+	index.resumeAt(10000)
+	io.WriteString(index, `{"a":1}`+"\n")
+	if err := index.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "{\"index\":{\"_id\":\"1700000000.1:10000\"}}\n{\"a\":1}\n" {
+		t.Errorf("Expected a document with an _id, got %q", requests)
+	}
+
+	index = newBatchSink(context.Background(), "es://logs-splunk", options, &elasticsearchIndex{client: client, index: "logs-splunk"})
+	if _, err := io.WriteString(index, `{"a":1}`+"\n"+`{"rejected":true}`+"\n"); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	err = index.Commit()
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 results were rejected by index logs-splunk, the first with mapper_parsing_exception") {
+		t.Errorf("Expected the rejected result to fail the commit, got %v", err)
+	}
+
+	// With a dead-letter file, only the rejected result goes there and the commit succeeds
+	options.deadLetter = newDeadLetter(filepath.Join(t.TempDir(), "dead.ndjson"))
+	options.warnings = &report.Warnings{}
+	index = newBatchSink(context.Background(), "es://logs-splunk", options, &elasticsearchIndex{client: client, index: "logs-splunk"})
+	if _, err := io.WriteString(index, `{"a":1}`+"\n"+`{"rejected":true}`+"\n"); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := index.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(options.deadLetter.path); string(data) != `{"rejected":true}`+"\n" {
+		t.Errorf("Expected the rejected result in the dead-letter file, got %q", data)
+	}
+	if len(options.warnings.List()) != 1 {
+		t.Errorf("Expected a warning about the dead-letter file, got %v", options.warnings.List())
+	}
+
+	for uri, valid := range map[string]bool{"es://logs-splunk": true, "es://": false, "es://Logs": false, "es://logs/doc": false} {
+		if _, err := parseElasticsearchURI(uri); (err == nil) != valid {
+			t.Errorf("Expected parseElasticsearchURI(%q) to be valid: %t, got %v", uri, valid, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"slices"
+	"strings"
+)
+
+// fieldSelection keeps the selected fields of each event in the order they were given. Splunk is
+// asked for only those fields, but leaves their order up to the output mode and may add its own.
+type fieldSelection struct {
+	recordReader
+	fields []string
+}
+
+// filter reduces a chunk of output to the selected fields. Values keep their original encoding, only
+// the fields are picked and ordered.
+func (f *fieldSelection) filter(data string, outputMode string) (string, error) {
+	records, err := f.records(data, outputMode, "selecting fields")
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, record := range records {
+		// Every selected field is in the header, and a csv row has an empty column for a field it lacks,
+		// so the header is the same whatever the search returned
+		selected := make([]Field, len(f.fields))
+		for i, name := range f.fields {
+			selected[i] = Field{Name: name, Absent: true}
+			if j := slices.IndexFunc(record.Fields, func(field Field) bool { return field.Name == name }); j >= 0 {
+				selected[i] = record.Fields[j]
+			}
+		}
+		encoded, err := f.codec.Encode(selected, record.Header)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(encoded)
+	}
+	return sb.String(), nil
+}
+
+// requested returns the fields to ask Splunk for, which includes _time when clipping needs it
+func (f *fieldSelection) requested(needTime bool) []string {
+	if needTime && !slices.Contains(f.fields, "_time") {
+		return append(slices.Clone(f.fields), "_time")
+	}
+	return f.fields
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldSelection(t *testing.T) {
+	tests := []struct {
+		name       string
+		outputMode string
+		chunks     []string
+		expected   string
+	}{
+		{
+			name:       "ndjson keeps the selected keys in order",
+			outputMode: "ndjson",
+			chunks: []string{
+				`{"_raw":"a \"quoted\" line","_time":"2025-08-26T02:00:00.000+00:00","host":"web01","source":"/var/log/a"}` + "\n",
+				`{"_time":"2025-08-26T02:00:01.000+00:00","source":"/var/log/b","extra":1}` + "\n",
+			},
+			expected: `{"host":"web01","_time":"2025-08-26T02:00:00.000+00:00","_raw":"a \"quoted\" line"}` + "\n" +
+				`{"_time":"2025-08-26T02:00:01.000+00:00"}` + "\n",
+		},
+		{
+			name:       "csv reorders the columns of every chunk",
+			outputMode: "csv",
+			chunks: []string{
+				"\"_raw\",\"_time\",host,source\n\"a, b\",2025-08-26T02:00:00.000+00:00,web01,/var/log/a\n",
+				"c,2025-08-26T02:00:01.000+00:00,web02,/var/log/b\n",
+			},
+			expected: "host,_time,_raw\nweb01,2025-08-26T02:00:00.000+00:00,\"a, b\"\nweb02,2025-08-26T02:00:01.000+00:00,c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := &fieldSelection{fields: []string{"host", "_time", "_raw"}}
+			var output string
+			for _, chunk := range tt.chunks {
+				filtered, err := selection.filter(chunk, tt.outputMode)
+				if err != nil {
+					t.Fatalf("filter returned error: %v", err)
+				}
+				output += filtered
+			}
+			if output != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, output)
+			}
+		})
+	}
+
+	selection := &fieldSelection{fields: []string{"host"}}
+	if requested := selection.requested(true); !reflect.DeepEqual(requested, []string{"host", "_time"}) {
+		t.Errorf("Expected _time to be requested for clipping, got %v", requested)
+	}
+	if requested := selection.requested(false); !reflect.DeepEqual(requested, []string{"host"}) {
+		t.Errorf("Expected only the selected fields, got %v", requested)
+	}
+}
@@ -0,0 +1,155 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// How often a running job is polled for new results while following it
+const defaultFollowInterval = 5 * time.Second
+
+// FollowSearchResults downloads the results of a job that may still be running. The job is polled for
+// the results found since the last poll, pulled from the last seen offset, until it is done. This
+// only works for searches whose results are appended to as they're found, such as event searches.
+// A realtime search is followed until the client's context is canceled.
+func (d *Downloader) FollowSearchResults() error {
+	defer d.closeProgress()
+	defer d.abortOutput()
+	slog.Debug("Starting to follow job", "sid", d.sid, "output_mode", d.outputMode)
+
+	if !hasRecords(d.outputMode) {
+		return fmt.Errorf("following a job is not supported for %s output since events may span multiple lines", d.outputMode)
+	}
+	if d.resume {
+		return fmt.Errorf("resuming is not supported while following a job")
+	}
+	if d.postFilter != "" {
+		return fmt.Errorf("post filters are not supported while following a job, filter the search instead")
+	}
+	if d.stopAfter > 0 {
+		return fmt.Errorf("downloading only the first results is not supported while following a job, add | head to the search instead")
+	}
+	if d.bucketSize > 0 && d.verify {
+		return fmt.Errorf("verification is not supported when writing time buckets")
+	}
+	err := d.prepareOutput()
+	if err != nil {
+		return err
+	}
+	writer, err := d.openOutput()
+	if err != nil {
+		return err
+	}
+
+	d.startedAt = time.Now()
+	err = d.followJob(writer)
+	if err != nil {
+		return errors.Join(err, writer.Close())
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	if d.verify {
+		err = d.verifyDownload()
+		if err != nil {
+			return err
+		}
+	}
+	err = d.finishOutput()
+	if err != nil {
+		return err
+	}
+	if d.deleteWhenDone {
+		err = d.client.DeleteSearchJob(d.sid)
+		if err != nil {
+			return fmt.Errorf("failed to delete job: %w", err)
+		}
+	}
+
+	slog.Info("Followed job to completion", "sid", d.sid, "rows", d.rowsWritten, "filename", d.filename)
+	return nil
+}
+
+// followJob writes the job's results to writer as they're found, until the job is done
+func (d *Downloader) followJob(writer chunkOutput) error {
+	cursor := 0 // the number of results pulled so far
+	pages := 0
+	for {
+		status, err := d.client.GetJobStatus(d.sid)
+		if err != nil {
+			return fmt.Errorf("failed to get job status: %w", err)
+		}
+		if status.IsFailed {
+			return fmt.Errorf("job %s has failed", d.sid)
+		}
+		if err := d.checkJobMessages(d.sid, status); err != nil {
+			return err
+		}
+		// The results pulled after the job is seen done are its final ones
+		done := status.IsDone
+
+		for {
+			page, err := d.client.GetJobResultsFrom(d.sid, cursor, chunkSize, d.outputMode, !done, d.resultsFilter())
+			if err != nil {
+				if ctxErr := d.client.Context().Err(); ctxErr != nil {
+					return fmt.Errorf("stopped following job %s after %d results: %w", d.sid, cursor, ctxErr)
+				}
+				return fmt.Errorf("failed to get results from offset %d: %w", cursor, err)
+			}
+			results, err := d.pageResults(page.Data)
+			if err != nil {
+				return fmt.Errorf("failed to count results from offset %d: %w", cursor, err)
+			}
+			// Results dropped while converting the page were still seen
+			results += page.Skipped
+			if results == 0 {
+				break
+			}
+
+			for _, warning := range page.Warnings {
+				d.warnings.Addf("chunk", "results from offset %d: %s", cursor, warning)
+			}
+			d.reportMessages(page.Messages)
+
+			err = d.writeChunk(writer, eventChunk{offset: pages, data: page.Data})
+			if err != nil {
+				return err
+			}
+			cursor += results
+			pages++
+			d.resultCount = cursor
+			d.sendProgress(pages)
+			slog.Debug("Pulled results", "sid", d.sid, "results", results, "cursor", cursor, "done", done)
+			if results < chunkSize {
+				break
+			}
+		}
+
+		if done {
+			return nil
+		}
+		select {
+		case <-time.After(d.followInterval):
+		case <-d.client.Context().Done():
+			return fmt.Errorf("stopped following job %s after %d results: %w", d.sid, cursor, d.client.Context().Err())
+		}
+	}
+}
+
+// pageResults counts the results in a page, not including the header of the first page. Raw events
+// may span lines, so for modes without records it's only an estimate.
+func (d *Downloader) pageResults(data string) (int, error) {
+	if d.pageCodec == nil {
+		codec, ok := newRecordCodec(d.outputMode)
+		if !ok {
+			return strings.Count(data, "\n"), nil
+		}
+		d.pageCodec = codec
+	}
+	return d.pageCodec.Count(data)
+}
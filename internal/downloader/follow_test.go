@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestFollowSearchResults(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	runningStatus := bytes.Replace(jobStatusData, []byte(`"isDone": true`), []byte(`"isDone": false`), 1)
+	const sid = "1756172871.1180"
+
+	// The job finds two results before the first poll and one more before it's done
+	var polls atomic.Int32
+	var requests []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("offset")
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			if polls.Add(1) == 1 {
+				w.Write(runningStatus)
+			} else {
+				w.Write(jobStatusData)
+			}
+		case "/services/search/v2/jobs/" + sid + "/results_preview":
+			requests = append(requests, "preview@"+offset)
+			if offset == "0" {
+				w.Write([]byte(`{"preview":true,"results":[{"_raw":"event 1"},{"_raw":"event 2"}]}`))
+				return
+			}
+			w.Write([]byte(`{"preview":true,"results":[]}`))
+		case "/services/search/v2/jobs/" + sid + "/results":
+			requests = append(requests, "results@"+offset)
+			if offset == "2" {
+				w.Write([]byte(`{"preview":false,"results":[{"_raw":"event 3"}]}`))
+				return
+			}
+			w.Write([]byte(`{"preview":false,"results":[]}`))
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.ndjson"
+	d := NewDownloader(createTestClient(testServer.URL, "ndjson"), config.DownloaderConfig{
+		OutputMode: "ndjson",
+		SID:        sid,
+		Filename:   filename,
+	})
+	d.followInterval = time.Millisecond
+	if err := d.FollowSearchResults(); err != nil {
+		t.Fatalf("FollowSearchResults returned error: %v", err)
+	}
+
+	// A short page means the results found so far have been pulled
+	expectedRequests := []string{"preview@0", "results@2"}
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Expected requests %v, got %v", expectedRequests, requests)
+	}
+
+	written, _ := os.ReadFile(filename)
+	expected := `{"_raw":"event 1"}` + "\n" + `{"_raw":"event 2"}` + "\n" + `{"_raw":"event 3"}` + "\n"
+	if string(written) != expected {
+		t.Errorf("Expected output\n%s\ngot\n%s", expected, written)
+	}
+	if d.rowsWritten != 3 {
+		t.Errorf("Expected 3 rows written, got %d", d.rowsWritten)
+	}
+}
@@ -0,0 +1,107 @@
+package downloader
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// Formatter writes results in a file format of its own, converted from the csv or ndjson output of a
+// download as the chunks are written
+type Formatter interface {
+	// WriteHeader writes what comes before the results. It's called once, before the first chunk.
+	WriteHeader() error
+	// WriteChunk adds a chunk of whole results
+	WriteChunk(data string) error
+	// Close ends the output once every chunk was written. It doesn't close the underlying writer.
+	Close() error
+}
+
+// FileFormat is a file format results are written in when the output's extension is Extension
+type FileFormat struct {
+	Name      string
+	Extension string // including the dot, e.g. .xlsx
+	Mode      string // the output mode converted, csv or ndjson
+	// Compressed is set for formats that compress their output already, which can't have a .gz or .zst
+	// suffix
+	Compressed bool
+	New        func(w io.Writer) Formatter
+}
+
+var fileFormats = struct {
+	mu          sync.RWMutex
+	byExtension map[string]FileFormat
+}{byExtension: map[string]FileFormat{
+	".xlsx":    {Name: "xlsx", Extension: ".xlsx", Mode: "csv", Compressed: true, New: func(w io.Writer) Formatter { return newXLSXWriter(w) }},
+	".json":    {Name: "JSON array", Extension: ".json", Mode: "ndjson", New: func(w io.Writer) Formatter { return newJSONArrayWriter(w) }},
+	".parquet": {Name: "Parquet", Extension: ".parquet", Mode: "csv", Compressed: true, New: func(w io.Writer) Formatter { return newParquetWriter(w) }},
+}}
+
+// RegisterFileFormat writes outputs with the format's extension in the format, replacing a format
+// registered before for the extension
+func RegisterFileFormat(format FileFormat) {
+	fileFormats.mu.Lock()
+	defer fileFormats.mu.Unlock()
+	fileFormats.byExtension[strings.ToLower(format.Extension)] = format
+}
+
+// FileFormatFor returns the file format of results written to filename, whose extension may be
+// followed by .gz or .zst, or false when they're written as the output mode has them
+func FileFormatFor(filename string) (FileFormat, bool) {
+	if isSink(filename) {
+		// Index and topic names and URLs may contain dots without being files
+		return FileFormat{}, false
+	}
+	_, ext := splitExt(filename)
+	ext = strings.TrimSuffix(strings.ToLower(ext), compressionOf(filename))
+	fileFormats.mu.RLock()
+	defer fileFormats.mu.RUnlock()
+	format, ok := fileFormats.byExtension[ext]
+	return format, ok
+}
+
+// fileFormatOf returns the file format of results written to filename, which for stdout is the one
+// StdoutFormat names by its extension
+func (d *Downloader) fileFormatOf(filename string) (FileFormat, bool) {
+	if filename != Stdout {
+		return FileFormatFor(filename)
+	}
+	if d.stdoutFormat == "" {
+		return FileFormat{}, false
+	}
+	fileFormats.mu.RLock()
+	defer fileFormats.mu.RUnlock()
+	format, ok := fileFormats.byExtension[strings.ToLower(d.stdoutFormat)]
+	return format, ok
+}
+
+// formattedOutput passes the chunks of an output through a Formatter
+type formattedOutput struct {
+	formatter Formatter
+	started   bool
+}
+
+func (f *formattedOutput) start() error {
+	if f.started {
+		return nil
+	}
+	f.started = true
+	return f.formatter.WriteHeader()
+}
+
+func (f *formattedOutput) WriteString(data string) (int, error) {
+	if err := f.start(); err != nil {
+		return 0, err
+	}
+	if err := f.formatter.WriteChunk(data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (f *formattedOutput) Close() error {
+	if err := f.start(); err != nil {
+		return err
+	}
+	return f.formatter.Close()
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// lineCounter is a formatter writing the number of lines of each chunk
+type lineCounter struct {
+	w io.Writer
+}
+
+func (l lineCounter) WriteHeader() error {
+	_, err := io.WriteString(l.w, "begin\n")
+	return err
+}
+
+func (l lineCounter) WriteChunk(data string) error {
+	_, err := fmt.Fprintf(l.w, "%d\n", strings.Count(data, "\n"))
+	return err
+}
+
+func (l lineCounter) Close() error {
+	_, err := io.WriteString(l.w, "end\n")
+	return err
+}
+
+func TestRegisterFileFormat(t *testing.T) {
+	RegisterFileFormat(FileFormat{Name: "line count", Extension: ".LINES", Mode: "ndjson", New: func(w io.Writer) Formatter { return lineCounter{w} }})
+
+	format, ok := FileFormatFor("results.lines.gz")
+	if !ok || format.Name != "line count" {
+		t.Fatalf("Expected the registered format for results.lines.gz, got %v, %t", format, ok)
+	}
+	if _, ok := FileFormatFor("results.ndjson"); ok {
+		t.Error("Expected no file format for results.ndjson")
+	}
+
+	filename := t.TempDir() + "/results.lines"
+	d := &Downloader{filename: filename, outputMode: "csv"}
+	if err := d.prepareOutput(); err == nil || !strings.Contains(err.Error(), "written from ndjson output") {
+		t.Errorf("Expected csv output to be refused, got %v", err)
+	}
+
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	for _, chunk := range []string{"{\"a\":1}\n{\"a\":2}\n", "{\"a\":3}\n"} {
+		if _, err := output.WriteString(chunk); err != nil {
+			t.Fatalf("WriteString returned error: %v", err)
+		}
+	}
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	written, err := os.ReadFile(partPath(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "begin\n2\n1\nend\n"; string(written) != expected {
+		t.Errorf("Expected %q, got %q", expected, written)
+	}
+}
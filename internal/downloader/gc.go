@@ -0,0 +1,70 @@
+package downloader
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+// StaleFile is a file spldl left behind that wasn't modified for a while
+type StaleFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// The endings of the files spldl keeps next to outputs while a download is incomplete: resume
+// sidecars, part files, the validators of resumable transfers and the checkpoints of spldl search
+// --interval
+var stateSuffixes = []string{".resume.json", partSuffix, partSuffix + ".validator", ".checkpoint.json"}
+
+// isStateFile reports whether the file is one spldl keeps the state of an incomplete download in
+func isStateFile(name string) bool {
+	for _, suffix := range stateSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StaleFiles returns the state files under the directories that weren't modified since before. The
+// resume sidecars of network outputs, which are kept in the state directory, are always included.
+func StaleFiles(dirs []string, before time.Time) ([]StaleFile, error) {
+	if stateDir, err := config.DefaultStateDir(); err == nil {
+		dirs = append(dirs, filepath.Join(stateDir, "resume"))
+	}
+	var stale []StaleFile
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if errors.Is(err, os.ErrNotExist) && path == dir {
+				return fs.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !isStateFile(entry.Name()) || seen[path] {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if info.ModTime().Before(before) {
+				seen[path] = true
+				stale = append(stale, StaleFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stale, nil
+}
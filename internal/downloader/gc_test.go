@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", stateDir)
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	// Outputs and files modified recently are kept
+	files := map[string]time.Time{
+		filepath.Join(dir, "results.csv"):                                        old,
+		filepath.Join(dir, "results.csv.part"):                                   old,
+		filepath.Join(dir, "results.csv.resume.json"):                            old,
+		filepath.Join(dir, "exports", "job.json.part.validator"):                 old,
+		filepath.Join(dir, "exports", "follow.ndjson.checkpoint.json"):           old,
+		filepath.Join(dir, "fresh.csv.part"):                                     time.Now(),
+		filepath.Join(stateDir, "spldl", "resume", "es%3A%2F%2Fidx.resume.json"): old,
+	}
+	for name, modTime := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stale, err := StaleFiles([]string{dir, filepath.Join(dir, "missing")}, time.Now().Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("StaleFiles returned error: %v", err)
+	}
+	var paths []string
+	for _, file := range stale {
+		paths = append(paths, file.Path)
+	}
+	slices.Sort(paths)
+	expected := []string{
+		filepath.Join(dir, "exports", "follow.ndjson.checkpoint.json"),
+		filepath.Join(dir, "exports", "job.json.part.validator"),
+		filepath.Join(dir, "results.csv.part"),
+		filepath.Join(dir, "results.csv.resume.json"),
+		filepath.Join(stateDir, "spldl", "resume", "es%3A%2F%2Fidx.resume.json"),
+	}
+	slices.Sort(expected)
+	if !slices.Equal(paths, expected) {
+		t.Errorf("Expected stale files\n%q\ngot\n%q", expected, paths)
+	}
+}
@@ -0,0 +1,66 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// isJSONArrayFile reports whether results written to filename are a JSON array, e.g. results.json or
+// results.json.gz, rather than one object per line
+func isJSONArrayFile(filename string) bool {
+	format, ok := FileFormatFor(filename)
+	return ok && format.Extension == ".json"
+}
+
+// jsonArrayWriter turns ndjson into a single indented JSON array of the results. The brackets and
+// commas between results are written as the chunks arrive, so the results are never held in memory.
+type jsonArrayWriter struct {
+	w       io.Writer
+	results int
+	buf     bytes.Buffer
+}
+
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w}
+}
+
+// WriteHeader writes nothing, the array is opened with its first result so an empty one is []
+func (j *jsonArrayWriter) WriteHeader() error {
+	return nil
+}
+
+// WriteChunk adds the results in data, one JSON object per line, to the array
+func (j *jsonArrayWriter) WriteChunk(data string) error {
+	for line := range strings.Lines(data) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		separator := ",\n  "
+		if j.results == 0 {
+			separator = "[\n  "
+		}
+		j.buf.Reset()
+		if err := json.Indent(&j.buf, []byte(line), "  ", "  "); err != nil {
+			return fmt.Errorf("failed to parse event: %w", err)
+		}
+		if _, err := io.WriteString(j.w, separator+j.buf.String()); err != nil {
+			return err
+		}
+		j.results++
+	}
+	return nil
+}
+
+// Close ends the array. It doesn't close the underlying writer.
+func (j *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if j.results == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
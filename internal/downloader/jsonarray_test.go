@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestJSONArrayOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected string
+	}{
+		{
+			name:   "results across chunks",
+			chunks: []string{"{\"host\":\"web01\",\"count\":\"5\"}\n{\"host\":\"web02\",\"tags\":[\"a\",\"b\"]}\n", "{\"host\":\"web03\"}\n"},
+			expected: `[
+  {
+    "host": "web01",
+    "count": "5"
+  },
+  {
+    "host": "web02",
+    "tags": [
+      "a",
+      "b"
+    ]
+  },
+  {
+    "host": "web03"
+  }
+]
+`,
+		},
+		{
+			name:     "no results",
+			chunks:   []string{""},
+			expected: "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := t.TempDir() + "/results.json"
+			output, err := newFileOutput(filename, nil)
+			if err != nil {
+				t.Fatalf("newFileOutput returned error: %v", err)
+			}
+			for _, chunk := range tt.chunks {
+				if _, err := output.WriteString(chunk); err != nil {
+					t.Fatalf("WriteString returned error: %v", err)
+				}
+			}
+			if err := output.Close(); err != nil {
+				t.Fatalf("Close returned error: %v", err)
+			}
+
+			written, err := os.ReadFile(partPath(filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(written) != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, written)
+			}
+			var results []map[string]any
+			if err := json.Unmarshal(written, &results); err != nil {
+				t.Errorf("Output isn't a JSON array: %v", err)
+			}
+		})
+	}
+
+	for filename, expected := range map[string]bool{"results.json": true, "results.JSON.gz": true, "results.ndjson": false, "results.jsonl": false} {
+		if isJSONArrayFile(filename) != expected {
+			t.Errorf("Expected isJSONArrayFile(%q) to be %t", filename, expected)
+		}
+	}
+}
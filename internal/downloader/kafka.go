@@ -0,0 +1,220 @@
+package downloader
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// kafkaTopic publishes batches of ndjson results to a Kafka topic, one message per result. Like
+// documents in an index, messages can't be taken back once they're published, so a failed download
+// leaves the batches published before it failed.
+type kafkaTopic struct {
+	client   *kgo.Client
+	topic    string
+	keyField string // the field whose value keys the messages, empty for messages without a key
+}
+
+// A batch the brokers haven't stored after this long fails
+const kafkaDeliveryTimeout = time.Minute
+
+// kafkaIDHeader carries the job and position of the result a message holds
+const kafkaIDHeader = "spldl-id"
+
+// kafkaCompressions are the codecs --kafka-compression accepts
+var kafkaCompressions = map[string]kgo.CompressionCodec{
+	"none":   kgo.NoCompression(),
+	"gzip":   kgo.GzipCompression(),
+	"snappy": kgo.SnappyCompression(),
+	"lz4":    kgo.Lz4Compression(),
+	"zstd":   kgo.ZstdCompression(),
+}
+
+// kafkaConfig returns the topic a kafka://topic uri names and the options of the client publishing
+// to it. The brokers default to the comma-separated KAFKA_BROKERS, and the SASL user and password to
+// KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD.
+func (d *Downloader) kafkaConfig(uri string) (string, []kgo.Opt, error) {
+	_, topic, _ := strings.Cut(uri, "://")
+	if topic == "" || strings.Contains(topic, "/") {
+		return "", nil, fmt.Errorf("invalid output %s, expected kafka://topic", uri)
+	}
+	brokers := d.kafkaBrokers
+	if len(brokers) == 0 && os.Getenv("KAFKA_BROKERS") != "" {
+		brokers = strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
+	}
+	if len(brokers) == 0 {
+		return "", nil, fmt.Errorf("kafka:// outputs need --kafka-brokers or KAFKA_BROKERS to be set")
+	}
+	codec, ok := kafkaCompressions[strings.ToLower(cmp.Or(d.kafkaCompression, "none"))]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown Kafka compression %q, use none, gzip, snappy, lz4 or zstd", d.kafkaCompression)
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.ClientID("spldl"),
+		kgo.ProducerBatchCompression(codec),
+		// Every message is stored by all in-sync replicas before the run succeeds, and the idempotent
+		// producer, which requires that, keeps the client's own retries from duplicating messages
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		// The client gives up on a batch it can't deliver, so the batch is sent again with backoff or
+		// written to the dead-letter file instead of holding up the download for good
+		kgo.RecordDeliveryTimeout(kafkaDeliveryTimeout),
+	}
+	tlsConfig, err := d.kafkaTLSConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	mechanism, err := d.kafkaSASL()
+	if err != nil {
+		return "", nil, err
+	}
+	if mechanism != nil {
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return topic, opts, nil
+}
+
+// kafkaTLSConfig returns the TLS config of the connections to the brokers, nil for plaintext. Setting
+// a CA file or a client certificate implies TLS.
+func (d *Downloader) kafkaTLSConfig() (*tls.Config, error) {
+	if !d.kafkaTLS && d.kafkaTLSCAFile == "" && d.kafkaTLSCertFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if d.kafkaTLSCAFile != "" {
+		data, err := os.ReadFile(d.kafkaTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Kafka CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", d.kafkaTLSCAFile)
+		}
+	}
+	if (d.kafkaTLSCertFile == "") != (d.kafkaTLSKeyFile == "") {
+		return nil, errors.New("--kafka-tls-cert and --kafka-tls-key must be set together")
+	}
+	if d.kafkaTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(d.kafkaTLSCertFile, d.kafkaTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the Kafka client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// kafkaSASL returns the SASL mechanism the client authenticates with, nil for none
+func (d *Downloader) kafkaSASL() (sasl.Mechanism, error) {
+	if d.kafkaSASLMechanism == "" {
+		return nil, nil
+	}
+	user := cmp.Or(d.kafkaSASLUsername, os.Getenv("KAFKA_SASL_USERNAME"))
+	password := os.Getenv("KAFKA_SASL_PASSWORD")
+	if user == "" || password == "" {
+		return nil, errors.New("Kafka SASL authentication needs --kafka-sasl-username or KAFKA_SASL_USERNAME, and KAFKA_SASL_PASSWORD to be set")
+	}
+	switch strings.ToLower(d.kafkaSASLMechanism) {
+	case "plain":
+		return plain.Auth{User: user, Pass: password}.AsMechanism(), nil
+	case "scram-sha-256":
+		return scram.Auth{User: user, Pass: password}.AsSha256Mechanism(), nil
+	case "scram-sha-512":
+		return scram.Auth{User: user, Pass: password}.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("unknown Kafka SASL mechanism %q, use plain, scram-sha-256 or scram-sha-512", d.kafkaSASLMechanism)
+	}
+}
+
+// openKafka starts publishing the output to the topic. The client connects to the brokers with the
+// first message.
+func (d *Downloader) openKafka(uri string) (*fileOutput, error) {
+	topic, opts, err := d.kafkaConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Kafka client: %w", err)
+	}
+	dest := &kafkaTopic{client: client, topic: topic, keyField: d.kafkaKeyField}
+	return newOutputTo(newBatchSink(d.client.Context(), uri, d.sinkOptions(), dest), uri), nil
+}
+
+// send publishes a batch and waits for the brokers to store every message of it. Messages the brokers
+// rejected for good, e.g. for being too large, fail the batch without another attempt.
+func (k *kafkaTopic) send(ctx context.Context, batch *resultBatch) error {
+	records := make([]*kgo.Record, len(batch.results))
+	results := make(map[*kgo.Record]sinkResult, len(batch.results))
+	for i, result := range batch.results {
+		records[i] = &kgo.Record{Key: resultKey(result.data, k.keyField), Value: result.data}
+		if id := batch.resultID(i); id != "" {
+			// Consumers can drop the messages a retry or a resumed download published again
+			records[i].Headers = []kgo.RecordHeader{{Key: kafkaIDHeader, Value: []byte(id)}}
+		}
+		results[records[i]] = result
+	}
+	produced := k.client.ProduceSync(ctx, records...)
+	err := produced.FirstErr()
+	if err == nil {
+		return nil
+	}
+	// The messages that were stored aren't published again
+	batch.results = batch.results[:0]
+	for _, result := range produced {
+		if result.Err != nil {
+			batch.results = append(batch.results, results[result.Record])
+		}
+	}
+	// The results are sent again in the order of the job
+	slices.SortFunc(batch.results, func(a, b sinkResult) int { return a.position - b.position })
+	var kafkaErr *kerr.Error
+	if errors.As(err, &kafkaErr) && !kafkaErr.Retriable {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// close disconnects from the brokers
+func (k *kafkaTopic) close() {
+	k.client.Close()
+}
+
+// resultKey returns the value of field in an ndjson result, nil if it's missing or field is empty.
+// Strings are used as they are and other values, such as the lists of multivalue fields, as JSON.
+func resultKey(result []byte, field string) []byte {
+	if field == "" {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(result, &fields); err != nil {
+		return nil
+	}
+	value, ok := fields[field]
+	if !ok || string(value) == "null" {
+		return nil
+	}
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return []byte(s)
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"crypto/tls"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestResultKey(t *testing.T) {
+	result := []byte(`{"host":"web-01","status":503,"tag":["prod","web"],"user":null}`)
+	tests := map[string]string{
+		"host":   "web-01",
+		"status": "503",
+		"tag":    `["prod","web"]`,
+		"user":   "<nil>",
+		"source": "<nil>",
+		"":       "<nil>",
+	}
+	for field, expected := range tests {
+		key := resultKey(result, field)
+		got := string(key)
+		if key == nil {
+			got = "<nil>"
+		}
+		if got != expected {
+			t.Errorf("resultKey(%q): expected %s, got %s", field, expected, got)
+		}
+	}
+}
+
+func TestKafkaConfig(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "")
+	t.Setenv("KAFKA_SASL_USERNAME", "")
+	t.Setenv("KAFKA_SASL_PASSWORD", "")
+	tests := []struct {
+		name     string
+		filename string
+		config   config.DownloaderConfig
+		expected string // the start of the error, empty for none
+	}{
+		{name: "brokers", filename: "kafka://splunk-events", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}}},
+		{name: "no brokers", filename: "kafka://splunk-events", expected: "kafka:// outputs need --kafka-brokers"},
+		{name: "no topic", filename: "kafka://", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}}, expected: "invalid output kafka://"},
+		{name: "path", filename: "kafka://a/b", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}}, expected: "invalid output kafka://a/b"},
+		{name: "zstd", filename: "kafka://splunk-events", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}, KafkaCompression: "zstd"}},
+		{name: "unknown compression", filename: "kafka://splunk-events", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}, KafkaCompression: "brotli"}, expected: "unknown Kafka compression"},
+		{name: "SASL without password", filename: "kafka://splunk-events", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}, KafkaSASLMechanism: "scram-sha-512", KafkaSASLUsername: "spldl"}, expected: "Kafka SASL authentication needs"},
+		{name: "certificate without key", filename: "kafka://splunk-events", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}, KafkaTLSCertFile: "client.pem"}, expected: "--kafka-tls-cert and --kafka-tls-key"},
+		{name: "missing CA file", filename: "kafka://splunk-events", config: config.DownloaderConfig{KafkaBrokers: []string{"localhost:9092"}, KafkaTLSCAFile: "missing.pem"}, expected: "error reading Kafka CA file"},
+	}
+	for _, tt := range tests {
+		d := NewDownloader(nil, tt.config)
+		_, _, err := d.kafkaConfig(tt.filename)
+		if tt.expected == "" && err != nil || tt.expected != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.expected)) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	t.Setenv("KAFKA_BROKERS", "kafka-1:9092,kafka-2:9092")
+	t.Setenv("KAFKA_SASL_USERNAME", "spldl")
+	t.Setenv("KAFKA_SASL_PASSWORD", "secret")
+	d := NewDownloader(nil, config.DownloaderConfig{KafkaSASLMechanism: "plain", KafkaTLS: true})
+	topic, opts, err := d.kafkaConfig("kafka://splunk-events")
+	if err != nil {
+		t.Fatalf("Expected the brokers and SASL credentials from the environment, got %v", err)
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		t.Fatalf("Failed to create the client: %v", err)
+	}
+	defer client.Close()
+	if topic != "splunk-events" || !slices.Equal(client.OptValue(kgo.SeedBrokers).([]string), []string{"kafka-1:9092", "kafka-2:9092"}) {
+		t.Errorf("Expected topic splunk-events on kafka-1 and kafka-2, got %s on %v", topic, client.OptValue(kgo.SeedBrokers))
+	}
+	if tlsConfig, _ := client.OptValue(kgo.DialTLSConfig).(*tls.Config); tlsConfig == nil {
+		t.Error("Expected --kafka-tls to connect with TLS")
+	}
+}
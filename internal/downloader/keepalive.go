@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Touches of a job are at least this far apart, however short its TTL
+const minKeepAliveInterval = time.Second
+
+// Touches of a job are at most this far apart, so a job saved for days is still touched regularly
+const maxKeepAliveInterval = 10 * time.Minute
+
+// keepAliveInterval returns how often a job with ttl seconds left is touched: twice per TTL, so a
+// touch that fails is retried before the job expires
+func keepAliveInterval(ttl int) time.Duration {
+	if ttl <= 0 {
+		return maxKeepAliveInterval
+	}
+	return min(max(time.Duration(ttl)*time.Second/2, minKeepAliveInterval), maxKeepAliveInterval)
+}
+
+// keepJobAlive touches the job sid until the returned function is called, so that Splunk doesn't
+// delete a job whose download takes longer than its TTL. Failed touches are only logged, the download
+// fails on its own if the job expires.
+func (d *Downloader) keepJobAlive(sid string, ttl int) (stop func()) {
+	interval := keepAliveInterval(ttl)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.client.TouchSearchJob(sid); err != nil {
+					if d.client.Context().Err() != nil {
+						return
+					}
+					slog.Warn("Failed to keep the job alive, it may expire before the download finishes", "sid", sid, "error", err)
+					continue
+				}
+				slog.Debug("Touched search job", "sid", sid, "interval", interval)
+			case <-done:
+				return
+			case <-d.client.Context().Done():
+				return
+			}
+		}
+	})
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
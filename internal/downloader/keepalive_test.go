@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepAliveInterval(t *testing.T) {
+	tests := map[int]time.Duration{
+		0:      maxKeepAliveInterval,
+		1:      minKeepAliveInterval,
+		600:    5 * time.Minute,
+		604800: maxKeepAliveInterval,
+	}
+	for ttl, expected := range tests {
+		if got := keepAliveInterval(ttl); got != expected {
+			t.Errorf("keepAliveInterval(%d): expected %s, got %s", ttl, expected, got)
+		}
+	}
+}
+
+func TestKeepJobAlive(t *testing.T) {
+	const sid = "1756172871.1180"
+	var touches atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Method != "POST" || r.URL.Path != "/services/search/v2/jobs/"+sid+"/control" || r.PostForm.Get("action") != "touch" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		touches.Add(1)
+		w.Write([]byte(`{"messages":[]}`))
+	}))
+	defer testServer.Close()
+
+	d := &Downloader{client: createTestClient(testServer.URL, "ndjson")}
+	stop := d.keepJobAlive(sid, 2)
+	time.Sleep(1500 * time.Millisecond)
+	stop()
+	if n := touches.Load(); n != 1 {
+		t.Errorf("Expected the job to be touched once, got %d", n)
+	}
+	time.Sleep(time.Second)
+	if n := touches.Load(); n != 1 {
+		t.Errorf("Expected no touches after stopping, got %d", n)
+	}
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// csvLocale formats the numbers and times of csv output the way a spreadsheet set to the locale
+// reads them, e.g. 3,14 and 26.08.2025 02:00:00 for de-DE
+type csvLocale struct {
+	decimal    byte   // the decimal separator
+	timeLayout string // how _time is written
+}
+
+// Locales whose decimal separator is a comma use a semicolon as delimiter, as Excel does for them
+var csvLocales = map[string]csvLocale{
+	"en-us": {decimal: '.', timeLayout: "01/02/2006 15:04:05"},
+	"en-gb": {decimal: '.', timeLayout: "02/01/2006 15:04:05"},
+	"ja-jp": {decimal: '.', timeLayout: "2006/01/02 15:04:05"},
+	"de-de": {decimal: ',', timeLayout: "02.01.2006 15:04:05"},
+	"de-ch": {decimal: '.', timeLayout: "02.01.2006 15:04:05"},
+	"fr-fr": {decimal: ',', timeLayout: "02/01/2006 15:04:05"},
+	"es-es": {decimal: ',', timeLayout: "02/01/2006 15:04:05"},
+	"it-it": {decimal: ',', timeLayout: "02/01/2006 15:04:05"},
+	"pt-br": {decimal: ',', timeLayout: "02/01/2006 15:04:05"},
+	"nl-nl": {decimal: ',', timeLayout: "02-01-2006 15:04:05"},
+	"pl-pl": {decimal: ',', timeLayout: "02.01.2006 15:04:05"},
+	"sv-se": {decimal: ',', timeLayout: "2006-01-02 15:04:05"},
+}
+
+// lookupLocale finds a locale by its tag, ignoring case and accepting _ for -, e.g. de_DE
+func lookupLocale(tag string) (csvLocale, bool) {
+	locale, ok := csvLocales[strings.ToLower(strings.ReplaceAll(tag, "_", "-"))]
+	return locale, ok
+}
+
+// Locales returns the tags of the supported locales
+func Locales() []string {
+	return slices.Sorted(maps.Keys(csvLocales))
+}
+
+// Only decimals are rewritten, integers read the same everywhere and dotted values such as versions
+// or IP addresses aren't numbers
+var decimalPattern = regexp.MustCompile(`^[-+]?[0-9]*\.[0-9]+$`)
+
+// format rewrites a field of the _time column, or any other field that is a decimal number
+func (l *csvLocale) format(value string, isTime bool) string {
+	if isTime {
+		if t, ok := parseEventTime(value); ok {
+			return t.Format(l.timeLayout)
+		}
+		return value
+	}
+	if l.decimal != '.' && decimalPattern.MatchString(value) {
+		return strings.Replace(value, ".", string(l.decimal), 1)
+	}
+	return value
+}
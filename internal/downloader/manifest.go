@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// Manifest is the sidecar file written next to a download describing what it contains
+type Manifest struct {
+	SID          string        `json:"sid"`
+	Filename     string        `json:"filename"`
+	OutputMode   string        `json:"output_mode"`
+	Search       string        `json:"search,omitempty"`  // the SPL dispatched, empty when downloading an existing job
+	Partial      bool          `json:"partial,omitempty"` // the job was finalized early, so it holds only part of the results
+	Verification *Verification `json:"verification,omitempty"`
+}
+
+// Verification records the outcome of comparing a download against a server-side recount
+type Verification struct {
+	VerifiedAt   time.Time `json:"verified_at"`
+	ExpectedRows int       `json:"expected_rows"`
+	WrittenRows  int       `json:"written_rows"`
+	SHA256       string    `json:"sha256"`
+	Passed       bool      `json:"passed"`
+	Signature    string    `json:"signature,omitempty"` // HMAC-SHA256 of the record, set when a signing key is configured
+}
+
+func manifestPath(filename string) string {
+	return filename + ".manifest.json"
+}
+
+// dispatchedSearch returns the SPL sent to Splunk for the search, empty when downloading an existing job
+func (d *Downloader) dispatchedSearch() string {
+	if d.search == "" {
+		return ""
+	}
+	return d.client.DispatchedSearch(d.search)
+}
+
+func writeManifest(filename string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath(filename), append(data, '\n'), 0o644)
+}
+
+// sign sets the signature of the record to the HMAC-SHA256 of its unsigned JSON encoding
+func (v *Verification) sign(key []byte) error {
+	v.Signature = ""
+	unsigned, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(unsigned)
+	v.Signature = hex.EncodeToString(mac.Sum(nil))
+	return nil
+}
+
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,113 @@
+package downloader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestVerificationSign(t *testing.T) {
+	verification := &Verification{
+		VerifiedAt:   time.Date(2025, 8, 26, 1, 47, 51, 0, time.UTC),
+		ExpectedRows: 10,
+		WrittenRows:  10,
+		SHA256:       "abc123",
+		Passed:       true,
+	}
+
+	key := []byte("secret")
+	if err := verification.sign(key); err != nil {
+		t.Fatalf("sign returned an error: %v", err)
+	}
+
+	// A consumer verifies the record by recomputing the HMAC without the signature
+	unsigned := *verification
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		t.Fatalf("Failed to marshal record: %v", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if verification.Signature != expected {
+		t.Errorf("Expected signature %s, got %s", expected, verification.Signature)
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	d := &Downloader{outputMode: "csv"}
+
+	rows, err := d.countRows("\"_raw\",host\n\"multi\nline\",web01\nfoo,web02\n")
+	if err != nil {
+		t.Fatalf("countRows returned an error: %v", err)
+	}
+	if rows != 2 {
+		t.Errorf("Expected 2 rows in the first chunk, got %d", rows)
+	}
+
+	rows, err = d.countRows("bar,web03\n")
+	if err != nil {
+		t.Fatalf("countRows returned an error: %v", err)
+	}
+	if rows != 1 {
+		t.Errorf("Expected 1 row in a headerless chunk, got %d", rows)
+	}
+}
+
+func TestPartialManifest(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			w.Write([]byte("event 0\n"))
+		case "/services/search/v2/jobs/" + sid + "/summary":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.txt"
+	d := NewDownloader(createTestClient(testServer.URL, "raw"), config.DownloaderConfig{
+		OutputMode:     "raw",
+		MaxConnections: 1,
+		SID:            sid,
+		Filename:       filename,
+		Partial:        true,
+		Search:         "index=main error",
+	})
+	if err := d.DownloadSearchResults(); err != nil {
+		t.Fatalf("DownloadSearchResults returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(manifestPath(filename))
+	if err != nil {
+		t.Fatalf("Expected a manifest for the partial download: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	// The manifest records the search as it was dispatched
+	expected := Manifest{SID: sid, Filename: filename, OutputMode: "raw", Search: "search index=main error", Partial: true}
+	if manifest != expected {
+		t.Errorf("Expected manifest %+v, got %+v", expected, manifest)
+	}
+}
@@ -0,0 +1,286 @@
+package downloader
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// downloadMergedJobs writes the results of the job d.sid and of every job in d.mergeSIDs to one
+// output, one job after another in the order given, or merged by _time when sortByTime is set. This
+// puts a search that was split into several jobs by hand back together.
+func (d *Downloader) downloadMergedJobs() error {
+	if d.verify || d.resume || d.parallelWrites || d.stopAfter > 0 {
+		return fmt.Errorf("verification, resuming, parallel writes and --stop-after are not supported when merging jobs")
+	}
+	if d.sortByTime && (d.outputMode != "ndjson" || d.bucketSize > 0) {
+		return fmt.Errorf("sorting merged jobs by _time is only supported for ndjson output without time buckets")
+	}
+
+	sids := append([]string{d.sid}, d.mergeSIDs...)
+	statuses := make([]splunkclient.SearchJobContent, len(sids))
+	total := 0
+	for i, sid := range sids {
+		if slices.Contains(sids[:i], sid) {
+			return fmt.Errorf("job %s is given more than once", sid)
+		}
+		status, err := d.client.GetJobStatus(sid)
+		if err != nil {
+			return fmt.Errorf("failed to get the status of job %s: %w", sid, err)
+		}
+		if !status.IsDone {
+			return fmt.Errorf("job %s is not complete (state: %s, progress: %.1f%%)", sid, status.DispatchState, status.DoneProgress*100)
+		}
+		if status.IsFailed {
+			return fmt.Errorf("job %s has failed", sid)
+		}
+		if err := d.checkJobMessages(sid, status); err != nil {
+			return err
+		}
+		if d.events {
+			if err := countEvents(sid, &status); err != nil {
+				return err
+			}
+		}
+		if status.ResultCount > maxJobResults {
+			return fmt.Errorf("job %s has more than %d results and can't be merged, split its search further", sid, maxJobResults)
+		}
+		total += status.ResultCount
+		statuses[i] = status
+	}
+	if d.maxResults > 0 && total > d.maxResults {
+		return fmt.Errorf("the jobs have %d results, more than the %d allowed by the system policy", total, d.maxResults)
+	}
+	slog.Info("Merging the results of several jobs", "jobs", len(sids), "result_count", total, "sort_by_time", d.sortByTime)
+
+	err := d.prepareOutput()
+	if err != nil {
+		return err
+	}
+	output, err := d.openOutput()
+	if err != nil {
+		return err
+	}
+	writer := output
+	var sorted *timeSortedOutput
+	if d.sortByTime {
+		sorted = &timeSortedOutput{output: output}
+		writer = sorted
+	}
+
+	for i, sid := range sids {
+		d.sid = sid
+		err = d.downloadJob(writer, statuses[i])
+		if err == nil && sorted != nil {
+			err = sorted.endJob()
+		}
+		if err != nil {
+			if sorted != nil {
+				sorted.discard()
+			}
+			return errors.Join(fmt.Errorf("job %s: %w", sid, err), output.Close())
+		}
+	}
+	// A row count mismatch names every job, the way --sid takes them
+	d.sid = strings.Join(sids, ",")
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	if err := d.checkRowCount(); err != nil {
+		return err
+	}
+	err = d.finishOutput()
+	if err != nil {
+		return err
+	}
+	if d.deleteWhenDone {
+		for _, sid := range sids {
+			d.deleteJob(sid)
+		}
+	}
+
+	slog.Info("Download completed successfully", "filename", d.filename, "jobs", len(sids), "rows", d.rowsWritten)
+	return nil
+}
+
+// timeSortedOutput merges the ndjson results of several jobs by _time, oldest first, keeping the
+// order of the jobs for results with the same _time. The results of the job being downloaded are held
+// in memory and spooled to a sorted temporary file when it ends, so memory is bounded by the largest
+// job. Close merges the spooled jobs into the output.
+type timeSortedOutput struct {
+	output chunkOutput
+	events []timedEvent // the results of the current job
+	runs   []string     // the sorted temporary file of every job that ended
+}
+
+type timedEvent struct {
+	time time.Time
+	line string
+}
+
+func (t *timeSortedOutput) WriteString(s string) (int, error) {
+	for line := range strings.Lines(s) {
+		eventTime, err := ndjsonEventTime(line)
+		if err != nil {
+			return 0, err
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		t.events = append(t.events, timedEvent{time: eventTime, line: line})
+	}
+	return len(s), nil
+}
+
+// ndjsonEventTime reads the _time of an ndjson result
+func ndjsonEventTime(line string) (time.Time, error) {
+	var event struct {
+		Time string `json:"_time"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse event: %w", err)
+	}
+	eventTime, ok := parseEventTime(event.Time)
+	if !ok {
+		return time.Time{}, errors.New("sorting by _time needs every result to have a readable _time, keep _time among the fields")
+	}
+	return eventTime, nil
+}
+
+// endJob sorts the results of the job that was downloaded and spools them to a temporary file
+func (t *timeSortedOutput) endJob() error {
+	slices.SortStableFunc(t.events, func(a, b timedEvent) int {
+		return a.time.Compare(b.time)
+	})
+	file, err := os.CreateTemp("", "spldl-merge-*.ndjson")
+	if err != nil {
+		return fmt.Errorf("failed to spool the job's results: %w", err)
+	}
+	t.runs = append(t.runs, file.Name())
+	writer := bufio.NewWriter(file)
+	for _, event := range t.events {
+		if _, err := writer.WriteString(event.line); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to spool the job's results: %w", err)
+		}
+	}
+	t.events = nil
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to spool the job's results: %w", err)
+	}
+	return file.Close()
+}
+
+// Close merges the spooled jobs into the output and closes it
+func (t *timeSortedOutput) Close() error {
+	defer t.discard()
+	if len(t.events) > 0 {
+		if err := t.endJob(); err != nil {
+			return errors.Join(err, t.output.Close())
+		}
+	}
+	if err := t.merge(); err != nil {
+		return errors.Join(fmt.Errorf("failed to merge the jobs by _time: %w", err), t.output.Close())
+	}
+	return t.output.Close()
+}
+
+// discard removes the temporary files of the jobs
+func (t *timeSortedOutput) discard() {
+	for _, run := range t.runs {
+		os.Remove(run)
+	}
+	t.runs = nil
+	t.events = nil
+}
+
+// merge writes the results of the sorted runs to the output in _time order
+func (t *timeSortedOutput) merge() error {
+	var heads eventHeap
+	for i, run := range t.runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		head := &runHead{run: i, reader: bufio.NewReader(file)}
+		if ok, err := head.next(); err != nil {
+			return err
+		} else if ok {
+			heads = append(heads, head)
+		}
+	}
+	heap.Init(&heads)
+	for len(heads) > 0 {
+		head := heads[0]
+		if _, err := t.output.WriteString(head.event.line); err != nil {
+			return err
+		}
+		ok, err := head.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&heads, 0)
+		} else {
+			heap.Pop(&heads)
+		}
+	}
+	return nil
+}
+
+// runHead is the next result of a sorted run
+type runHead struct {
+	run    int
+	reader *bufio.Reader
+	event  timedEvent
+}
+
+// next reads the following result of the run, returning false at its end
+func (h *runHead) next() (bool, error) {
+	line, err := h.reader.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return false, nil
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	eventTime, err := ndjsonEventTime(line)
+	if err != nil {
+		return false, err
+	}
+	h.event = timedEvent{time: eventTime, line: line}
+	return true, nil
+}
+
+// eventHeap orders the heads of the runs by _time, then by the order of their jobs
+type eventHeap []*runHead
+
+func (h eventHeap) Len() int { return len(h) }
+func (h eventHeap) Less(i, j int) bool {
+	if c := h[i].event.time.Compare(h[j].event.time); c != 0 {
+		return c < 0
+	}
+	return h[i].run < h[j].run
+}
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *eventHeap) Push(x any)   { *h = append(*h, x.(*runHead)) }
+func (h *eventHeap) Pop() any {
+	old := *h
+	head := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return head
+}
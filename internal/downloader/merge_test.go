@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestMergeJobs(t *testing.T) {
+	// Splunk returns events newest first, and the jobs' time ranges overlap
+	results := map[string]map[string]string{
+		"ndjson": {
+			"job1": `{"results":[{"_time":"2025-08-26T03:00:00.000+00:00","n":"1c"},{"_time":"2025-08-26T01:00:00.000+00:00","n":"1a"}]}`,
+			"job2": `{"results":[{"_time":"2025-08-26T04:00:00.000+00:00","n":"2d"},{"_time":"2025-08-26T02:00:00.000+00:00","n":"2b"}]}`,
+		},
+		"csv": {
+			"job1": "_time,n\n2025-08-26T03:00:00.000+00:00,1c\n2025-08-26T01:00:00.000+00:00,1a\n",
+			"job2": "_time,n\n2025-08-26T04:00:00.000+00:00,2d\n2025-08-26T02:00:00.000+00:00,2b\n",
+		},
+	}
+	var deleted []string
+
+	tests := []struct {
+		name       string
+		outputMode string
+		sortByTime bool
+		expected   []string // the n of every result, in order
+	}{
+		{name: "concatenated", outputMode: "ndjson", expected: []string{"1c", "1a", "2d", "2b"}},
+		{name: "sorted", outputMode: "ndjson", sortByTime: true, expected: []string{"1a", "2b", "1c", "2d"}},
+		{name: "csv", outputMode: "csv", expected: []string{"n", "1c", "1a", "2d", "2b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted = nil
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sid, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/services/search/v2/jobs/"), "/")
+				switch {
+				case path == "" && r.Method == http.MethodDelete:
+					deleted = append(deleted, sid)
+				case path == "":
+					w.Write([]byte(`{"entry":[{"content":{"sid":"` + sid + `","dispatchState":"DONE","isDone":true,"resultCount":2}}]}`))
+				case path == "results":
+					w.Write([]byte(results[tt.outputMode][sid]))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer testServer.Close()
+
+			filename := t.TempDir() + "/results." + tt.outputMode
+			downloader := NewDownloader(createTestClient(testServer.URL, tt.outputMode), config.DownloaderConfig{
+				OutputMode:     tt.outputMode,
+				MaxConnections: 2,
+				SID:            "job1",
+				MergeSIDs:      []string{"job2"},
+				SortByTime:     tt.sortByTime,
+				Filename:       filename,
+				DeleteWhenDone: true,
+			})
+			if err := downloader.DownloadSearchResults(); err != nil {
+				t.Fatalf("DownloadSearchResults returned error: %v", err)
+			}
+			if downloader.RowsWritten() != 4 {
+				t.Errorf("Expected 4 rows, got %d", downloader.RowsWritten())
+			}
+			output, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []string
+			for line := range strings.Lines(string(output)) {
+				if tt.outputMode == "csv" {
+					_, n, _ := strings.Cut(strings.TrimSpace(line), ",")
+					order = append(order, n)
+					continue
+				}
+				_, n, _ := strings.Cut(line, `"n":"`)
+				order = append(order, strings.TrimSuffix(strings.TrimSpace(n), `"}`))
+			}
+			if strings.Join(order, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("Expected the results %v, got %v", tt.expected, order)
+			}
+			if strings.Join(deleted, " ") != "job1 job2" {
+				t.Errorf("Expected both jobs to be deleted, got %v", deleted)
+			}
+		})
+	}
+
+	downloader := NewDownloader(nil, config.DownloaderConfig{OutputMode: "csv", SID: "job1", MergeSIDs: []string{"job2"}, SortByTime: true})
+	if err := downloader.DownloadSearchResults(); err == nil || !strings.Contains(err.Error(), "ndjson") {
+		t.Errorf("Expected sorting csv output to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Splunk's default [restapi] maxresultrows, the most results a oneshot search returns
+const oneshotResultLimit = 50000
+
+// OneshotSearchResults runs a small search in a single request and writes its results, skipping the
+// job that DownloadSearchResults needs to create, poll, download from and delete
+func (d *Downloader) OneshotSearchResults(search, earliest, latest string) error {
+	defer d.closeProgress()
+	defer d.abortOutput()
+	slog.Debug("Starting oneshot search", "output_mode", d.outputMode)
+	d.startedAt = time.Now()
+
+	if d.verify {
+		return fmt.Errorf("verification is not supported for oneshot searches since they have no job to recount")
+	}
+	if d.postFilter != "" {
+		return fmt.Errorf("post filters are not supported for oneshot searches since they have no job, filter the search instead")
+	}
+	if d.events {
+		return fmt.Errorf("events are downloaded from a job and are not supported for oneshot searches")
+	}
+	if d.stopAfter > 0 {
+		return fmt.Errorf("downloading only the first results is not supported for oneshot searches since they have no job, add | head to the search instead")
+	}
+	err := d.prepareOutput()
+	if err != nil {
+		return err
+	}
+
+	page, err := d.client.OneshotSearch(search, earliest, latest, d.outputMode)
+	if err != nil {
+		return fmt.Errorf("failed to run oneshot search: %w", err)
+	}
+	for _, warning := range page.Warnings {
+		d.warnings.Addf("oneshot", "%s", warning)
+	}
+	d.reportMessages(page.Messages)
+	// Counted before dedupe or clipping drop any of them
+	returned, err := d.pageResults(page.Data)
+	if err != nil {
+		return fmt.Errorf("failed to count results: %w", err)
+	}
+
+	writer, err := d.openOutput()
+	if err != nil {
+		return err
+	}
+	err = d.writeChunk(writer, eventChunk{offset: 0, data: page.Data})
+	if err != nil {
+		return errors.Join(err, writer.Close())
+	}
+	d.sendProgress(1)
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	err = d.finishOutput()
+	if err != nil {
+		return err
+	}
+
+	if returned >= oneshotResultLimit {
+		slog.Warn("Oneshot search may be truncated", "results", returned, "limit", oneshotResultLimit)
+		d.warnings.Addf("oneshot", "%d results were returned, the search may have been cut off at Splunk's maxresultrows limit; run it without --oneshot", returned)
+	}
+	slog.Info("Oneshot search completed successfully", "rows", d.rowsWritten, "filename", d.filename)
+	return nil
+}
@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestOneshotSearchResults(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/services/search/jobs" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("exec_mode") != "oneshot" || r.Form.Get("search") != "search index=main" || r.Form.Get("earliest_time") != "-1h" {
+			t.Errorf("Unexpected form %v", r.Form)
+		}
+		w.Write([]byte(`{"preview":false,"messages":[{"type":"WARN","text":"Field extraction failed"}],"results":[{"_raw":"event 1"},{"_raw":"event 2"}]}`))
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.ndjson"
+	d := NewDownloader(createTestClient(testServer.URL, "ndjson"), config.DownloaderConfig{
+		OutputMode: "ndjson",
+		Filename:   filename,
+	})
+	if err := d.OneshotSearchResults("search index=main", "-1h", "now"); err != nil {
+		t.Fatalf("OneshotSearchResults returned error: %v", err)
+	}
+
+	written, _ := os.ReadFile(filename)
+	expected := `{"_raw":"event 1"}` + "\n" + `{"_raw":"event 2"}` + "\n"
+	if string(written) != expected {
+		t.Errorf("Expected output\n%s\ngot\n%s", expected, written)
+	}
+	if d.rowsWritten != 2 {
+		t.Errorf("Expected 2 rows written, got %d", d.rowsWritten)
+	}
+}
+
+func TestOneshotSearchResultsMaxResults(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"preview":false,"results":[{"_raw":"event 1"},{"_raw":"event 2"}]}`))
+	}))
+	defer testServer.Close()
+
+	d := NewDownloader(createTestClient(testServer.URL, "ndjson"), config.DownloaderConfig{
+		OutputMode: "ndjson",
+		Filename:   t.TempDir() + "/results.ndjson",
+		MaxResults: 1,
+	})
+	err := d.OneshotSearchResults("search index=main", "-1h", "now")
+	if err == nil || !strings.Contains(err.Error(), "allowed by the system policy") {
+		t.Errorf("Expected the system policy to reject the results, got %v", err)
+	}
+}
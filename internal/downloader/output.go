@@ -0,0 +1,245 @@
+package downloader
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Stdout is the filename that writes the results to standard output
+const Stdout = "-"
+
+// Results are written to <filename>.part and renamed to filename once the download succeeded, so a
+// failed run never leaves an incomplete file where downstream jobs look for it
+const partSuffix = ".part"
+
+func partPath(filename string) string {
+	return filename + partSuffix
+}
+
+// chunkOutput is where the collector writes chunks once they are in order
+type chunkOutput interface {
+	WriteString(s string) (int, error)
+	Close() error
+}
+
+// fileOutput writes everything to a single file, or another destination
+type fileOutput struct {
+	dest       Destination
+	file       *os.File       // the part file of local outputs, which checkpoints and in-place writes use, nil otherwise
+	compressor io.WriteCloser // compresses the output of .gz and .zst files, nil otherwise
+	writer     *bufio.Writer
+	format     chunkOutput // converts the output into its file format, such as .xlsx workbooks, nil otherwise
+}
+
+// newFileOutput creates the part file of filename, or writes to stdout when filename is Stdout
+func newFileOutput(filename string, stdout io.Writer) (*fileOutput, error) {
+	if filename == Stdout {
+		return newOutputTo(streamDestination{stdout}, filename), nil
+	}
+	file, err := os.Create(partPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	return newFileOutputFrom(file, filename), nil
+}
+
+// newFileOutputFrom writes to file, the part file of filename
+func newFileOutputFrom(file *os.File, filename string) *fileOutput {
+	output := newOutputTo(&fileDestination{File: file, filename: filename}, filename)
+	output.file = file
+	return output
+}
+
+// newOutputTo writes to dest, compressed and converted as the extension of filename says
+func newOutputTo(dest Destination, filename string) *fileOutput {
+	output := &fileOutput{dest: dest}
+	var w io.Writer = dest
+	switch compressionOf(filename) {
+	case ".gz":
+		output.compressor = gzip.NewWriter(w)
+	case ".zst":
+		// A single encoder goroutine keeps the memory of concurrent outputs bounded. The options are
+		// valid, so there's no error.
+		output.compressor, _ = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	if output.compressor != nil {
+		w = output.compressor
+	}
+	output.writer = bufio.NewWriter(w)
+	if format, ok := FileFormatFor(filename); ok {
+		output.format = &formattedOutput{formatter: format.New(output.writer)}
+	}
+	return output
+}
+
+// compressionOf returns the suffix of the compression of results written to filename, .gz for gzip
+// and .zst for zstd, or an empty string when they're uncompressed
+func compressionOf(filename string) string {
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".gz", ".zst":
+		return ext
+	default:
+		return ""
+	}
+}
+
+// isCompressedFile reports whether results written to filename are gzip- or zstd-compressed
+func isCompressedFile(filename string) bool {
+	return compressionOf(filename) != ""
+}
+
+// splitExt splits filename into its base and extension, keeping a .gz or .zst suffix with the
+// extension it compresses, e.g. results and .ndjson.gz
+func splitExt(filename string) (string, string) {
+	ext := filepath.Ext(filename)
+	if isCompressedFile(filename) {
+		ext = filepath.Ext(strings.TrimSuffix(filename, ext)) + ext
+	}
+	return strings.TrimSuffix(filename, ext), ext
+}
+
+func (f *fileOutput) WriteString(s string) (int, error) {
+	if f.format != nil {
+		return f.format.WriteString(s)
+	}
+	return f.writer.WriteString(s)
+}
+
+// commit flushes what has been written to the file and returns the file's size, or waits for a
+// network output to take it
+func (f *fileOutput) commit() (int64, error) {
+	if err := f.writer.Flush(); err != nil {
+		return 0, err
+	}
+	// A network output has taken the results once every batch sent so far arrived
+	if sink, ok := f.dest.(*batchSink); ok {
+		return 0, sink.flush()
+	}
+	return f.file.Seek(0, io.SeekCurrent)
+}
+
+func (f *fileOutput) Close() error {
+	err := f.finish()
+	if closeErr := f.dest.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// finish ends the conversion and compression of the output
+func (f *fileOutput) finish() error {
+	if f.format != nil {
+		if err := f.format.Close(); err != nil {
+			return err
+		}
+	}
+	if err := f.writer.Flush(); err != nil {
+		return err
+	}
+	if f.compressor != nil {
+		return f.compressor.Close()
+	}
+	return nil
+}
+
+func (d *Downloader) openOutput() (chunkOutput, error) {
+	if d.bucketSize > 0 {
+		d.buckets = newBucketOutput(d.filename, d.outputMode, d.bucketSize, d.overwrite, d.csvDialect)
+		return d.buckets, nil
+	}
+	if len(d.tee) == 0 {
+		output, err := d.openTarget(d.filename)
+		if err != nil {
+			return nil, err
+		}
+		d.destinations = []Destination{output.dest}
+		return output, nil
+	}
+	tee := &teeOutput{}
+	for _, filename := range d.outputs() {
+		output, err := d.openTarget(filename)
+		if err != nil {
+			tee.Close()
+			return nil, err
+		}
+		tee.outputs = append(tee.outputs, output)
+		d.destinations = append(d.destinations, output.dest)
+	}
+	return tee, nil
+}
+
+// openTarget opens one output of the download, a local file, stdout or a remote destination
+func (d *Downloader) openTarget(filename string) (*fileOutput, error) {
+	if isRemote(filename) {
+		return d.openRemote(filename)
+	}
+	output, err := newFileOutput(filename, d.stdout)
+	if format, ok := d.fileFormatOf(filename); ok && err == nil && output.format == nil {
+		output.format = &formattedOutput{formatter: format.New(output.writer)}
+	}
+	if err == nil && d.append {
+		dest := output.dest.(*fileDestination)
+		dest.append, dest.csv = true, d.outputMode == "csv"
+	}
+	return output, err
+}
+
+// teeOutput writes every chunk to several outputs, so the results are downloaded once for all of them
+type teeOutput struct {
+	outputs []*fileOutput
+}
+
+func (t *teeOutput) WriteString(s string) (int, error) {
+	for _, output := range t.outputs {
+		if _, err := output.WriteString(s); err != nil {
+			return 0, err
+		}
+	}
+	return len(s), nil
+}
+
+func (t *teeOutput) Close() error {
+	var errs []error
+	for _, output := range t.outputs {
+		errs = append(errs, output.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// checkOverwrite refuses to replace an existing output file unless Overwrite is set
+func checkOverwrite(filename string, overwrite bool) error {
+	if overwrite {
+		return nil
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", filename)
+	}
+	return nil
+}
+
+// publishOutput commits the outputs of a successful download in order, or renames the part files of
+// time buckets to the output files
+func (d *Downloader) publishOutput() error {
+	if d.destinations != nil {
+		for _, dest := range d.destinations {
+			if err := dest.Commit(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, path := range d.OutputFiles() {
+		if err := os.Rename(partPath(path), path); err != nil {
+			return fmt.Errorf("failed to move the output into place: %w", err)
+		}
+	}
+	return nil
+}
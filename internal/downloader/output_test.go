@@ -0,0 +1,256 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdoutOutput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output, err := newFileOutput(Stdout, os.Stdout)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	output.WriteString("{\"a\":1}\n")
+	output.WriteString("{\"a\":2}\n")
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// Closing the output leaves stdout open
+	if _, err := w.WriteString("{\"a\":3}\n"); err != nil {
+		t.Fatalf("stdout was closed: %v", err)
+	}
+	w.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n" {
+		t.Errorf("Unexpected output %q", data)
+	}
+}
+
+func TestStdoutRejectsFileOptions(t *testing.T) {
+	d := &Downloader{filename: Stdout, outputMode: "ndjson", verify: true}
+	if err := d.prepareOutput(); err == nil {
+		t.Error("Expected an error for verification of stdout")
+	}
+	d = &Downloader{filename: Stdout, outputMode: "ndjson", bucketSize: time.Hour}
+	if err := d.prepareOutput(); err == nil {
+		t.Error("Expected an error for time buckets on stdout")
+	}
+}
+
+func TestGzipOutput(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.ndjson.gz")
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	output.WriteString("{\"a\":1}\n")
+	output.WriteString("{\"a\":2}\n")
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	file, err := os.Open(partPath(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Output is not gzip-compressed: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\"a\":1}\n{\"a\":2}\n" {
+		t.Errorf("Unexpected output %q", data)
+	}
+}
+
+func TestZstdOutput(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "results.ndjson.zst")
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	output.WriteString("{\"a\":1}\n")
+	output.WriteString("{\"a\":2}\n")
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := output.dest.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+
+	compressed, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(compressed, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("Output is not zstd-compressed: %q", compressed)
+	}
+	reader, err := OpenResultsFile(filename)
+	if err != nil {
+		t.Fatalf("OpenResultsFile returned error: %v", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{\"a\":1}\n{\"a\":2}\n" {
+		t.Errorf("Unexpected output %q", data)
+	}
+}
+
+func TestGzipRejectsResume(t *testing.T) {
+	d := &Downloader{filename: "results.ndjson.gz", outputMode: "ndjson", resume: true}
+	if err := d.prepareOutput(); err == nil {
+		t.Error("Expected an error for resuming compressed output")
+	}
+}
+
+func TestSplitExt(t *testing.T) {
+	tests := []struct {
+		filename string
+		base     string
+		ext      string
+	}{
+		{"results.ndjson", "results", ".ndjson"},
+		{"out/results.csv.gz", "out/results", ".csv.gz"},
+		{"results.ndjson.zst", "results", ".ndjson.zst"},
+		{"results.GZ", "results", ".GZ"},
+		{"results", "results", ""},
+	}
+	for _, tt := range tests {
+		base, ext := splitExt(tt.filename)
+		if base != tt.base || ext != tt.ext {
+			t.Errorf("splitExt(%q) = %q, %q, expected %q, %q", tt.filename, base, ext, tt.base, tt.ext)
+		}
+	}
+}
+
+func TestOverwriteProtection(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "results.ndjson")
+	if err := os.WriteFile(filename, []byte("{\"a\":1}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{filename: filename, outputMode: "ndjson"}
+	if err := d.prepareOutput(); err == nil {
+		t.Error("Expected an error for an existing output file")
+	}
+	d.overwrite = true
+	if err := d.prepareOutput(); err != nil {
+		t.Errorf("Expected an existing file to be overwritten, got %v", err)
+	}
+
+	// The existing file is only replaced once the new output is moved into place
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	output.WriteString("{\"a\":2}\n")
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "{\"a\":1}\n" {
+		t.Errorf("Expected the existing file to be untouched before publishing, got %q", data)
+	}
+	if err := d.publishOutput(); err != nil {
+		t.Fatalf("publishOutput returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "{\"a\":2}\n" {
+		t.Errorf("Expected the new output after publishing, got %q", data)
+	}
+	if _, err := os.Stat(partPath(filename)); !os.IsNotExist(err) {
+		t.Errorf("Expected the part file to be gone, got %v", err)
+	}
+
+	// Time buckets are checked as they're created
+	if err := os.WriteFile(filepath.Join(dir, "results_2024-06-01.ndjson"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buckets := newBucketOutput(filename, "ndjson", 24*time.Hour, false, nil)
+	if _, err := buckets.WriteString("{\"_time\":\"2024-06-01T01:00:00.000+00:00\"}\n"); err == nil {
+		t.Error("Expected an error for an existing bucket file")
+	}
+	buckets.Close()
+}
+
+func TestTeeOutput(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "results.csv")
+	compressed := filepath.Join(dir, "results.csv.gz")
+	var stdout strings.Builder
+	d := &Downloader{filename: filename, tee: []string{compressed, Stdout}, outputMode: "csv", stdout: &stdout}
+	if err := d.prepareOutput(); err != nil {
+		t.Fatalf("prepareOutput returned error: %v", err)
+	}
+	output, err := d.openOutput()
+	if err != nil {
+		t.Fatalf("openOutput returned error: %v", err)
+	}
+	output.WriteString("a,b\n1,2\n")
+	output.WriteString("3,4\n")
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := d.publishOutput(); err != nil {
+		t.Fatalf("publishOutput returned error: %v", err)
+	}
+
+	expected := "a,b\n1,2\n3,4\n"
+	data, err := os.ReadFile(filename)
+	if err != nil || string(data) != expected {
+		t.Errorf("Expected %s to hold %q, got %q, %v", filename, expected, data, err)
+	}
+	file, err := os.Open(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(reader); string(data) != expected {
+		t.Errorf("Expected %s to hold %q, got %q", compressed, expected, data)
+	}
+	if stdout.String() != expected {
+		t.Errorf("Expected stdout to get %q, got %q", expected, stdout.String())
+	}
+	if files := d.OutputFiles(); len(files) != 2 || files[0] != filename || files[1] != compressed {
+		t.Errorf("Expected the output files %s and %s, got %v", filename, compressed, files)
+	}
+
+	for _, d := range []*Downloader{
+		{filename: filename, tee: []string{filename}, outputMode: "csv", overwrite: true},
+		{filename: filename, tee: []string{compressed}, outputMode: "raw", parallelWrites: true},
+		{filename: filepath.Join(dir, "new.csv"), tee: []string{compressed}, outputMode: "csv"},
+	} {
+		if err := d.prepareOutput(); err == nil {
+			t.Errorf("Expected an error for outputs %s and %v", d.filename, d.tee)
+		}
+	}
+}
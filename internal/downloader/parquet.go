@@ -0,0 +1,111 @@
+package downloader
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetWriter converts the CSV Splunk sends into a Parquet file with a column per field of the header.
+// Splunk's results carry no types, so every column holds optional strings, and fields a result lacks
+// are null. Rows are written in row groups as they arrive, so memory stays bounded however many
+// results there are.
+type parquetWriter struct {
+	w       io.Writer
+	writer  *parquet.Writer // created with the header
+	columns []int           // for each CSV column, its Parquet column or -1 for a repeated name
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: w}
+}
+
+// WriteHeader writes nothing, the schema comes with the header row
+func (p *parquetWriter) WriteHeader() error {
+	return nil
+}
+
+// WriteChunk adds the CSV records in data to the file. Chunks are written whole, so data never ends
+// within a record.
+func (p *parquetWriter) WriteChunk(data string) error {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	var rows []parquet.Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse row: %w", err)
+		}
+		if p.writer == nil {
+			p.start(record)
+			continue
+		}
+		rows = append(rows, p.row(record))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := p.writer.WriteRows(rows)
+	return err
+}
+
+// start creates the writer with a schema of the header's fields
+func (p *parquetWriter) start(header []string) {
+	group := parquet.Group{}
+	for _, name := range header {
+		group[name] = parquet.Optional(parquet.String())
+	}
+	// A group orders its columns by name
+	names := make([]string, 0, len(group))
+	for name := range group {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	p.columns = make([]int, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		p.columns[i] = -1
+		if !seen[name] {
+			seen[name] = true
+			p.columns[i], _ = slices.BinarySearch(names, name)
+		}
+	}
+	p.writer = parquet.NewWriter(p.w, parquet.NewSchema("results", group), parquet.Compression(&parquet.Snappy))
+}
+
+// row returns the values of a record in the order of the columns. Splunk leaves fields that don't exist
+// for a result empty, which are written as nulls.
+func (p *parquetWriter) row(record []string) parquet.Row {
+	columns := 0
+	for _, column := range p.columns {
+		columns = max(columns, column+1)
+	}
+	row := make(parquet.Row, columns)
+	for column := range row {
+		row[column] = parquet.NullValue().Level(0, 0, column)
+	}
+	for i, column := range p.columns {
+		if column < 0 || i >= len(record) || record[i] == "" {
+			continue
+		}
+		row[column] = parquet.ValueOf(record[i]).Level(0, 1, column)
+	}
+	return row
+}
+
+// Close writes the file's footer. It doesn't close the underlying writer.
+func (p *parquetWriter) Close() error {
+	if p.writer == nil {
+		// Results without a header have no fields
+		p.start(nil)
+	}
+	return p.writer.Close()
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetOutput(t *testing.T) {
+	filename := t.TempDir() + "/results.parquet"
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	for _, chunk := range []string{
+		"\"_time\",host,\"_raw\"\n2025-08-26T02:00:00.000+00:00,web01,\"a, b\"\n",
+		"2025-08-26T02:00:01.000+00:00,,\"two\nlines\"\n",
+	} {
+		if _, err := output.WriteString(chunk); err != nil {
+			t.Fatalf("WriteString returned error: %v", err)
+		}
+	}
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	type result struct {
+		Time *string `parquet:"_time,optional"`
+		Host *string `parquet:"host,optional"`
+		Raw  *string `parquet:"_raw,optional"`
+	}
+	rows, err := parquet.ReadFile[result](partPath(filename))
+	if err != nil {
+		t.Fatalf("Output isn't a Parquet file: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+	if *rows[0].Host != "web01" || *rows[0].Raw != "a, b" || *rows[1].Time != "2025-08-26T02:00:01.000+00:00" {
+		t.Errorf("Unexpected rows %+v, %+v", rows[0], rows[1])
+	}
+	if rows[1].Host != nil || *rows[1].Raw != "two\nlines" {
+		t.Errorf("Expected the missing host to be null, got %+v", rows[1])
+	}
+}
+
+func TestParquetOutputWithoutResults(t *testing.T) {
+	filename := t.TempDir() + "/results.parquet"
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	rows, err := parquet.ReadFile[struct{}](partPath(filename))
+	if err != nil || len(rows) != 0 {
+		t.Errorf("Expected an empty Parquet file, got %d rows, %v", len(rows), err)
+	}
+}
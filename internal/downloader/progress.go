@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// Progress is a snapshot of a download's progress
+type Progress struct {
+	SID          string
+	ChunksDone   int
+	ChunksTotal  int // 0 when unknown, as for exports
+	BytesWritten int64
+	// Response data received from Splunk, including retried requests: WireBytes as sent over the
+	// network, compressed when Splunk gzipped it, and BytesReceived after decompression
+	WireBytes     int64
+	BytesReceived int64
+	Started       time.Time // when the download of the job started
+	Updated       time.Time
+}
+
+// Throughput returns the bytes written per second
+func (p Progress) Throughput() float64 {
+	elapsed := p.Updated.Sub(p.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesWritten) / elapsed
+}
+
+// ETA returns the predicted time until the download completes, or false when it can't be predicted yet
+func (p Progress) ETA() (time.Duration, bool) {
+	if p.ChunksTotal == 0 || p.ChunksDone == 0 {
+		return 0, false
+	}
+	elapsed := p.Updated.Sub(p.Started)
+	return elapsed * time.Duration(p.ChunksTotal-p.ChunksDone) / time.Duration(p.ChunksDone), true
+}
+
+// Progress returns the channel progress updates are sent on. Updates that aren't read in time are
+// replaced by newer ones, so a slow reader never holds up the download. The channel is closed once
+// the download finishes.
+func (d *Downloader) Progress() <-chan Progress {
+	return d.progress
+}
+
+func (d *Downloader) sendProgress(chunksDone int) {
+	now := time.Now()
+	var transfer splunkclient.Transfer
+	if d.client != nil {
+		transfer = d.client.Transferred()
+	}
+	update := Progress{
+		SID:           d.sid,
+		ChunksDone:    chunksDone,
+		ChunksTotal:   d.totalChunks,
+		BytesWritten:  d.bytesWritten,
+		WireBytes:     transfer.WireBytes,
+		BytesReceived: transfer.Bytes,
+		Started:       d.startedAt,
+		Updated:       now,
+	}
+
+	// Drop the previous update if nobody has read it yet, only the latest one matters
+	select {
+	case <-d.progress:
+	default:
+	}
+	select {
+	case d.progress <- update:
+	default:
+	}
+
+	d.checkTokenExpiry(chunksDone, now)
+}
+
+func (d *Downloader) closeProgress() {
+	d.progressOnce.Do(func() { close(d.progress) })
+}
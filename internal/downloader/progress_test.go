@@ -0,0 +1,46 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestProgress(t *testing.T) {
+	d := NewDownloader(nil, config.DownloaderConfig{})
+	d.sid = "1756172871.1180"
+	d.totalChunks = 10
+	d.startedAt = time.Now().Add(-20 * time.Second)
+
+	// Unread updates are replaced by newer ones instead of blocking
+	for done := 1; done <= 4; done++ {
+		d.bytesWritten = int64(done) * 1000
+		d.sendProgress(done)
+	}
+	d.closeProgress()
+
+	var updates []Progress
+	for update := range d.Progress() {
+		updates = append(updates, update)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("Expected only the latest update, got %d", len(updates))
+	}
+
+	progress := updates[0]
+	if progress.ChunksDone != 4 || progress.ChunksTotal != 10 || progress.BytesWritten != 4000 || progress.SID != d.sid {
+		t.Errorf("Unexpected progress %+v", progress)
+	}
+	// 4 chunks in 20s leaves 30s for the remaining 6
+	if eta, ok := progress.ETA(); !ok || eta.Round(time.Second) != 30*time.Second {
+		t.Errorf("Expected an ETA of 30s, got %s", eta)
+	}
+	if throughput := progress.Throughput(); throughput < 190 || throughput > 210 {
+		t.Errorf("Expected a throughput of about 200 B/s, got %.1f", throughput)
+	}
+
+	if _, ok := (Progress{ChunksDone: 3}).ETA(); ok {
+		t.Error("Expected no ETA when the total is unknown")
+	}
+}
@@ -0,0 +1,37 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// projectRawJSON reduces every event in a chunk of ndjson output to its _time and _raw, dropping the
+// extracted fields. An event missing either keeps the other, so every event still takes one line.
+func projectRawJSON(data string) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for line := range strings.Lines(data) {
+		var event struct {
+			Time json.RawMessage `json:"_time"`
+			Raw  json.RawMessage `json:"_raw"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return "", fmt.Errorf("failed to parse event: %w", err)
+		}
+		sb.WriteByte('{')
+		if event.Time != nil {
+			sb.WriteString(`"_time":`)
+			sb.Write(event.Time)
+		}
+		if event.Raw != nil {
+			if event.Time != nil {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(`"_raw":`)
+			sb.Write(event.Raw)
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String(), nil
+}
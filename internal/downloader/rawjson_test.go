@@ -0,0 +1,31 @@
+package downloader
+
+import "testing"
+
+func TestProjectRawJSON(t *testing.T) {
+	data := `{"host":"web01","_raw":"GET /index.html \"200\"","_time":"2025-08-26T02:00:00.000+00:00","tag":["a","b"]}` + "\n" +
+		`{"host":"web02","_raw":"no time"}` + "\n" +
+		`{"host":"web03"}` + "\n"
+	expected := `{"_time":"2025-08-26T02:00:00.000+00:00","_raw":"GET /index.html \"200\""}` + "\n" +
+		`{"_raw":"no time"}` + "\n" +
+		`{}` + "\n"
+
+	projected, err := projectRawJSON(data)
+	if err != nil {
+		t.Fatalf("projectRawJSON returned error: %v", err)
+	}
+	if projected != expected {
+		t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", expected, projected)
+	}
+
+	if _, err := projectRawJSON("{\"_raw\":\n"); err == nil {
+		t.Error("Expected an error for a malformed event")
+	}
+}
+
+func TestRawJSONRequiresNDJSON(t *testing.T) {
+	d := &Downloader{filename: "results.csv", outputMode: "csv", rawJSON: true}
+	if err := d.prepareOutput(); err == nil {
+		t.Error("Expected an error for _raw as JSON in csv output")
+	}
+}
@@ -0,0 +1,275 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Field is a field of a result
+type Field struct {
+	Name  string
+	Value string // the value as text: JSON strings unquoted, other JSON values as they're encoded
+	// Encoded is the value as the output mode encodes it, e.g. quoted JSON, which a rewritten record
+	// keeps. It's empty for modes such as csv, whose values are written as text.
+	Encoded string
+	// Absent marks a selected field the result lacks, which csv writes as an empty column and ndjson
+	// leaves out
+	Absent bool
+}
+
+// Record is a record of a chunk of output: a result, or a header naming the fields of the results
+type Record struct {
+	Text   string // as the output has it, line end included
+	Header bool
+	Fields []Field // for a header, the fields it names
+}
+
+// Get returns the value of the field name and whether the record has it
+func (r Record) Get(name string) (string, bool) {
+	for _, field := range r.Fields {
+		if field.Name == name && !field.Absent {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// RecordCodec reads the records of an output mode's chunks of whole results and writes new ones, so
+// results can be clipped, deduplicated, narrowed down and split into time buckets field by field
+// whatever the mode. A codec reads a single output from its start, so it may remember what the first
+// chunk said, such as the csv header.
+type RecordCodec interface {
+	// Records splits a chunk into its records
+	Records(data string) ([]Record, error)
+	// Encode writes a record of fields, a header naming them when header is set. Modes without a header
+	// return an empty string for one.
+	Encode(fields []Field, header bool) (string, error)
+	// Count returns the number of results in a chunk, not counting a header
+	Count(data string) (int, error)
+}
+
+var recordCodecs = struct {
+	mu     sync.RWMutex
+	byMode map[string]func() RecordCodec
+}{byMode: map[string]func() RecordCodec{
+	"ndjson": func() RecordCodec { return ndjsonCodec{} },
+	"csv":    func() RecordCodec { return &csvCodec{} },
+}}
+
+// RegisterRecordCodec makes the results of the output mode readable record by record, with a new
+// codec from newCodec for every output, replacing the codec registered before for the mode. Modes
+// without one, such as raw, can't be clipped, deduplicated, narrowed down to fields or split into time
+// buckets.
+func RegisterRecordCodec(mode string, newCodec func() RecordCodec) {
+	recordCodecs.mu.Lock()
+	defer recordCodecs.mu.Unlock()
+	recordCodecs.byMode[mode] = newCodec
+}
+
+// newRecordCodec returns a codec for the records of an output in the mode, or false when the mode has
+// no codec registered
+func newRecordCodec(mode string) (RecordCodec, bool) {
+	recordCodecs.mu.RLock()
+	defer recordCodecs.mu.RUnlock()
+	newCodec, ok := recordCodecs.byMode[mode]
+	if !ok {
+		return nil, false
+	}
+	return newCodec(), true
+}
+
+// LookupRecordCodec returns a new codec for the records of an output in the mode, or false when the
+// mode has none
+func LookupRecordCodec(mode string) (RecordCodec, bool) {
+	return newRecordCodec(mode)
+}
+
+// hasRecords reports whether results of the mode can be read record by record
+func hasRecords(mode string) bool {
+	recordCodecs.mu.RLock()
+	defer recordCodecs.mu.RUnlock()
+	_, ok := recordCodecs.byMode[mode]
+	return ok
+}
+
+// ndjsonCodec reads and writes a JSON object per line. Rewritten records keep the order of their
+// fields and the original encoding of the values.
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) Records(data string) ([]Record, error) {
+	var records []Record
+	for line := range strings.Lines(data) {
+		fields, err := parseJSONFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse event: %w", err)
+		}
+		records = append(records, Record{Text: line, Fields: fields})
+	}
+	return records, nil
+}
+
+// parseJSONFields returns the fields of a JSON object in the order it has them
+func parseJSONFields(line string) ([]Field, error) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, errors.New("event is not a JSON object")
+	}
+	var fields []Field
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		field := Field{Encoded: string(value), Value: string(value)}
+		field.Name, _ = token.(string)
+		if len(value) > 0 && value[0] == '"' {
+			json.Unmarshal(value, &field.Value)
+		}
+		fields = append(fields, field)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (ndjsonCodec) Encode(fields []Field, header bool) (string, error) {
+	if header {
+		return "", nil
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	first := true
+	for _, field := range fields {
+		if field.Absent {
+			continue
+		}
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(field.Name)
+		sb.Write(key)
+		sb.WriteByte(':')
+		if field.Encoded != "" {
+			sb.WriteString(field.Encoded)
+		} else {
+			value, _ := json.Marshal(field.Value)
+			sb.Write(value)
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+func (ndjsonCodec) Count(data string) (int, error) {
+	return strings.Count(data, "\n"), nil
+}
+
+// csvCodec reads and writes csv records, the first of which is the header naming the columns. The
+// original bytes of records read are kept, so their quoting is untouched.
+type csvCodec struct {
+	header     []string // nil until the header is seen
+	headerSeen bool     // counted, for Count
+}
+
+func (c *csvCodec) Records(data string) ([]Record, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	var records []Record
+	start := int64(0)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse row: %w", err)
+		}
+		end := reader.InputOffset()
+		record := Record{Text: data[start:end], Fields: make([]Field, len(row))}
+		start = end
+
+		if c.header == nil {
+			c.header = row
+			record.Header = true
+		}
+		for i, value := range row {
+			record.Fields[i] = Field{Value: value}
+			if i < len(c.header) {
+				record.Fields[i].Name = c.header[i]
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+func (c *csvCodec) Encode(fields []Field, header bool) (string, error) {
+	row := make([]string, len(fields))
+	for i, field := range fields {
+		switch {
+		case header:
+			row[i] = field.Name
+		case !field.Absent:
+			row[i] = field.Value
+		}
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(row)
+	writer.Flush()
+	return buf.String(), writer.Error()
+}
+
+func (c *csvCodec) Count(data string) (int, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows := 0
+	for {
+		_, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		rows++
+	}
+	if !c.headerSeen && rows > 0 {
+		c.headerSeen = true
+		rows--
+	}
+	return rows, nil
+}
+
+// recordReader reads the chunks of an output record by record, with a codec for the output mode it
+// picks with the first chunk
+type recordReader struct {
+	codec RecordCodec
+}
+
+// records returns the records of a chunk. what names the feature reading them, for the error when the
+// mode has no codec.
+func (r *recordReader) records(data, outputMode, what string) ([]Record, error) {
+	if r.codec == nil {
+		codec, ok := newRecordCodec(outputMode)
+		if !ok {
+			return nil, fmt.Errorf("%s is not supported for %s output", what, outputMode)
+		}
+		r.codec = codec
+	}
+	return r.codec.Records(data)
+}
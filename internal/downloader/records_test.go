@@ -0,0 +1,54 @@
+package downloader
+
+import "testing"
+
+func TestRecordCodecs(t *testing.T) {
+	tests := []struct {
+		mode    string
+		chunks  []string
+		headers int
+	}{
+		{"ndjson", []string{"{\"_time\":\"1\",\"count\":3,\"host\":\"web01\"}\n", "{\"host\":\"web02\",\"tag\":[\"a\"]}\n"}, 0},
+		{"csv", []string{"_time,host\n1,web01\n", "2,\"web,02\"\n"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			codec, ok := newRecordCodec(tt.mode)
+			if !ok {
+				t.Fatalf("No codec for %s", tt.mode)
+			}
+			headers := 0
+			for _, chunk := range tt.chunks {
+				records, err := codec.Records(chunk)
+				if err != nil {
+					t.Fatalf("Records returned error: %v", err)
+				}
+				text := ""
+				for _, record := range records {
+					if record.Header {
+						headers++
+					}
+					// Encoding the fields read gives the record back as it was
+					encoded, err := codec.Encode(record.Fields, record.Header)
+					if err != nil {
+						t.Fatalf("Encode returned error: %v", err)
+					}
+					if encoded != record.Text && !(record.Header && encoded == "") {
+						t.Errorf("Expected %q to be encoded as it was, got %q", record.Text, encoded)
+					}
+					text += record.Text
+				}
+				if text != chunk {
+					t.Errorf("Expected the records to make up %q, got %q", chunk, text)
+				}
+			}
+			if headers != tt.headers {
+				t.Errorf("Expected %d headers, got %d", tt.headers, headers)
+			}
+		})
+	}
+
+	if _, ok := newRecordCodec("raw"); ok {
+		t.Error("Expected raw output to have no records")
+	}
+}
@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"slices"
+	"strings"
+)
+
+// redactedValue replaces the values of masked fields
+const redactedValue = "[REDACTED]"
+
+// redactor masks the values of some fields of every result and drops others, keeping the rest of
+// each result as it was
+type redactor struct {
+	recordReader
+	mask []string
+	drop []string
+}
+
+// filter redacts a chunk of output
+func (r *redactor) filter(data string, outputMode string) (string, error) {
+	records, err := r.records(data, outputMode, "redacting fields")
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, record := range records {
+		fields := slices.DeleteFunc(record.Fields, func(field Field) bool { return slices.Contains(r.drop, field.Name) })
+		redacted := len(fields) != len(record.Fields)
+		// A header names the fields and has no values to mask, and empty csv columns are left empty
+		for i, field := range fields {
+			if !record.Header && !field.Absent && field.Value != "" && slices.Contains(r.mask, field.Name) {
+				fields[i] = Field{Name: field.Name, Value: redactedValue}
+				redacted = true
+			}
+		}
+		if !redacted {
+			sb.WriteString(record.Text)
+			continue
+		}
+		encoded, err := r.codec.Encode(fields, record.Header)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(encoded)
+	}
+	return sb.String(), nil
+}
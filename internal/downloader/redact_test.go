@@ -0,0 +1,51 @@
+package downloader
+
+import "testing"
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name       string
+		outputMode string
+		chunks     []string
+		expected   string
+	}{
+		{
+			name:       "ndjson masks values and drops fields",
+			outputMode: "ndjson",
+			chunks: []string{
+				`{"_time":"2025-08-26T02:00:00.000+00:00","user":"bob","password":"hunter2","src":["10.0.0.1","10.0.0.2"]}` + "\n" +
+					`{"_time":"2025-08-26T02:00:01.000+00:00","host":"web02"}` + "\n",
+				`{"user":"alice","count":3}` + "\n",
+			},
+			expected: `{"_time":"2025-08-26T02:00:00.000+00:00","user":"[REDACTED]","src":"[REDACTED]"}` + "\n" +
+				`{"_time":"2025-08-26T02:00:01.000+00:00","host":"web02"}` + "\n" +
+				`{"user":"[REDACTED]","count":3}` + "\n",
+		},
+		{
+			name:       "csv keeps the header of masked columns",
+			outputMode: "csv",
+			chunks: []string{
+				"\"_time\",user,password,src\n2025-08-26T02:00:00.000+00:00,bob,hunter2,\"10.0.0.1\n10.0.0.2\"\n",
+				"2025-08-26T02:00:01.000+00:00,,,\n",
+			},
+			expected: "_time,user,src\n2025-08-26T02:00:00.000+00:00,[REDACTED],[REDACTED]\n2025-08-26T02:00:01.000+00:00,,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &redactor{mask: []string{"user", "src"}, drop: []string{"password"}}
+			var output string
+			for _, chunk := range tt.chunks {
+				filtered, err := filter.filter(chunk, tt.outputMode)
+				if err != nil {
+					t.Fatalf("filter returned error: %v", err)
+				}
+				output += filtered
+			}
+			if output != tt.expected {
+				t.Errorf("Output mismatch:\nExpected: %q\nGot:      %q", tt.expected, output)
+			}
+		})
+	}
+}
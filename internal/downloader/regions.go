@@ -0,0 +1,136 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// regionWriter lets workers write raw chunks straight into their region of the output file instead
+// of passing them through the collector. Raw chunks are written unchanged, so a chunk's region starts
+// where the previous chunk's ends. A worker only waits until every earlier chunk has been downloaded
+// and sized, not written, and the writes themselves happen in parallel.
+type regionWriter struct {
+	file  *os.File
+	mu    sync.Mutex
+	sized *sync.Cond
+	next  int   // the next chunk to be given a region
+	end   int64 // where the region of the next chunk starts
+	err   error // the first write that failed
+}
+
+func newRegionWriter(file *os.File, start int64) *regionWriter {
+	r := &regionWriter{file: file, end: start}
+	r.sized = sync.NewCond(&r.mu)
+	return r
+}
+
+// reserve waits for the regions of the chunks before offset and returns where the chunk's region of
+// size bytes starts. Every chunk must be reserved exactly once, failed chunks with a size of 0, or
+// the chunks after it wait forever.
+func (r *regionWriter) reserve(offset int, size int) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.next != offset {
+		r.sized.Wait()
+	}
+	start := r.end
+	r.end += int64(size)
+	r.next++
+	r.sized.Broadcast()
+	return start
+}
+
+func (r *regionWriter) failed() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *regionWriter) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// writesInPlace reports whether the chunks of the job can be written from the workers. Only raw
+// output qualifies since every other format is changed by the collector before it's written.
+func (d *Downloader) writesInPlace(writer chunkOutput) (*fileOutput, bool) {
+	output, ok := writer.(*fileOutput)
+	if !d.parallelWrites || d.outputMode != "raw" || !ok || output.file == nil {
+		return nil, false
+	}
+	return output, true
+}
+
+// downloadJobChunksInPlace is downloadJobChunks with every worker writing its chunks to the file itself
+func (d *Downloader) downloadJobChunksInPlace(output *fileOutput, totalChunks int) error {
+	// The jobs of a split search before this one went through the buffered writer
+	if err := output.writer.Flush(); err != nil {
+		return err
+	}
+	start, err := output.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	regions := newRegionWriter(output.file, start)
+	d.chunksWritten = 0
+
+	offsetChan := make(chan int, 100)
+	var workerWg sync.WaitGroup
+	slog.Debug("Starting in-place writers", "worker_count", d.maxConnections, "start", start)
+	for range d.maxConnections {
+		workerWg.Go(func() { profiled("region_writer", func() { d.regionWorker(regions, offsetChan) }) })
+	}
+	for i := 0; i < totalChunks; i++ {
+		offsetChan <- i
+	}
+	close(offsetChan)
+	workerWg.Wait()
+
+	// Later writes, such as the next job of a split search, continue after the last region
+	_, seekErr := output.file.Seek(regions.end, io.SeekStart)
+
+	if err := d.client.Context().Err(); err != nil {
+		// Regions after a canceled chunk may have been written, only the ones before it are complete
+		return errors.Join(fmt.Errorf("download interrupted, %s is incomplete: %w", d.filename, err), regions.err, seekErr)
+	}
+	if d.chunkErr != nil {
+		return errors.Join(fmt.Errorf("%d chunk(s) could not be downloaded, first failure: %w", d.failedChunks, d.chunkErr), regions.err, seekErr)
+	}
+	return errors.Join(regions.err, seekErr)
+}
+
+func (d *Downloader) regionWorker(regions *regionWriter, offsetChan chan int) {
+	for offset := range offsetChan {
+		if d.chunkFailed() || regions.failed() != nil {
+			regions.reserve(offset, 0)
+			continue
+		}
+		chunk, ok := d.fetchEventChunk(offset)
+		if !ok {
+			regions.reserve(offset, 0)
+			continue
+		}
+
+		rows, _ := d.countRows(chunk.data)
+		at := regions.reserve(offset, len(chunk.data))
+		n, err := regions.file.WriteAt([]byte(chunk.data), at)
+		if err != nil {
+			regions.fail(fmt.Errorf("failed to write chunk %d: %w", offset, err))
+		}
+
+		d.writeMu.Lock()
+		d.rowsWritten += rows
+		d.bytesWritten += int64(n)
+		d.chunksWritten++
+		d.sendProgress(d.chunksWritten)
+		d.writeMu.Unlock()
+		slog.Debug("Wrote chunk in place", "offset", offset, "at", at, "size", n)
+	}
+}
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ResultsFile is a local results file written outside a download, e.g. by spldl convert. Like the
+// outputs of a download it's compressed and converted as its extension says, and written to a part
+// file until it's committed.
+type ResultsFile struct {
+	filename string
+	output   *fileOutput
+	dialect  *csvDialect // writes .tsv files with tabs, nil otherwise
+}
+
+// CreateResultsFile creates the part file of filename. What's written is in the output mode of the
+// file's format, or the mode the extension names when it has none. .tsv files take csv, which is
+// written with tabs.
+func CreateResultsFile(filename string) (*ResultsFile, error) {
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	f := &ResultsFile{filename: filename, output: output}
+	if ResultsExt(filename) == ".tsv" {
+		f.dialect = &csvDialect{delimiter: '\t', timeColumn: -1}
+	}
+	return f, nil
+}
+
+// WriteString adds whole results to the file
+func (f *ResultsFile) WriteString(data string) (int, error) {
+	if f.dialect != nil {
+		reformatted, err := f.dialect.reformat(data)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := f.output.WriteString(reformatted); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	return f.output.WriteString(data)
+}
+
+// Commit ends the file and moves it into place, or removes the part file when that fails
+func (f *ResultsFile) Commit() error {
+	err := f.output.Close()
+	if err == nil {
+		err = f.output.dest.Commit()
+	}
+	if err != nil {
+		os.Remove(partPath(f.filename))
+	}
+	return err
+}
+
+// Abort closes the file and removes the part file
+func (f *ResultsFile) Abort() {
+	f.output.Close()
+	os.Remove(partPath(f.filename))
+}
+
+// decompressedFile closes the decompressor along with the file underneath it
+type decompressedFile struct {
+	io.Reader
+	close func() error
+	file  *os.File
+}
+
+func (r *decompressedFile) Close() error {
+	return errors.Join(r.close(), r.file.Close())
+}
+
+// OpenResultsFile opens a results file for reading, decompressing it as its extension says
+func OpenResultsFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch compressionOf(filename) {
+	case ".gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		return &decompressedFile{Reader: gz, close: gz.Close, file: file}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		return &decompressedFile{Reader: zr, close: func() error { zr.Close(); return nil }, file: file}, nil
+	default:
+		return file, nil
+	}
+}
+
+// ResultsExt returns the lowercased extension of a results file, without the suffix of its
+// compression, e.g. .ndjson for results.ndjson.gz
+func ResultsExt(filename string) string {
+	_, ext := splitExt(filename)
+	return strings.TrimSuffix(strings.ToLower(ext), compressionOf(filename))
+}
+
+// ResultsFileMode returns the output mode a ResultsFile named filename takes, or false when its
+// extension leaves that to the caller
+func ResultsFileMode(filename string) (string, bool) {
+	if format, ok := FileFormatFor(filename); ok {
+		return format.Mode, true
+	}
+	if ResultsExt(filename) == ".tsv" {
+		return "csv", true
+	}
+	return "", false
+}
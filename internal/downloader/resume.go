@@ -0,0 +1,201 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+// resumeState is the sidecar file recording how much of a download has been written. The collector
+// writes chunks in order, so the written chunks are always the first ones of the job. For network
+// outputs, such as an Elasticsearch index, it records the chunks the output took.
+type resumeState struct {
+	SID        string `json:"sid"`
+	OutputMode string `json:"output_mode"`
+	Chunks     int    `json:"chunks"` // chunks written
+	Bytes      int64  `json:"bytes"`  // size of the output after those chunks
+	Rows       int    `json:"rows"`
+}
+
+// resumeStatePath returns the sidecar file of filename. Network outputs aren't files, so theirs are
+// kept in the state directory.
+func resumeStatePath(filename string) string {
+	if !isSink(filename) {
+		return filename + ".resume.json"
+	}
+	dir, err := config.DefaultStateDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "resume", url.QueryEscape(filename)+".resume.json")
+}
+
+// loadResumeState reads the resume state of filename, or returns false when there is none
+func loadResumeState(filename string) (resumeState, bool, error) {
+	data, err := os.ReadFile(resumeStatePath(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return resumeState{}, false, nil
+	}
+	if err != nil {
+		return resumeState{}, false, err
+	}
+	var state resumeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return resumeState{}, false, fmt.Errorf("invalid resume state %s: %w", resumeStatePath(filename), err)
+	}
+	return state, true, nil
+}
+
+// ResumeSID returns the job of an interrupted download to filename, or an empty string when there
+// is nothing to resume
+func ResumeSID(filename string) (string, error) {
+	state, _, err := loadResumeState(filename)
+	return state.SID, err
+}
+
+// openCheckpointedOutput opens the output of a single job. Unless the output isn't a plain file or a
+// network output, the progress is checkpointed after every chunk, and with --resume an interrupted
+// download is continued.
+func (d *Downloader) openCheckpointedOutput(totalChunks int) (chunkOutput, error) {
+	// A compressed stream can't be cut back to a checkpoint
+	if d.bucketSize > 0 || d.filename == Stdout || isRemote(d.filename) && !isSink(d.filename) || d.parallelWrites || isCompressedFile(d.filename) || len(d.tee) > 0 || d.append {
+		return d.openOutput()
+	}
+
+	if d.resume {
+		state, ok, err := loadResumeState(d.filename)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			output, err := d.reopenOutput(state, totalChunks)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resume the download: %w", err)
+			}
+			d.checkpoint = output
+			d.destinations = []Destination{output.dest}
+			return output, nil
+		}
+		slog.Info("No interrupted download to resume, starting from the beginning", "filename", d.filename)
+	}
+
+	output, err := d.openTarget(d.filename)
+	if err != nil {
+		return nil, err
+	}
+	d.checkpoint = output
+	d.destinations = []Destination{output.dest}
+	return output, nil
+}
+
+// reopenOutput opens the output of an interrupted download, dropping anything written after the
+// last checkpoint
+func (d *Downloader) reopenOutput(state resumeState, totalChunks int) (*fileOutput, error) {
+	if state.SID != d.sid {
+		return nil, fmt.Errorf("%s was downloaded from job %s, not %s", d.filename, state.SID, d.sid)
+	}
+	if state.OutputMode != d.outputMode {
+		return nil, fmt.Errorf("%s was downloaded as %s, not %s", d.filename, state.OutputMode, d.outputMode)
+	}
+	if state.Chunks > totalChunks {
+		return nil, fmt.Errorf("%d chunks were written but job %s only has %d", state.Chunks, d.sid, totalChunks)
+	}
+	if isSink(d.filename) {
+		return d.reopenSink(state, totalChunks)
+	}
+
+	file, err := os.OpenFile(partPath(d.filename), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && info.Size() < state.Bytes {
+		err = fmt.Errorf("%s is shorter than the %d bytes written before", partPath(d.filename), state.Bytes)
+	}
+	if err == nil {
+		err = file.Truncate(state.Bytes)
+	}
+	if err == nil {
+		_, err = file.Seek(state.Bytes, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	d.firstChunk = state.Chunks
+	d.rowsWritten = state.Rows
+	slog.Info("Resuming download", "sid", d.sid, "chunks_written", state.Chunks, "total_chunks", totalChunks)
+	return newFileOutputFrom(file, d.filename), nil
+}
+
+// reopenSink continues sending the results of an interrupted download to a network output after the
+// chunks it took. Those sent after the last checkpoint are sent again and, identified by their job and
+// position, replace themselves.
+func (d *Downloader) reopenSink(state resumeState, totalChunks int) (*fileOutput, error) {
+	output, err := d.openRemote(d.filename)
+	if err != nil {
+		return nil, err
+	}
+	output.dest.(*batchSink).resumeAt(state.Rows)
+	d.firstChunk = state.Chunks
+	d.rowsWritten = state.Rows
+	slog.Info("Resuming download", "sid", d.sid, "chunks_sent", state.Chunks, "total_chunks", totalChunks)
+	return output, nil
+}
+
+// checkpointChunks records that the first chunks of the job have been written
+func (d *Downloader) checkpointChunks(chunks int) error {
+	if d.checkpoint == nil {
+		return nil
+	}
+	size, err := d.checkpoint.commit()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(resumeState{
+		SID:        d.sid,
+		OutputMode: d.outputMode,
+		Chunks:     chunks,
+		Bytes:      size,
+		Rows:       d.rowsWritten,
+	})
+	if err != nil {
+		return err
+	}
+	path := resumeStatePath(d.filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// CanResume reports whether a failed download left a resume state behind for --resume
+func (d *Downloader) CanResume() bool {
+	if d.checkpoint == nil {
+		return false
+	}
+	_, ok, _ := loadResumeState(d.filename)
+	return ok
+}
+
+// removeCheckpoint deletes the resume state of a completed download
+func (d *Downloader) removeCheckpoint() {
+	if d.checkpoint == nil {
+		return
+	}
+	if err := os.Remove(resumeStatePath(d.filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Failed to remove resume state", "error", err)
+	}
+}
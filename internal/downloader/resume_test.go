@@ -0,0 +1,236 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestResume(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+	// Four chunks
+	jobStatusData = bytes.Replace(jobStatusData, []byte(`"resultCount": 2,`), []byte(`"resultCount": 35000,`), 1)
+
+	chunkData := func(chunk int) string {
+		return "event " + strconv.Itoa(chunk) + "-a\nevent " + strconv.Itoa(chunk) + "-b\n"
+	}
+
+	failChunk := 2
+	var requested []int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			chunk := offset / 10000
+			requested = append(requested, chunk)
+			if chunk == failChunk {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(chunkData(chunk)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.txt"
+	downloaderConfig := config.DownloaderConfig{
+		OutputMode:     "raw",
+		MaxConnections: 1,
+		SID:            sid,
+		Filename:       filename,
+	}
+
+	// The first run fails at the third chunk, leaving the first two written
+	d := NewDownloader(createTestClient(testServer.URL, "raw"), downloaderConfig)
+	if err := d.DownloadSearchResults(); err == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+	if !d.CanResume() {
+		t.Fatal("Expected the failed download to be resumable")
+	}
+	if resumeSID, _ := ResumeSID(filename); resumeSID != sid {
+		t.Errorf("Expected resume sid %s, got %q", sid, resumeSID)
+	}
+	// Simulate a partial write after the last checkpoint
+	file, _ := os.OpenFile(partPath(filename), os.O_APPEND|os.O_WRONLY, 0)
+	file.WriteString("event 2-")
+	file.Close()
+
+	// Resuming only requests the missing chunks
+	failChunk = -1
+	requested = nil
+	downloaderConfig.Resume = true
+	d = NewDownloader(createTestClient(testServer.URL, "raw"), downloaderConfig)
+	if err := d.DownloadSearchResults(); err != nil {
+		t.Fatalf("Resumed download returned error: %v", err)
+	}
+	if len(requested) != 2 || requested[0] != 2 || requested[1] != 3 {
+		t.Errorf("Expected chunks 2 and 3 to be requested, got %v", requested)
+	}
+
+	expected := chunkData(0) + chunkData(1) + chunkData(2) + chunkData(3)
+	written, _ := os.ReadFile(filename)
+	if string(written) != expected {
+		t.Errorf("Expected %q, got %q", expected, written)
+	}
+	if d.rowsWritten != 8 {
+		t.Errorf("Expected 8 rows, got %d", d.rowsWritten)
+	}
+	if _, err := os.Stat(resumeStatePath(filename)); !os.IsNotExist(err) {
+		t.Errorf("Expected the resume state to be removed, got %v", err)
+	}
+
+	// A resume state of another job is refused
+	os.WriteFile(resumeStatePath(filename), []byte(`{"sid":"other","output_mode":"raw","chunks":1,"bytes":10}`), 0o644)
+	downloaderConfig.Overwrite = true
+	d = NewDownloader(createTestClient(testServer.URL, "raw"), downloaderConfig)
+	err = d.DownloadSearchResults()
+	if err == nil || !strings.Contains(err.Error(), "was downloaded from job other") {
+		t.Errorf("Expected an error for another job's resume state, got %v", err)
+	}
+}
+
+func TestInterruptedDownload(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+	// Two chunks
+	jobStatusData = bytes.Replace(jobStatusData, []byte(`"resultCount": 2,`), []byte(`"resultCount": 15000,`), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			if r.URL.Query().Get("offset") == "0" {
+				w.Write([]byte("event 0\n"))
+				return
+			}
+			// Ctrl-C while the second chunk is in flight
+			cancel()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := t.TempDir() + "/results.txt"
+	d := NewDownloader(createTestClient(testServer.URL, "raw").WithContext(ctx), config.DownloaderConfig{
+		OutputMode:     "raw",
+		MaxConnections: 1,
+		SID:            sid,
+		Filename:       filename,
+	})
+	err = d.DownloadSearchResults()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the download to be canceled, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "interrupted after 1 of 2 chunks (1 rows written)") {
+		t.Errorf("Expected the error to describe what was written, got %v", err)
+	}
+
+	// The chunk written before the interruption is flushed and can be resumed from, but isn't moved
+	// into place
+	written, _ := os.ReadFile(partPath(filename))
+	if string(written) != "event 0\n" {
+		t.Errorf("Expected the first chunk to be written, got %q", written)
+	}
+	if !d.CanResume() {
+		t.Error("Expected the interrupted download to be resumable")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file for the interrupted download, got %v", err)
+	}
+}
+
+func TestResumeSink(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+	// Three chunks
+	jobStatusData = bytes.Replace(jobStatusData, []byte(`"resultCount": 2,`), []byte(`"resultCount": 25000,`), 1)
+	splunk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			chunk := strconv.Itoa(offset / 10000)
+			w.Write([]byte(`{"preview":false,"results":[{"_raw":"event ` + chunk + `-a"},{"_raw":"event ` + chunk + `-b"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer splunk.Close()
+
+	// The receiver refuses the second chunk until it's fixed
+	refuse := true
+	var keys []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if refuse && strings.Contains(string(body), "event 1-") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+	}))
+	defer receiver.Close()
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	downloaderConfig := config.DownloaderConfig{
+		OutputMode:     "ndjson",
+		MaxConnections: 1,
+		SID:            sid,
+		Filename:       receiver.URL + "/ingest",
+		AllowPartial:   true,
+	}
+	d := NewDownloader(createTestClient(splunk.URL, "ndjson"), downloaderConfig)
+	if err := d.DownloadSearchResults(); err == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+	if !d.CanResume() {
+		t.Fatal("Expected the failed download to be resumable")
+	}
+	if resumeSID, _ := ResumeSID(downloaderConfig.Filename); resumeSID != sid {
+		t.Errorf("Expected resume sid %s, got %q", sid, resumeSID)
+	}
+
+	// Resuming sends the chunks after the first, keeping the positions of their results
+	refuse = false
+	downloaderConfig.Resume = true
+	d = NewDownloader(createTestClient(splunk.URL, "ndjson"), downloaderConfig)
+	if err := d.DownloadSearchResults(); err != nil {
+		t.Fatalf("Resumed download returned error: %v", err)
+	}
+	expected := []string{sid + ":0-1", sid + ":2-3", sid + ":4-5"}
+	if strings.Join(keys, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected batches %v, got %v", expected, keys)
+	}
+	if _, err := os.Stat(resumeStatePath(downloaderConfig.Filename)); !os.IsNotExist(err) {
+		t.Errorf("Expected the resume state to be removed, got %v", err)
+	}
+}
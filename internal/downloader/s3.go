@@ -0,0 +1,266 @@
+package downloader
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 rejects multipart uploads with more parts than this
+const s3MaxParts = 10000
+
+// s3Client sends requests to S3 or a store speaking its API, signed with AWS Signature Version 4
+type s3Client struct {
+	endpoint     *url.URL // nil for AWS, whose endpoint depends on the bucket
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// s3ClientFromEnv configures the client from the environment variables the AWS CLI reads
+func s3ClientFromEnv() (*s3Client, error) {
+	c := &s3Client{
+		region:       cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("s3:// outputs need AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to be set")
+	}
+	if endpoint := cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+		}
+		c.endpoint = u
+	}
+	return c, nil
+}
+
+// gcsClientFromEnv configures a client for Google Cloud Storage, whose XML API takes the requests of
+// S3 signed with an HMAC key
+func gcsClientFromEnv() (*s3Client, error) {
+	c := &s3Client{
+		region:    "auto",
+		accessKey: os.Getenv("GCS_HMAC_ACCESS_ID"),
+		secretKey: os.Getenv("GCS_HMAC_SECRET"),
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, fmt.Errorf("gs:// outputs need the HMAC key in GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET to be set")
+	}
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = host
+		if !strings.Contains(host, "://") {
+			endpoint = "http://" + host
+		}
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Cloud Storage endpoint %q", endpoint)
+	}
+	c.endpoint = u
+	return c, nil
+}
+
+// objectURL returns the URL of an object. Other stores are addressed by path, AWS by virtual host.
+func (c *s3Client) objectURL(bucket, key string) *url.URL {
+	u := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, c.region), Path: "/" + key}
+	if c.endpoint != nil {
+		u = &url.URL{Scheme: c.endpoint.Scheme, Host: c.endpoint.Host, Path: strings.TrimSuffix(c.endpoint.Path, "/") + "/" + bucket + "/" + key}
+	}
+	u.RawPath = uriEscapePath(u.Path)
+	return u
+}
+
+// do sends a signed request for the object at u and returns the response's headers and body
+func (c *s3Client) do(ctx context.Context, method string, u *url.URL, query url.Values, body []byte) (http.Header, []byte, error) {
+	return sendStorageRequest(ctx, func() (*http.Request, error) {
+		target := *u
+		target.RawQuery = s3CanonicalQuery(query)
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		c.sign(req, body, time.Now())
+		return req, nil
+	})
+}
+
+// sign adds the AWS Signature Version 4 headers to req
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := slices.Sorted(maps.Keys(headers))
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encodes query sorted by name, which is both the query sent and the one signed
+func s3CanonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEscape(name)+"="+uriEscape(value))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// s3Object is an object in S3 or Cloud Storage. Parts go into a multipart upload started with the
+// first one.
+type s3Object struct {
+	client   *s3Client
+	url      *url.URL
+	uploadID string
+	parts    []s3Part
+}
+
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func newS3Object(uri string) (objectStore, error) {
+	return newS3ObjectFrom(uri, s3ClientFromEnv)
+}
+
+func newGCSObject(uri string) (objectStore, error) {
+	return newS3ObjectFrom(uri, gcsClientFromEnv)
+}
+
+func newS3ObjectFrom(uri string, newClient func() (*s3Client, error)) (objectStore, error) {
+	bucket, key, err := parseObjectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{client: client, url: client.objectURL(bucket, key)}, nil
+}
+
+func (o *s3Object) exists(ctx context.Context) (bool, error) {
+	_, _, err := o.client.do(ctx, http.MethodHead, o.url, nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (o *s3Object) put(ctx context.Context, data []byte) error {
+	_, _, err := o.client.do(ctx, http.MethodPut, o.url, nil, data)
+	return err
+}
+
+func (o *s3Object) uploadPart(ctx context.Context, number int, data []byte) error {
+	if o.uploadID == "" {
+		_, body, err := o.client.do(ctx, http.MethodPost, o.url, url.Values{"uploads": {""}}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to start the upload: %w", err)
+		}
+		var initiated struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := xml.Unmarshal(body, &initiated); err != nil || initiated.UploadID == "" {
+			return fmt.Errorf("failed to start the upload: no upload ID in the response")
+		}
+		o.uploadID = initiated.UploadID
+	}
+
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {o.uploadID}}
+	header, _, err := o.client.do(ctx, http.MethodPut, o.url, query, data)
+	if err != nil {
+		return err
+	}
+	o.parts = append(o.parts, s3Part{PartNumber: number, ETag: header.Get("ETag")})
+	return nil
+}
+
+func (o *s3Object) complete(ctx context.Context) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: o.parts})
+	if err != nil {
+		return err
+	}
+	_, response, err := o.client.do(ctx, http.MethodPost, o.url, url.Values{"uploadId": {o.uploadID}}, body)
+	if err == nil && bytes.Contains(response, []byte("<Error>")) {
+		// Completing can fail after S3 has already sent the 200 status
+		storageErr := &storageError{StatusCode: http.StatusOK}
+		xml.Unmarshal(response, storageErr)
+		err = storageErr
+	}
+	return err
+}
+
+func (o *s3Object) abort(ctx context.Context) error {
+	_, _, err := o.client.do(ctx, http.MethodDelete, o.url, url.Values{"uploadId": {o.uploadID}}, nil)
+	return err
+}
+
+func (o *s3Object) maxParts() int {
+	return s3MaxParts
+}
@@ -0,0 +1,249 @@
+package downloader
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpDialTimeout bounds connecting and the SSH handshake
+const sftpDialTimeout = 30 * time.Second
+
+// sftpFile is a file on an SSH server. Parts are appended to a part file next to it, which is renamed
+// to the file once complete, so like an object in cloud storage the file only ever appears whole.
+type sftpFile struct {
+	addr   string
+	config *ssh.ClientConfig
+	path   string
+
+	conn   *ssh.Client // connected with the first part
+	client *sftp.Client
+	part   *sftp.File
+}
+
+// newSFTPFile opens sftp://[user[:password]@]host[:port]/path. The path is absolute, or relative to
+// the login directory when it starts with /~/. The user defaults to SFTP_USER or the local user, and
+// the host key must be in SFTP_KNOWN_HOSTS or ~/.ssh/known_hosts.
+func newSFTPFile(uri string) (objectStore, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Hostname() == "" || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return nil, fmt.Errorf("invalid output %s, expected sftp://[user@]host[:port]/path", uri)
+	}
+	path := u.Path
+	if rest, ok := strings.CutPrefix(path, "/~/"); ok {
+		path = rest
+	}
+
+	username := cmp.Or(u.User.Username(), os.Getenv("SFTP_USER"))
+	if username == "" {
+		if current, err := user.Current(); err == nil {
+			username = current.Username
+		}
+	}
+	password, _ := u.User.Password()
+	auth, err := sftpAuthMethods(cmp.Or(password, os.Getenv("SFTP_PASSWORD")))
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := sftpHostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	return &sftpFile{
+		addr:   net.JoinHostPort(u.Hostname(), cmp.Or(u.Port(), "22")),
+		config: &ssh.ClientConfig{User: username, Auth: auth, HostKeyCallback: hostKeys, Timeout: sftpDialTimeout},
+		path:   path,
+	}, nil
+}
+
+// sftpAuthMethods returns the ways to log in that are configured: the password, the keys of a running
+// SSH agent and the private key in SFTP_IDENTITY_FILE, or the default keys in ~/.ssh without one
+func sftpAuthMethods(password string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	keyFiles := []string{os.Getenv("SFTP_IDENTITY_FILE")}
+	if keyFiles[0] == "" {
+		home, _ := os.UserHomeDir()
+		keyFiles = []string{filepath.Join(home, ".ssh", "id_ed25519"), filepath.Join(home, ".ssh", "id_ecdsa"), filepath.Join(home, ".ssh", "id_rsa")}
+	}
+	var signers []ssh.Signer
+	for i, keyFile := range keyFiles {
+		key, err := os.ReadFile(keyFile)
+		if errors.Is(err, os.ErrNotExist) && len(keyFiles) > 1 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the SSH key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) && len(keyFiles) > 1 {
+			// Default keys protected by a passphrase are left to the agent
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the SSH key %s: %w", keyFiles[i], err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("sftp:// outputs need an SSH agent, a key in SFTP_IDENTITY_FILE or ~/.ssh, or SFTP_PASSWORD")
+	}
+	return methods, nil
+}
+
+// sftpHostKeyCallback checks the server's key against SFTP_KNOWN_HOSTS, or ~/.ssh/known_hosts
+func sftpHostKeyCallback() (ssh.HostKeyCallback, error) {
+	knownHosts := os.Getenv("SFTP_KNOWN_HOSTS")
+	if knownHosts == "" {
+		home, _ := os.UserHomeDir()
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("sftp:// outputs need the server's host key in %s: %w", knownHosts, err)
+	}
+	return callback, nil
+}
+
+// connect logs into the server
+func (f *sftpFile) connect(ctx context.Context) (*ssh.Client, *sftp.Client, error) {
+	dialer := net.Dialer{Timeout: sftpDialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", f.addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(netConn, f.addr, f.config)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	conn := ssh.NewClient(sshConn, channels, requests)
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, client, nil
+}
+
+// disconnect closes the connection of an upload that ended
+func (f *sftpFile) disconnect() {
+	if f.part != nil {
+		f.part.Close()
+		f.part = nil
+	}
+	if f.client != nil {
+		f.client.Close()
+		f.conn.Close()
+		f.client, f.conn = nil, nil
+	}
+}
+
+func (f *sftpFile) exists(ctx context.Context) (bool, error) {
+	conn, client, err := f.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	defer client.Close()
+	_, err = client.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *sftpFile) put(ctx context.Context, data []byte) error {
+	if err := f.uploadPart(ctx, 1, data); err != nil {
+		f.abort(ctx)
+		return err
+	}
+	return f.complete(ctx)
+}
+
+// uploadPart appends the part to the part file, which the first part creates
+func (f *sftpFile) uploadPart(ctx context.Context, number int, data []byte) error {
+	if f.part == nil {
+		conn, client, err := f.connect(ctx)
+		if err != nil {
+			return err
+		}
+		f.conn, f.client = conn, client
+		part, err := client.OpenFile(partPath(f.path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			f.disconnect()
+			return err
+		}
+		f.part = part
+	}
+	_, err := f.part.Write(data)
+	return err
+}
+
+// complete renames the part file over the file, replacing it at once on servers that support it. The
+// part file is removed when that fails.
+func (f *sftpFile) complete(ctx context.Context) error {
+	defer f.disconnect()
+	err := f.part.Close()
+	f.part = nil
+	if err == nil {
+		err = f.rename()
+	}
+	if err != nil {
+		f.client.Remove(partPath(f.path))
+	}
+	return err
+}
+
+func (f *sftpFile) rename() error {
+	if _, ok := f.client.HasExtension("posix-rename@openssh.com"); ok {
+		return f.client.PosixRename(partPath(f.path), f.path)
+	}
+	if err := f.client.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return f.client.Rename(partPath(f.path), f.path)
+}
+
+// abort removes the part file
+func (f *sftpFile) abort(ctx context.Context) error {
+	defer f.disconnect()
+	if f.part == nil {
+		return nil
+	}
+	f.part.Close()
+	f.part = nil
+	return f.client.Remove(partPath(f.path))
+}
+
+// maxParts is unlimited, since the parts are appended to a single file
+func (f *sftpFile) maxParts() int {
+	return math.MaxInt
+}
@@ -0,0 +1,194 @@
+package downloader
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSFTPServer serves SFTP on the local file system to logins with the password "secret", with the
+// server's key in a known_hosts file the client reads. It returns the server's address.
+func startSFTPServer(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() != "spldl" || string(password) != "secret" {
+				return nil, errors.New("access denied")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config)
+		}
+	}()
+
+	addr := listener.Addr().String()
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("SFTP_KNOWN_HOSTS", knownHosts)
+	t.Setenv("SFTP_USER", "spldl")
+	t.Setenv("SFTP_PASSWORD", "secret")
+	return addr
+}
+
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}()
+		go func() {
+			defer channel.Close()
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+		}()
+	}
+}
+
+func TestSFTPOutput(t *testing.T) {
+	addr := startSFTPServer(t)
+	large := strings.Repeat("x", uploadPartSize+100)
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "single put", data: "a,b\n1,2\n"},
+		{name: "parts", data: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "results.csv")
+			uri := "sftp://" + addr + filename
+
+			store, err := openObjectStore(uri)
+			if err != nil {
+				t.Fatalf("openObjectStore returned error: %v", err)
+			}
+			if exists, err := store.exists(context.Background()); err != nil || exists {
+				t.Fatalf("Expected the file not to exist, got %t, %v", exists, err)
+			}
+			upload := &partUpload{ctx: context.Background(), store: store, uri: uri}
+			if _, err := io.WriteString(upload, tt.data); err != nil {
+				t.Fatalf("Write returned error: %v", err)
+			}
+			if _, err := os.Stat(filename); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected the file to appear only once committed, got %v", err)
+			}
+			if err := upload.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := upload.Commit(); err != nil {
+				t.Fatalf("Commit returned error: %v", err)
+			}
+
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.data {
+				t.Errorf("Expected a file of %d bytes, got %d", len(tt.data), len(data))
+			}
+			if _, err := os.Stat(partPath(filename)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected the part file to be gone, got %v", err)
+			}
+			if exists, err := store.exists(context.Background()); err != nil || !exists {
+				t.Errorf("Expected the file to exist, got %t, %v", exists, err)
+			}
+		})
+	}
+}
+
+func TestSFTPOutputAbort(t *testing.T) {
+	addr := startSFTPServer(t)
+	filename := filepath.Join(t.TempDir(), "results.csv")
+	uri := "sftp://" + addr + filename
+
+	store, err := openObjectStore(uri)
+	if err != nil {
+		t.Fatalf("openObjectStore returned error: %v", err)
+	}
+	upload := &partUpload{ctx: context.Background(), store: store, uri: uri}
+	if _, err := io.WriteString(upload, strings.Repeat("x", uploadPartSize+100)); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if _, err := os.Stat(partPath(filename)); err != nil {
+		t.Fatalf("Expected a part file during the upload, got %v", err)
+	}
+	upload.Abort()
+
+	entries, _ := os.ReadDir(filepath.Dir(filename))
+	if len(entries) != 0 {
+		t.Errorf("Expected an aborted upload to leave nothing behind, got %v", entries)
+	}
+}
+
+func TestSFTPOutputUnknownHost(t *testing.T) {
+	addr := startSFTPServer(t)
+	startSFTPServer(t) // replaces the known_hosts file, which no longer has the first server
+
+	store, err := openObjectStore("sftp://" + addr + "/results.csv")
+	if err != nil {
+		t.Fatalf("openObjectStore returned error: %v", err)
+	}
+	var keyErr *knownhosts.KeyError
+	if _, err := store.exists(context.Background()); !errors.As(err, &keyErr) {
+		t.Errorf("Expected a host key error, got %v", err)
+	}
+}
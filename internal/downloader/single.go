@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DownloadResultsFile downloads the results of the finished job in a single request straight into the
+// output file, as Splunk sends them, instead of in chunks over several connections. A transfer that
+// drops, during the run or in an earlier run that failed, continues from the last byte written with a
+// Range request, so a large export isn't downloaded again from the start; one whose job changed since
+// starts over. Nothing is done to the results on the way, so only csv and raw results written to a
+// local, uncompressed file are supported.
+func (d *Downloader) DownloadResultsFile() error {
+	defer d.closeProgress()
+	slog.Debug("Starting single request download", "sid", d.sid, "output_mode", d.outputMode)
+	if err := d.checkResultsFile(); err != nil {
+		return err
+	}
+
+	jobStatus, err := d.client.GetJobStatus(d.sid)
+	if err != nil {
+		return fmt.Errorf("failed to get job status: %w", err)
+	}
+	if !jobStatus.IsDone {
+		return fmt.Errorf("job %s is not complete (state: %s, progress: %.1f%%)", d.sid, jobStatus.DispatchState, jobStatus.DoneProgress*100)
+	}
+	if jobStatus.IsFailed {
+		return fmt.Errorf("job %s has failed", d.sid)
+	}
+	if err := d.checkJobMessages(d.sid, jobStatus); err != nil {
+		return err
+	}
+	if d.maxResults > 0 && jobStatus.ResultCount > d.maxResults {
+		return fmt.Errorf("job %s has %d results, more than the %d allowed by the system policy", d.sid, jobStatus.ResultCount, d.maxResults)
+	}
+	if jobStatus.ResultCount > maxJobResults {
+		return fmt.Errorf("job %s has more than %d results. Split your search into multiple jobs", d.sid, maxJobResults)
+	}
+	if err := checkOverwrite(d.filename, d.overwrite); err != nil {
+		return err
+	}
+
+	d.startedAt = time.Now()
+	d.totalChunks = 1
+	size, err := d.client.DownloadJobResults(d.sid, d.outputMode, d.filename)
+	d.bytesWritten = size
+	if err != nil {
+		return fmt.Errorf("failed to download the results of job %s after %d bytes, run the same command again to continue: %w", d.sid, size, err)
+	}
+	// The file holds the results as Splunk sent them, which aren't read to count them
+	d.rowsWritten = jobStatus.ResultCount
+	d.sendProgress(1)
+
+	if d.deleteWhenDone {
+		slog.Debug("Deleting search job", "sid", d.sid)
+		if err := d.client.DeleteSearchJob(d.sid); err != nil {
+			return fmt.Errorf("failed to delete job: %w", err)
+		}
+	}
+	slog.Info("Download completed successfully", "sid", d.sid, "bytes", size, "filename", d.filename)
+	return nil
+}
+
+// checkResultsFile rejects what a download in a single request can't do, since the results go to the
+// file without being read
+func (d *Downloader) checkResultsFile() error {
+	if d.outputMode != "csv" && d.outputMode != "raw" {
+		return fmt.Errorf("single request downloads write csv or raw results, not %s", d.outputMode)
+	}
+	if len(d.mergeSIDs) > 0 || d.autoSplit {
+		return fmt.Errorf("single request downloads write the results of a single job")
+	}
+	if d.filename == Stdout || isRemote(d.filename) || isCompressedFile(d.filename) || len(d.tee) > 0 {
+		return fmt.Errorf("single request downloads write to an uncompressed local file")
+	}
+	if _, ok := d.fileFormatOf(d.filename); ok || d.csvDialect != nil {
+		return fmt.Errorf("single request downloads keep the results as Splunk sends them and can't convert them to another format")
+	}
+	if d.fields != nil || d.postFilter != "" || d.clip != nil || d.dedupeState != "" || d.annotations != nil || d.redactor != nil || d.rawJSON || d.stopAfter > 0 {
+		return fmt.Errorf("single request downloads can't select fields, filter, clip, deduplicate, drop annotations, redact or stop early")
+	}
+	if d.resume || d.append || d.bucketSize > 0 || d.parallelWrites || d.verify || d.events {
+		return fmt.Errorf("single request downloads continue on their own and can't be combined with --resume, --append, --bucket, --parallel-writes, --verify or --events")
+	}
+	return nil
+}
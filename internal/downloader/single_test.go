@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestDownloadResultsFile(t *testing.T) {
+	jobStatusData, err := os.ReadFile("testdata/job_status.json")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	csvData, err := os.ReadFile("testdata/results.csv")
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const sid = "1756172871.1180"
+
+	// The first run's transfers all drop halfway, the second run's don't
+	dropping := true
+	var ranges []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write(jobStatusData)
+		case "/services/search/v2/jobs/" + sid + "/results":
+			if r.URL.Query().Get("count") != "0" || r.URL.Query().Get("output_mode") != "csv" {
+				t.Errorf("Unexpected results query %s", r.URL.RawQuery)
+			}
+			ranges = append(ranges, r.Header.Get("Range"))
+			w.Header().Set("ETag", `"results"`)
+			if dropping {
+				w.Header().Set("Content-Length", strconv.Itoa(len(csvData)))
+				w.Write(csvData[:len(csvData)/2])
+				return
+			}
+			http.ServeContent(w, r, "results.csv", time.Time{}, bytes.NewReader(csvData))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	filename := filepath.Join(t.TempDir(), "results.csv")
+	newDownloader := func() *Downloader {
+		return NewDownloader(createTestClient(testServer.URL, "csv"), config.DownloaderConfig{
+			OutputMode: "csv",
+			SID:        sid,
+			Filename:   filename,
+		})
+	}
+
+	if err := newDownloader().DownloadResultsFile(); err == nil || !strings.Contains(err.Error(), "run the same command again to continue") {
+		t.Fatalf("Expected the dropped transfer to fail, got %v", err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("Expected no output before the transfer is complete, got %v", err)
+	}
+
+	dropping, ranges = false, nil
+	if err := newDownloader().DownloadResultsFile(); err != nil {
+		t.Fatalf("DownloadResultsFile returned error: %v", err)
+	}
+	written, _ := os.ReadFile(filename)
+	if !bytes.Equal(written, csvData) {
+		t.Errorf("Output doesn't match the results")
+	}
+	expected := "bytes=" + strconv.Itoa(len(csvData)/2) + "-"
+	if len(ranges) != 1 || ranges[0] != expected {
+		t.Errorf("Expected the second run to continue with %q, got %q", expected, ranges)
+	}
+}
+
+func TestDownloadResultsFileRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.DownloaderConfig
+		expected string
+	}{
+		{"ndjson", config.DownloaderConfig{OutputMode: "ndjson", Filename: "results.ndjson"}, "csv or raw results, not ndjson"},
+		{"stdout", config.DownloaderConfig{OutputMode: "csv", Filename: Stdout}, "uncompressed local file"},
+		{"compressed", config.DownloaderConfig{OutputMode: "csv", Filename: "results.csv.gz"}, "uncompressed local file"},
+		{"converted", config.DownloaderConfig{OutputMode: "csv", Filename: "results.parquet"}, "can't convert them"},
+		{"fields", config.DownloaderConfig{OutputMode: "csv", Filename: "results.csv", Fields: []string{"host"}}, "can't select fields"},
+		{"resume", config.DownloaderConfig{OutputMode: "csv", Filename: "results.csv", Resume: true}, "--resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDownloader(nil, tt.config).DownloadResultsFile()
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
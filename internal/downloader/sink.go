@@ -0,0 +1,374 @@
+package downloader
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/report"
+)
+
+// Network outputs send results in batches of this many by default, with this many batches on their
+// way at once, and give a batch up after this many attempts
+const (
+	defaultSinkBatchSize   = 1000
+	defaultSinkMaxInFlight = 2
+	defaultSinkAttempts    = 5
+	defaultSinkBackoff     = time.Second
+)
+
+// sinkOptions shape how results are sent to a network output such as an Elasticsearch index
+type sinkOptions struct {
+	batchSize     int           // results per batch
+	flushInterval time.Duration // how long a batch that isn't full waits for more results, 0 until the end
+	maxInFlight   int           // batches being sent at once before writes wait
+	attempts      int           // attempts at sending a batch before it's given up
+	backoff       time.Duration // delay before the second attempt, doubled after every further one
+	deadLetter    *deadLetter   // takes the batches that were given up, nil to fail the download
+	warnings      *report.Warnings
+	sid           string // the job whose results are sent, which identifies them across runs, empty for exports
+}
+
+// sinkOptions returns the options of the network outputs of the download
+func (d *Downloader) sinkOptions() sinkOptions {
+	options := sinkOptions{
+		batchSize:     d.sinkBatchSize,
+		flushInterval: d.sinkFlushInterval,
+		maxInFlight:   d.sinkMaxInFlight,
+		attempts:      d.sinkAttempts,
+		backoff:       cmp.Or(d.sinkBackoff, defaultSinkBackoff),
+		deadLetter:    d.deadLetter,
+		warnings:      d.warnings,
+		sid:           d.sid,
+	}
+	if options.batchSize <= 0 {
+		options.batchSize = defaultSinkBatchSize
+	}
+	if options.maxInFlight <= 0 {
+		options.maxInFlight = defaultSinkMaxInFlight
+	}
+	if options.attempts <= 0 {
+		options.attempts = defaultSinkAttempts
+	}
+	return options
+}
+
+// permanentError is a failure to send a batch that sending it again won't fix, such as results the
+// destination rejected as invalid
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// isPermanentStatus reports whether err is an HTTP response that sending the request again won't
+// change. Timeouts, throttling and server errors are worth another attempt.
+func isPermanentStatus(err error) bool {
+	var storageErr *storageError
+	if !errors.As(err, &storageErr) {
+		return false
+	}
+	switch storageErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return storageErr.StatusCode < 500
+}
+
+// deadLetter is the ndjson file the batches a network output didn't take are appended to, so the
+// download carries on and they can be sent again later
+type deadLetter struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newDeadLetter(path string) *deadLetter {
+	if path == "" {
+		return nil
+	}
+	return &deadLetter{path: path}
+}
+
+// write appends results to the file, one per line
+func (l *deadLetter) write(results []sinkResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, result := range results {
+		w.Write(result.data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sinkResult is an ndjson result sent to a network output
+type sinkResult struct {
+	position int // among all results of the job sent to the output, counting from 0
+	data     []byte
+}
+
+// resultBatch is a batch of ndjson results sent to a network output
+type resultBatch struct {
+	sid     string // the job of the results, empty for exports
+	results []sinkResult
+}
+
+// resultID identifies the i-th result of the batch across runs, as its job and position, so sending it
+// again replaces it instead of adding it twice. Results of exports have no job and no ID.
+func (b *resultBatch) resultID(i int) string {
+	if b.sid == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", b.sid, b.results[i].position)
+}
+
+// batchSender sends batches of results to a network output
+type batchSender interface {
+	// send sends batch. When it fails it leaves in batch only the results the output didn't take, and
+	// returns a permanentError when sending them again is pointless.
+	send(ctx context.Context, batch *resultBatch) error
+	// close releases the connections to the output once no more batches are sent
+	close()
+}
+
+// batchSink sends the results written to a network output in batches, with its sender. A batch goes once
+// it's full, or once it's waited the flush interval for more results. While the most batches allowed
+// are on their way, writes wait for one of them, which holds back the collector and with it the
+// download, so a slow destination isn't overwhelmed and nothing is dropped. A batch that fails is sent
+// again with backoff; once it's given up it goes to the dead-letter file, or fails the download
+// without one.
+type batchSink struct {
+	ctx     context.Context
+	uri     string
+	sender  batchSender
+	options sinkOptions
+	lines   lineSplitter
+	slots   chan struct{} // holds a token for every batch on its way
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	batch  *resultBatch // the results waiting to be sent
+	queued int          // results added to batches, including those sent before a resumed download
+	timer  *time.Timer  // sends the batch once it's waited the flush interval
+	sent   int          // results the output took
+	dead   int          // results given up and written to the dead-letter file
+	err    error        // the first batch that failed
+	done   bool
+}
+
+func newBatchSink(ctx context.Context, uri string, options sinkOptions, sender batchSender) *batchSink {
+	return &batchSink{
+		ctx:     ctx,
+		uri:     uri,
+		sender:  sender,
+		options: options,
+		slots:   make(chan struct{}, options.maxInFlight),
+	}
+}
+
+// resumeAt continues a download after the results sent before it was interrupted, so the results keep
+// their positions
+func (s *batchSink) resumeAt(position int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued = position
+}
+
+// Write adds the results in p to the batch and sends every full batch
+func (s *batchSink) Write(p []byte) (int, error) {
+	if err := s.lines.write(p, s.add); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *batchSink) add(result []byte) error {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return s.err
+	}
+	if s.batch == nil {
+		s.batch = &resultBatch{sid: s.options.sid}
+		if s.options.flushInterval > 0 {
+			s.timer = time.AfterFunc(s.options.flushInterval, s.flushWaiting)
+		}
+	}
+	// The splitter reuses its buffer, so the result is copied
+	s.batch.results = append(s.batch.results, sinkResult{position: s.queued, data: append([]byte(nil), result...)})
+	s.queued++
+	var full *resultBatch
+	if len(s.batch.results) == s.options.batchSize {
+		full = s.takeBatch()
+	}
+	s.mu.Unlock()
+	return s.dispatch(full)
+}
+
+// takeBatch returns the waiting batch for sending, nil if there's none. The caller holds mu.
+func (s *batchSink) takeBatch() *resultBatch {
+	batch := s.batch
+	s.batch = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	return batch
+}
+
+// flushWaiting sends a batch that's waited the flush interval without filling up
+func (s *batchSink) flushWaiting() {
+	s.mu.Lock()
+	batch := s.takeBatch()
+	s.mu.Unlock()
+	if err := s.dispatch(batch); err != nil {
+		s.fail(err)
+	}
+}
+
+// dispatch sends batch once fewer than the most batches allowed are on their way
+func (s *batchSink) dispatch(batch *resultBatch) error {
+	if batch == nil {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+		s.deliver(batch)
+	}()
+	return nil
+}
+
+// deliver sends batch, again with backoff until it succeeds, fails for good or runs out of attempts
+func (s *batchSink) deliver(batch *resultBatch) {
+	count := len(batch.results)
+	backoff := s.options.backoff
+	for attempt := 1; ; attempt++ {
+		err := s.sender.send(s.ctx, batch)
+		if err == nil {
+			s.mu.Lock()
+			s.sent += count
+			s.mu.Unlock()
+			slog.Debug("Sent batch", "uri", s.uri, "results", count)
+			return
+		}
+		var permanent *permanentError
+		if attempt < s.options.attempts && s.ctx.Err() == nil && !errors.As(err, &permanent) {
+			slog.Warn("Failed to send batch, retrying", "uri", s.uri, "results", len(batch.results), "attempt", attempt, "backoff", backoff, "error", err)
+			select {
+			case <-time.After(backoff):
+			case <-s.ctx.Done():
+			}
+			backoff = min(backoff*2, maxRetryBackoff)
+			continue
+		}
+		s.giveUp(batch, count, fmt.Errorf("failed to send results to %s: %w", s.uri, err))
+		return
+	}
+}
+
+// giveUp writes the results of batch the output didn't take to the dead-letter file, or fails the
+// download with err without one
+func (s *batchSink) giveUp(batch *resultBatch, count int, err error) {
+	if s.options.deadLetter == nil || s.ctx.Err() != nil {
+		s.fail(err)
+		return
+	}
+	if writeErr := s.options.deadLetter.write(batch.results); writeErr != nil {
+		s.fail(fmt.Errorf("%w, and writing them to the dead-letter file failed: %w", err, writeErr))
+		return
+	}
+	slog.Warn("Gave up sending results, wrote them to the dead-letter file", "uri", s.uri, "results", len(batch.results), "file", s.options.deadLetter.path, "error", err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent += count - len(batch.results)
+	s.dead += len(batch.results)
+}
+
+// fail records the first error, which the next write or the commit returns
+func (s *batchSink) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// flush sends the waiting batch and waits for every batch on its way
+func (s *batchSink) flush() error {
+	s.mu.Lock()
+	batch := s.takeBatch()
+	s.mu.Unlock()
+	err := s.dispatch(batch)
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// Close keeps the last batch for Commit
+func (s *batchSink) Close() error {
+	return nil
+}
+
+// Commit sends the last batch, with a final result that didn't end in a newline, and waits for the
+// output to take every batch
+func (s *batchSink) Commit() error {
+	if rest := s.lines.rest(); rest != nil {
+		if err := s.add(rest); err != nil {
+			return err
+		}
+	}
+	err := s.flush()
+	s.sender.close()
+	if err != nil {
+		return err
+	}
+	s.done = true
+	slog.Info("Sent the results", "uri", s.uri, "results", s.sent)
+	if s.dead > 0 {
+		s.options.warnings.Addf("sink", "%d results %s didn't take were written to %s", s.dead, s.uri, s.options.deadLetter.path)
+	}
+	return nil
+}
+
+// Abort stops sending and leaves the batches sent so far, since the output can't take them back
+func (s *batchSink) Abort() {
+	if s.done {
+		return
+	}
+	s.mu.Lock()
+	s.takeBatch()
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.sender.close()
+	if s.sent > 0 {
+		slog.Warn("The download failed after results were sent, those sent stay there", "uri", s.uri, "results", s.sent)
+	}
+}
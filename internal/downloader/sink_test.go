@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/report"
+)
+
+// funcSender sends batches with a function
+type funcSender func(ctx context.Context, batch *resultBatch) error
+
+func (f funcSender) send(ctx context.Context, batch *resultBatch) error { return f(ctx, batch) }
+
+func (f funcSender) close() {}
+
+func TestBatchSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches []string
+	release := make(chan struct{})
+	send := func(ctx context.Context, batch *resultBatch) error {
+		<-release
+		var lines []string
+		for _, result := range batch.results {
+			lines = append(lines, string(result.data))
+		}
+		mu.Lock()
+		batches = append(batches, strings.Join(lines, ","))
+		mu.Unlock()
+		return nil
+	}
+
+	// With one batch on its way, the write of a second full batch waits for it
+	sink := newBatchSink(context.Background(), "test://", sinkOptions{batchSize: 2, maxInFlight: 1}, funcSender(send))
+	written := make(chan error)
+	go func() {
+		_, err := io.WriteString(sink, "1\n2\n3\n4\n5\n")
+		written <- err
+	}()
+	select {
+	case <-written:
+		t.Fatal("Expected the write to wait while a batch is on its way")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-written; err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if strings.Join(batches, "|") != "1,2|3,4|5" {
+		t.Errorf("Expected batches 1,2|3,4|5, got %v", batches)
+	}
+
+	// A batch that isn't full goes once it's waited the flush interval
+	batches = nil
+	sink = newBatchSink(context.Background(), "test://", sinkOptions{batchSize: 100, maxInFlight: 1, flushInterval: 10 * time.Millisecond}, funcSender(send))
+	if _, err := io.WriteString(sink, "1\n2\n"); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(batches)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the batch to be sent after the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if strings.Join(batches, "|") != "1,2" {
+		t.Errorf("Expected one batch 1,2, got %v", batches)
+	}
+}
+
+func TestBatchSinkRetries(t *testing.T) {
+	attempts := 0
+	flaky := funcSender(func(ctx context.Context, batch *resultBatch) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	options := sinkOptions{batchSize: 10, maxInFlight: 1, attempts: 3, backoff: time.Millisecond}
+	sink := newBatchSink(context.Background(), "test://", options, flaky)
+	io.WriteString(sink, "1\n2\n")
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected the batch to be sent 3 times, got %d", attempts)
+	}
+
+	// A permanent failure isn't retried, and without a dead-letter file fails the download
+	attempts = 0
+	rejecting := funcSender(func(ctx context.Context, batch *resultBatch) error {
+		attempts++
+		return &permanentError{errors.New("rejected")}
+	})
+	sink = newBatchSink(context.Background(), "test://", options, rejecting)
+	io.WriteString(sink, "1\n2\n")
+	if err := sink.Commit(); err == nil || err.Error() != "failed to send results to test://: rejected" || attempts != 1 {
+		t.Errorf("Expected the commit to fail after one attempt, got %v after %d", err, attempts)
+	}
+
+	// A batch that's given up goes to the dead-letter file
+	attempts = 0
+	failing := funcSender(func(ctx context.Context, batch *resultBatch) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	options.deadLetter = newDeadLetter(filepath.Join(t.TempDir(), "dead.ndjson"))
+	options.warnings = &report.Warnings{}
+	sink = newBatchSink(context.Background(), "test://", options, failing)
+	io.WriteString(sink, "1\n2\n")
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(options.deadLetter.path); string(data) != "1\n2\n" || attempts != 3 {
+		t.Errorf("Expected the batch in the dead-letter file after 3 attempts, got %q after %d", data, attempts)
+	}
+	if len(options.warnings.List()) != 1 {
+		t.Errorf("Expected a warning about the dead-letter file, got %v", options.warnings.List())
+	}
+}
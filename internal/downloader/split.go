@@ -0,0 +1,167 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// A split search with more windows than this is most likely a mistake, such as an all-time search
+const maxSplitWindows = 10000
+
+// timeWindow is the [start, end) time range of one job of a split search
+type timeWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+func (w timeWindow) String() string {
+	return w.start.UTC().Format(time.RFC3339) + " to " + w.end.UTC().Format(time.RFC3339)
+}
+
+// splitWindows cuts [start, end) into windows of size, the last window covering what remains
+func splitWindows(start, end time.Time, size time.Duration) []timeWindow {
+	var windows []timeWindow
+	for windowStart := start; windowStart.Before(end); windowStart = windowStart.Add(size) {
+		windows = append(windows, timeWindow{start: windowStart, end: minTime(windowStart.Add(size), end)})
+	}
+	return windows
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// epoch formats t as a Splunk time modifier
+func epoch(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', 3, 64)
+}
+
+// downloadSplitSearch re-dispatches the search of a job with too many results across consecutive
+// time windows and writes the results of every window to the output, oldest window first.
+// Windows that still have too many results are halved until they don't.
+func (d *Downloader) downloadSplitSearch(jobStatus splunkclient.SearchJobContent) error {
+	if d.search == "" {
+		return fmt.Errorf("job %s has more than %d results and can only be split when spldl runs its search", d.sid, maxJobResults)
+	}
+	if d.verify {
+		return fmt.Errorf("verification is not supported for split searches")
+	}
+	if d.resume {
+		return fmt.Errorf("resuming is not supported for split searches")
+	}
+	if d.splitWindow <= 0 {
+		return fmt.Errorf("split window must be positive")
+	}
+
+	windows := splitWindows(jobStatus.EarliestTime, jobStatus.LatestTime, d.splitWindow)
+	if len(windows) == 0 {
+		return fmt.Errorf("job %s has no time range to split", d.sid)
+	}
+	if len(windows) > maxSplitWindows {
+		return fmt.Errorf("splitting %s into windows of %s would take %d jobs, use a larger --split-window or a narrower time range",
+			timeWindow{jobStatus.EarliestTime, jobStatus.LatestTime}, d.splitWindow, len(windows))
+	}
+	slog.Info("Splitting search across time windows", "sid", d.sid, "result_count", jobStatus.ResultCount, "windows", len(windows), "window", d.splitWindow)
+
+	// The original job is replaced by the window jobs
+	if d.deleteWhenDone {
+		d.deleteJob(d.sid)
+	}
+
+	err := d.prepareOutput()
+	if err != nil {
+		return err
+	}
+	writer, err := d.openOutput()
+	if err != nil {
+		return err
+	}
+
+	for len(windows) > 0 {
+		window := windows[0]
+		windows = windows[1:]
+
+		halves, err := d.downloadWindow(writer, window)
+		if err != nil {
+			return errors.Join(fmt.Errorf("window %s: %w", window, err), writer.Close())
+		}
+		// Keep the halves first so the output stays in time order
+		windows = append(halves, windows...)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	if err := d.checkRowCount(); err != nil {
+		return err
+	}
+	err = d.finishOutput()
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Download completed successfully", "filename", d.filename, "rows", d.rowsWritten)
+	return nil
+}
+
+// downloadWindow runs the search over window and downloads its results, or returns the halves of
+// the window when it has more results than Splunk keeps
+func (d *Downloader) downloadWindow(writer chunkOutput, window timeWindow) ([]timeWindow, error) {
+	sid, err := d.client.NewSearchJob(d.search, epoch(window.start), epoch(window.end))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create search job: %w", err)
+	}
+	slog.Info("Created window search job", "sid", sid, "window", window.String())
+
+	err = d.client.WaitUntilJobIsDone(sid)
+	if err != nil {
+		return nil, err
+	}
+	status, err := d.client.GetJobStatus(sid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job status: %w", err)
+	}
+	if status.IsFailed {
+		return nil, fmt.Errorf("job %s has failed", sid)
+	}
+	if err := d.checkJobMessages(sid, status); err != nil {
+		return nil, err
+	}
+
+	if status.ResultCount > maxJobResults {
+		// Window jobs are spldl's own, so they're cleaned up regardless of --delete-when-done
+		d.deleteJob(sid)
+		if window.end.Sub(window.start) <= time.Second {
+			return nil, fmt.Errorf("more than %d results within a second, the search can't be split by time", maxJobResults)
+		}
+		middle := window.start.Add(window.end.Sub(window.start) / 2)
+		slog.Info("Window has too many results, halving it", "window", window.String(), "result_count", status.ResultCount)
+		return []timeWindow{{window.start, middle}, {middle, window.end}}, nil
+	}
+
+	d.sid = sid
+	err = d.downloadJob(writer, status)
+	if err != nil {
+		return nil, err
+	}
+	if d.deleteWhenDone {
+		d.deleteJob(sid)
+	}
+	return nil, nil
+}
+
+// deleteJob deletes a job spldl no longer needs, the job expires on its own when that fails
+func (d *Downloader) deleteJob(sid string) {
+	if err := d.client.DeleteSearchJob(sid); err != nil {
+		slog.Warn("Failed to delete search job", "sid", sid, "error", err)
+	}
+}
@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitWindows(t *testing.T) {
+	start := time.Date(2025, 8, 25, 1, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 8, 26, 1, 47, 51, 0, time.UTC)
+
+	windows := splitWindows(start, end, 12*time.Hour)
+	expected := []timeWindow{
+		{start, start.Add(12 * time.Hour)},
+		{start.Add(12 * time.Hour), start.Add(24 * time.Hour)},
+		{start.Add(24 * time.Hour), end},
+	}
+	if !reflect.DeepEqual(windows, expected) {
+		t.Errorf("Expected %v, got %v", expected, windows)
+	}
+
+	if e := epoch(time.UnixMilli(1756172871118)); e != "1756172871.118" {
+		t.Errorf("Unexpected epoch %s", e)
+	}
+}
+
+func TestDropRepeatedHeader(t *testing.T) {
+	d := &Downloader{outputMode: "csv", sid: "2"}
+
+	first, err := d.dropRepeatedHeader("_time,_raw\n1,a\n")
+	if err != nil || first != "_time,_raw\n1,a\n" {
+		t.Errorf("Expected the first header to be kept, got %q, %v", first, err)
+	}
+	second, err := d.dropRepeatedHeader("_time,_raw\n2,b\n")
+	if err != nil || second != "2,b\n" {
+		t.Errorf("Expected the repeated header to be dropped, got %q, %v", second, err)
+	}
+	if _, err := d.dropRepeatedHeader("_time,host\n3,c\n"); err == nil {
+		t.Error("Expected an error for different columns")
+	}
+
+	// ndjson results have no header to drop
+	d = &Downloader{outputMode: "ndjson", sid: "2"}
+	for range 2 {
+		if data, err := d.dropRepeatedHeader("{\"_raw\":\"a\"}\n"); err != nil || data != "{\"_raw\":\"a\"}\n" {
+			t.Errorf("Expected ndjson to be kept, got %q, %v", data, err)
+		}
+	}
+}
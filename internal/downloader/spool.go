@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// bufferedChunk is an out-of-order chunk held compressed until the collector can write it. Splunk
+// results compress very well, so this keeps large reorder windows affordable.
+type bufferedChunk struct {
+	offset     int
+	size       int // uncompressed size
+	compressed []byte
+}
+
+func compressChunk(chunk eventChunk) (bufferedChunk, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return bufferedChunk{}, err
+	}
+	if _, err := io.WriteString(writer, chunk.data); err != nil {
+		return bufferedChunk{}, err
+	}
+	if err := writer.Close(); err != nil {
+		return bufferedChunk{}, err
+	}
+
+	return bufferedChunk{
+		offset:     chunk.offset,
+		size:       len(chunk.data),
+		compressed: buf.Bytes(),
+	}, nil
+}
+
+func (b bufferedChunk) decompress() (eventChunk, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(b.compressed))
+	if err != nil {
+		return eventChunk{}, err
+	}
+	defer reader.Close()
+
+	var sb bytes.Buffer
+	sb.Grow(b.size)
+	if _, err := io.Copy(&sb, reader); err != nil {
+		return eventChunk{}, err
+	}
+
+	return eventChunk{
+		offset: b.offset,
+		data:   sb.String(),
+	}, nil
+}
+
+// reorderWindow bounds how far ahead of the collector the workers download. One stalled chunk would
+// otherwise let the other workers fill memory with every chunk after it.
+type reorderWindow struct {
+	mu      sync.Mutex
+	moved   *sync.Cond
+	next    int // the next chunk the collector writes
+	size    int
+	aborted bool
+}
+
+func newReorderWindow(next, size int) *reorderWindow {
+	w := &reorderWindow{next: next, size: size}
+	w.moved = sync.NewCond(&w.mu)
+	return w
+}
+
+// wait blocks until offset is inside the window. It returns false when the download was aborted, since
+// the chunks holding up the window may never arrive.
+func (w *reorderWindow) wait(offset int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for offset >= w.next+w.size && !w.aborted {
+		w.moved.Wait()
+	}
+	return !w.aborted
+}
+
+// advance moves the window once the chunks before next have been written
+func (w *reorderWindow) advance(next int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.next = next
+	w.moved.Broadcast()
+}
+
+// abort releases the waiting workers after a chunk failed
+func (w *reorderWindow) abort() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.aborted = true
+	w.moved.Broadcast()
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBufferedChunkRoundTrip(t *testing.T) {
+	chunk := eventChunk{
+		offset: 3,
+		data:   strings.Repeat("{\"_raw\":\"127.0.0.1 - - GET /index.html 200\",\"host\":\"web01\"}\n", 1000),
+	}
+
+	buffered, err := compressChunk(chunk)
+	if err != nil {
+		t.Fatalf("compressChunk returned an error: %v", err)
+	}
+	if len(buffered.compressed) >= len(chunk.data) {
+		t.Errorf("Expected compressed size below %d, got %d", len(chunk.data), len(buffered.compressed))
+	}
+
+	restored, err := buffered.decompress()
+	if err != nil {
+		t.Fatalf("decompress returned an error: %v", err)
+	}
+	if restored != chunk {
+		t.Errorf("Restored chunk does not match the original (offset %d, %d bytes)", restored.offset, len(restored.data))
+	}
+}
+
+func TestReorderWindow(t *testing.T) {
+	window := newReorderWindow(0, 2)
+	if !window.wait(1) {
+		t.Fatal("Expected chunk 1 to be inside the window")
+	}
+
+	waited := make(chan bool)
+	go func() { waited <- window.wait(3) }()
+	select {
+	case <-waited:
+		t.Fatal("Expected chunk 3 to wait for the window to move")
+	case <-time.After(20 * time.Millisecond):
+	}
+	window.advance(2)
+	if !<-waited {
+		t.Error("Expected chunk 3 to be released once chunk 1 was written")
+	}
+
+	go func() { waited <- window.wait(10) }()
+	window.abort()
+	if <-waited {
+		t.Error("Expected waiting workers to be released by abort")
+	}
+}
@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// webhook posts batches of ndjson results to an HTTP endpoint, one request per batch. Like an index,
+// the receiver keeps the batches it took before a download failed.
+type webhook struct {
+	url     string
+	headers http.Header
+}
+
+// newWebhook returns the webhook at the http(s) URL uri, sending headers, each "Name: value", with
+// every batch
+func newWebhook(uri string, headers []string) (*webhook, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid output %s, expected e.g. https://host/path", uri)
+	}
+	w := &webhook{url: uri, headers: http.Header{}}
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid webhook header %q, expected Name: value", header)
+		}
+		w.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return w, nil
+}
+
+// openWebhook starts posting the output to the URL uri
+func (d *Downloader) openWebhook(uri string) (*fileOutput, error) {
+	dest, err := newWebhook(uri, d.webhookHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return newOutputTo(newBatchSink(d.client.Context(), uri, d.sinkOptions(), dest), uri), nil
+}
+
+// send posts a batch. Responses other than timeouts, throttling and server errors fail it for good.
+func (w *webhook) send(ctx context.Context, batch *resultBatch) error {
+	var body bytes.Buffer
+	for _, result := range batch.results {
+		body.Write(result.data)
+		body.WriteByte('\n')
+	}
+	// A batch sent again, by a retry or a resumed download, carries the same key
+	var key string
+	if batch.sid != "" && len(batch.results) > 0 {
+		key = fmt.Sprintf("%s:%d-%d", batch.sid, batch.results[0].position, batch.results[len(batch.results)-1].position)
+	}
+	_, _, err := sendStorageRequestOnce(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = w.headers.Clone()
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		return req, nil
+	})
+	if isPermanentStatus(err) {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// close has nothing to release, the requests don't share a connection
+func (w *webhook) close() {}
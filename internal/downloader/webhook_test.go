@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var requests []string
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// The first request finds the receiver overloaded
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+	}))
+	defer server.Close()
+
+	hook, err := newWebhook(server.URL+"/ingest", []string{"Authorization: Bearer secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := sinkOptions{batchSize: 2, maxInFlight: 1, attempts: 2, backoff: time.Millisecond}
+	sink := newBatchSink(context.Background(), server.URL+"/ingest", options, hook)
+	io.WriteString(sink, "{\"a\":1}\n{\"a\":2}\n{\"a\":3}")
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	expected := []string{"{\"a\":1}\n{\"a\":2}\n", "{\"a\":3}\n"}
+	if strings.Join(requests, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected requests %q, got %q", expected, requests)
+	}
+
+	// A receiver refusing the requests fails the download without more attempts
+	hook, _ = newWebhook(server.URL+"/ingest", nil)
+	sink = newBatchSink(context.Background(), server.URL+"/ingest", options, hook)
+	io.WriteString(sink, "{\"a\":1}\n")
+	if err := sink.Commit(); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Expected the unauthorized request to fail the commit, got %v", err)
+	}
+
+	for _, header := range []string{"Authorization", ": value"} {
+		if _, err := newWebhook(server.URL, []string{header}); err == nil {
+			t.Errorf("Expected header %q to be rejected", header)
+		}
+	}
+}
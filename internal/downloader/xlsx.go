@@ -0,0 +1,148 @@
+package downloader
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Excel refuses to open sheets with more rows or longer cells than this
+const (
+	xlsxMaxRows     = 1048576
+	xlsxMaxCellSize = 32767
+)
+
+// The parts of a workbook besides its one sheet, which never change
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Results" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	// Style 1 is the bold header
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font/><font><b/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="2"><xf/><xf fontId="1" applyFont="1"/></cellXfs></styleSheet>`},
+}
+
+// The sheet's first row stays in view while scrolling
+const (
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/><selection pane="bottomLeft"/></sheetView></sheetViews><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// Numbers Excel can hold without losing digits are written as numbers, anything else as text so IDs
+// with leading zeros or more than 15 digits stay intact
+var xlsxNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,14})(\.[0-9]{1,15})?$`)
+
+// xlsxWriter converts the CSV Splunk sends into a workbook with one sheet, whose first row is the
+// header. Rows are written to the sheet as they arrive, so memory stays bounded however many
+// results there are.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer // the sheet part, opened by WriteHeader
+	rows  int
+}
+
+func newXLSXWriter(w io.Writer) *xlsxWriter {
+	return &xlsxWriter{zip: zip.NewWriter(w)}
+}
+
+// WriteHeader writes the fixed parts and opens the sheet
+func (x *xlsxWriter) WriteHeader() error {
+	for _, part := range xlsxParts {
+		w, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+	sheet, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return err
+	}
+	x.sheet = sheet
+	return nil
+}
+
+// WriteChunk adds the CSV records in data to the sheet. Chunks are written whole, so data never
+// ends within a record.
+func (x *xlsxWriter) WriteChunk(data string) error {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	var sb strings.Builder
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse row: %w", err)
+		}
+		if x.rows == xlsxMaxRows {
+			return fmt.Errorf("xlsx sheets hold at most %d rows, use csv for more results", xlsxMaxRows)
+		}
+		x.rows++
+		x.writeRow(&sb, row)
+	}
+	_, err := io.WriteString(x.sheet, sb.String())
+	return err
+}
+
+func (x *xlsxWriter) writeRow(sb *strings.Builder, row []string) {
+	header := x.rows == 1
+	fmt.Fprintf(sb, `<row r="%d">`, x.rows)
+	for _, field := range row {
+		switch {
+		case header:
+			sb.WriteString(`<c t="inlineStr" s="1"><is><t xml:space="preserve">`)
+			xml.EscapeText(sb, []byte(field))
+			sb.WriteString(`</t></is></c>`)
+		case xlsxNumberPattern.MatchString(field):
+			sb.WriteString(`<c><v>`)
+			sb.WriteString(field)
+			sb.WriteString(`</v></c>`)
+		case field == "":
+			sb.WriteString(`<c/>`)
+		default:
+			sb.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(sb, []byte(truncateCell(field)))
+			sb.WriteString(`</t></is></c>`)
+		}
+	}
+	sb.WriteString(`</row>`)
+}
+
+// truncateCell cuts a field to what a cell holds, on a character boundary
+func truncateCell(field string) string {
+	if len(field) <= xlsxMaxCellSize {
+		return field
+	}
+	field = field[:xlsxMaxCellSize]
+	for !utf8.ValidString(field) {
+		field = field[:len(field)-1]
+	}
+	return field
+}
+
+// Close ends the sheet and writes the workbook's directory. It doesn't close the underlying writer.
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
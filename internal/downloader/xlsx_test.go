@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXOutput(t *testing.T) {
+	filename := t.TempDir() + "/results.xlsx"
+	output, err := newFileOutput(filename, nil)
+	if err != nil {
+		t.Fatalf("newFileOutput returned error: %v", err)
+	}
+	for _, chunk := range []string{
+		"\"_time\",host,bytes,\"_raw\"\n2025-08-26T02:00:00.000+00:00,web01,1024,\"a <b> & \"\"c\"\"\"\n",
+		"2025-08-26T02:00:01.000+00:00,,007,\"two\nlines\"\n",
+	} {
+		if _, err := output.WriteString(chunk); err != nil {
+			t.Fatalf("WriteString returned error: %v", err)
+		}
+	}
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	workbook, err := zip.OpenReader(partPath(filename))
+	if err != nil {
+		t.Fatalf("Output isn't a zip file: %v", err)
+	}
+	defer workbook.Close()
+	parts := make(map[string]string)
+	for _, file := range workbook.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[file.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Fatalf("Workbook lacks %s", name)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s isn't well-formed XML: %v", name, err)
+			}
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, expected := range []string{
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`,
+		`<row r="1"><c t="inlineStr" s="1"><is><t xml:space="preserve">_time</t></is></c>`,
+		`<c><v>1024</v></c><c t="inlineStr"><is><t xml:space="preserve">a &lt;b&gt; &amp; &#34;c&#34;</t></is></c></row>`,
+		// Empty fields stay empty and leading zeros are kept as text
+		`<row r="3"><c t="inlineStr"><is><t xml:space="preserve">2025-08-26T02:00:01.000+00:00</t></is></c><c/><c t="inlineStr"><is><t xml:space="preserve">007</t></is></c>`,
+		`two&#xA;lines`,
+	} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("Expected the sheet to contain %q, got %q", expected, sheet)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package keyring stores secrets in the platform's credential store: the login keychain on macOS,
+// the Secret Service (through secret-tool) on Linux and the Credential Manager on Windows.
+package keyring
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when no secret is stored for the service and account
+	ErrNotFound = errors.New("no secret stored in the keyring")
+	// ErrUnsupported is returned when the platform has no keyring spldl can use
+	ErrUnsupported = errors.New("no keyring available on this system")
+)
+
+// Get returns the secret stored for the service and account
+func Get(service, account string) (string, error) {
+	return get(service, account)
+}
+
+// Set stores a secret for the service and account, replacing the one stored before
+func Set(service, account, secret string) error {
+	return set(service, account, secret)
+}
+
+// Delete removes the secret stored for the service and account
+func Delete(service, account string) error {
+	return remove(service, account)
+}
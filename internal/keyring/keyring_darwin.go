@@ -0,0 +1,57 @@
+package keyring
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// security exits with this status when the item doesn't exist
+const errSecItemNotFound = 44
+
+func get(service, account string) (string, error) {
+	out, err := security(nil, "find-generic-password", "-s", service, "-a", account, "-w")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func set(service, account, secret string) error {
+	// Commands read from stdin with -i keep the secret out of the process list
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", quote(service), quote(account), hex.EncodeToString([]byte(secret)))
+	_, err := security(strings.NewReader(command), "-i")
+	return err
+}
+
+func remove(service, account string) error {
+	_, err := security(nil, "delete-generic-password", "-s", service, "-a", account)
+	return err
+}
+
+func security(stdin *strings.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("/usr/bin/security", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound:
+		return nil, ErrNotFound
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, ErrUnsupported
+	case errors.As(err, &exitErr):
+		return nil, fmt.Errorf("security %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	case err != nil:
+		return nil, err
+	}
+	return out, nil
+}
+
+// quote quotes an argument for the command line security -i reads
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
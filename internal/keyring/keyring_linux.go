@@ -0,0 +1,57 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Secrets are kept in the Secret Service (GNOME Keyring, KWallet) through libsecret's secret-tool
+
+func get(service, account string) (string, error) {
+	out, err := secretTool(nil, "lookup", "service", service, "account", account)
+	if err != nil {
+		return "", err
+	}
+	// secret-tool exits successfully without output for some missing items
+	if len(out) == 0 {
+		return "", ErrNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func set(service, account, secret string) error {
+	// store reads the secret from stdin, keeping it out of the process list
+	_, err := secretTool(strings.NewReader(secret), "store", "--label", service+" ("+account+")", "service", service, "account", account)
+	return err
+}
+
+func remove(service, account string) error {
+	if _, err := get(service, account); err != nil {
+		return err
+	}
+	_, err := secretTool(nil, "clear", "service", service, "account", account)
+	return err
+}
+
+func secretTool(stdin *strings.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("secret-tool", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, ErrUnsupported
+	case errors.As(err, &exitErr) && args[0] == "lookup" && len(exitErr.Stderr) == 0:
+		// A lookup without a match fails without saying why
+		return nil, ErrNotFound
+	case errors.As(err, &exitErr):
+		return nil, fmt.Errorf("secret-tool %s failed: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	case err != nil:
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !linux && !windows
+
+package keyring
+
+func get(service, account string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func set(service, account, secret string) error {
+	return ErrUnsupported
+}
+
+func remove(service, account string) error {
+	return ErrUnsupported
+}
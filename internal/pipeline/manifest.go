@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cschmidt0121/spldl/internal/yaml"
+)
+
+// How many searches of a manifest run at once when it doesn't say
+const defaultConcurrency = 4
+
+// Manifest is a list of searches, each downloaded to its own output, that spldl batch runs
+// concurrently. Every search becomes a pipeline with a single sink.
+type Manifest struct {
+	Connection  Connection       `yaml:"connection"`
+	Concurrency int              `yaml:"concurrency"` // how many searches run at once
+	Defaults    ManifestDefaults `yaml:"defaults"`
+	Searches    []ManifestSearch `yaml:"searches"`
+}
+
+// ManifestDefaults are the settings of the searches that don't set them
+type ManifestDefaults struct {
+	Earliest       string `yaml:"earliest"`
+	Latest         string `yaml:"latest"`
+	DeleteWhenDone bool   `yaml:"delete_when_done"`
+	MaxConnections int    `yaml:"max_connections"`
+}
+
+// ManifestSearch is one search of a manifest
+type ManifestSearch struct {
+	Name           string `yaml:"name"` // shown in the summary alongside the output
+	Query          string `yaml:"query"`
+	Earliest       string `yaml:"earliest"`
+	Latest         string `yaml:"latest"`
+	Output         string `yaml:"output"`
+	Format         string `yaml:"format"` // detected from the output when empty
+	DeleteWhenDone bool   `yaml:"delete_when_done"`
+	MaxConnections int    `yaml:"max_connections"`
+}
+
+// LoadManifest reads a manifest and returns it with its searches as pipelines, in order
+func LoadManifest(path string) (*Manifest, []*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, nil, fmt.Errorf("%s: invalid manifest: %w", path, err)
+	}
+	pipelines, err := m.pipelines()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: invalid manifest: %w", path, err)
+	}
+	m.Concurrency = cmp.Or(m.Concurrency, defaultConcurrency)
+	return &m, pipelines, nil
+}
+
+func (m *Manifest) pipelines() ([]*Pipeline, error) {
+	if len(m.Searches) == 0 {
+		return nil, errors.New("searches must list at least one search")
+	}
+	if m.Concurrency < 0 {
+		return nil, errors.New("concurrency can't be negative")
+	}
+	outputs := make(map[string]bool)
+	var pipelines []*Pipeline
+	for i, search := range m.Searches {
+		if search.Query == "" || search.Output == "" {
+			return nil, fmt.Errorf("search %d: query and output are required", i+1)
+		}
+		// Searches writing to the same file, or stdout, would mix up each other's results
+		if outputs[search.Output] {
+			return nil, fmt.Errorf("search %d: output %s is written by an earlier search", i+1, search.Output)
+		}
+		if search.Output == "-" && len(m.Searches) > 1 {
+			return nil, fmt.Errorf("search %d: only a manifest with a single search may write to stdout (-)", i+1)
+		}
+		outputs[search.Output] = true
+		p := &Pipeline{
+			Name:       search.Name,
+			Connection: m.Connection,
+			Search: Search{
+				Query:          search.Query,
+				Earliest:       cmp.Or(search.Earliest, m.Defaults.Earliest, "-24h"),
+				Latest:         cmp.Or(search.Latest, m.Defaults.Latest, "now"),
+				DeleteWhenDone: search.DeleteWhenDone || m.Defaults.DeleteWhenDone,
+				MaxConnections: cmp.Or(search.MaxConnections, m.Defaults.MaxConnections, 8),
+			},
+			Steps: []Step{{Type: StepSink, Path: search.Output, Format: search.Format}},
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("search %d: %w", i+1, err)
+		}
+		pipelines = append(pipelines, p)
+	}
+	return pipelines, nil
+}
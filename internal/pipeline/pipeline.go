@@ -0,0 +1,221 @@
+// Package pipeline loads export pipelines: a search followed by the steps applied to its results,
+// defined in a YAML file that can be reviewed and kept under version control. Manifests list many
+// searches in one file, each becoming a pipeline.
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cschmidt0121/spldl/internal/yaml"
+)
+
+// Step types, in the order they may appear in a pipeline
+const (
+	StepTransform = "transform" // appends SPL to the search
+	StepRedact    = "redact"    // masks or drops fields of the results
+	StepDedupe    = "dedupe"    // skips events exported by previous runs
+	StepSplit     = "split"     // splits the output into one file per time bucket
+	StepVerify    = "verify"    // recounts the results and writes a verification record
+	StepSink      = "sink"      // writes the results to a file
+)
+
+var stepOrder = map[string]int{
+	StepTransform: 0,
+	StepRedact:    1,
+	StepDedupe:    2,
+	StepSplit:     3,
+	StepVerify:    4,
+	StepSink:      5,
+}
+
+type Pipeline struct {
+	Name       string     `yaml:"name"`
+	Connection Connection `yaml:"connection"`
+	Search     Search     `yaml:"search"`
+	Steps      []Step     `yaml:"steps"`
+
+	Checksum string `yaml:"-"` // SHA-256 of the file the pipeline was loaded from, set by Load
+}
+
+// Connection holds the non-secret connection settings. Credentials come from flags, the environment
+// or the profile.
+type Connection struct {
+	Profile  string `yaml:"profile"` // config file profile providing the settings not set here
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Insecure bool   `yaml:"insecure"`
+	App      string `yaml:"app"`   // app whose context the search runs in
+	Owner    string `yaml:"owner"` // user whose context the search runs in
+}
+
+type Search struct {
+	Query          string `yaml:"query"`
+	SID            string `yaml:"sid"`    // download an existing job instead of running Query
+	JobID          string `yaml:"job_id"` // dispatch Query with this search ID, reusing the job if it exists
+	Label          string `yaml:"label"`  // prefix for the search ID of the dispatched job
+	Earliest       string `yaml:"earliest"`
+	Latest         string `yaml:"latest"`
+	DeleteWhenDone bool   `yaml:"delete_when_done"`
+	MaxConnections int    `yaml:"max_connections"`
+
+	FailOnJobErrors bool `yaml:"fail_on_job_errors"` // fail when Splunk reports an error for the job
+	AllowPartial    bool `yaml:"allow_partial"`      // warn instead of failing when rows are missing from the output
+}
+
+// Step is one stage of a pipeline. Only the fields of its type are used.
+type Step struct {
+	Type   string   `yaml:"type"`
+	SPL    string   `yaml:"spl"`    // transform
+	Mask   []string `yaml:"mask"`   // redact, fields whose values are replaced with [REDACTED]
+	Drop   []string `yaml:"drop"`   // redact, fields removed from the results
+	State  string   `yaml:"state"`  // dedupe
+	Window string   `yaml:"window"` // dedupe, e.g. 7d
+	Bucket string   `yaml:"bucket"` // split, e.g. 1h
+	Path   string   `yaml:"path"`   // sink
+	Format string   `yaml:"format"` // sink, detected from the path when empty
+}
+
+// Load reads and validates a pipeline file
+func Load(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	p.Checksum = hex.EncodeToString(sum[:])
+	return p, nil
+}
+
+// Parse parses and validates a pipeline definition
+func Parse(data []byte) (*Pipeline, error) {
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid pipeline: %w", err)
+	}
+
+	if p.Search.Earliest == "" {
+		p.Search.Earliest = "-24h"
+	}
+	if p.Search.Latest == "" {
+		p.Search.Latest = "now"
+	}
+	if p.Search.MaxConnections == 0 {
+		p.Search.MaxConnections = 8
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid pipeline: %w", err)
+	}
+	return &p, nil
+}
+
+func (p *Pipeline) validate() error {
+	if (p.Search.Query == "") == (p.Search.SID == "") {
+		return errors.New("search must set exactly one of query and sid")
+	}
+	if p.Search.Label != "" && (p.Search.JobID != "" || p.Search.SID != "") {
+		return errors.New("search label can't be combined with job_id or sid")
+	}
+
+	seen := map[string]bool{}
+	last := 0
+	for i, step := range p.Steps {
+		order, ok := stepOrder[step.Type]
+		if !ok {
+			return fmt.Errorf("step %d: unknown type %q", i+1, step.Type)
+		}
+		if order < last {
+			return fmt.Errorf("step %d: %s must come before %s steps", i+1, step.Type, p.Steps[i-1].Type)
+		}
+		last = order
+		if seen[step.Type] && step.Type != StepTransform && step.Type != StepSink {
+			return fmt.Errorf("step %d: only one %s step is allowed", i+1, step.Type)
+		}
+		seen[step.Type] = true
+
+		switch step.Type {
+		case StepTransform:
+			if step.SPL == "" {
+				return fmt.Errorf("step %d: transform requires spl", i+1)
+			}
+			if p.Search.SID != "" {
+				return fmt.Errorf("step %d: transform cannot be applied to an existing sid", i+1)
+			}
+		case StepRedact:
+			if len(step.Mask) == 0 && len(step.Drop) == 0 {
+				return fmt.Errorf("step %d: redact requires mask or drop", i+1)
+			}
+		case StepDedupe:
+			if step.State == "" {
+				return fmt.Errorf("step %d: dedupe requires state", i+1)
+			}
+		case StepSplit:
+			if step.Bucket == "" {
+				return fmt.Errorf("step %d: split requires bucket", i+1)
+			}
+		case StepSink:
+			if step.Path == "" {
+				return fmt.Errorf("step %d: sink requires path", i+1)
+			}
+		}
+	}
+
+	sinks := p.Sinks()
+	if len(sinks) == 0 {
+		return errors.New("at least one sink step is required")
+	}
+	for _, sink := range sinks {
+		if sink.Path == "-" && len(sinks) > 1 {
+			return errors.New("a sink writing to stdout (-) must be the only sink")
+		}
+		// Further sinks are converted from the first one's file
+		if strings.Contains(sink.Path, "://") && len(sinks) > 1 {
+			return errors.New("a sink uploading to cloud storage must be the only sink")
+		}
+	}
+	if seen[StepSplit] && len(sinks) > 1 {
+		return errors.New("split supports a single sink")
+	}
+	return nil
+}
+
+// Query returns the search with the SPL of every transform step appended
+func (p *Pipeline) Query() string {
+	query := strings.TrimSpace(p.Search.Query)
+	for _, step := range p.Steps {
+		if step.Type == StepTransform {
+			query += " " + strings.TrimSpace(step.SPL)
+		}
+	}
+	return query
+}
+
+// Find returns the step of the given type, for types that appear at most once
+func (p *Pipeline) Find(stepType string) (Step, bool) {
+	for _, step := range p.Steps {
+		if step.Type == stepType {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+// Sinks returns the sink steps in order. The first sink is downloaded to, the others are converted from it.
+func (p *Pipeline) Sinks() []Step {
+	var sinks []Step
+	for _, step := range p.Steps {
+		if step.Type == StepSink {
+			sinks = append(sinks, step)
+		}
+	}
+	return sinks
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	p, err := Load("testdata/pipeline.yaml")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	expected := &Pipeline{
+		Name:       "auth-failures",
+		Connection: Connection{Host: "splunk.example.com", Port: 8089, Insecure: true},
+		Search: Search{
+			Query:          "index=auth action=failure | table _time user src_ip _raw",
+			Earliest:       "-1d@d",
+			Latest:         "@d",
+			DeleteWhenDone: true,
+			MaxConnections: 8,
+		},
+		Steps: []Step{
+			{Type: StepTransform, SPL: `| where user!="svc_health"`},
+			{Type: StepRedact, Mask: []string{"user"}, Drop: []string{"src_ip"}},
+			{Type: StepDedupe, State: "auth.dedupe", Window: "7d"},
+			{Type: StepVerify},
+			{Type: StepSink, Path: "auth.ndjson"},
+			{Type: StepSink, Path: "auth.csv"},
+		},
+		Checksum: fileChecksum(t, "testdata/pipeline.yaml"),
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+
+	if query := p.Query(); query != `index=auth action=failure | table _time user src_ip _raw | where user!="svc_health"` {
+		t.Errorf("Unexpected query %q", query)
+	}
+	if sinks := p.Sinks(); len(sinks) != 2 || sinks[0].Path != "auth.ndjson" {
+		t.Errorf("Unexpected sinks %+v", sinks)
+	}
+	if _, ok := p.Find(StepSplit); ok {
+		t.Error("Expected no split step")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		pipeline      string
+		expectedError string
+	}{
+		{
+			name:          "no sink",
+			pipeline:      "search:\n  query: index=main\n",
+			expectedError: "at least one sink",
+		},
+		{
+			name:          "query and sid",
+			pipeline:      "search:\n  query: index=main\n  sid: 123.4\nsteps:\n  - type: sink\n    path: a.csv\n",
+			expectedError: "exactly one of query and sid",
+		},
+		{
+			name:          "unknown field",
+			pipeline:      "search:\n  query: index=main\n  qeury: x\nsteps:\n  - type: sink\n    path: a.csv\n",
+			expectedError: "field qeury not found",
+		},
+		{
+			name:          "unknown step",
+			pipeline:      "search:\n  query: index=main\nsteps:\n  - type: email\n",
+			expectedError: `unknown type "email"`,
+		},
+		{
+			name:          "step out of order",
+			pipeline:      "search:\n  query: index=main\nsteps:\n  - type: sink\n    path: a.csv\n  - type: verify\n",
+			expectedError: "verify must come before sink",
+		},
+		{
+			name:          "redact without fields",
+			pipeline:      "search:\n  query: index=main\nsteps:\n  - type: redact\n  - type: sink\n    path: a.csv\n",
+			expectedError: "redact requires mask or drop",
+		},
+		{
+			name:          "redact after dedupe",
+			pipeline:      "search:\n  query: index=main\nsteps:\n  - type: dedupe\n    state: a.dedupe\n  - type: redact\n    drop: [password]\n  - type: sink\n    path: a.csv\n",
+			expectedError: "redact must come before dedupe",
+		},
+		{
+			name:          "split with two sinks",
+			pipeline:      "search:\n  query: index=main\nsteps:\n  - type: split\n    bucket: 1h\n  - type: sink\n    path: a.csv\n  - type: sink\n    path: a.ndjson\n",
+			expectedError: "split supports a single sink",
+		},
+		{
+			name:          "stdout with another sink",
+			pipeline:      "search:\n  query: index=main\nsteps:\n  - type: sink\n    path: \"-\"\n  - type: sink\n    path: a.csv\n",
+			expectedError: "must be the only sink",
+		},
+		{
+			name:          "bad indentation",
+			pipeline:      "search:\n  query: index=main\n    sid: 1\n",
+			expectedError: "line 3",
+		},
+		{
+			name:          "tab indentation",
+			pipeline:      "search:\n\tquery: index=main\n",
+			expectedError: "line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.pipeline))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	m, pipelines, err := LoadManifest("testdata/manifest.yaml")
+	if err != nil {
+		t.Fatalf("LoadManifest returned error: %v", err)
+	}
+	if m.Concurrency != 2 {
+		t.Errorf("Expected a concurrency of 2, got %d", m.Concurrency)
+	}
+	expected := []*Pipeline{
+		{
+			Name:       "failed-logins",
+			Connection: Connection{Host: "splunk.example.com"},
+			Search:     Search{Query: "index=auth action=failure | table _time user src_ip", Earliest: "-1d@d", Latest: "@d", DeleteWhenDone: true, MaxConnections: 8},
+			Steps:      []Step{{Type: StepSink, Path: "failed_logins.csv"}},
+		},
+		{
+			Connection: Connection{Host: "splunk.example.com"},
+			Search:     Search{Query: "index=firewall action=blocked", Earliest: "-7d@d", Latest: "@d", DeleteWhenDone: true, MaxConnections: 2},
+			Steps:      []Step{{Type: StepSink, Path: "blocked.ndjson"}},
+		},
+	}
+	if !reflect.DeepEqual(pipelines, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, pipelines)
+	}
+
+	invalid := map[string]string{
+		"no searches":      "connection:\n  host: splunk\n",
+		"missing output":   "searches:\n  - query: index=main\n",
+		"duplicate output": "searches:\n  - query: index=main\n    output: a.csv\n  - query: index=web\n    output: a.csv\n",
+		"stdout and more":  "searches:\n  - query: index=main\n    output: \"-\"\n  - query: index=web\n    output: b.csv\n",
+	}
+	for name, data := range invalid {
+		path := t.TempDir() + "/manifest.yaml"
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := LoadManifest(path); err == nil {
+			t.Errorf("%s: expected LoadManifest to fail", name)
+		}
+	}
+}
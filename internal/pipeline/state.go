@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// BatchState records the progress of a batch of pipelines in a file, so that running the batch again
+// after a crash skips the pipelines that completed and picks up the jobs of the others. Its methods
+// do nothing on a nil BatchState.
+type BatchState struct {
+	path    string
+	Entries map[string]BatchEntry `json:"entries"` // by the path of the pipeline file
+}
+
+// BatchEntry is the progress of one pipeline of a batch
+type BatchEntry struct {
+	Checksum  string    `json:"checksum"` // of the pipeline file, a pipeline that changed since starts over
+	SID       string    `json:"sid,omitempty"`
+	Done      bool      `json:"done"`
+	Completed time.Time `json:"completed,omitzero"`
+	Updated   time.Time `json:"updated"` // when the pipeline last started or completed, for pruning
+}
+
+// LoadBatchState reads the batch state at path, or returns an empty state when there is none yet
+func LoadBatchState(path string) (*BatchState, error) {
+	state := &BatchState{path: path, Entries: make(map[string]BatchEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid batch state %s: %w", path, err)
+	}
+	if state.Entries == nil {
+		state.Entries = make(map[string]BatchEntry)
+	}
+	return state, nil
+}
+
+// Entry returns the recorded progress of the pipeline loaded from path, unless it changed since
+func (s *BatchState) Entry(path string, p *Pipeline) (BatchEntry, bool) {
+	if s == nil {
+		return BatchEntry{}, false
+	}
+	entry, ok := s.Entries[path]
+	if !ok || entry.Checksum != p.Checksum {
+		return BatchEntry{}, false
+	}
+	return entry, true
+}
+
+// Prune drops the entries last updated before the given time and saves the state when it dropped
+// any, so that a state file reused for other batches doesn't keep growing. Entries of pipelines that
+// aren't part of the current run are kept, so running a crashed batch again with only some of its
+// pipelines doesn't lose the progress of the others. It returns how many entries were dropped.
+func (s *BatchState) Prune(before time.Time) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	pruned := 0
+	for path, entry := range s.Entries {
+		if entry.Updated.Before(before) {
+			delete(s.Entries, path)
+			pruned++
+		}
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+	return pruned, s.save()
+}
+
+// Started records the job the pipeline loaded from path is running
+func (s *BatchState) Started(path string, p *Pipeline, sid string) error {
+	if s == nil {
+		return nil
+	}
+	s.Entries[path] = BatchEntry{Checksum: p.Checksum, SID: sid, Updated: time.Now().UTC()}
+	return s.save()
+}
+
+// Completed records that the pipeline loaded from path completed
+func (s *BatchState) Completed(path string, p *Pipeline, sid string) error {
+	if s == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	s.Entries[path] = BatchEntry{Checksum: p.Checksum, SID: sid, Done: true, Completed: now, Updated: now}
+	return s.save()
+}
+
+// Remove deletes the state file once the batch is complete, so that the next run starts afresh
+func (s *BatchState) Remove() error {
+	if s == nil {
+		return nil
+	}
+	err := os.Remove(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *BatchState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file first, so that a crash mid-write leaves the previous state intact
+	if err := os.WriteFile(s.path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fileChecksum(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBatchState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.json")
+	first := &Pipeline{Checksum: "aaa"}
+	second := &Pipeline{Checksum: "bbb"}
+
+	state, err := LoadBatchState(path)
+	if err != nil {
+		t.Fatalf("LoadBatchState returned error: %v", err)
+	}
+	if err := state.Completed("first.yaml", first, "1756172871.1180"); err != nil {
+		t.Fatalf("Completed returned error: %v", err)
+	}
+	if err := state.Started("second.yaml", second, "1756172871.1181"); err != nil {
+		t.Fatalf("Started returned error: %v", err)
+	}
+
+	// A later run sees the progress of the crashed one
+	state, err = LoadBatchState(path)
+	if err != nil {
+		t.Fatalf("LoadBatchState returned error: %v", err)
+	}
+	if entry, ok := state.Entry("first.yaml", first); !ok || !entry.Done || entry.SID != "1756172871.1180" {
+		t.Errorf("Expected first.yaml to be done, got %+v", entry)
+	}
+	if entry, ok := state.Entry("second.yaml", second); !ok || entry.Done || entry.SID != "1756172871.1181" {
+		t.Errorf("Expected second.yaml to be in flight, got %+v", entry)
+	}
+
+	// A pipeline edited since starts over
+	if _, ok := state.Entry("second.yaml", &Pipeline{Checksum: "ccc"}); ok {
+		t.Error("Expected no entry for a changed pipeline")
+	}
+	if _, ok := state.Entry("third.yaml", first); ok {
+		t.Error("Expected no entry for an unknown pipeline")
+	}
+
+	// Only entries older than the cutoff are forgotten, whichever pipelines the run has
+	entry := state.Entries["first.yaml"]
+	entry.Updated = time.Now().Add(-30 * 24 * time.Hour)
+	state.Entries["first.yaml"] = entry
+	pruned, err := state.Prune(time.Now().Add(-7 * 24 * time.Hour))
+	if err != nil || pruned != 1 {
+		t.Fatalf("Expected Prune to drop one entry, got %d, %v", pruned, err)
+	}
+	state, err = LoadBatchState(path)
+	if err != nil {
+		t.Fatalf("LoadBatchState returned error: %v", err)
+	}
+	if _, ok := state.Entry("first.yaml", first); ok {
+		t.Error("Expected first.yaml to be pruned")
+	}
+	if _, ok := state.Entry("second.yaml", second); !ok {
+		t.Error("Expected second.yaml to be kept")
+	}
+
+	if err := state.Remove(); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the state file to be removed, got %v", err)
+	}
+
+	// Without --state, the batch state is nil and records nothing
+	var none *BatchState
+	if err := none.Started("first.yaml", first, "1"); err != nil {
+		t.Errorf("Started on a nil state returned error: %v", err)
+	}
+	if _, ok := none.Entry("first.yaml", first); ok {
+		t.Error("Expected no entry in a nil state")
+	}
+}
@@ -0,0 +1,60 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a callback's body, hex encoded and prefixed with "sha256="
+const SignatureHeader = "X-Spldl-Signature"
+
+// Callback posts the final status of a run to a URL, so orchestrators can trigger the next step
+// without parsing logs
+type Callback struct {
+	URL    string
+	Key    []byte       // signs the body when set
+	Client *http.Client // http.DefaultClient when nil
+}
+
+// Send posts status as JSON and fails unless the receiver answers with a 2xx status
+func (c *Callback) Send(ctx context.Context, status Status) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(c.Key) > 0 {
+		req.Header.Set(SignatureHeader, Sign(body, c.Key))
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the value of SignatureHeader for body, for receivers to compare with hmac.Equal
+func Sign(body, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCallback(t *testing.T) {
+	key := []byte("secret")
+	var received Status
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(body, key) {
+			t.Errorf("Unexpected signature %q", r.Header.Get(SignatureHeader))
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Failed to decode callback: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	h := NewHeartbeat(time.Minute)
+	h.OnFinish(func(status Status) {
+		callback := &Callback{URL: server.URL, Key: key}
+		if err := callback.Send(context.Background(), status); err != nil {
+			t.Errorf("Send returned error: %v", err)
+		}
+	})
+	h.SetPhase(PhaseDownloading, "123.4")
+	h.Result("results.ndjson", 42, []string{"[limits] export may be truncated"})
+	h.Finish(0, nil)
+
+	if received.Phase != PhaseDone || received.SID != "123.4" || received.Filename != "results.ndjson" || received.RowsWritten != 42 || len(received.Warnings) != 1 {
+		t.Errorf("Unexpected callback %+v", received)
+	}
+	if received.ExitCode == nil || *received.ExitCode != 0 {
+		t.Errorf("Expected exit code 0, got %v", received.ExitCode)
+	}
+}
+
+func TestCallbackRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	callback := &Callback{URL: server.URL}
+	if err := callback.Send(context.Background(), Status{Phase: PhaseFailed}); err == nil {
+		t.Error("Expected an error for a rejected callback")
+	}
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SearchCost describes the load a search put on the cluster, so capacity teams can quantify exports
+type SearchCost struct {
+	SID         string
+	ScanCount   int // events read from the indexes
+	EventCount  int // events that matched the search
+	ResultCount int // results the search produced
+	RunDuration time.Duration
+	DiskUsage   int64    // bytes of the job's artifacts on the search head
+	Indexes     []string // empty when Splunk kept no summary for the job
+}
+
+// Add accumulates the cost of another job, such as one window of a split search
+func (c *SearchCost) Add(other SearchCost) {
+	if c.SID == "" {
+		c.SID = other.SID
+	}
+	c.ScanCount += other.ScanCount
+	c.EventCount += other.EventCount
+	c.ResultCount += other.ResultCount
+	c.RunDuration += other.RunDuration
+	c.DiskUsage += other.DiskUsage
+	for _, index := range other.Indexes {
+		if !slices.Contains(c.Indexes, index) {
+			c.Indexes = append(c.Indexes, index)
+		}
+	}
+}
+
+// ScanEfficiency returns the share of scanned events that ended up as results
+func (c SearchCost) ScanEfficiency() float64 {
+	if c.ScanCount == 0 {
+		return 0
+	}
+	return float64(c.ResultCount) / float64(c.ScanCount)
+}
+
+func (c SearchCost) String() string {
+	indexes := "unknown"
+	if len(c.Indexes) > 0 {
+		indexes = strings.Join(c.Indexes, ",")
+	}
+	return fmt.Sprintf("sid=%s scanned=%d matched=%d results=%d efficiency=%.1f%% run_duration=%s disk_usage=%dB indexes=%s",
+		c.SID, c.ScanCount, c.EventCount, c.ResultCount, c.ScanEfficiency()*100, c.RunDuration, c.DiskUsage, indexes)
+}
@@ -0,0 +1,226 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Phases of a run reported by Heartbeat
+const (
+	PhaseStarting    = "starting"
+	PhaseSearching   = "searching"
+	PhaseDownloading = "downloading"
+	PhaseDone        = "done"
+	PhaseFailed      = "failed"
+)
+
+// Status is a snapshot of a run's progress as reported to orchestrators
+type Status struct {
+	Phase          string    `json:"phase"`
+	SID            string    `json:"sid,omitempty"`
+	ChunksDone     int       `json:"chunks_done"`
+	ChunksTotal    int       `json:"chunks_total"`
+	WireBytes      int64     `json:"wire_bytes"`     // received over the network, compressed
+	BytesReceived  int64     `json:"bytes_received"` // received after decompression
+	Filename       string    `json:"filename,omitempty"`
+	RowsWritten    int       `json:"rows_written"`
+	Warnings       []string  `json:"warnings,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	ExitCode       *int      `json:"exit_code,omitempty"` // set once the run has finished
+	StartedAt      time.Time `json:"started_at"`
+	LastProgressAt time.Time `json:"last_progress_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Heartbeat tracks the progress of a run and reports it through a periodically rewritten file and
+// HTTP health endpoints. A nil *Heartbeat is valid and reports nothing.
+type Heartbeat struct {
+	mu           sync.Mutex
+	status       Status
+	file         string
+	stallTimeout time.Duration
+	onFinish     func(Status)
+	stop         chan struct{}
+	stopped      sync.WaitGroup
+}
+
+// NewHeartbeat creates a heartbeat that reports the run as stalled when a download makes no
+// progress for stallTimeout
+func NewHeartbeat(stallTimeout time.Duration) *Heartbeat {
+	now := time.Now()
+	return &Heartbeat{
+		status:       Status{Phase: PhaseStarting, StartedAt: now, LastProgressAt: now},
+		stallTimeout: stallTimeout,
+		stop:         make(chan struct{}),
+	}
+}
+
+// WriteEvery rewrites file with the current status every interval until the heartbeat finishes
+func (h *Heartbeat) WriteEvery(file string, interval time.Duration) error {
+	h.file = file
+	if err := h.writeFile(); err != nil {
+		return err
+	}
+
+	h.stopped.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				if err := h.writeFile(); err != nil {
+					slog.Debug("Failed to write heartbeat file", "file", file, "error", err)
+				}
+			}
+		}
+	})
+	return nil
+}
+
+func (h *Heartbeat) SetPhase(phase, sid string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Phase = phase
+	if sid != "" {
+		h.status.SID = sid
+	}
+	h.status.LastProgressAt = time.Now()
+}
+
+// Progress records that done of total chunks have been written
+func (h *Heartbeat) Progress(done, total int) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.ChunksDone = done
+	h.status.ChunksTotal = total
+	h.status.LastProgressAt = time.Now()
+}
+
+// Transfer records how much data has been received, over the network and after decompression
+func (h *Heartbeat) Transfer(wireBytes, bytesReceived int64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.WireBytes = wireBytes
+	h.status.BytesReceived = bytesReceived
+}
+
+// Result records what the download wrote, once it has stopped
+func (h *Heartbeat) Result(filename string, rows int, warnings []string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Filename = filename
+	h.status.RowsWritten = rows
+	h.status.Warnings = warnings
+}
+
+// OnFinish sets a function called with the final status once the run has finished
+func (h *Heartbeat) OnFinish(f func(Status)) {
+	h.onFinish = f
+}
+
+// Finish records the outcome of the run, stops the periodic writes and writes the final status
+func (h *Heartbeat) Finish(exitCode int, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	if h.status.ExitCode != nil {
+		h.mu.Unlock()
+		return
+	}
+	h.status.Phase = PhaseDone
+	if err != nil {
+		h.status.Phase = PhaseFailed
+		h.status.Error = err.Error()
+	}
+	h.status.ExitCode = &exitCode
+	h.mu.Unlock()
+
+	close(h.stop)
+	h.stopped.Wait()
+	if h.file != "" {
+		if err := h.writeFile(); err != nil {
+			slog.Debug("Failed to write heartbeat file", "file", h.file, "error", err)
+		}
+	}
+	if h.onFinish != nil {
+		h.onFinish(h.Status())
+	}
+}
+
+// Status returns the current status
+func (h *Heartbeat) Status() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := h.status
+	status.UpdatedAt = time.Now()
+	return status
+}
+
+// stalled reports whether a download has made no progress for longer than the stall timeout.
+// Searches are not considered stalled since Splunk doesn't report their progress in chunks.
+func (h *Heartbeat) stalled(status Status) bool {
+	return h.stallTimeout > 0 && status.Phase == PhaseDownloading && status.UpdatedAt.Sub(status.LastProgressAt) > h.stallTimeout
+}
+
+// Handler serves /healthz, which fails once the run has stalled or failed, and /status with the current status
+func (h *Heartbeat) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := h.Status()
+		switch {
+		case status.Phase == PhaseFailed:
+			http.Error(w, "failed: "+status.Error, http.StatusServiceUnavailable)
+		case h.stalled(status):
+			http.Error(w, fmt.Sprintf("stalled: no progress since %s", status.LastProgressAt.Format(time.RFC3339)), http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintln(w, "ok")
+		}
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.Status())
+	})
+	return mux
+}
+
+// writeFile replaces the heartbeat file atomically so readers never see a partial status
+func (h *Heartbeat) writeFile() error {
+	data, err := json.Marshal(h.Status())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.file), filepath.Base(h.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), h.file)
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "heartbeat.json")
+
+	h := NewHeartbeat(time.Minute)
+	if err := h.WriteEvery(file, time.Hour); err != nil {
+		t.Fatalf("WriteEvery returned error: %v", err)
+	}
+	status := readStatus(t, file)
+	if status.Phase != PhaseStarting || status.ExitCode != nil {
+		t.Errorf("Unexpected initial status %+v", status)
+	}
+
+	h.SetPhase(PhaseDownloading, "123.4")
+	h.Progress(3, 5)
+	h.Finish(5, errors.New("disk full"))
+
+	status = readStatus(t, file)
+	if status.Phase != PhaseFailed || status.SID != "123.4" || status.ChunksDone != 3 || status.ChunksTotal != 5 || status.Error != "disk full" {
+		t.Errorf("Unexpected final status %+v", status)
+	}
+	if status.ExitCode == nil || *status.ExitCode != 5 {
+		t.Errorf("Expected exit code 5, got %v", status.ExitCode)
+	}
+
+	// Finishing again keeps the first outcome
+	h.Finish(0, nil)
+	if status := h.Status(); status.Phase != PhaseFailed {
+		t.Errorf("Expected phase to remain failed, got %s", status.Phase)
+	}
+}
+
+func TestHeartbeatHealthz(t *testing.T) {
+	h := NewHeartbeat(time.Millisecond)
+	server := httptest.NewServer(h.Handler())
+	defer server.Close()
+
+	healthz := func() int {
+		resp, err := http.Get(server.URL + "/healthz")
+		if err != nil {
+			t.Fatalf("GET /healthz failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Waiting for a search is never considered stalled
+	h.SetPhase(PhaseSearching, "123.4")
+	time.Sleep(5 * time.Millisecond)
+	if code := healthz(); code != http.StatusOK {
+		t.Errorf("Expected 200 while searching, got %d", code)
+	}
+
+	h.SetPhase(PhaseDownloading, "")
+	time.Sleep(5 * time.Millisecond)
+	if code := healthz(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a stalled download, got %d", code)
+	}
+
+	resp, err := http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatalf("GET /status failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if status.Phase != PhaseDownloading || status.SID != "123.4" {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func readStatus(t *testing.T, file string) Status {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read heartbeat file: %v", err)
+	}
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("Failed to decode heartbeat file: %v", err)
+	}
+	return status
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"os"
+	"time"
+)
+
+var exportReportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(part, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(part) / float64(total) * 100
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>spldl export report{{with .Cost.SID}} - {{.}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+td.num { text-align: right; }
+pre, code { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+pre { background: #f4f4f4; padding: 8px; white-space: pre-wrap; word-break: break-all; }
+.warning { color: #8a5300; }
+</style>
+</head>
+<body>
+<h1>spldl export report</h1>
+<p>Generated {{.GeneratedAt.UTC.Format "2006-01-02 15:04:05 UTC"}}</p>
+
+<h2>Search</h2>
+<table>
+<tr><th>SID</th><td><code>{{.Cost.SID}}</code></td></tr>
+<tr><th>Query</th><td>{{if .Query}}<pre>{{.Query}}</pre>{{else}}existing job{{end}}</td></tr>
+<tr><th>Time range</th><td>{{if or .Earliest .Latest}}<code>{{.Earliest}}</code> to <code>{{.Latest}}</code>{{else}}as dispatched{{end}}</td></tr>
+</table>
+
+<h2>Counts</h2>
+<table>
+<tr><th>Events scanned</th><td class="num">{{.Cost.ScanCount}}</td></tr>
+<tr><th>Events matched</th><td class="num">{{.Cost.EventCount}}</td></tr>
+<tr><th>Results</th><td class="num">{{.Cost.ResultCount}}</td></tr>
+<tr><th>Rows written</th><td class="num">{{.RowsWritten}}</td></tr>
+<tr><th>Run duration</th><td class="num">{{.Cost.RunDuration}}</td></tr>
+<tr><th>Indexes</th><td>{{range $i, $index := .Cost.Indexes}}{{if $i}}, {{end}}<code>{{$index}}</code>{{else}}unknown{{end}}</td></tr>
+</table>
+
+{{if .Fields}}
+<h2>Top fields</h2>
+<table>
+<tr><th>Field</th><th>Coverage</th><th>Distinct values</th><th>Most common values</th></tr>
+{{range .Fields}}<tr><td><code>{{.Name}}</code></td><td class="num">{{printf "%.1f" (percent .Count $.Cost.ResultCount)}}%</td><td class="num">{{.DistinctCount}}</td><td>{{range $i, $value := .TopValues}}{{if $i}}, {{end}}<code>{{$value}}</code>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Files</h2>
+{{if .Files}}<table>
+<tr><th>File</th><th>Size (bytes)</th><th>SHA-256</th></tr>
+{{range .Files}}<tr><td><code>{{.Path}}</code></td><td class="num">{{.Size}}</td><td><code>{{.SHA256}}</code></td></tr>
+{{end}}</table>
+{{else}}<p>The results were written to standard output.</p>{{end}}
+
+{{if .Warnings}}
+<h2>Warnings</h2>
+<ul>
+{{range .Warnings}}<li class="warning">{{.}}</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
+
+// ExportReport is the evidence of an export, what was searched and what was written, rendered as a
+// self-contained HTML page that can be attached to a ticket
+type ExportReport struct {
+	GeneratedAt time.Time
+	Query       string // empty when an existing job was downloaded
+	Earliest    string
+	Latest      string
+	Cost        SearchCost
+	RowsWritten int
+	Fields      []FieldStat
+	Files       []FileChecksum
+	Warnings    []string
+}
+
+// FieldStat describes one of the most common fields of the results
+type FieldStat struct {
+	Name          string
+	Count         int // results with the field
+	DistinctCount int
+	TopValues     []string
+}
+
+// FileChecksum identifies a file written by the export
+type FileChecksum struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// ChecksumFile returns the size and SHA-256 of a file
+func ChecksumFile(path string) (FileChecksum, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileChecksum{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return FileChecksum{}, err
+	}
+	return FileChecksum{Path: path, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// WriteHTML renders the report as a single HTML page without external resources
+func (r ExportReport) WriteHTML(w io.Writer) error {
+	return exportReportHTML.Execute(w, r)
+}
+
+// WriteHTMLFile writes the report to path
+func (r ExportReport) WriteHTMLFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteHTML(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,59 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportReportHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	if err := os.WriteFile(path, []byte("host\nweb01\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := ChecksumFile(path)
+	if err != nil {
+		t.Fatalf("ChecksumFile returned error: %v", err)
+	}
+	if checksum.Size != 11 || checksum.SHA256 != "caf6b08d31af65e410738e553fc7dca10b7bdf527d1d872537089be4acf45c8c" {
+		t.Errorf("Unexpected checksum %+v", checksum)
+	}
+
+	r := ExportReport{
+		GeneratedAt: time.Date(2025, 8, 26, 2, 0, 0, 0, time.UTC),
+		Query:       `index=main sourcetype="access_combined" | where status>=500 | table _time host`,
+		Earliest:    "-24h",
+		Latest:      "now",
+		Cost:        SearchCost{SID: "1756172871.1180", ScanCount: 1000, EventCount: 40, ResultCount: 40, Indexes: []string{"main"}},
+		RowsWritten: 40,
+		Fields:      []FieldStat{{Name: "host", Count: 30, DistinctCount: 2, TopValues: []string{"web01", "<web02>"}}},
+		Files:       []FileChecksum{checksum},
+		Warnings:    []string{"[splunk] WARN: The lookup table 'user_info' does not exist"},
+	}
+	var sb strings.Builder
+	if err := r.WriteHTML(&sb); err != nil {
+		t.Fatalf("WriteHTML returned error: %v", err)
+	}
+	html := sb.String()
+
+	for _, expected := range []string{
+		"Generated 2025-08-26 02:00:00 UTC",
+		`index=main sourcetype=&#34;access_combined&#34; | where status&gt;=500 | table _time host`,
+		"<code>-24h</code> to <code>now</code>",
+		`<td class="num">40</td>`,
+		"<code>main</code>",
+		`<td class="num">75.0%</td>`,
+		"<code>&lt;web02&gt;</code>",
+		checksum.SHA256,
+		"user_info",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected the report to contain %q", expected)
+		}
+	}
+	if strings.Contains(html, "<link") || strings.Contains(html, "<script") {
+		t.Error("Expected the report to be self-contained")
+	}
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Warning is a non-fatal condition encountered during a run
+type Warning struct {
+	Source  string // the part of spldl that raised the warning, e.g. "chunk" or "limits"
+	Message string
+}
+
+// Warnings collects non-fatal conditions from concurrent goroutines so they can be summarized at exit
+// instead of getting lost in the logs
+type Warnings struct {
+	mu   sync.Mutex
+	list []Warning
+}
+
+func (w *Warnings) Add(source, message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.list = append(w.list, Warning{Source: source, Message: message})
+}
+
+func (w *Warnings) Addf(source, format string, args ...any) {
+	w.Add(source, fmt.Sprintf(format, args...))
+}
+
+// Extend adds every warning collected by other
+func (w *Warnings) Extend(other *Warnings) {
+	for _, warning := range other.List() {
+		w.Add(warning.Source, warning.Message)
+	}
+}
+
+// List returns a copy of the collected warnings in the order they were added
+func (w *Warnings) List() []Warning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := make([]Warning, len(w.list))
+	copy(list, w.list)
+	return list
+}
+
+func (w *Warnings) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.list)
+}
+
+// Summary returns one line per distinct warning, with repeated warnings collapsed into a count
+func (w *Warnings) Summary() []string {
+	var distinct []Warning
+	counts := make(map[Warning]int)
+	for _, warning := range w.List() {
+		if counts[warning] == 0 {
+			distinct = append(distinct, warning)
+		}
+		counts[warning]++
+	}
+
+	lines := make([]string, 0, len(distinct))
+	for _, warning := range distinct {
+		line := fmt.Sprintf("[%s] %s", warning.Source, warning.Message)
+		if counts[warning] > 1 {
+			line += fmt.Sprintf(" (x%d)", counts[warning])
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,29 @@
+package report
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWarningsSummary(t *testing.T) {
+	var warnings Warnings
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() { warnings.Add("chunk", "results could not be parsed") })
+	}
+	wg.Wait()
+	warnings.Addf("limits", "searches are finalized after %s", "10m0s")
+
+	expected := []string{
+		"[chunk] results could not be parsed (x3)",
+		"[limits] searches are finalized after 10m0s",
+	}
+	if summary := warnings.Summary(); !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Summary mismatch:\nExpected: %q\nGot:      %q", expected, summary)
+	}
+	if warnings.Len() != 4 {
+		t.Errorf("Expected 4 warnings, got %d", warnings.Len())
+	}
+}
@@ -0,0 +1,249 @@
+package spl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Finding is a problem found in a search
+type Finding struct {
+	Rule       string // short identifier of the check, e.g. missing-index
+	Message    string
+	Suggestion string
+}
+
+func (f Finding) String() string {
+	return f.Message + ". " + f.Suggestion
+}
+
+// Searches spanning more than this are too long for transaction to group reliably
+const transactionSpanLimit = 24 * time.Hour
+
+// Lint checks a search run over [earliest, latest) for common export mistakes. now resolves
+// relative times.
+func Lint(search, earliest, latest string, now time.Time) []Finding {
+	commands, subsearches := splitPipeline(search)
+	var findings []Finding
+
+	// Searches starting with a generating command such as | tstats don't read an index directly
+	if len(commands) > 0 && !strings.HasPrefix(strings.TrimSpace(search), "|") {
+		findings = append(findings, lintBaseSearch(baseSearch(commands[0]))...)
+	}
+
+	if subsearches > 0 {
+		findings = append(findings, Finding{
+			Rule:       "subsearch",
+			Message:    "the search uses a subsearch, which Splunk silently cuts off at 10000 results or 60 seconds by default (limits.conf [subsearch] maxout and maxtime)",
+			Suggestion: "Use a lookup, or stats over both datasets, instead of a subsearch or join",
+		})
+	}
+
+	for _, command := range commands[min(1, len(commands)):] {
+		name, args := commandName(command)
+		switch name {
+		case "transaction":
+			if span, ok := TimeSpan(earliest, latest, now); ok && span > transactionSpanLimit {
+				findings = append(findings, Finding{
+					Rule:       "transaction-range",
+					Message:    fmt.Sprintf("transaction runs over %s, and evicts open transactions silently when it runs out of memory (limits.conf [transactions] maxopentxn and maxopenevents)", describeSpan(span)),
+					Suggestion: "Group events with stats by the transaction's fields, or narrow the time range",
+				})
+			}
+		case "sort":
+			if !sortHasLimit(args) {
+				findings = append(findings, Finding{
+					Rule:       "sort-limit",
+					Message:    "sort without a count keeps only the first 10000 results",
+					Suggestion: "Use sort 0 to sort every result",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+var indexIn = regexp.MustCompile(`(?i)(?:^|[\s(])index\s+in\s*\(([^)]*)\)`)
+
+// Indexes returns the indexes the base search of a search selects, such as main for index=main.
+// index=* and index!=<name> don't select an index. Searches starting with a generating command
+// such as | tstats have no base search.
+func Indexes(search string) []string {
+	if strings.HasPrefix(strings.TrimSpace(search), "|") {
+		return nil
+	}
+	commands, _ := splitPipeline(search)
+	if len(commands) == 0 {
+		return nil
+	}
+	return baseIndexes(baseSearch(commands[0]))
+}
+
+// baseSearch drops the optional search command from the first command of a search
+func baseSearch(command string) string {
+	if name, args := commandName(command); name == "search" {
+		return args
+	}
+	return command
+}
+
+func baseIndexes(base string) []string {
+	var indexes []string
+	for _, m := range indexIn.FindAllStringSubmatch(base, -1) {
+		for _, index := range strings.Split(m[1], ",") {
+			if index = strings.Trim(strings.TrimSpace(index), `"`); index != "" && index != "*" {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	for _, term := range splitTerms(base) {
+		field, value, isField := strings.Cut(term, "=")
+		if value = strings.Trim(value, `"`); isField && strings.EqualFold(field, "index") && value != "*" && value != "" {
+			indexes = append(indexes, value)
+		}
+	}
+	return indexes
+}
+
+// lintBaseSearch checks the terms of the search leading the pipeline
+func lintBaseSearch(base string) []Finding {
+	var findings []Finding
+	hasIndex := len(baseIndexes(base)) > 0
+	var leading []string
+	for _, term := range splitTerms(base) {
+		value := term
+		if _, v, isField := strings.Cut(term, "="); isField {
+			value = v
+		}
+		if value = strings.Trim(value, `"`); len(value) > 1 && strings.HasPrefix(value, "*") {
+			leading = append(leading, term)
+		}
+	}
+
+	if !hasIndex {
+		findings = append(findings, Finding{
+			Rule:       "missing-index",
+			Message:    "the search doesn't select an index, so it scans every index the user searches by default",
+			Suggestion: "Add index=<name> to the search",
+		})
+	}
+	if len(leading) > 0 {
+		findings = append(findings, Finding{
+			Rule:       "leading-wildcard",
+			Message:    fmt.Sprintf("%s starts with a wildcard, which can't use the index and reads every event", strings.Join(leading, ", ")),
+			Suggestion: "Match a prefix or an exact field value instead",
+		})
+	}
+	return findings
+}
+
+// splitPipeline splits a search into its commands and counts its subsearches. Pipes and brackets in
+// quotes and within subsearches don't count.
+func splitPipeline(search string) ([]string, int) {
+	var commands []string
+	subsearches := 0
+	depth := 0
+	quoted := false
+	start := 0
+	for i := 0; i < len(search); i++ {
+		switch c := search[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			if depth == 0 {
+				subsearches++
+			}
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == '|' && depth == 0:
+			if command := strings.TrimSpace(search[start:i]); command != "" {
+				commands = append(commands, command)
+			}
+			start = i + 1
+		}
+	}
+	if command := strings.TrimSpace(search[start:]); command != "" {
+		commands = append(commands, command)
+	}
+	return commands, subsearches
+}
+
+// splitTerms splits a search into its whitespace-separated terms, keeping quoted strings together and
+// dropping subsearches, parentheses and boolean operators
+func splitTerms(search string) []string {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	depth := 0
+	flush := func() {
+		t := strings.Trim(term.String(), "()")
+		switch t {
+		case "", "AND", "OR", "NOT":
+		default:
+			terms = append(terms, t)
+		}
+		term.Reset()
+	}
+	for i := 0; i < len(search); i++ {
+		c := search[i]
+		switch {
+		case depth > 0:
+			if c == '[' {
+				depth++
+			} else if c == ']' {
+				depth--
+			}
+		case c == '"':
+			quoted = !quoted
+			term.WriteByte(c)
+		case quoted:
+			term.WriteByte(c)
+		case c == '[':
+			flush()
+			depth++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			term.WriteByte(c)
+		}
+	}
+	flush()
+	return terms
+}
+
+// commandName returns the lowercased name of a command and its arguments
+func commandName(command string) (string, string) {
+	name, args, _ := strings.Cut(strings.TrimSpace(command), " ")
+	// A newline may follow the name as well
+	name, rest, found := strings.Cut(name, "\n")
+	if found {
+		args = rest + " " + args
+	}
+	return strings.ToLower(name), strings.TrimSpace(args)
+}
+
+// sortHasLimit reports whether the arguments of sort start with a count, as in sort 0 -_time or
+// sort limit=0 _time
+func sortHasLimit(args string) bool {
+	first, _, _ := strings.Cut(args, " ")
+	if _, err := strconv.Atoi(first); err == nil {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(first), "limit=")
+}
+
+func describeSpan(span time.Duration) string {
+	if span > 100*365*24*time.Hour {
+		return "all time"
+	}
+	if days := span / (24 * time.Hour); days > 1 {
+		return fmt.Sprintf("%d days", days)
+	}
+	return span.String()
+}
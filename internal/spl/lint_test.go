@@ -0,0 +1,71 @@
+package spl
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func rules(findings []Finding) []string {
+	var names []string
+	for _, f := range findings {
+		names = append(names, f.Rule)
+	}
+	return names
+}
+
+func TestLint(t *testing.T) {
+	now := time.Date(2025, 8, 26, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		search   string
+		earliest string
+		latest   string
+		want     []string
+	}{
+		{"index=main sourcetype=syslog | table _time host _raw", "-24h", "now", nil},
+		{"search index=main error", "-24h", "now", nil},
+		{`index IN (main, web) status=500`, "-24h", "now", nil},
+		{"| tstats count where index=main by host", "0", "now", nil},
+		{"sourcetype=syslog error", "-24h", "now", []string{"missing-index"}},
+		{"index=* error", "-24h", "now", []string{"missing-index"}},
+		{"index!=main error", "-24h", "now", []string{"missing-index"}},
+		{"index=main *error", "-24h", "now", []string{"leading-wildcard"}},
+		{`index=main user="*admin"`, "-24h", "now", []string{"leading-wildcard"}},
+		{`index=main "contains | pipe and [bracket]"`, "-24h", "now", nil},
+		{"index=main [search index=users | fields user]", "-24h", "now", []string{"subsearch"}},
+		{"index=main | join user [search index=users]", "-24h", "now", []string{"subsearch"}},
+		{"index=main | transaction session_id", "-7d@d", "now", []string{"transaction-range"}},
+		{"index=main | transaction session_id", "", "", []string{"transaction-range"}},
+		{"index=main | transaction session_id", "-4h", "now", nil},
+		{"index=main | transaction session_id", "-1d@d", "@d", nil},
+		{"index=main | sort -_time", "-24h", "now", []string{"sort-limit"}},
+		{"index=main | sort 0 -_time", "-24h", "now", nil},
+		{"index=main | sort limit=0 _time", "-24h", "now", nil},
+	}
+	for _, tt := range tests {
+		got := rules(Lint(tt.search, tt.earliest, tt.latest, now))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Lint(%q, %q, %q) = %v, want %v", tt.search, tt.earliest, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{"index=main error", []string{"main"}},
+		{`search index="web" OR index=app* | stats count`, []string{"web", "app*"}},
+		{"index IN (main, \"web\") status=500", []string{"main", "web"}},
+		{"index=* error", nil},
+		{"index!=main error", nil},
+		{"error [search index=users]", nil},
+		{"| tstats count where index=main", nil},
+	}
+	for _, tt := range tests {
+		if got := Indexes(tt.search); !slices.Equal(got, tt.want) {
+			t.Errorf("Indexes(%q) = %v, want %v", tt.search, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package spl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// templateToken matches the $name$ and $name|raw$ parameters of a search template. Dollar signs
+// around anything but a name, as in rex "^(?<id>\d+)$", are left alone.
+var templateToken = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)(\|raw)?\$`)
+
+var validParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseParams parses name=value template parameters
+func ParseParams(params []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(params))
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || !validParamName.MatchString(name) {
+			return nil, fmt.Errorf("invalid parameter %q, expected name=value with a name of letters, digits and '_'", param)
+		}
+		parsed[name] = value
+	}
+	return parsed, nil
+}
+
+// Substitute fills in the parameters of a search template. $name$ becomes the value as a quoted
+// string, so a value can't end the term or inject commands, while $name|raw$ inserts it as it is,
+// e.g. for a count or a list of fields. Every parameter of the template must be given and every
+// parameter given must be used, which catches misspelled names.
+func Substitute(template string, params map[string]string) (string, error) {
+	var missing []string
+	used := make(map[string]bool)
+	search := templateToken.ReplaceAllStringFunc(template, func(token string) string {
+		match := templateToken.FindStringSubmatch(token)
+		name, raw := match[1], match[2] != ""
+		value, ok := params[name]
+		if !ok {
+			if !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return token
+		}
+		used[name] = true
+		if raw {
+			return value
+		}
+		return Quote(value)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("the search uses $%s$ but no value was given for it", strings.Join(missing, "$, $"))
+	}
+	for name := range params {
+		if !used[name] {
+			return "", fmt.Errorf("parameter %s isn't used by the search", name)
+		}
+	}
+	return search, nil
+}
+
+// Quote returns value as an SPL string, escaping backslashes and double quotes
+func Quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
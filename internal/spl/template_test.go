@@ -0,0 +1,68 @@
+package spl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		template string
+		params   map[string]string
+		want     string
+		wantErr  string
+	}{
+		{
+			template: "index=$index$ user=$user$ | head $limit|raw$",
+			params:   map[string]string{"index": "auth", "user": "adm*", "limit": "100"},
+			want:     `index="auth" user="adm*" | head 100`,
+		},
+		{
+			template: "index=main user=$user$",
+			params:   map[string]string{"user": `bob" OR 1=1 | delete`},
+			want:     `index=main user="bob\" OR 1=1 | delete"`,
+		},
+		{
+			template: `index=main path=$path$ | rex "^(?<id>\d+)$" | eval cost="$5"`,
+			params:   map[string]string{"path": `C:\Windows`},
+			want:     `index=main path="C:\\Windows" | rex "^(?<id>\d+)$" | eval cost="$5"`,
+		},
+		{
+			template: "index=$index$ src=$src$ dest=$src$",
+			params:   map[string]string{"index": "fw"},
+			wantErr:  "the search uses $src$ but no value was given for it",
+		},
+		{
+			template: "index=$index$",
+			params:   map[string]string{"index": "fw", "idx": "web"},
+			wantErr:  "parameter idx isn't used by the search",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Substitute(tt.template, tt.params)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Substitute(%q): expected error %q, got %v", tt.template, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Substitute(%q): expected %q, got %q, %v", tt.template, tt.want, got, err)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	params, err := ParseParams([]string{"index=auth", "query=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("ParseParams returned error: %v", err)
+	}
+	if params["index"] != "auth" || params["query"] != "a=b" || params["empty"] != "" {
+		t.Errorf("Unexpected params %v", params)
+	}
+	for _, invalid := range []string{"index", "=auth", "my-index=auth"} {
+		if _, err := ParseParams([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
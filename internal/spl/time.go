@@ -0,0 +1,78 @@
+// Package spl interprets Splunk's search language: it resolves time modifiers and statically checks
+// searches for mistakes that make exports slow or silently incomplete, before they are dispatched, and
+// fills in the parameters of search templates.
+package spl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var relativeTime = regexp.MustCompile(`^([+-]?)(\d*)(s|sec|secs|second|seconds|m|min|mins|minute|minutes|h|hr|hrs|hour|hours|d|day|days|w|week|weeks|mon|month|months|q|qtr|qtrs|quarter|quarters|y|yr|yrs|year|years)?(@.*)?$`)
+
+// TimeSpan returns how long the time range is, when its bounds can be resolved. The snap to a unit
+// of relative times is ignored, which is close enough for a warning.
+func TimeSpan(earliest, latest string, now time.Time) (time.Duration, bool) {
+	start, ok := resolveTime(earliest, now, time.Unix(0, 0))
+	if !ok {
+		return 0, false
+	}
+	end, ok := resolveTime(latest, now, now)
+	if !ok {
+		return 0, false
+	}
+	return end.Sub(start), true
+}
+
+// resolveTime resolves a Splunk time modifier, unset returning missing
+func resolveTime(modifier string, now, missing time.Time) (time.Time, bool) {
+	modifier = strings.TrimSpace(modifier)
+	switch modifier {
+	case "", "0":
+		return missing, true
+	case "now", "rt":
+		return now, true
+	}
+	// Unsigned numbers are epoch times, signed ones seconds relative to now
+	if seconds, err := strconv.ParseFloat(modifier, 64); err == nil && !strings.ContainsAny(modifier[:1], "+-") {
+		return time.Unix(int64(seconds), 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, modifier); err == nil {
+		return t, true
+	}
+	m := relativeTime.FindStringSubmatch(strings.TrimPrefix(modifier, "rt"))
+	if m == nil || (m[2] == "" && m[3] == "") {
+		// A bare snap such as @d is at most a unit away from now
+		if strings.HasPrefix(modifier, "@") {
+			return now, true
+		}
+		return time.Time{}, false
+	}
+	n := 1
+	if m[2] != "" {
+		n, _ = strconv.Atoi(m[2])
+	}
+	if m[1] == "-" {
+		n = -n
+	}
+	switch unit := m[3]; {
+	case unit == "" || strings.HasPrefix(unit, "s"):
+		return now.Add(time.Duration(n) * time.Second), true
+	case unit == "m" || strings.HasPrefix(unit, "min"):
+		return now.Add(time.Duration(n) * time.Minute), true
+	case strings.HasPrefix(unit, "h"):
+		return now.Add(time.Duration(n) * time.Hour), true
+	case strings.HasPrefix(unit, "d"):
+		return now.AddDate(0, 0, n), true
+	case strings.HasPrefix(unit, "w"):
+		return now.AddDate(0, 0, 7*n), true
+	case strings.HasPrefix(unit, "mon"):
+		return now.AddDate(0, n, 0), true
+	case strings.HasPrefix(unit, "q"):
+		return now.AddDate(0, 3*n, 0), true
+	default:
+		return now.AddDate(n, 0, 0), true
+	}
+}
@@ -0,0 +1,31 @@
+package spl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeSpan(t *testing.T) {
+	now := time.Date(2025, 8, 26, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		earliest, latest string
+		want             time.Duration
+	}{
+		{"-24h", "now", 24 * time.Hour},
+		{"-7d@d", "now", 7 * 24 * time.Hour},
+		{"-30m", "-15m", 15 * time.Minute},
+		{"1756195200", "1756209600", 4 * time.Hour},
+		{"2025-08-26T00:00:00Z", "now", 12 * time.Hour},
+		{"-2w", "", 14 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, ok := TimeSpan(tt.earliest, tt.latest, now)
+		if !ok || got != tt.want {
+			t.Errorf("TimeSpan(%q, %q) = %s, %t, want %s", tt.earliest, tt.latest, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := TimeSpan("08/26/2025:00:00:00", "now", now); ok {
+		t.Error("Expected an unsupported time format not to resolve")
+	}
+}
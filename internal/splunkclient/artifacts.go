@@ -0,0 +1,60 @@
+package splunkclient
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// DownloadJobMetadata writes the job's entry to filename as Splunk sends it, with every property of
+// the job rather than the ones SearchJobContent keeps
+func (c *Client) DownloadJobMetadata(sid string, filename string) (int64, error) {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s", sid))
+	return c.DownloadFile(path, map[string]string{"output_mode": "json"}, filename)
+}
+
+// GetJobEvents requests up to count of the events a job read, starting at event number start. Unlike
+// its results, these are the events before any transforming command. Splunk only keeps them for jobs
+// dispatched with status buckets.
+func (c *Client) GetJobEvents(sid string, start, count int, outputMode string) (ResultsPage, error) {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s/events", sid))
+	queryParams := map[string]string{
+		"count":       fmt.Sprintf("%d", count),
+		"offset":      fmt.Sprintf("%d", start),
+		"output_mode": requestOutputMode(outputMode),
+	}
+
+	response, transfer, err := c.get(path, queryParams)
+	if err != nil {
+		return ResultsPage{}, err
+	}
+
+	page := parseResultsResponse(response, outputMode, start)
+	page.Transfer = transfer
+	slog.Debug("Job events page processed", "sid", sid, "start", start, "response_size", len(response), "parsed_size", len(page.Data))
+
+	return page, nil
+}
+
+// DownloadJobTimeline writes the job's timeline, the count of events per time bucket, to filename as
+// the XML Splunk sends it
+func (c *Client) DownloadJobTimeline(sid string, filename string) (int64, error) {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s/timeline", sid))
+	return c.DownloadFile(path, nil, filename)
+}
+
+// DownloadSearchLog writes the job's search.log, the search process's own log, to filename
+func (c *Client) DownloadSearchLog(sid string, filename string) (int64, error) {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s/search.log", sid))
+	return c.DownloadFile(path, nil, filename)
+}
+
+// DownloadJobResults writes all the results of a finished job to filename with a single request, in
+// the output mode exactly as Splunk sends them. Only modes Splunk writes the way spldl does, csv and
+// raw, can be downloaded this way. A dropped transfer continues where it stopped, see DownloadFile.
+func (c *Client) DownloadJobResults(sid string, outputMode string, filename string) (int64, error) {
+	if outputMode != "csv" && outputMode != "raw" {
+		return 0, fmt.Errorf("%s results can't be downloaded in a single request, use csv or raw", outputMode)
+	}
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s/results", sid))
+	return c.DownloadFile(path, map[string]string{"count": "0", "output_mode": outputMode}, filename)
+}
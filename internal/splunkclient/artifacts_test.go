@@ -0,0 +1,82 @@
+package splunkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestJobArtifacts(t *testing.T) {
+	const sid = "1756064805.1039"
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid + "/events":
+			query := r.URL.Query()
+			if query.Get("offset") != "2" || query.Get("count") != "10" || query.Get("output_mode") != "csv" {
+				t.Errorf("Unexpected events query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte("host,_raw\nweb01,a\n"))
+		case "/services/search/v2/jobs/" + sid:
+			if r.URL.Query().Get("output_mode") != "json" {
+				t.Errorf("Unexpected job query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"entry":[{"name":"search index=main"}]}`))
+		case "/services/search/v2/jobs/" + sid + "/results":
+			query := r.URL.Query()
+			if query.Get("count") != "0" || query.Get("output_mode") != "csv" {
+				t.Errorf("Unexpected results query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte("host,_raw\nweb01,a\n"))
+		case "/services/search/v2/jobs/" + sid + "/timeline":
+			w.Write([]byte(`<timeline c="1"><bucket a="1756064400" c="1"/></timeline>`))
+		case "/services/search/v2/jobs/" + sid + "/search.log":
+			w.Write([]byte("INFO  SearchParser - PARSING: search index=main\n"))
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	page, err := client.GetJobEvents(sid, 2, 10, "csv")
+	if err != nil {
+		t.Fatalf("GetJobEvents returned error: %v", err)
+	}
+	// Pages after the first leave out the header
+	if page.Data != "web01,a\n" {
+		t.Errorf("Unexpected events %q", page.Data)
+	}
+
+	dir := t.TempDir()
+	downloads := []struct {
+		name     string
+		download func(filename string) (int64, error)
+		expected string
+	}{
+		{"job.json", func(filename string) (int64, error) { return client.DownloadJobMetadata(sid, filename) }, `{"entry":[{"name":"search index=main"}]}`},
+		{"timeline.xml", func(filename string) (int64, error) { return client.DownloadJobTimeline(sid, filename) }, `<timeline c="1"><bucket a="1756064400" c="1"/></timeline>`},
+		{"search.log", func(filename string) (int64, error) { return client.DownloadSearchLog(sid, filename) }, "INFO  SearchParser - PARSING: search index=main\n"},
+		{"results.csv", func(filename string) (int64, error) { return client.DownloadJobResults(sid, "csv", filename) }, "host,_raw\nweb01,a\n"},
+	}
+	for _, d := range downloads {
+		filename := filepath.Join(dir, d.name)
+		if _, err := d.download(filename); err != nil {
+			t.Fatalf("Downloading %s returned error: %v", d.name, err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil || string(data) != d.expected {
+			t.Errorf("Unexpected %s %q, %v", d.name, data, err)
+		}
+	}
+
+	if _, err := client.DownloadJobResults(sid, "ndjson", filepath.Join(dir, "results.ndjson")); err == nil {
+		t.Errorf("Expected ndjson results to be refused")
+	}
+}
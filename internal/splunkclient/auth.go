@@ -0,0 +1,112 @@
+package splunkclient
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+// Authenticator adds credentials to the requests a Client sends. Implementations must be safe for
+// concurrent use, since a client's copies share it.
+type Authenticator interface {
+	// Apply authenticates request before it's sent
+	Apply(request *http.Request) error
+	// Refresh is called when Splunk rejected a request Apply authenticated with 401 Unauthorized. It
+	// renews the credentials if it can and reports whether the request is worth sending again.
+	Refresh(rejected *http.Request) (bool, error)
+}
+
+// tlsAuthenticator is an Authenticator that identifies the client during the TLS handshake
+type tlsAuthenticator interface {
+	configureTLS(tlsConfig *tls.Config)
+}
+
+// BasicAuth sends a username and password with every request
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Apply(request *http.Request) error {
+	request.SetBasicAuth(a.Username, a.Password)
+	slog.Debug("Using HTTP Basic authentication")
+	return nil
+}
+
+// Refresh gives up, since sending the same username and password again won't help
+func (a BasicAuth) Refresh(*http.Request) (bool, error) {
+	return false, nil
+}
+
+// BearerToken sends a Splunk authentication token with every request
+type BearerToken struct {
+	Token string
+}
+
+func (a BearerToken) Apply(request *http.Request) error {
+	request.Header.Set("Authorization", "Bearer "+a.Token)
+	slog.Debug("Using Bearer token authentication")
+	return nil
+}
+
+// Refresh gives up, since a rejected token stays rejected
+func (a BearerToken) Refresh(*http.Request) (bool, error) {
+	return false, nil
+}
+
+// ClientCertificate authenticates with a certificate during the TLS handshake (mutual TLS) instead of
+// a header. It takes effect on clients whose transport is an *http.Transport.
+type ClientCertificate struct {
+	Certificate tls.Certificate
+}
+
+func (a ClientCertificate) Apply(*http.Request) error {
+	return nil
+}
+
+// Refresh gives up, since the certificate is presented when the connection is made
+func (a ClientCertificate) Refresh(*http.Request) (bool, error) {
+	return false, nil
+}
+
+func (a ClientCertificate) configureTLS(tlsConfig *tls.Config) {
+	tlsConfig.Certificates = []tls.Certificate{a.Certificate}
+}
+
+// newAuthenticator returns the built-in Authenticator for cfg's credentials, nil without any
+func newAuthenticator(cfg config.ClientConfig, c *Client) Authenticator {
+	switch cfg.Auth.Type {
+	case config.AuthHTTPBasic:
+		if cfg.SessionAuth {
+			return &sessionAuth{client: c, username: cfg.Auth.Username, password: cfg.Auth.Password}
+		}
+		return BasicAuth{Username: cfg.Auth.Username, Password: cfg.Auth.Password}
+	case config.AuthToken:
+		return BearerToken{Token: cfg.Auth.Token}
+	}
+	return nil
+}
+
+// WithAuthenticator returns a copy of the client that authenticates its requests with auth instead
+// of the credentials it was configured with
+func (c *Client) WithAuthenticator(auth Authenticator) *Client {
+	clone := *c
+	clone.auth = auth
+	if tlsAuth, ok := auth.(tlsAuthenticator); ok {
+		if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+			transport = transport.Clone()
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			tlsAuth.configureTLS(transport.TLSClientConfig)
+			httpClient := *c.httpClient
+			httpClient.Transport = transport
+			clone.httpClient = &httpClient
+		} else {
+			slog.Warn("The client's transport isn't an *http.Transport, so its certificate can't be presented")
+		}
+	}
+	return &clone
+}
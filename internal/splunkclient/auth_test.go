@@ -0,0 +1,113 @@
+package splunkclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+// signedHeaderAuth signs requests with a generation number, which Refresh bumps
+type signedHeaderAuth struct {
+	generation atomic.Int32
+	refreshes  atomic.Int32
+}
+
+func (a *signedHeaderAuth) Apply(request *http.Request) error {
+	request.Header.Set("X-Signature", strconv.Itoa(int(a.generation.Load())))
+	return nil
+}
+
+func (a *signedHeaderAuth) Refresh(*http.Request) (bool, error) {
+	a.refreshes.Add(1)
+	a.generation.Add(1)
+	return true, nil
+}
+
+func TestCustomAuthenticator(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Expected no Authorization header, got %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("X-Signature") != "1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer testServer.Close()
+
+	auth := &signedHeaderAuth{}
+	client := NewClient(config.ClientConfig{
+		Auth: config.AuthConfig{Type: config.AuthToken, Token: "replaced"},
+	}).WithAuthenticator(auth)
+	client.baseURL = testServer.URL
+
+	// The first signature is rejected, so it's refreshed and the request is sent again
+	if _, err := client.Get("/services/server/info", nil); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if _, err := client.Get("/services/server/info", nil); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if auth.refreshes.Load() != 1 {
+		t.Errorf("Expected 1 refresh, got %d", auth.refreshes.Load())
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	testServer.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	testServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(testServer.Certificate())
+	client := NewClient(config.ClientConfig{UseTLS: true, VerifyTLS: true, RootCAs: roots})
+	client.baseURL = testServer.URL
+
+	if _, err := client.Get("/services/server/info", nil); err == nil {
+		t.Error("Expected the handshake to fail without a client certificate")
+	}
+
+	client = client.WithAuthenticator(ClientCertificate{Certificate: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}})
+	if _, err := client.Get("/services/server/info", nil); err != nil {
+		t.Errorf("Get with a client certificate returned error: %v", err)
+	}
+}
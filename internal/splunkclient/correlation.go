@@ -0,0 +1,38 @@
+package splunkclient
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CorrelationHeader identifies the run a request belongs to, so Splunk admins can find it in _audit
+const CorrelationHeader = "X-Correlation-ID"
+
+// runComment matches the comment added to dispatched searches by withRunComment
+var runComment = regexp.MustCompile("\\s*```spldl run_id=[0-9A-Za-z-]+```\\s*$")
+
+// NewCorrelationID returns a random UUID (version 4) identifying a run
+func NewCorrelationID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// DispatchedSearch returns search as it's sent to Splunk: normalized, and ending in a comment with
+// the client's correlation ID, which Splunk records with the search in _audit
+func (c *Client) DispatchedSearch(search string) string {
+	search = NormalizeSearch(search)
+	if c.correlationID == "" {
+		return search
+	}
+	return strings.TrimRight(search, " \t\r\n") + " ```spldl run_id=" + c.correlationID + "```"
+}
+
+// withoutRunComment removes the comment added by DispatchedSearch
+func withoutRunComment(search string) string {
+	return runComment.ReplaceAllString(search, "")
+}
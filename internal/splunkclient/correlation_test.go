@@ -0,0 +1,46 @@
+package splunkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestCorrelationID(t *testing.T) {
+	id := NewCorrelationID()
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Fatalf("Expected a version 4 UUID, got %q", id)
+	}
+
+	var requests int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get(CorrelationHeader); got != id {
+			t.Errorf("Expected %s %q, got %q", CorrelationHeader, id, got)
+		}
+		if r.Method == "POST" {
+			expected := "search index=main | head 10 ```spldl run_id=" + id + "```"
+			if got := r.FormValue("search"); got != expected {
+				t.Errorf("Expected search %q, got %q", expected, got)
+			}
+		}
+		w.Write([]byte(`{"sid": "1756172871.1180"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{CorrelationID: id})
+	client.baseURL = testServer.URL
+
+	if _, err := client.NewSearchJob("index=main | head 10 ", "-1h", "now"); err != nil {
+		t.Fatalf("NewSearchJob returned error: %v", err)
+	}
+	if err := client.DeleteSearchJob("1756172871.1180"); err != nil {
+		t.Fatalf("DeleteSearchJob returned error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+}
@@ -0,0 +1,157 @@
+package splunkclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// How often a dropped transfer is resumed before DownloadFile gives up
+const maxResumeAttempts = 3
+
+// downloadPartPath returns the file a download is written to until it's complete
+func downloadPartPath(filename string) string {
+	return filename + ".part"
+}
+
+// downloadValidatorPath returns the file holding the ETag, or Last-Modified date, of the response a
+// part file is the beginning of
+func downloadValidatorPath(filename string) string {
+	return downloadPartPath(filename) + ".validator"
+}
+
+// DownloadFile streams the response of a GET request for path into filename and returns its size.
+// The response is written to filename.part and moved into place once complete. A transfer that
+// drops, mid-run or in an earlier run, continues from the last byte written with a Range request. The
+// server's ETag, or Last-Modified date, is kept next to the part file and sent as If-Range, so a
+// response that changed since is downloaded again from the start instead of being added to the stale
+// bytes. A part file without one, and responses from servers that ignore the Range header, also start
+// over.
+func (c *Client) DownloadFile(path string, queryParams map[string]string, filename string) (int64, error) {
+	part := downloadPartPath(filename)
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	download := &fileDownload{file: file, validatorPath: downloadValidatorPath(filename)}
+	if validator, err := os.ReadFile(download.validatorPath); err == nil {
+		download.validator = string(validator)
+		info, err := file.Stat()
+		if err != nil {
+			return 0, err
+		}
+		download.written = info.Size()
+	} else if err := file.Truncate(0); err != nil {
+		return 0, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		done, err := c.downloadFrom(path, queryParams, download)
+		if done {
+			break
+		}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) || attempt >= maxResumeAttempts {
+			return download.written, err
+		}
+		slog.Warn("Download interrupted, resuming", "filename", filename, "offset", download.written, "attempt", attempt+1, "error", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return download.written, err
+	}
+	if err := os.Rename(part, filename); err != nil {
+		return download.written, err
+	}
+	os.Remove(download.validatorPath)
+	slog.Debug("File download completed", "filename", filename, "size", download.written)
+	return download.written, nil
+}
+
+// fileDownload is the part file of a download and what's known about the response it holds
+type fileDownload struct {
+	file          *os.File
+	written       int64  // bytes of the response in the file
+	validator     string // the response's ETag or Last-Modified date, empty when the server sent neither
+	validatorPath string
+}
+
+// downloadFrom requests the rest of the response and adds it to the part file. It returns whether the
+// download is complete.
+func (c *Client) downloadFrom(path string, queryParams map[string]string, download *fileDownload) (bool, error) {
+	request, err := http.NewRequestWithContext(c.Context(), "GET", c.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	q := request.URL.Query()
+	for key, value := range queryParams {
+		q.Add(key, value)
+	}
+	request.URL.RawQuery = q.Encode()
+	// Ranges and validators apply to the bytes as they're sent, so the response is never compressed
+	request.Header.Set("Accept-Encoding", "identity")
+	// Without a validator nothing tells the bytes written apart from a response that changed since
+	offset := download.written
+	if download.validator == "" {
+		offset = 0
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		request.Header.Set("If-Range", download.validator)
+	}
+
+	resp, err := c.sendRequest(request)
+	var httpErr *HTTPError
+	if offset > 0 && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The response didn't change, and a previous attempt wrote all of it
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent || !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+		if offset > 0 {
+			slog.Warn("The file changed or the server doesn't support resuming downloads, starting over", "offset", offset)
+		}
+		offset = 0
+		if err := download.restart(resp.Header); err != nil {
+			return false, err
+		}
+	}
+	if _, err := download.file.Seek(offset, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	copied, err := io.Copy(download.file, resp.Body)
+	download.written = offset + copied
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restart empties the part file for a response sent from its start, and keeps its validator. Responses
+// with only a weak ETag can't be resumed, since If-Range needs a strong one.
+func (d *fileDownload) restart(header http.Header) error {
+	d.written = 0
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	d.validator = header.Get("ETag")
+	if d.validator == "" || strings.HasPrefix(d.validator, "W/") {
+		d.validator = header.Get("Last-Modified")
+	}
+	if d.validator == "" {
+		os.Remove(d.validatorPath)
+		return nil
+	}
+	return os.WriteFile(d.validatorPath, []byte(d.validator), 0o644)
+}
@@ -0,0 +1,152 @@
+package splunkclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestDownloadFile(t *testing.T) {
+	content := []byte(strings.Repeat("_time,host,_raw\n2025-08-26T01:47:51.000+00:00,web01,foo\n", 500))
+	const etag = `"results-v1"`
+
+	tests := []struct {
+		name           string
+		existing       []byte // the part file of an earlier run
+		validator      string // recorded for the part file
+		dropFirst      bool   // cut the first response off halfway
+		ignoreRange    bool
+		expectedRanges []string
+	}{
+		{
+			name:           "fresh download",
+			expectedRanges: []string{""},
+		},
+		{
+			name:           "resume from previous run",
+			existing:       content[:1000],
+			validator:      etag,
+			expectedRanges: []string{"bytes=1000-"},
+		},
+		{
+			name:           "resume after dropped connection",
+			dropFirst:      true,
+			expectedRanges: []string{"", "bytes=" + strconv.Itoa(len(content)/2) + "-"},
+		},
+		{
+			name:           "remote file changed",
+			existing:       []byte("stale partial content"),
+			validator:      `"results-v0"`,
+			expectedRanges: []string{"bytes=21-"},
+		},
+		{
+			name:           "part file without validator",
+			existing:       []byte("stale partial content"),
+			expectedRanges: []string{""},
+		},
+		{
+			name:           "server without range support",
+			existing:       content[:1000],
+			validator:      etag,
+			ignoreRange:    true,
+			expectedRanges: []string{"bytes=1000-"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if r.Header.Get("Range") != "" && r.Header.Get("If-Range") == "" {
+					t.Errorf("Expected an If-Range header with the Range header")
+				}
+				w.Header().Set("ETag", etag)
+				if tt.dropFirst && len(ranges) == 1 {
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					w.Write(content[:len(content)/2])
+					return
+				}
+				if tt.ignoreRange {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "results.csv", time.Time{}, bytes.NewReader(content))
+			}))
+			defer testServer.Close()
+
+			client := NewClient(config.ClientConfig{})
+			client.baseURL = testServer.URL
+
+			filename := filepath.Join(t.TempDir(), "results.csv")
+			if tt.existing != nil {
+				if err := os.WriteFile(downloadPartPath(filename), tt.existing, 0o644); err != nil {
+					t.Fatalf("Failed to write existing file: %v", err)
+				}
+			}
+			if tt.validator != "" {
+				if err := os.WriteFile(downloadValidatorPath(filename), []byte(tt.validator), 0o644); err != nil {
+					t.Fatalf("Failed to write validator: %v", err)
+				}
+			}
+
+			size, err := client.DownloadFile("/services/search/v2/jobs/1756064805.1039/results", nil, filename)
+			if err != nil {
+				t.Fatalf("DownloadFile returned an error: %v", err)
+			}
+			if size != int64(len(content)) {
+				t.Errorf("Expected size %d, got %d", len(content), size)
+			}
+
+			downloaded, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read downloaded file: %v", err)
+			}
+			if !bytes.Equal(downloaded, content) {
+				t.Errorf("Downloaded content does not match (%d bytes, expected %d)", len(downloaded), len(content))
+			}
+			for _, leftover := range []string{downloadPartPath(filename), downloadValidatorPath(filename)} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be removed, got %v", filepath.Base(leftover), err)
+				}
+			}
+
+			if strings.Join(ranges, "|") != strings.Join(tt.expectedRanges, "|") {
+				t.Errorf("Expected Range headers %q, got %q", tt.expectedRanges, ranges)
+			}
+		})
+	}
+}
+
+func TestDownloadFileKeepsPartOnFailure(t *testing.T) {
+	content := []byte(strings.Repeat("event\n", 1000))
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:100])
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	filename := filepath.Join(t.TempDir(), "search.log")
+	if _, err := client.DownloadFile("/services/search/v2/jobs/1756064805.1039/search.log", nil, filename); err == nil {
+		t.Fatal("Expected the download to fail")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("Expected no file before the download is complete, got %v", err)
+	}
+	part, _ := os.ReadFile(downloadPartPath(filename))
+	validator, _ := os.ReadFile(downloadValidatorPath(filename))
+	if len(part) != 100 || string(validator) != `"v1"` {
+		t.Errorf("Expected the part file and its validator to be kept for the next run, got %d bytes and %q", len(part), validator)
+	}
+}
@@ -0,0 +1,93 @@
+package splunkclient
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPError is returned when Splunk answers a request with an error status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Message    string        // the error message from Splunk's response body, if there was one
+	RetryAfter time.Duration // how long Splunk asked to wait before trying again, 0 if it didn't say
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("HTTP %d: %s (%s)", e.StatusCode, e.Status, e.Message)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Status)
+}
+
+// JobFailedError is returned when a job being waited on failed, was paused or its search process died
+type JobFailedError struct {
+	SID           string
+	DispatchState string   // FAILED, PAUSED or ZOMBIE
+	Messages      []string // the errors Splunk reported for the job, e.g. a syntax error in the search
+}
+
+func (e *JobFailedError) Error() string {
+	var reason string
+	switch e.DispatchState {
+	case "PAUSED":
+		reason = "was paused"
+	case "ZOMBIE":
+		reason = "stopped without finishing, its search process died"
+	default:
+		reason = "failed"
+	}
+	if len(e.Messages) > 0 {
+		return fmt.Sprintf("job %s %s: %s", e.SID, reason, strings.Join(e.Messages, "; "))
+	}
+	return fmt.Sprintf("job %s %s", e.SID, reason)
+}
+
+// JobTimeoutError is returned when a job isn't done within the time given to wait for it. The job
+// keeps running.
+type JobTimeoutError struct {
+	SID     string
+	Timeout time.Duration
+}
+
+func (e *JobTimeoutError) Error() string {
+	return fmt.Sprintf("job %s isn't done after waiting %s", e.SID, e.Timeout)
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	httpErr := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
+	// Splunk explains most errors in a messages list, e.g. "Unknown sid."
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return httpErr
+	}
+	var parsed struct {
+		Messages []ResultsMessage `json:"messages"`
+	}
+	var texts []string
+	if json.Unmarshal(body, &parsed) == nil {
+		for _, message := range parsed.Messages {
+			texts = append(texts, message.Text)
+		}
+	} else {
+		// Endpoints that don't take an output mode, or that don't exist, answer in XML
+		var response struct {
+			Messages []string `xml:"messages>msg"`
+		}
+		if xml.Unmarshal(body, &response) == nil {
+			texts = response.Messages
+		}
+	}
+	httpErr.Message = strings.Join(texts, "; ")
+	return httpErr
+}
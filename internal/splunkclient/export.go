@@ -0,0 +1,168 @@
+package splunkclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// How many results ExportStream.Next returns at most
+const exportBatchSize = 1000
+
+// exportLine is one line of the json export stream, holding either a result or messages
+type exportLine struct {
+	Preview  bool             `json:"preview"`
+	Result   json.RawMessage  `json:"result"`
+	Messages []ResultsMessage `json:"messages"`
+}
+
+// ExportStream reads the results of an export search in batches as they arrive, without
+// waiting for the search to finish or holding more than a batch in memory
+type ExportStream struct {
+	body     io.ReadCloser
+	reader   *bufio.Reader
+	stream   func(line string, data *strings.Builder, page *ResultsPage) int // reads the lines of the output mode
+	offset   int
+	transfer Transfer // read from the body by earlier batches
+}
+
+// ExportSearch runs a search through the export endpoint, which streams results as they are found and isn't
+// limited in the number of results. The caller must close the stream.
+func (c *Client) ExportSearch(search string, earliest string, latest string, outputMode string) (*ExportStream, error) {
+	search = c.DispatchedSearch(search)
+	slog.Debug("Starting export search", "search", search, "earliest", earliest, "latest", latest)
+
+	data := url.Values{
+		"search":        {search},
+		"earliest_time": {earliest},
+		"latest_time":   {latest},
+		"output_mode":   {requestOutputMode(outputMode)},
+	}
+	request, err := http.NewRequestWithContext(c.Context(), "POST", c.baseURL+c.searchPath("search/v2/jobs/export"), strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.sendRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportStream{
+		body:   resp.Body,
+		reader: bufio.NewReaderSize(resp.Body, 64*1024),
+		stream: newExportStream(outputMode),
+	}, nil
+}
+
+// Next returns the next batch of results in the stream's output mode, or io.EOF once the search is done
+func (s *ExportStream) Next() (ResultsPage, error) {
+	var page ResultsPage
+	var sb strings.Builder
+	results := 0
+
+	for results < exportBatchSize {
+		line, err := s.reader.ReadString('\n')
+		if line != "" && !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+
+		results += s.stream(line, &sb, &page)
+
+		if errors.Is(err, io.EOF) {
+			if sb.Len() == 0 && len(page.Messages) == 0 && page.Skipped == 0 {
+				return ResultsPage{}, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return ResultsPage{}, fmt.Errorf("export stream interrupted after %d results: %w", s.offset+results, err)
+		}
+	}
+
+	s.offset += results
+	page.Data = sb.String()
+	total := transferOf(s.body)
+	page.Transfer = Transfer{WireBytes: total.WireBytes - s.transfer.WireBytes, Bytes: total.Bytes - s.transfer.Bytes}
+	s.transfer = total
+	return page, nil
+}
+
+// newExportStream returns the reader of the lines of an export stream in the output mode
+func newExportStream(outputMode string) func(line string, data *strings.Builder, page *ResultsPage) int {
+	if parser, ok := LookupParser(outputMode); ok {
+		if streamParser, ok := parser.(StreamParser); ok {
+			return streamParser.NewStream()
+		}
+	}
+	// Raw events and modes without a stream parser are passed through a line at a time
+	return func(line string, data *strings.Builder, page *ResultsPage) int {
+		data.WriteString(line)
+		if line == "" {
+			return 0
+		}
+		return 1
+	}
+}
+
+// newCSVStream returns the reader of the lines of a csv export stream, which passes them through and
+// counts a result at every newline outside quotes, since quoted fields may span lines
+func newCSVStream() func(line string, data *strings.Builder, page *ResultsPage) int {
+	inQuotes := false
+	return func(line string, data *strings.Builder, page *ResultsPage) int {
+		data.WriteString(line)
+		if strings.Count(line, `"`)%2 == 1 {
+			inQuotes = !inQuotes
+		}
+		if !inQuotes && line != "" {
+			return 1
+		}
+		return 0
+	}
+}
+
+// parseExportJSONLine converts a line of the json export stream to an ndjson line and returns the
+// number of results it held
+func parseExportJSONLine(line string, sb *strings.Builder, page *ResultsPage) int {
+	if strings.TrimSpace(line) == "" {
+		return 0
+	}
+	var parsed exportLine
+	if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+		slog.Debug("Skipping malformed export line", "error", err)
+		page.Skipped++
+		page.Warnings = append(page.Warnings, fmt.Sprintf("skipped a malformed result: %v", err))
+		return 0
+	}
+
+	for _, message := range parsed.Messages {
+		if message.IsProblem() {
+			page.Messages = append(page.Messages, message)
+		}
+	}
+	// Transforming searches stream previews of their results before the final results
+	if parsed.Preview || len(parsed.Result) == 0 {
+		return 0
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, parsed.Result); err != nil {
+		page.Skipped++
+		return 0
+	}
+	sb.Write(compacted.Bytes())
+	sb.WriteByte('\n')
+	return 1
+}
+
+func (s *ExportStream) Close() error {
+	return s.body.Close()
+}
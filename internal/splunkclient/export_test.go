@@ -0,0 +1,92 @@
+package splunkclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestExportSearch(t *testing.T) {
+	tests := []struct {
+		name             string
+		outputMode       string
+		response         string
+		expected         string
+		expectedMessages int
+		expectedSkipped  int
+	}{
+		{
+			name:       "ndjson skips previews and keeps field order",
+			outputMode: "ndjson",
+			response: `{"preview":true,"offset":0,"result":{"count":"1"}}
+{"preview":false,"messages":[{"type":"WARN","text":"Search was truncated"}]}
+{"preview":false,"offset":0,"result":{"sourcetype":"a", "count":"5"}}
+not json
+{"preview":false,"offset":1,"lastrow":true,"result":{"sourcetype":"b","count":"7"}}
+`,
+			expected:         "{\"sourcetype\":\"a\",\"count\":\"5\"}\n{\"sourcetype\":\"b\",\"count\":\"7\"}\n",
+			expectedMessages: 1,
+			expectedSkipped:  1,
+		},
+		{
+			name:       "csv keeps records spanning lines",
+			outputMode: "csv",
+			response:   "host,_raw\nweb01,\"line one\nline two\"\nweb02,single",
+			expected:   "host,_raw\nweb01,\"line one\nline two\"\nweb02,single\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/services/search/v2/jobs/export" {
+					t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+				if search := r.FormValue("search"); search != "search index=main" {
+					t.Errorf("Expected normalized search, got %q", search)
+				}
+				if mode := r.FormValue("output_mode"); mode != requestOutputMode(tt.outputMode) {
+					t.Errorf("Expected output_mode=%s, got %s", requestOutputMode(tt.outputMode), mode)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer testServer.Close()
+
+			client := NewClient(config.ClientConfig{})
+			client.baseURL = testServer.URL
+
+			stream, err := client.ExportSearch("index=main", "-1h", "now", tt.outputMode)
+			if err != nil {
+				t.Fatalf("ExportSearch returned error: %v", err)
+			}
+			defer stream.Close()
+
+			var data strings.Builder
+			messages, skipped := 0, 0
+			for {
+				page, err := stream.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next returned error: %v", err)
+				}
+				data.WriteString(page.Data)
+				messages += len(page.Messages)
+				skipped += page.Skipped
+			}
+
+			if data.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data.String())
+			}
+			if messages != tt.expectedMessages || skipped != tt.expectedSkipped {
+				t.Errorf("Expected %d messages and %d skipped, got %d and %d", tt.expectedMessages, tt.expectedSkipped, messages, skipped)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package splunkclient
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,63 +25,156 @@ func parseCSVResponse(response string, offset int) string {
 	return response
 }
 
-func parseJSONResponse(response string) string {
+func parseJSONResponse(response string) ResultsPage {
 	var unmarshalled SearchJobResults
+	var page ResultsPage
 
 	err := json.Unmarshal([]byte(response), &unmarshalled)
 	if err != nil {
-		slog.Error("Error unmarshalling JSON", "error", err)
-		return ""
+		slog.Debug("Error unmarshalling JSON, salvaging results record by record", "error", err)
+		page.Malformed = true
+		unmarshalled.Results, page.Skipped = salvageJSONResults(response)
+		page.Warnings = append(page.Warnings, fmt.Sprintf("results were malformed (%v), recovered %d and skipped %d", err, len(unmarshalled.Results), page.Skipped))
+	}
+
+	for _, message := range unmarshalled.Messages {
+		if message.IsProblem() {
+			page.Messages = append(page.Messages, message)
+		}
 	}
 
-	var sb strings.Builder
+	// Each result is copied as is, only stripped of whitespace, instead of being decoded and encoded again
+	var buf bytes.Buffer
+	buf.Grow(len(response))
 	for _, result := range unmarshalled.Results {
-		line, err := json.Marshal(result)
-		if err != nil {
-			slog.Error("Error marshalling result to JSON", "error", err)
+		if err := json.Compact(&buf, result); err != nil {
+			slog.Debug("Error compacting result", "error", err)
+			page.Skipped++
+			page.Warnings = append(page.Warnings, fmt.Sprintf("dropped a result that could not be converted to JSON: %v", err))
 			continue
 		}
-		sb.Write(line)
-		sb.WriteByte('\n')
+		buf.WriteByte('\n')
+	}
+	page.Data = buf.String()
+	return page
+}
+
+func parseResultsResponse(response string, outputMode string, offset int) ResultsPage {
+	parser, ok := LookupParser(outputMode)
+	if !ok {
+		return ResultsPage{}
+	}
+	return parser.Parse(response, offset)
+}
+
+// requestOutputMode maps an output mode to the output_mode Splunk should be asked for
+func requestOutputMode(outputMode string) string {
+	if parser, ok := LookupParser(outputMode); ok {
+		return parser.SplunkMode()
 	}
-	return sb.String()
+	return outputMode
+}
+
+// ResultsFilter narrows down the results returned by GetJobResults and GetJobResultsFrom. Empty fields
+// keep everything.
+type ResultsFilter struct {
+	Fields []string // the only fields of each result returned
+	Search string   // post-process search the results are filtered with, e.g. error OR warn, or commands starting with |
+	Events bool     // return the events the search read instead of its results, e.g. those a transforming search aggregated
 }
-func parseResultsResponse(response string, outputMode string, offset int) string {
-	switch outputMode {
-	case "raw":
-		return response
-	case "csv":
-		return parseCSVResponse(response, offset)
-	case "ndjson":
-		return parseJSONResponse(response)
+
+// endpoint returns the path of the job's events when the filter asks for them, of its preview results
+// when preview is set, and of its results otherwise. A running job's events are those found so far.
+func (f ResultsFilter) endpoint(sid string, preview bool) string {
+	switch {
+	case f.Events:
+		return fmt.Sprintf("search/v2/jobs/%s/events", sid)
+	case preview:
+		return fmt.Sprintf("search/v2/jobs/%s/results_preview", sid)
 	default:
-		return ""
+		return fmt.Sprintf("search/v2/jobs/%s/results", sid)
+	}
+}
+
+// apply adds the filter to the path and query parameters of a results request. Fields go in the path
+// since Splunk takes a single field per f parameter, which the map of query parameters can't repeat.
+func (f ResultsFilter) apply(path string, queryParams map[string]string) string {
+	if f.Search != "" {
+		queryParams["search"] = postProcessSearch(f.Search)
 	}
+	if len(f.Fields) == 0 {
+		return path
+	}
+	return path + "?" + url.Values{"f": f.Fields}.Encode()
 }
 
-func (c *Client) GetJobResults(sid string, count, offset int, outputMode string) (string, error) {
-	path := fmt.Sprintf("/services/search/v2/jobs/%s/results", sid)
+// postProcessSearch turns a filter into the search language the search parameter expects: bare terms
+// are filtered with the search command, and a leading pipe is dropped from commands
+func postProcessSearch(filter string) string {
+	filter = strings.TrimSpace(filter)
+	if command, ok := strings.CutPrefix(filter, "|"); ok {
+		return strings.TrimSpace(command)
+	}
+	if strings.HasPrefix(strings.ToLower(filter), "search ") {
+		return filter
+	}
+	return "search " + filter
+}
 
+// GetJobResults requests the offset-th chunk of count results, narrowed down by filter. Splunk only
+// documents GET for the results endpoints, so unlike a dispatch their parameters stay in the URL.
+func (c *Client) GetJobResults(sid string, count, offset int, outputMode string, filter ResultsFilter) (ResultsPage, error) {
 	queryParams := map[string]string{
 		"count":       fmt.Sprintf("%d", count),
 		"offset":      fmt.Sprintf("%d", offset*count),
-		"output_mode": outputMode,
+		"output_mode": requestOutputMode(outputMode),
 	}
+	path := filter.apply(c.searchPath(filter.endpoint(sid, false)), queryParams)
 
-	response, err := c.Get(path, queryParams)
+	response, transfer, err := c.get(path, queryParams)
 	if err != nil {
-		return "", err
+		return ResultsPage{}, err
 	}
 
-	parsed := parseResultsResponse(response, outputMode, offset)
-	slog.Debug("Job results chunk processed", "sid", sid, "chunk_offset", offset, "response_size", len(response), "parsed_size", len(parsed))
+	page := parseResultsResponse(response, outputMode, offset)
+	page.Transfer = transfer
+	slog.Debug("Job results chunk processed", "sid", sid, "chunk_offset", offset, "response_size", len(response), "wire_size", transfer.WireBytes, "parsed_size", len(page.Data), "warnings", len(page.Warnings), "messages", len(page.Messages))
 
-	return parsed, nil
+	return page, nil
+}
+
+// GetJobResultsFrom requests up to count results starting at result number start. With preview set
+// it reads the results a running job has found so far, letting a job be followed while it runs.
+func (c *Client) GetJobResultsFrom(sid string, start, count int, outputMode string, preview bool, filter ResultsFilter) (ResultsPage, error) {
+	path := c.searchPath(filter.endpoint(sid, preview))
+	queryParams := map[string]string{
+		"count":       fmt.Sprintf("%d", count),
+		"offset":      fmt.Sprintf("%d", start),
+		"output_mode": requestOutputMode(outputMode),
+	}
+	path = filter.apply(path, queryParams)
+
+	response, transfer, err := c.get(path, queryParams)
+	if err != nil {
+		return ResultsPage{}, err
+	}
+
+	page := parseResultsResponse(response, outputMode, start)
+	page.Transfer = transfer
+	slog.Debug("Job results page processed", "sid", sid, "start", start, "preview", preview, "response_size", len(response), "parsed_size", len(page.Data))
+
+	return page, nil
 }
 
 // GetJobStatus retrieves the status of a search job
 func (c *Client) GetJobStatus(sid string) (SearchJobContent, error) {
-	path := fmt.Sprintf("/services/search/v2/jobs/%s", sid)
+	entry, err := c.GetJob(sid)
+	return entry.Content, err
+}
+
+// GetJob retrieves a search job with its status and ownership
+func (c *Client) GetJob(sid string) (SearchJobEntry, error) {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s", sid))
 
 	queryParams := map[string]string{
 		"output_mode": "json",
@@ -84,23 +182,134 @@ func (c *Client) GetJobStatus(sid string) (SearchJobContent, error) {
 
 	response, err := c.Get(path, queryParams)
 	if err != nil {
-		return SearchJobContent{}, err
+		return SearchJobEntry{}, err
 	}
 
 	var job SplunkSearchResponse
 	err = json.Unmarshal([]byte(response), &job)
 	if err != nil {
-		return SearchJobContent{}, fmt.Errorf("error unmarshalling job status: %w", err)
+		return SearchJobEntry{}, fmt.Errorf("error unmarshalling job status: %w", err)
 	}
 
 	if len(job.Entry) == 0 {
-		return SearchJobContent{}, fmt.Errorf("no job found for sid %s", sid)
+		return SearchJobEntry{}, fmt.Errorf("no job found for sid %s", sid)
 	}
 
-	return job.Entry[0].Content, nil
+	return job.Entry[0], nil
 }
 
-func (c *Client) NewSearchJob(search string, earliest string, latest string) (string, error) {
+// GetFieldSummary retrieves the distribution of a field's values in a job. Splunk only keeps summaries
+// for jobs dispatched with status buckets, so the summary of other jobs is empty.
+func (c *Client) GetFieldSummary(sid string, field string) (FieldSummary, error) {
+	fields, err := c.getFieldSummaries(sid, map[string]string{"f": field, "top_count": "100"})
+	return fields[field], err
+}
+
+// GetFieldSummaries retrieves the summary of every field of a job with its most common values, keyed
+// by field name. Like GetFieldSummary, it is empty for jobs without status buckets.
+func (c *Client) GetFieldSummaries(sid string, topCount int) (map[string]FieldSummary, error) {
+	return c.getFieldSummaries(sid, map[string]string{"top_count": strconv.Itoa(topCount)})
+}
+
+func (c *Client) getFieldSummaries(sid string, queryParams map[string]string) (map[string]FieldSummary, error) {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s/summary", sid))
+	queryParams["output_mode"] = "json"
+
+	response, err := c.Get(path, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary struct {
+		Fields map[string]FieldSummary `json:"fields"`
+	}
+	err = json.Unmarshal([]byte(response), &summary)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling field summary: %w", err)
+	}
+
+	return summary.Fields, nil
+}
+
+// JobFilter narrows down the jobs returned by ListSearchJobs. Empty fields match everything.
+type JobFilter struct {
+	Owner          string
+	App            string
+	DispatchState  string
+	OlderThan      time.Duration // only match jobs dispatched at least this long ago
+	Label          string        // only match jobs dispatched with this --label
+	Mine           bool          // only match jobs owned by the authenticated user
+	Running        bool          // only match jobs that aren't done
+	SearchContains string        // only match jobs whose search contains this, ignoring case
+}
+
+func (f JobFilter) matches(entry SearchJobEntry, now time.Time) bool {
+	if f.Owner != "" && entry.ACL.Owner != f.Owner {
+		return false
+	}
+	if f.App != "" && entry.ACL.App != f.App {
+		return false
+	}
+	if f.DispatchState != "" && !strings.EqualFold(entry.Content.DispatchState, f.DispatchState) {
+		return false
+	}
+	if f.OlderThan > 0 && now.Sub(entry.Published) < f.OlderThan {
+		return false
+	}
+	if f.Label != "" && JobLabel(entry.Content.SID) != f.Label {
+		return false
+	}
+	if f.Running && entry.Content.IsDone {
+		return false
+	}
+	// The entry is named after the job's search
+	if f.SearchContains != "" && !strings.Contains(strings.ToLower(entry.Name), strings.ToLower(f.SearchContains)) {
+		return false
+	}
+	return true
+}
+
+// ListSearchJobs retrieves all search jobs visible to the current user that match the filter
+func (c *Client) ListSearchJobs(filter JobFilter) ([]SearchJobEntry, error) {
+	path := c.searchPath("search/v2/jobs")
+	queryParams := map[string]string{
+		"output_mode": "json",
+		"count":       "0",
+	}
+
+	if filter.Mine {
+		context, err := c.GetCurrentContext()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the current user: %w", err)
+		}
+		filter.Owner = context.Username
+	}
+
+	response, err := c.Get(path, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs SplunkSearchResponse
+	err = json.Unmarshal([]byte(response), &jobs)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling job list: %w", err)
+	}
+
+	now := time.Now()
+	var matched []SearchJobEntry
+	for _, entry := range jobs.Entry {
+		if filter.matches(entry, now) {
+			matched = append(matched, entry)
+		}
+	}
+	slog.Debug("Listed search jobs", "total", len(jobs.Entry), "matched", len(matched))
+
+	return matched, nil
+}
+
+// NormalizeSearch prepends "search " to searches that don't start with a command, as Splunk expects
+func NormalizeSearch(search string) string {
 	// Check if search matches the regex pattern \s*(\||search ).*
 	// If not, prepend "search " to the search string
 	pattern := regexp.MustCompile(`^\s*(\||search ).*`)
@@ -108,23 +317,104 @@ func (c *Client) NewSearchJob(search string, earliest string, latest string) (st
 		search = "search " + search
 		slog.Debug("Prepended 'search ' to search string", "modified_search", search)
 	}
+	return search
+}
 
-	slog.Debug("Creating new search job", "search", search, "earliest", earliest, "latest", latest)
+func (c *Client) NewSearchJob(search string, earliest string, latest string) (string, error) {
+	return c.NewSearchJobWithID(search, earliest, latest, "")
+}
 
-	path := "/services/search/jobs"
-	queryParams := map[string]string{
-		"output_mode": "json",
+// validJobID matches the characters Splunk allows in search IDs
+var validJobID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// labeledJobID matches the search IDs made by LabeledJobID
+var labeledJobID = regexp.MustCompile(`^(.+)_\d{8}T\d{6}_[0-9a-f]{6}$`)
+
+// LabeledJobID returns a new search ID starting with label, so the job can be found among other
+// searches by its label
+func LabeledJobID(label string, now time.Time) (string, error) {
+	if !validJobID.MatchString(label) {
+		return "", fmt.Errorf("invalid label %q, only letters, digits, '_', '.' and '-' are allowed", label)
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_%s_%x", label, now.UTC().Format("20060102T150405"), suffix), nil
+}
+
+// JobLabel returns the label of a search ID made by LabeledJobID, or an empty string for other search IDs
+func JobLabel(sid string) string {
+	match := labeledJobID.FindStringSubmatch(sid)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// Dispatch parameters spldl sets from its own options, which --dispatch can't replace
+var reservedDispatchParams = map[string]string{
+	"search":        "the search",
+	"earliest_time": "--earliest",
+	"latest_time":   "--latest",
+	"id":            "--job-id",
+	"output_mode":   "the output file's format",
+	"exec_mode":     "--oneshot",
+}
+
+// ParseDispatchParams parses key=value parameters to create jobs with. Keys spldl sets from other
+// options are rejected.
+func ParseDispatchParams(params []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(params))
+	for _, param := range params {
+		key, value, ok := strings.Cut(param, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid dispatch parameter %q, expected key=value", param)
+		}
+		if option, reserved := reservedDispatchParams[key]; reserved {
+			return nil, fmt.Errorf("dispatch parameter %s is set by %s", key, option)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// dispatchValues returns the parameters jobs are created with as form values
+func dispatchValues(params map[string]string) url.Values {
+	values := make(url.Values, len(params))
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return values
+}
+
+// NewSearchJobWithID creates a search job with the given search ID, or a generated one when id is empty
+func (c *Client) NewSearchJobWithID(search string, earliest string, latest string, id string) (string, error) {
+	if id != "" && !validJobID.MatchString(id) {
+		return "", fmt.Errorf("invalid job id %q, only letters, digits, '_', '.' and '-' are allowed", id)
 	}
+	search = c.DispatchedSearch(search)
+
+	slog.Debug("Creating new search job", "search", search, "earliest", earliest, "latest", latest)
 
+	// Every parameter goes into the body, which unlike the URL has no length limit for long searches
 	data := url.Values{
 		"search":        {search},
 		"earliest_time": {earliest},
 		"latest_time":   {latest},
 		"rf":            {"*"},
-		"timeout":       {"3600"},
+		"timeout":       {strconv.Itoa(int(c.jobTTL.Seconds()))},
+		"output_mode":   {"json"},
+	}
+	for key, values := range c.dispatch {
+		data[key] = values
+	}
+	if id != "" {
+		data.Set("id", id)
 	}
 
-	response, err := c.Post(path, "application/x-www-form-urlencoded", queryParams, []byte(data.Encode()))
+	response, err := c.Post(c.searchPath("search/jobs"), "application/x-www-form-urlencoded", nil, []byte(data.Encode()))
 	if err != nil {
 		return "", err
 	}
@@ -139,29 +429,224 @@ func (c *Client) NewSearchJob(search string, earliest string, latest string) (st
 	return job.SID, nil
 }
 
+// OneshotSearch runs a search and returns its results in the response, without a job to poll,
+// download from or delete. Splunk returns at most the [restapi] maxresultrows results (50000 by default).
+func (c *Client) OneshotSearch(search string, earliest string, latest string, outputMode string) (ResultsPage, error) {
+	search = c.DispatchedSearch(search)
+	slog.Debug("Running oneshot search", "search", search, "earliest", earliest, "latest", latest)
+
+	data := url.Values{
+		"search":        {search},
+		"earliest_time": {earliest},
+		"latest_time":   {latest},
+		"exec_mode":     {"oneshot"},
+		"output_mode":   {requestOutputMode(outputMode)},
+		"count":         {"0"},
+	}
+	for key, values := range c.dispatch {
+		data[key] = values
+	}
+
+	response, err := c.Post(c.searchPath("search/jobs"), "application/x-www-form-urlencoded", nil, []byte(data.Encode()))
+	if err != nil {
+		return ResultsPage{}, err
+	}
+	return parseResultsResponse(response, outputMode, 0), nil
+}
+
+// DispatchSearchJob creates a search job with the given id unless that job already exists, so that a
+// retried run waits on the original job instead of dispatching a duplicate. A failed job is replaced.
+// The returned bool reports whether an existing job was reused.
+func (c *Client) DispatchSearchJob(search string, earliest string, latest string, id string) (string, bool, error) {
+	entry, err := c.GetJob(id)
+	var httpErr *HTTPError
+	switch {
+	case err == nil:
+		// The name of a job entry is its search, which another run commented with its own correlation ID
+		if strings.TrimSpace(withoutRunComment(entry.Name)) != strings.TrimSpace(NormalizeSearch(search)) {
+			return "", false, fmt.Errorf("job %s already exists for a different search: %s", id, entry.Name)
+		}
+		if !entry.Content.IsFailed {
+			slog.Debug("Reusing existing search job", "sid", id, "dispatch_state", entry.Content.DispatchState)
+			return id, true, nil
+		}
+		slog.Debug("Replacing failed search job", "sid", id)
+		if err := c.DeleteSearchJob(id); err != nil {
+			return "", false, fmt.Errorf("failed to delete failed job %s: %w", id, err)
+		}
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+	default:
+		return "", false, fmt.Errorf("failed to look up job %s: %w", id, err)
+	}
+
+	sid, err := c.NewSearchJobWithID(search, earliest, latest, id)
+	return sid, false, err
+}
+
+// WaitUntilJobIsDone polls the job until it is done, or until the client's context is canceled. Jobs
+// waited on at the same time are polled together. A job that fails, is paused or whose search process
+// dies returns a *JobFailedError, and one that isn't done within the client's wait timeout a
+// *JobTimeoutError.
 func (c *Client) WaitUntilJobIsDone(sid string) error {
 	slog.Debug("Waiting for job to complete", "sid", sid)
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
+	return c.poller.wait(c, sid)
+}
 
-	for range ticker.C {
+// WaitForResults polls the job like WaitUntilJobIsDone, but finalizes it once it has found at least
+// count results, so a broad search stops early. The returned bool reports whether the job was
+// finalized, in which case it may hold somewhat more than count results.
+func (c *Client) WaitForResults(sid string, count int) (bool, error) {
+	slog.Debug("Waiting for job results", "sid", sid, "count", count)
+	var deadline time.Time
+	if c.waitTimeout > 0 {
+		deadline = time.Now().Add(c.waitTimeout)
+	}
+
+	interval := c.poller.interval
+	for {
 		status, err := c.GetJobStatus(sid)
 		if err != nil {
-			return fmt.Errorf("failed to get job status: %w", err)
+			return false, fmt.Errorf("failed to get job status: %w", err)
+		}
+		if err := jobFailure(sid, status); err != nil {
+			return false, err
+		}
+		if status.IsDone {
+			return false, nil
+		}
+		if status.ResultCount >= count {
+			slog.Info("Job found enough results, finalizing it", "sid", sid, "results", status.ResultCount, "count", count)
+			if err := c.FinalizeSearchJob(sid); err != nil {
+				return false, fmt.Errorf("failed to finalize job: %w", err)
+			}
+			return true, c.WaitUntilJobIsDone(sid)
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return false, &JobTimeoutError{SID: sid, Timeout: c.waitTimeout}
 		}
 
-		slog.Debug("Job status check", "sid", sid, "is_done", status.IsDone, "dispatch_state", status.DispatchState, "done_progress", status.DoneProgress)
+		select {
+		case <-time.After(interval):
+		case <-c.Context().Done():
+			return false, fmt.Errorf("stopped waiting for job %s: %w", sid, c.Context().Err())
+		}
+		interval = min(interval*2, c.poller.maxInterval)
+	}
+}
 
-		if status.IsDone {
-			slog.Debug("Job completed successfully", "sid", sid)
-			return nil
+// CountJobResults recounts the results of a finished job server-side by running | loadjob <sid> | stats count.
+// The count search is dispatched without the client's dispatch parameters, since one such as
+// max_count or sample_ratio would change the count.
+func (c *Client) CountJobResults(sid string) (int, error) {
+	c = c.withoutDispatch()
+	countSID, err := c.NewSearchJob(fmt.Sprintf("| loadjob %s | stats count", sid), "0", "now")
+	if err != nil {
+		return 0, fmt.Errorf("failed to dispatch count search: %w", err)
+	}
+	defer func() {
+		if err := c.DeleteSearchJob(countSID); err != nil {
+			slog.Debug("Failed to delete count search job", "sid", countSID, "error", err)
 		}
+	}()
+
+	err = c.WaitUntilJobIsDone(countSID)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+
+	page, err := c.GetJobResults(countSID, 1, 0, "csv", ResultsFilter{})
+	if err != nil {
+		return 0, err
+	}
+	count, err := parseCount(page.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	slog.Debug("Counted job results", "sid", sid, "count", count)
+	return count, nil
+}
+
+// EstimateEventCount counts the events indexes hold in a time range with tstats, which only reads
+// index metadata. Without indexes, the user's default indexes are counted. It's an upper bound of
+// the events a search over the indexes reads.
+func (c *Client) EstimateEventCount(indexes []string, earliest, latest string) (int, error) {
+	search := "| tstats count"
+	if len(indexes) > 0 {
+		terms := make([]string, len(indexes))
+		for i, index := range indexes {
+			terms[i] = "index=" + strconv.Quote(index)
+		}
+		search += " where " + strings.Join(terms, " OR ")
+	}
+
+	page, err := c.OneshotSearch(search, earliest, latest, "csv")
+	if err != nil {
+		return 0, fmt.Errorf("failed to run estimate search: %w", err)
+	}
+	count, err := parseCount(page.Data)
+	if err != nil {
+		return 0, err
+	}
+	slog.Debug("Estimated event count", "indexes", indexes, "earliest", earliest, "latest", latest, "count", count)
+	return count, nil
+}
+
+// parseCount parses the CSV results of a search ending with stats count
+func parseCount(response string) (int, error) {
+	// The response is a header line followed by the count
+	lines := strings.Fields(response)
+	if len(lines) != 2 {
+		return 0, fmt.Errorf("unexpected count search response %q", response)
+	}
+	count, err := strconv.Atoi(strings.Trim(lines[1], `"`))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected count search response %q", response)
+	}
+	return count, nil
+}
+
+// controlSearchJob runs one of the actions of the job's control endpoint
+func (c *Client) controlSearchJob(sid, action string) error {
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s/control", sid))
+
+	queryParams := map[string]string{
+		"output_mode": "json",
+	}
+	data := url.Values{"action": {action}}
+
+	_, err := c.Post(path, "application/x-www-form-urlencoded", queryParams, []byte(data.Encode()))
+	return err
+}
+
+// FinalizeSearchJob stops a running job early, keeping the results found so far. The job is done once
+// Splunk has finalized it.
+func (c *Client) FinalizeSearchJob(sid string) error {
+	return c.controlSearchJob(sid, "finalize")
+}
+
+// CancelSearchJob stops a running job and has Splunk delete it along with its results
+func (c *Client) CancelSearchJob(sid string) error {
+	return c.controlSearchJob(sid, "cancel")
+}
+
+// PauseSearchJob suspends a running job until it's unpaused
+func (c *Client) PauseSearchJob(sid string) error {
+	return c.controlSearchJob(sid, "pause")
+}
+
+// UnpauseSearchJob resumes a paused job
+func (c *Client) UnpauseSearchJob(sid string) error {
+	return c.controlSearchJob(sid, "unpause")
+}
+
+// TouchSearchJob resets the job's TTL, so Splunk keeps it for another TTL from now
+func (c *Client) TouchSearchJob(sid string) error {
+	return c.controlSearchJob(sid, "touch")
 }
 
 func (c *Client) DeleteSearchJob(sid string) error {
-	path := fmt.Sprintf("/services/search/v2/jobs/%s", sid)
+	path := c.searchPath(fmt.Sprintf("search/v2/jobs/%s", sid))
 
 	queryParams := map[string]string{
 		"output_mode": "json",
@@ -1,10 +1,13 @@
 package splunkclient
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,6 +54,15 @@ func assertJobContentEqual(t *testing.T, expected, actual SearchJobContent) {
 		if actual.RunDuration != expected.RunDuration {
 			t.Errorf("  RunDuration: expected %f, got %f", expected.RunDuration, actual.RunDuration)
 		}
+		if actual.ScanCount != expected.ScanCount {
+			t.Errorf("  ScanCount: expected %d, got %d", expected.ScanCount, actual.ScanCount)
+		}
+		if actual.DiskUsage != expected.DiskUsage {
+			t.Errorf("  DiskUsage: expected %d, got %d", expected.DiskUsage, actual.DiskUsage)
+		}
+		if actual.TTL != expected.TTL {
+			t.Errorf("  TTL: expected %d, got %d", expected.TTL, actual.TTL)
+		}
 	}
 }
 
@@ -120,8 +132,635 @@ func TestJobStatus(t *testing.T) {
 		EventCount:          154569,
 		EventAvailableCount: 0,
 		RunDuration:         0.522,
+		ScanCount:           154569,
+		DiskUsage:           3768320,
+		TTL:                 86400,
+		Messages:            []ResultsMessage{},
 	}
 
 	// Make sure unmarshalling works as intended
 	assertJobContentEqual(t, expected, jobStatus)
 }
+
+func TestListSearchJobs(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/services/authentication/current-context" {
+			w.Write([]byte(`{"entry": [{"name": "context", "content": {"username": "analyst", "roles": ["user"]}}]}`))
+			return
+		}
+		if r.Method != "GET" || r.URL.Path != "/services/search/v2/jobs" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if count := r.URL.Query().Get("count"); count != "0" {
+			t.Errorf("Expected count=0, got %s", count)
+		}
+
+		data, err := os.ReadFile("testdata/jobs_list.json")
+		if err != nil {
+			t.Fatalf("Failed to read test data: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{
+		Auth: config.AuthConfig{
+			Type:  config.AuthToken,
+			Token: "testtoken",
+		},
+	})
+	client.baseURL = testServer.URL
+
+	tests := []struct {
+		name         string
+		filter       JobFilter
+		expectedSIDs []string
+	}{
+		{
+			name:         "no filter",
+			filter:       JobFilter{},
+			expectedSIDs: []string{"1756064805.1039", "1756172871.1180", "scheduler__admin__es__RMD5abc_at_1756170000_42", "auth_export_20250826T020000_3fa9c1"},
+		},
+		{
+			name:         "owner filter",
+			filter:       JobFilter{Owner: "admin"},
+			expectedSIDs: []string{"1756064805.1039", "scheduler__admin__es__RMD5abc_at_1756170000_42"},
+		},
+		{
+			name:         "app and state filter",
+			filter:       JobFilter{App: "search", DispatchState: "running"},
+			expectedSIDs: []string{"1756172871.1180"},
+		},
+		{
+			name:         "age filter",
+			filter:       JobFilter{OlderThan: time.Since(time.Date(2025, 8, 25, 0, 0, 0, 0, time.UTC))},
+			expectedSIDs: []string{"1756064805.1039"},
+		},
+		{
+			name:         "label filter",
+			filter:       JobFilter{Label: "auth_export"},
+			expectedSIDs: []string{"auth_export_20250826T020000_3fa9c1"},
+		},
+		{
+			name:         "mine filter",
+			filter:       JobFilter{Mine: true},
+			expectedSIDs: []string{"1756172871.1180"},
+		},
+		{
+			name:         "running filter",
+			filter:       JobFilter{Running: true},
+			expectedSIDs: []string{"1756172871.1180"},
+		},
+		{
+			name:         "search filter",
+			filter:       JobFilter{SearchContains: "INDEX=_internal"},
+			expectedSIDs: []string{"1756064805.1039"},
+		},
+		{
+			name:         "label prefix doesn't match",
+			filter:       JobFilter{Label: "auth"},
+			expectedSIDs: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := client.ListSearchJobs(tt.filter)
+			if err != nil {
+				t.Fatalf("ListSearchJobs returned an error: %v", err)
+			}
+
+			var sids []string
+			for _, job := range jobs {
+				sids = append(sids, job.Content.SID)
+			}
+			if !reflect.DeepEqual(sids, tt.expectedSIDs) {
+				t.Errorf("Expected SIDs %v, got %v", tt.expectedSIDs, sids)
+			}
+		})
+	}
+}
+
+func TestParseJSONResponseMalformed(t *testing.T) {
+	tests := []struct {
+		name            string
+		response        string
+		expectedData    string
+		expectedSkipped int
+	}{
+		{
+			name:            "corrupted record",
+			response:        `{"preview":false,"results":[{"_raw":"foo"},{"_raw":"b"ar"},{"_raw":"baz {x}"}]}`,
+			expectedData:    "{\"_raw\":\"foo\"}\n{\"_raw\":\"baz {x}\"}\n",
+			expectedSkipped: 1,
+		},
+		{
+			name:            "truncated payload",
+			response:        `{"preview":false,"results":[{"_raw":"foo"},{"_raw":"with \"quotes\" and }"},{"_raw":"ba`,
+			expectedData:    "{\"_raw\":\"foo\"}\n{\"_raw\":\"with \\\"quotes\\\" and }\"}\n",
+			expectedSkipped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := parseJSONResponse(tt.response)
+			if !page.Malformed {
+				t.Error("Expected page to be marked as malformed")
+			}
+			if page.Data != tt.expectedData {
+				t.Errorf("Data mismatch:\nExpected: %q\nGot:      %q", tt.expectedData, page.Data)
+			}
+			if page.Skipped != tt.expectedSkipped {
+				t.Errorf("Expected %d skipped results, got %d", tt.expectedSkipped, page.Skipped)
+			}
+		})
+	}
+}
+
+func TestParseJSONResponseKeepsFieldOrder(t *testing.T) {
+	response := `{"preview":false,"fields":[{"name":"_time"},{"name":"host"},{"name":"_raw"}],"results":[
+	{"_time": "2025-08-26T01:47:51.000+00:00", "host": "web01", "_raw": "a <b> & c", "tag": ["x", "y"]},
+	{"zeta":"1","alpha":{"nested":true}}
+]}`
+	expected := "{\"_time\":\"2025-08-26T01:47:51.000+00:00\",\"host\":\"web01\",\"_raw\":\"a <b> & c\",\"tag\":[\"x\",\"y\"]}\n{\"zeta\":\"1\",\"alpha\":{\"nested\":true}}\n"
+
+	page := parseJSONResponse(response)
+	if page.Malformed || page.Skipped != 0 {
+		t.Fatalf("Expected a clean page, got malformed=%v skipped=%d", page.Malformed, page.Skipped)
+	}
+	if page.Data != expected {
+		t.Errorf("Data mismatch:\nExpected: %q\nGot:      %q", expected, page.Data)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"messages":[{"type":"FATAL","text":"Unknown sid."}]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	_, err := client.GetJobStatus("1756064805.1039")
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected an HTTPError, got %v", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code 404, got %d", httpErr.StatusCode)
+	}
+	if httpErr.Message != "Unknown sid." {
+		t.Errorf("Expected message %q, got %q", "Unknown sid.", httpErr.Message)
+	}
+}
+
+func TestDispatchSearchJob(t *testing.T) {
+	const id = "nightly_2025-08-26"
+
+	tests := []struct {
+		name           string
+		existing       string // the job entry returned for id, empty when it doesn't exist
+		expectedReused bool
+		expectedCalls  []string
+		expectedError  string
+	}{
+		{
+			name:          "dispatches a new job",
+			expectedCalls: []string{"GET", "POST"},
+		},
+		{
+			name:           "reuses a running job",
+			existing:       `{"name": "search index=main", "content": {"sid": "` + id + `", "dispatchState": "RUNNING"}}`,
+			expectedReused: true,
+			expectedCalls:  []string{"GET"},
+		},
+		{
+			name:           "reuses a job dispatched by another run",
+			existing:       `{"name": "search index=main ` + "```spldl run_id=0f8b2c4e-1a2b-4c3d-8e9f-0123456789ab```" + `", "content": {"sid": "` + id + `", "dispatchState": "DONE", "isDone": true}}`,
+			expectedReused: true,
+			expectedCalls:  []string{"GET"},
+		},
+		{
+			name:          "replaces a failed job",
+			existing:      `{"name": "search index=main", "content": {"sid": "` + id + `", "dispatchState": "FAILED", "isFailed": true}}`,
+			expectedCalls: []string{"GET", "DELETE", "POST"},
+		},
+		{
+			name:          "refuses a job of another search",
+			existing:      `{"name": "search index=other", "content": {"sid": "` + id + `", "dispatchState": "DONE"}}`,
+			expectedCalls: []string{"GET"},
+			expectedError: "already exists for a different search",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.Method)
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/services/search/v2/jobs/"+id:
+					if tt.existing == "" {
+						w.WriteHeader(http.StatusNotFound)
+						w.Write([]byte(`{"messages":[{"type":"FATAL","text":"Unknown sid."}]}`))
+						return
+					}
+					w.Write([]byte(`{"entry": [` + tt.existing + `]}`))
+				case r.Method == "DELETE" && r.URL.Path == "/services/search/v2/jobs/"+id:
+					w.Write([]byte("{}"))
+				case r.Method == "POST" && r.URL.Path == "/services/search/jobs":
+					if got := r.FormValue("id"); got != id {
+						t.Errorf("Expected id=%s, got %q", id, got)
+					}
+					w.Write([]byte(`{"sid": "` + id + `"}`))
+				default:
+					t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer testServer.Close()
+
+			client := NewClient(config.ClientConfig{})
+			client.baseURL = testServer.URL
+
+			sid, reused, err := client.DispatchSearchJob("index=main", "-24h", "now", id)
+			if !reflect.DeepEqual(calls, tt.expectedCalls) {
+				t.Errorf("Expected requests %v, got %v", tt.expectedCalls, calls)
+			}
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DispatchSearchJob returned error: %v", err)
+			}
+			if sid != id || reused != tt.expectedReused {
+				t.Errorf("Expected sid %s reused=%v, got %s reused=%v", id, tt.expectedReused, sid, reused)
+			}
+		})
+	}
+}
+
+func TestLabeledJobID(t *testing.T) {
+	now := time.Date(2025, 8, 26, 2, 0, 0, 0, time.UTC)
+	sid, err := LabeledJobID("auth_export", now)
+	if err != nil {
+		t.Fatalf("LabeledJobID returned error: %v", err)
+	}
+	if !strings.HasPrefix(sid, "auth_export_20250826T020000_") {
+		t.Errorf("Unexpected job id %q", sid)
+	}
+	if label := JobLabel(sid); label != "auth_export" {
+		t.Errorf("Expected label auth_export, got %q", label)
+	}
+	if label := JobLabel("1756064805.1039"); label != "" {
+		t.Errorf("Expected no label for a generated sid, got %q", label)
+	}
+
+	_, err = LabeledJobID("nightly export", now)
+	if err == nil {
+		t.Error("Expected an error for a label with a space")
+	}
+}
+
+func TestWaitUntilJobIsDoneCanceled(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+	}))
+	defer testServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+	client = client.WithContext(ctx)
+
+	err := client.WaitUntilJobIsDone("1756064805.1039")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a canceled error, got %v", err)
+	}
+}
+
+func TestControlSearchJob(t *testing.T) {
+	var actions []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/services/search/v2/jobs/1756064805.1039/control" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		r.ParseForm()
+		actions = append(actions, r.PostForm.Get("action"))
+		w.Write([]byte(`{"messages":[{"type":"INFO","text":"Search job updated."}]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	for _, control := range []func(string) error{client.FinalizeSearchJob, client.CancelSearchJob, client.PauseSearchJob, client.UnpauseSearchJob} {
+		if err := control("1756064805.1039"); err != nil {
+			t.Errorf("Controlling the job returned error: %v", err)
+		}
+	}
+	expected := []string{"finalize", "cancel", "pause", "unpause"}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("Expected actions %v, got %v", expected, actions)
+	}
+}
+
+func TestTouchSearchJob(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/services/search/v2/jobs/1756064805.1039/control":
+			if action := r.PostForm.Get("action"); action != "touch" {
+				t.Errorf("Expected action touch, got %q", action)
+			}
+			w.Write([]byte(`{"messages":[{"type":"INFO","text":"Search job touched."}]}`))
+		case r.Method == "POST" && r.URL.Path == "/services/search/jobs":
+			if timeout := r.PostForm.Get("timeout"); timeout != "14400" {
+				t.Errorf("Expected the job's TTL to be 14400s, got %q", timeout)
+			}
+			w.Write([]byte(`{"sid": "1756064805.1039"}`))
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{JobTTL: 4 * time.Hour})
+	client.baseURL = testServer.URL
+
+	if _, err := client.NewSearchJob("index=main", "-24h", "now"); err != nil {
+		t.Errorf("NewSearchJob returned error: %v", err)
+	}
+	if err := client.TouchSearchJob("1756064805.1039"); err != nil {
+		t.Errorf("TouchSearchJob returned error: %v", err)
+	}
+}
+
+func TestSearchNamespace(t *testing.T) {
+	var paths []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Method == "POST" {
+			w.Write([]byte(`{"sid": "1756064805.1039"}`))
+			return
+		}
+		w.Write([]byte(`{"entry": [{"content": {"sid": "1756064805.1039", "isDone": true}}]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{App: "security"})
+	client.baseURL = testServer.URL
+
+	sid, err := client.NewSearchJob("`failed_logins`", "-24h", "now")
+	if err != nil {
+		t.Fatalf("NewSearchJob returned error: %v", err)
+	}
+	if _, err := client.GetJobStatus(sid); err != nil {
+		t.Fatalf("GetJobStatus returned error: %v", err)
+	}
+	if _, err := client.ListSearchJobs(JobFilter{}); err != nil {
+		t.Fatalf("ListSearchJobs returned error: %v", err)
+	}
+	expected := []string{
+		"POST /servicesNS/nobody/security/search/jobs",
+		"GET /servicesNS/nobody/security/search/v2/jobs/1756064805.1039",
+		"GET /servicesNS/nobody/security/search/v2/jobs",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, paths)
+	}
+
+	if path := NewClient(config.ClientConfig{}).searchPath("search/jobs"); path != "/services/search/jobs" {
+		t.Errorf("Expected the default context without a namespace, got %s", path)
+	}
+}
+
+func TestDispatchParams(t *testing.T) {
+	params, err := ParseDispatchParams([]string{"max_count=1000", "timeout=86400", "workload_pool=exports", "adhoc_search_level=fast"})
+	if err != nil {
+		t.Fatalf("ParseDispatchParams returned error: %v", err)
+	}
+	for _, invalid := range []string{"max_count", "=1", "earliest_time=-1d", "output_mode=csv"} {
+		if _, err := ParseDispatchParams([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		expected := map[string]string{"max_count": "1000", "timeout": "86400", "workload_pool": "exports", "adhoc_search_level": "fast", "rf": "*", "earliest_time": "-24h"}
+		for key, value := range expected {
+			if got := r.PostForm.Get(key); got != value {
+				t.Errorf("Expected %s=%s, got %q", key, value, got)
+			}
+		}
+		w.Write([]byte(`{"sid": "1756064805.1039"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{DispatchParams: params})
+	client.baseURL = testServer.URL
+	if _, err := client.NewSearchJob("index=main", "-24h", "now"); err != nil {
+		t.Errorf("NewSearchJob returned error: %v", err)
+	}
+}
+
+func TestCountJobResultsWithoutDispatchParams(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			r.ParseForm()
+			if r.PostForm.Has("max_count") || r.PostForm.Has("sample_ratio") {
+				t.Errorf("Expected the count search without dispatch parameters, got %v", r.PostForm)
+			}
+			w.Write([]byte(`{"sid": "count.1"}`))
+		case r.Method == "DELETE":
+		case strings.HasSuffix(r.URL.Path, "/results"):
+			w.Write([]byte("count\n42\n"))
+		default:
+			w.Write([]byte(`{"entry": [{"content": {"sid": "count.1", "isDone": true}}]}`))
+		}
+	}))
+	defer testServer.Close()
+
+	params, err := ParseDispatchParams([]string{"max_count=10", "sample_ratio=100"})
+	if err != nil {
+		t.Fatalf("ParseDispatchParams returned error: %v", err)
+	}
+	client := NewClient(config.ClientConfig{DispatchParams: params})
+	client.baseURL = testServer.URL
+	client.poller.interval = 10 * time.Millisecond
+
+	count, err := client.CountJobResults("1756064805.1039")
+	if err != nil || count != 42 {
+		t.Errorf("CountJobResults returned %d, %v", count, err)
+	}
+}
+
+func TestGetJobResultsFilter(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !reflect.DeepEqual(query["f"], []string{"host", "_raw"}) || query.Get("search") != "search error OR warn" || query.Get("output_mode") != "csv" || query.Get("offset") != "10" {
+			t.Errorf("Unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte("host,_raw\nweb01,a\n"))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	if _, err := client.GetJobResults("1756064805.1039", 10, 1, "csv", ResultsFilter{Fields: []string{"host", "_raw"}, Search: "error OR warn"}); err != nil {
+		t.Errorf("GetJobResults returned error: %v", err)
+	}
+}
+
+func TestGetJobEvents(t *testing.T) {
+	var paths []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("_time,_raw\n2025-08-26T02:00:00.000+00:00,a\n"))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	events := ResultsFilter{Events: true}
+	if _, err := client.GetJobResults("1756064805.1039", 10, 0, "csv", events); err != nil {
+		t.Errorf("GetJobResults returned error: %v", err)
+	}
+	// A running job's events are read from the same endpoint
+	if _, err := client.GetJobResultsFrom("1756064805.1039", 0, 10, "csv", true, events); err != nil {
+		t.Errorf("GetJobResultsFrom returned error: %v", err)
+	}
+	if _, err := client.GetJobResultsFrom("1756064805.1039", 0, 10, "csv", true, ResultsFilter{}); err != nil {
+		t.Errorf("GetJobResultsFrom returned error: %v", err)
+	}
+	expected := []string{
+		"/services/search/v2/jobs/1756064805.1039/events",
+		"/services/search/v2/jobs/1756064805.1039/events",
+		"/services/search/v2/jobs/1756064805.1039/results_preview",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected requests to %v, got %v", expected, paths)
+	}
+}
+
+func TestPostProcessSearch(t *testing.T) {
+	tests := map[string]string{
+		"error OR warn":              "search error OR warn",
+		"  status>=500 ":             "search status>=500",
+		"| where bytes > 1024":       "where bytes > 1024",
+		"|stats count by host":       "stats count by host",
+		"search sourcetype=access_*": "search sourcetype=access_*",
+	}
+	for filter, expected := range tests {
+		if got := postProcessSearch(filter); got != expected {
+			t.Errorf("postProcessSearch(%q) = %q, expected %q", filter, got, expected)
+		}
+	}
+}
+
+func TestEstimateEventCount(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/services/search/jobs" || r.PostForm.Get("exec_mode") != "oneshot" {
+			t.Errorf("Expected a oneshot search, got %s %s", r.URL.Path, r.PostForm.Get("exec_mode"))
+		}
+		if search := r.PostForm.Get("search"); search != `| tstats count where index="main" OR index="web*"` {
+			t.Errorf("Unexpected search %q", search)
+		}
+		if r.PostForm.Get("earliest_time") != "-30d" || r.PostForm.Get("latest_time") != "now" {
+			t.Errorf("Unexpected time range %s to %s", r.PostForm.Get("earliest_time"), r.PostForm.Get("latest_time"))
+		}
+		w.Write([]byte("count\n\"1234567\"\n"))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	count, err := client.EstimateEventCount([]string{"main", "web*"}, "-30d", "now")
+	if err != nil {
+		t.Fatalf("EstimateEventCount returned error: %v", err)
+	}
+	if count != 1234567 {
+		t.Errorf("Expected 1234567 events, got %d", count)
+	}
+}
+
+func TestLongSearchInBody(t *testing.T) {
+	// Inline lookups can make a search longer than any proxy accepts in a URL
+	search := "search index=main | eval risk=case(" + strings.Repeat(`user="someone", 1, `, 4000) + "true(), 0)"
+	if len(search) < 64*1024 {
+		t.Fatalf("Expected a search over 64KB, got %d bytes", len(search))
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path == "/services/search/v2/jobs/1756064805.1039/results" {
+			// The results endpoints only take GET, so the post filter stays in the URL
+			if r.Method != "GET" || r.URL.Query().Get("search") != "search host=web01" {
+				t.Errorf("Expected a GET request with the post filter in the URL, got %s with %q", r.Method, r.URL.Query().Get("search"))
+			}
+			w.Write([]byte("count\n1\n"))
+			return
+		}
+		if r.Method != "POST" {
+			t.Errorf("Expected a POST request to %s, got %s", r.URL.Path, r.Method)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("Expected no query parameters for %s, got %d bytes", r.URL.Path, len(r.URL.RawQuery))
+		}
+		switch r.URL.Path {
+		case "/services/search/jobs":
+			if r.PostForm.Get("search") != search {
+				t.Errorf("Expected the search in the body, got %d bytes", len(r.PostForm.Get("search")))
+			}
+			if r.PostForm.Get("exec_mode") == "oneshot" {
+				w.Write([]byte("count\n1\n"))
+				return
+			}
+			w.Write([]byte(`{"sid":"1756064805.1039"}`))
+		case "/services/search/v2/jobs/export":
+			if r.PostForm.Get("search") != search {
+				t.Errorf("Expected the search in the body, got %d bytes", len(r.PostForm.Get("search")))
+			}
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	sid, err := client.NewSearchJob(search, "-1h", "now")
+	if err != nil || sid != "1756064805.1039" {
+		t.Fatalf("NewSearchJob returned %q, %v", sid, err)
+	}
+	if _, err := client.OneshotSearch(search, "-1h", "now", "csv"); err != nil {
+		t.Errorf("OneshotSearch returned error: %v", err)
+	}
+	stream, err := client.ExportSearch(search, "-1h", "now", "csv")
+	if err != nil {
+		t.Errorf("ExportSearch returned error: %v", err)
+	} else {
+		stream.Close()
+	}
+	if _, err := client.GetJobResults(sid, 100, 0, "csv", ResultsFilter{Search: "host=web01"}); err != nil {
+		t.Errorf("GetJobResults returned error: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package splunkclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SearchLimits contains the server-side limits that can silently truncate an export
+type SearchLimits struct {
+	Roles         []string
+	MaxResultRows int           // most rows a single results request returns, 0 if unknown
+	SrchMaxTime   time.Duration // how long a search may run before it is finalized, 0 if unlimited
+	SrchTimeWin   time.Duration // widest time range a search may cover, 0 if unlimited
+	MaxCount      int           // most results a job keeps, 0 if unknown
+}
+
+// getEntryContent fetches path and unmarshals the content of its first entry into content
+func getEntryContent[T any](c *Client, path string, content *T) error {
+	queryParams := map[string]string{
+		"output_mode": "json",
+	}
+
+	response, err := c.Get(path, queryParams)
+	if err != nil {
+		return err
+	}
+
+	var entries entryResponse[T]
+	err = json.Unmarshal([]byte(response), &entries)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling %s: %w", path, err)
+	}
+	if len(entries.Entry) == 0 {
+		return fmt.Errorf("no entry found at %s", path)
+	}
+
+	*content = entries.Entry[0].Content
+	return nil
+}
+
+// GetCurrentContext retrieves the user the client is authenticated as
+func (c *Client) GetCurrentContext() (CurrentContext, error) {
+	var context CurrentContext
+	err := getEntryContent(c, "/services/authentication/current-context", &context)
+	return context, err
+}
+
+// GetRole retrieves the search restrictions of a role
+func (c *Client) GetRole(name string) (RoleContent, error) {
+	var role RoleContent
+	err := getEntryContent(c, "/services/authorization/roles/"+url.PathEscape(name), &role)
+	return role, err
+}
+
+// GetRoles retrieves the given roles along with the roles they import. Roles the user isn't allowed to read are skipped.
+func (c *Client) GetRoles(names []string) []Role {
+	var roles []Role
+	pending := slices.Clone(names)
+	for i := 0; i < len(pending); i++ {
+		content, err := c.GetRole(pending[i])
+		if err != nil {
+			slog.Debug("Unable to read role", "role", pending[i], "error", err)
+			continue
+		}
+		for _, imported := range content.ImportedRoles {
+			if !slices.Contains(pending, imported) {
+				pending = append(pending, imported)
+			}
+		}
+		roles = append(roles, Role{Name: pending[i], RoleContent: content})
+	}
+	return roles
+}
+
+// GetSearchLimits determines the effective limits of the current user. Lookups the user isn't
+// allowed to perform are skipped, leaving the corresponding limit unset.
+func (c *Client) GetSearchLimits() (SearchLimits, error) {
+	context, err := c.GetCurrentContext()
+	if err != nil {
+		return SearchLimits{}, fmt.Errorf("failed to get current context: %w", err)
+	}
+
+	limits := SearchLimits{Roles: context.Roles}
+
+	// Splunk applies the most permissive value across all of a user's roles, including imported ones
+	var maxTime, timeWin int
+	for i, role := range c.GetRoles(context.Roles) {
+		maxTime = mostPermissive(maxTime, role.SrchMaxTime, i == 0)
+		timeWin = mostPermissive(timeWin, role.SrchTimeWin, i == 0)
+	}
+	limits.SrchMaxTime = time.Duration(maxTime) * time.Second
+	limits.SrchTimeWin = time.Duration(timeWin) * time.Second
+
+	var restapi RestAPILimits
+	err = getEntryContent(c, "/services/configs/conf-limits/restapi", &restapi)
+	if err != nil {
+		slog.Debug("Unable to read restapi limits", "error", err)
+	} else if rows, err := strconv.Atoi(restapi.MaxResultRows); err == nil {
+		limits.MaxResultRows = rows
+	}
+
+	// Jobs keep as many results as limits.conf allows, unless they're dispatched with their own max_count
+	var search SearchLimitsConf
+	err = getEntryContent(c, "/services/configs/conf-limits/search", &search)
+	if err != nil {
+		slog.Debug("Unable to read search limits", "error", err)
+	} else if count, err := strconv.Atoi(search.MaxCount); err == nil {
+		limits.MaxCount = count
+	}
+	if count, err := strconv.Atoi(c.dispatch.Get("max_count")); err == nil {
+		limits.MaxCount = count
+	}
+
+	slog.Debug("Search limits retrieved", "roles", limits.Roles, "max_result_rows", limits.MaxResultRows, "srch_max_time", limits.SrchMaxTime, "srch_time_win", limits.SrchTimeWin, "max_count", limits.MaxCount)
+	return limits, nil
+}
+
+// mostPermissive combines two role limits where anything <= 0 means unlimited
+func mostPermissive(current, limit int, first bool) int {
+	limit = max(limit, 0)
+	if first {
+		return limit
+	}
+	if current == 0 || limit == 0 {
+		return 0
+	}
+	return max(current, limit)
+}
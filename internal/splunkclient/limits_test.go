@@ -0,0 +1,77 @@
+package splunkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestGetSearchLimits(t *testing.T) {
+	responses := map[string]string{
+		"/services/authentication/current-context": `{"entry": [{"name": "context", "content": {"username": "analyst", "roles": ["analyst"]}}]}`,
+		"/services/authorization/roles/analyst":    `{"entry": [{"name": "analyst", "content": {"imported_roles": ["user"], "srchMaxTime": 600, "srchTimeWin": 86400, "srchIndexesAllowed": ["web*"], "srchFilter": "host=web01"}}]}`,
+		"/services/authorization/roles/user":       `{"entry": [{"name": "user", "content": {"imported_roles": [], "srchMaxTime": 3600, "srchTimeWin": -1, "srchIndexesAllowed": ["main"], "srchIndexesDefault": ["main"]}}]}`,
+		"/services/configs/conf-limits/restapi":    `{"entry": [{"name": "restapi", "content": {"maxresultrows": "5000"}}]}`,
+		"/services/configs/conf-limits/search":     `{"entry": [{"name": "search", "content": {"max_count": "500000"}}]}`,
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(response))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{
+		Auth: config.AuthConfig{
+			Type:  config.AuthToken,
+			Token: "testtoken",
+		},
+	})
+	client.baseURL = testServer.URL
+
+	limits, err := client.GetSearchLimits()
+	if err != nil {
+		t.Fatalf("GetSearchLimits returned an error: %v", err)
+	}
+
+	expected := SearchLimits{
+		Roles:         []string{"analyst"},
+		MaxResultRows: 5000,
+		SrchMaxTime:   time.Hour,
+		SrchTimeWin:   0,
+		MaxCount:      500000,
+	}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("Limits mismatch:\nExpected: %+v\nGot:      %+v", expected, limits)
+	}
+
+	// A job dispatched with its own max_count keeps that many results instead
+	client.dispatch = url.Values{"max_count": {"1000"}}
+	limits, err = client.GetSearchLimits()
+	if err != nil {
+		t.Fatalf("GetSearchLimits returned an error: %v", err)
+	}
+	if limits.MaxCount != 1000 {
+		t.Errorf("Expected max_count 1000 from the dispatch parameters, got %d", limits.MaxCount)
+	}
+
+	roles := client.GetRoles([]string{"analyst"})
+	expectedRoles := []Role{
+		{Name: "analyst", RoleContent: RoleContent{ImportedRoles: []string{"user"}, SrchMaxTime: 600, SrchTimeWin: 86400, SrchIndexesAllowed: []string{"web*"}, SrchFilter: "host=web01"}},
+		{Name: "user", RoleContent: RoleContent{ImportedRoles: []string{}, SrchMaxTime: 3600, SrchTimeWin: -1, SrchIndexesAllowed: []string{"main"}, SrchIndexesDefault: []string{"main"}}},
+	}
+	if !reflect.DeepEqual(roles, expectedRoles) {
+		t.Errorf("Roles mismatch:\nExpected: %+v\nGot:      %+v", expectedRoles, roles)
+	}
+}
@@ -0,0 +1,81 @@
+package splunkclient
+
+import (
+	"strings"
+	"sync"
+)
+
+// Parser turns Splunk's responses to results requests into the data written for an output mode
+type Parser interface {
+	// SplunkMode is the output_mode Splunk is asked for
+	SplunkMode() string
+	// Parse converts the response to the request for the offset-th chunk of results
+	Parse(response string, offset int) ResultsPage
+}
+
+// ParserFunc adapts a function converting responses of Splunk's mode to a Parser
+type ParserFunc struct {
+	Mode string
+	Func func(response string, offset int) ResultsPage
+}
+
+func (p ParserFunc) SplunkMode() string { return p.Mode }
+
+func (p ParserFunc) Parse(response string, offset int) ResultsPage { return p.Func(response, offset) }
+
+// StreamParser is a Parser that also reads the stream of the export endpoint, which sends the results
+// line by line as they're found. The lines of parsers that aren't are passed through, a result each.
+type StreamParser interface {
+	Parser
+	// NewStream returns a function taking the lines of one stream in order. It adds what a line holds
+	// to data, or to page's messages, and returns how many results the line completed.
+	NewStream() func(line string, data *strings.Builder, page *ResultsPage) int
+}
+
+// streamParserFunc adds the reading of export streams to a ParserFunc
+type streamParserFunc struct {
+	ParserFunc
+	newStream func() func(line string, data *strings.Builder, page *ResultsPage) int
+}
+
+func (p streamParserFunc) NewStream() func(line string, data *strings.Builder, page *ResultsPage) int {
+	return p.newStream()
+}
+
+var parsers = struct {
+	mu     sync.RWMutex
+	byName map[string]Parser
+}{byName: map[string]Parser{
+	"raw": ParserFunc{"raw", func(response string, offset int) ResultsPage {
+		return ResultsPage{Data: response}
+	}},
+	"csv": streamParserFunc{
+		ParserFunc{"csv", func(response string, offset int) ResultsPage {
+			return ResultsPage{Data: parseCSVResponse(response, offset)}
+		}},
+		newCSVStream,
+	},
+	// Splunk has no ndjson mode, the JSON response is converted by parseJSONResponse
+	"ndjson": streamParserFunc{
+		ParserFunc{"json", func(response string, offset int) ResultsPage {
+			return parseJSONResponse(response)
+		}},
+		func() func(line string, data *strings.Builder, page *ResultsPage) int { return parseExportJSONLine },
+	},
+}}
+
+// RegisterParser makes the output mode name available to results requests, replacing the parser of
+// a mode registered before
+func RegisterParser(name string, parser Parser) {
+	parsers.mu.Lock()
+	defer parsers.mu.Unlock()
+	parsers.byName[name] = parser
+}
+
+// LookupParser returns the parser of the output mode name
+func LookupParser(name string) (Parser, bool) {
+	parsers.mu.RLock()
+	defer parsers.mu.RUnlock()
+	parser, ok := parsers.byName[name]
+	return parser, ok
+}
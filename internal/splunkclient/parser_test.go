@@ -0,0 +1,25 @@
+package splunkclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterParser(t *testing.T) {
+	RegisterParser("upper", ParserFunc{"csv", func(response string, offset int) ResultsPage {
+		return ResultsPage{Data: strings.ToUpper(parseCSVResponse(response, offset))}
+	}})
+
+	if mode := requestOutputMode("upper"); mode != "csv" {
+		t.Errorf("Expected Splunk to be asked for csv, got %s", mode)
+	}
+	if mode := requestOutputMode("ndjson"); mode != "json" {
+		t.Errorf("Expected Splunk to be asked for json, got %s", mode)
+	}
+	if page := parseResultsResponse("host\nweb01\n", "upper", 1); page.Data != "WEB01\n" {
+		t.Errorf("Expected the registered parser to convert the response, got %q", page.Data)
+	}
+	if page := parseResultsResponse("host\nweb01\n", "unknown", 0); page.Data != "" {
+		t.Errorf("Expected no data for an unknown output mode, got %q", page.Data)
+	}
+}
@@ -0,0 +1,311 @@
+package splunkclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the poller checks a job at first, and at most once it has backed off
+const (
+	defaultPollInterval    = 3 * time.Second
+	defaultMaxPollInterval = time.Minute
+)
+
+// How often the progress of a job being waited on is logged
+const pollProgressInterval = 30 * time.Second
+
+// The most jobs looked up by a single request to the jobs list, keeping its URL short
+const maxPolledJobsPerRequest = 50
+
+// poller checks the jobs waited on by WaitUntilJobIsDone, so that waiting on many jobs at once looks
+// them up with one request to the jobs list instead of polling each job on its own. Every job is
+// checked after interval at first, backing off exponentially up to maxInterval while it runs.
+type poller struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	maxInterval time.Duration
+	jobs        map[string]*polledJob
+	wake        chan struct{}      // tells the polling loop that a job was added
+	stop        context.CancelFunc // stops the polling loop, nil while nobody waits
+}
+
+// polledJob is a job being waited on
+type polledJob struct {
+	waiters      []chan error
+	interval     time.Duration // the current delay between checks
+	next         time.Time     // when the job is checked next
+	lastProgress time.Time     // when the job's progress was last logged
+}
+
+func newPoller(interval, maxInterval time.Duration) *poller {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = defaultMaxPollInterval
+	}
+	return &poller{
+		interval:    interval,
+		maxInterval: max(interval, maxInterval),
+		jobs:        make(map[string]*polledJob),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// wait blocks until the job is done, fails, looking it up fails, c's wait timeout passes or c's context
+// is canceled
+func (p *poller) wait(c *Client, sid string) error {
+	ctx := c.Context()
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("stopped waiting for job %s: %w", sid, err)
+	}
+
+	done := make(chan error, 1)
+	p.mu.Lock()
+	job := p.jobs[sid]
+	if job == nil {
+		now := time.Now()
+		job = &polledJob{interval: p.interval, next: now.Add(p.interval), lastProgress: now}
+		p.jobs[sid] = job
+	}
+	job.waiters = append(job.waiters, done)
+	if p.stop == nil {
+		var loopCtx context.Context
+		loopCtx, p.stop = context.WithCancel(context.Background())
+		go p.run(c.WithContext(loopCtx))
+	}
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if c.waitTimeout > 0 {
+		timer := time.NewTimer(c.waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		p.remove(sid, done)
+		return fmt.Errorf("stopped waiting for job %s: %w", sid, ctx.Err())
+	case <-timeout:
+		p.remove(sid, done)
+		return &JobTimeoutError{SID: sid, Timeout: c.waitTimeout}
+	}
+}
+
+// remove forgets a waiter that stopped waiting and stops polling when it was the last one
+func (p *poller) remove(sid string, done chan error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job := p.jobs[sid]
+	if job == nil {
+		return
+	}
+	for i, waiter := range job.waiters {
+		if waiter == done {
+			job.waiters = append(job.waiters[:i], job.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(job.waiters) == 0 {
+		delete(p.jobs, sid)
+	}
+	p.stopIfIdle()
+}
+
+func (p *poller) stopIfIdle() {
+	if len(p.jobs) == 0 && p.stop != nil {
+		p.stop()
+		p.stop = nil
+	}
+}
+
+// due returns the jobs to check now and when the next job is due otherwise. Jobs due within half
+// the base interval are checked along with the others to batch them.
+func (p *poller) due(now time.Time) ([]string, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var sids []string
+	var next time.Time
+	for sid, job := range p.jobs {
+		if !job.next.After(now.Add(p.interval / 2)) {
+			sids = append(sids, sid)
+		} else if next.IsZero() || job.next.Before(next) {
+			next = job.next
+		}
+	}
+	return sids, next
+}
+
+// run looks up the jobs being waited on when they're due until the client's context is canceled
+func (p *poller) run(c *Client) {
+	timer := time.NewTimer(p.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-p.wake:
+		case <-c.Context().Done():
+			return
+		}
+
+		now := time.Now()
+		sids, next := p.due(now)
+		if len(sids) > 0 {
+			statuses, errs := c.jobStatuses(sids)
+			if c.Context().Err() != nil {
+				// Everybody stopped waiting while the jobs were looked up, and a new loop may have started
+				return
+			}
+			p.update(sids, statuses, errs)
+			_, next = p.due(time.Now())
+		}
+		if next.IsZero() {
+			next = now.Add(p.interval)
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+// update notifies the waiters of the jobs that are done or failed and schedules the next check of
+// the others
+func (p *poller) update(sids []string, statuses map[string]SearchJobContent, errs map[string]error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, sid := range sids {
+		job := p.jobs[sid]
+		if job == nil {
+			continue
+		}
+		err, failed := errs[sid]
+		status := statuses[sid]
+		if !failed {
+			slog.Debug("Job status check", "sid", sid, "is_done", status.IsDone, "dispatch_state", status.DispatchState, "done_progress", status.DoneProgress)
+			err = jobFailure(sid, status)
+			if err == nil && !status.IsDone {
+				if now.Sub(job.lastProgress) >= pollProgressInterval {
+					slog.Info("Waiting for job", "sid", sid, "dispatch_state", status.DispatchState,
+						"progress", fmt.Sprintf("%.0f%%", status.DoneProgress*100), "scanned", status.ScanCount, "events", status.EventCount,
+						"run_duration", time.Duration(status.RunDuration*float64(time.Second)).Round(time.Second))
+					job.lastProgress = now
+				}
+				job.interval = min(job.interval*2, p.maxInterval)
+				job.next = now.Add(job.interval)
+				continue
+			}
+			if err == nil {
+				slog.Debug("Job completed successfully", "sid", sid)
+			}
+		} else {
+			err = fmt.Errorf("failed to get job status: %w", err)
+		}
+		for _, done := range job.waiters {
+			done <- err
+		}
+		delete(p.jobs, sid)
+	}
+	p.stopIfIdle()
+}
+
+// jobFailure returns a *JobFailedError when the job failed or won't finish on its own
+func jobFailure(sid string, status SearchJobContent) error {
+	var state string
+	switch {
+	case status.IsFailed || status.DispatchState == "FAILED":
+		state = "FAILED"
+	case status.IsZombie:
+		state = "ZOMBIE"
+	case status.IsPaused || status.DispatchState == "PAUSED":
+		state = "PAUSED"
+	default:
+		return nil
+	}
+
+	var messages []string
+	for _, message := range status.Messages {
+		if message.Type == "ERROR" || message.Type == "FATAL" {
+			messages = append(messages, message.Text)
+		}
+	}
+	return &JobFailedError{SID: sid, DispatchState: state, Messages: messages}
+}
+
+// jobStatuses looks up the status of jobs, a few at a time from the jobs list. Jobs missing from the
+// list, e.g. because the search head doesn't support the filter, are looked up one by one.
+func (c *Client) jobStatuses(sids []string) (map[string]SearchJobContent, map[string]error) {
+	statuses := make(map[string]SearchJobContent, len(sids))
+	errs := make(map[string]error)
+	if len(sids) > 1 {
+		for start := 0; start < len(sids); start += maxPolledJobsPerRequest {
+			batch := sids[start:min(start+maxPolledJobsPerRequest, len(sids))]
+			entries, err := c.listJobsBySID(batch)
+			if err != nil {
+				slog.Debug("Failed to list jobs, looking them up one by one", "jobs", len(batch), "error", err)
+				continue
+			}
+			for _, entry := range entries {
+				statuses[entry.Content.SID] = entry.Content
+			}
+		}
+	}
+
+	for _, sid := range sids {
+		if _, ok := statuses[sid]; ok {
+			continue
+		}
+		status, err := c.GetJobStatus(sid)
+		if err != nil {
+			errs[sid] = err
+			continue
+		}
+		statuses[sid] = status
+	}
+	return statuses, errs
+}
+
+// listJobsBySID retrieves the jobs with the given sids from the jobs list, ignoring any other job the
+// filter matches
+func (c *Client) listJobsBySID(sids []string) ([]SearchJobEntry, error) {
+	terms := make([]string, len(sids))
+	wanted := make(map[string]bool, len(sids))
+	for i, sid := range sids {
+		terms[i] = "sid=" + sid
+		wanted[sid] = true
+	}
+
+	response, err := c.Get(c.searchPath("search/v2/jobs"), map[string]string{
+		"output_mode": "json",
+		"count":       "0",
+		"search":      strings.Join(terms, " OR "),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs SplunkSearchResponse
+	err = json.Unmarshal([]byte(response), &jobs)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling job list: %w", err)
+	}
+
+	var matched []SearchJobEntry
+	for _, entry := range jobs.Entry {
+		if wanted[entry.Content.SID] {
+			matched = append(matched, entry)
+		}
+	}
+	return matched, nil
+}
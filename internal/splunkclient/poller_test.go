@@ -0,0 +1,233 @@
+package splunkclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestWaitUntilJobIsDoneSharesPolls(t *testing.T) {
+	var mu sync.Mutex
+	polls := 0
+	lists := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/services/search/v2/jobs" {
+			t.Errorf("Expected the jobs list to be polled, got %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		polls++
+		search := r.URL.Query().Get("search")
+		if strings.Contains(search, "sid=job.1") && strings.Contains(search, " OR ") {
+			lists++
+		}
+		// Every job is done on the second poll, and the list has a job nobody waits on
+		var entries []string
+		for _, sid := range []string{"job.1", "job.2", "job.3", "other"} {
+			entries = append(entries, fmt.Sprintf(`{"content":{"sid":%q,"isDone":%t}}`, sid, polls > 1))
+		}
+		w.Write([]byte(`{"entry":[` + strings.Join(entries, ",") + `]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+	// Long enough for every waiter to join before the first poll
+	client.poller.interval = 50 * time.Millisecond
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for _, sid := range []string{"job.1", "job.2", "job.3"} {
+		wg.Go(func() {
+			<-start
+			if err := client.WithContext(context.Background()).WaitUntilJobIsDone(sid); err != nil {
+				t.Errorf("WaitUntilJobIsDone(%s) returned error: %v", sid, err)
+			}
+		})
+	}
+	close(start)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if polls > 3 {
+		t.Errorf("Expected the jobs to be polled together, got %d polls", polls)
+	}
+	if lists == 0 {
+		t.Error("Expected the jobs to be looked up with one filtered list request")
+	}
+}
+
+func TestWaitUntilJobIsDoneFallsBackToJob(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs":
+			// A search head that ignores the filter and doesn't list the jobs
+			w.Write([]byte(`{"entry":[]}`))
+		case "/services/search/v2/jobs/job.1":
+			w.Write([]byte(`{"entry":[{"content":{"sid":"job.1","isDone":true}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"messages":[{"type":"FATAL","text":"Unknown sid."}]}`))
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+	client.poller.interval = 10 * time.Millisecond
+
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, sid := range []string{"job.1", "job.2"} {
+		wg.Go(func() {
+			err := client.WaitUntilJobIsDone(sid)
+			mu.Lock()
+			errs[sid] = err
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	if errs["job.1"] != nil {
+		t.Errorf("Expected job.1 to be done, got %v", errs["job.1"])
+	}
+	var httpErr *HTTPError
+	if !errors.As(errs["job.2"], &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404 for job.2, got %v", errs["job.2"])
+	}
+}
+
+func TestWaitUntilJobIsDoneBacksOff(t *testing.T) {
+	var mu sync.Mutex
+	var polls []time.Time
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		polls = append(polls, time.Now())
+		fmt.Fprintf(w, `{"entry":[{"content":{"sid":"job.1","isDone":%t,"doneProgress":0.5}}]}`, len(polls) == 5)
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{PollInterval: 20 * time.Millisecond, MaxPollInterval: 60 * time.Millisecond})
+	client.baseURL = testServer.URL
+
+	start := time.Now()
+	if err := client.WaitUntilJobIsDone("job.1"); err != nil {
+		t.Fatalf("WaitUntilJobIsDone returned error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(polls) != 5 {
+		t.Fatalf("Expected 5 polls, got %d", len(polls))
+	}
+	// The delay doubles up to the maximum; checks may run up to half the first interval early
+	previous := start
+	for i, expected := range []time.Duration{20, 40, 60, 60, 60} {
+		expected *= time.Millisecond
+		if gap := polls[i].Sub(previous); gap < expected-10*time.Millisecond {
+			t.Errorf("Expected poll %d about %s after the previous one, got %s", i+1, expected, gap)
+		}
+		previous = polls[i]
+	}
+}
+
+func TestWaitUntilJobIsDoneFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		state   string
+	}{
+		{"failed", `{"sid":"job.1","isDone":true,"isFailed":true,"dispatchState":"FAILED","messages":[{"type":"FATAL","text":"Error in 'search' command: Unknown search command 'tabel'."},{"type":"INFO","text":"Your timerange was substituted"}]}`, "FAILED"},
+		{"paused", `{"sid":"job.1","dispatchState":"PAUSED","isPaused":true}`, "PAUSED"},
+		{"zombie", `{"sid":"job.1","dispatchState":"RUNNING","isZombie":true}`, "ZOMBIE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"entry":[{"content":` + tt.content + `}]}`))
+			}))
+			defer testServer.Close()
+
+			client := NewClient(config.ClientConfig{PollInterval: 10 * time.Millisecond})
+			client.baseURL = testServer.URL
+
+			err := client.WaitUntilJobIsDone("job.1")
+			var failed *JobFailedError
+			if !errors.As(err, &failed) || failed.DispatchState != tt.state {
+				t.Fatalf("Expected a %s job error, got %v", tt.state, err)
+			}
+			if tt.state == "FAILED" && (len(failed.Messages) != 1 || !strings.Contains(err.Error(), "Unknown search command")) {
+				t.Errorf("Expected the job's fatal message, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWaitUntilJobIsDoneTimeout(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"entry":[{"content":{"sid":"job.1","dispatchState":"RUNNING","doneProgress":0.1}}]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{PollInterval: 10 * time.Millisecond, WaitTimeout: 50 * time.Millisecond})
+	client.baseURL = testServer.URL
+
+	err := client.WaitUntilJobIsDone("job.1")
+	var timeout *JobTimeoutError
+	if !errors.As(err, &timeout) || timeout.SID != "job.1" {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if client.poller.stop != nil {
+		t.Error("Expected polling to stop once nobody waits")
+	}
+}
+
+func TestWaitForResults(t *testing.T) {
+	var mu sync.Mutex
+	polls := 0
+	finalized := false
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/services/search/v2/jobs/job.1/control":
+			finalized = true
+			w.Write([]byte(`{"messages":[]}`))
+		case finalized:
+			w.Write([]byte(`{"entry":[{"content":{"sid":"job.1","isDone":true,"resultCount":1200}}]}`))
+		default:
+			polls++
+			fmt.Fprintf(w, `{"entry":[{"content":{"sid":"job.1","isDone":false,"resultCount":%d}}]}`, polls*400)
+		}
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{PollInterval: 10 * time.Millisecond, MaxPollInterval: 10 * time.Millisecond})
+	client.baseURL = testServer.URL
+
+	stopped, err := client.WaitForResults("job.1", 1000)
+	if err != nil {
+		t.Fatalf("WaitForResults returned error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !stopped || !finalized {
+		t.Errorf("Expected the job to be finalized, got stopped=%t finalized=%t", stopped, finalized)
+	}
+	if polls != 3 {
+		t.Errorf("Expected the job to be finalized on the third poll, got %d polls", polls)
+	}
+}
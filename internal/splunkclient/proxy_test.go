@@ -0,0 +1,33 @@
+package splunkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the search head
+		proxied = append(proxied, r.URL.Host)
+		w.Write([]byte(`{}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(config.ClientConfig{Host: "splunk.invalid", Port: 8089, Proxy: proxyURL})
+
+	if err := client.DeleteSearchJob("1756172871.1180"); err != nil {
+		t.Fatalf("DeleteSearchJob returned error: %v", err)
+	}
+	if len(proxied) != 1 || proxied[0] != "splunk.invalid:8089" {
+		t.Errorf("Expected one request for splunk.invalid:8089 through the proxy, got %v", proxied)
+	}
+}
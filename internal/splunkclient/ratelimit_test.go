@@ -0,0 +1,50 @@
+package splunkclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestRateLimit(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{MaxRequestsPerSecond: 100})
+	client.baseURL = testServer.URL
+
+	// The first request starts right away, the other four 10ms apart
+	started := time.Now()
+	for range 5 {
+		if _, err := client.Get("/services/server/info", nil); err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected 5 requests to take at least 40ms at 100 per second, took %s", elapsed)
+	}
+}
+
+func TestRateLimitCanceled(t *testing.T) {
+	limiter := newRateLimiter(0.001)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("Expected the first request to start right away, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while waiting, got %v", err)
+	}
+
+	if newRateLimiter(0).wait(context.Background()) != nil {
+		t.Error("Expected no limit without a rate")
+	}
+}
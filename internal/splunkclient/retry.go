@@ -0,0 +1,103 @@
+package splunkclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Retries wait at most this long between attempts, unless Splunk asks for longer with Retry-After
+const maxRetryBackoff = 30 * time.Second
+
+// A Retry-After longer than this is cut short, a search head asking for more is better reported
+const maxRetryAfter = 5 * time.Minute
+
+// IsRetryable reports whether a failed request may succeed when repeated: Splunk was overloaded or
+// restarting (429, 502, 503 or 504) or the connection was dropped. Other errors, such as an expired
+// job or rejected credentials, won't go away by retrying, and canceled requests aren't retried.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// shouldRetry reports whether a request that failed with err is sent again. A POST that may have
+// reached Splunk, e.g. one whose connection dropped, isn't repeated since it could create a second job.
+func (c *Client) shouldRetry(request *http.Request, err error, retry int) bool {
+	if retry >= c.maxRetries || !IsRetryable(err) {
+		return false
+	}
+	var httpErr *HTTPError
+	return request.Method != http.MethodPost || errors.As(err, &httpErr)
+}
+
+// retryDelay returns how long to wait before the given retry: the Retry-After Splunk asked for, or
+// an exponential backoff with jitter so concurrent requests don't retry in lockstep
+func (c *Client) retryDelay(err error, retry int) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return min(httpErr.RetryAfter, maxRetryAfter)
+	}
+	backoff := min(c.retryBackoff<<min(retry, 16), maxRetryBackoff)
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// retryRequest waits for the retry's delay and returns a copy of request that can be sent again
+func (c *Client) retryRequest(request *http.Request, err error, retry int) (*http.Request, error) {
+	delay := c.retryDelay(err, retry)
+	slog.Warn("Request failed, retrying", "method", request.Method, "path", request.URL.Path, "retry", retry+1, "max_retries", c.maxRetries, "delay", delay, "error", err)
+	select {
+	case <-time.After(delay):
+	case <-request.Context().Done():
+		return nil, request.Context().Err()
+	}
+
+	return cloneRequest(request)
+}
+
+// cloneRequest returns a copy of request that can be sent again, with a fresh body
+func cloneRequest(request *http.Request) (*http.Request, error) {
+	cloned := request.Clone(request.Context())
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		cloned.Body = body
+	}
+	return cloned, nil
+}
@@ -0,0 +1,155 @@
+package splunkclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestRetries(t *testing.T) {
+	// dropConnection closes the connection without answering, like a search head restarting
+	dropConnection := func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatalf("Hijack failed: %v", err)
+		}
+		conn.Close()
+	}
+
+	tests := []struct {
+		name             string
+		method           string
+		fail             func(w http.ResponseWriter)
+		failures         int
+		maxRetries       int
+		expectedRequests int32
+		expectedError    bool
+	}{
+		{
+			name:             "retries 503 until it succeeds",
+			method:           "GET",
+			fail:             func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			failures:         2,
+			maxRetries:       3,
+			expectedRequests: 3,
+		},
+		{
+			name:             "gives up after max retries",
+			method:           "GET",
+			fail:             func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) },
+			failures:         5,
+			maxRetries:       2,
+			expectedRequests: 3,
+			expectedError:    true,
+		},
+		{
+			name:             "doesn't retry fatal errors",
+			method:           "GET",
+			fail:             func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 1,
+			expectedError:    true,
+		},
+		{
+			name:             "retries a dropped connection",
+			method:           "GET",
+			fail:             dropConnection,
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 2,
+		},
+		{
+			name:             "retries a POST that Splunk refused",
+			method:           "POST",
+			fail:             func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 2,
+		},
+		{
+			name:             "doesn't repeat a POST that may have reached Splunk",
+			method:           "POST",
+			fail:             dropConnection,
+			failures:         1,
+			maxRetries:       3,
+			expectedRequests: 1,
+			expectedError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "POST" {
+					r.ParseForm()
+					if r.PostForm.Get("search") != "search index=main" {
+						t.Errorf("Expected the body to be resent, got %v", r.PostForm)
+					}
+				}
+				if int(requests.Add(1)) <= tt.failures {
+					tt.fail(w)
+					return
+				}
+				w.Write([]byte(`{"sid":"1756064805.1039"}`))
+			}))
+			defer testServer.Close()
+
+			client := NewClient(config.ClientConfig{MaxRetries: tt.maxRetries, RetryBackoff: time.Millisecond})
+			client.baseURL = testServer.URL
+
+			var err error
+			if tt.method == "POST" {
+				_, err = client.Post("/services/search/jobs", "application/x-www-form-urlencoded", nil, []byte("search=search+index%3Dmain"))
+			} else {
+				_, err = client.Get("/services/search/v2/jobs", nil)
+			}
+			if (err != nil) != tt.expectedError {
+				t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if requests.Load() != tt.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tt.expectedRequests, requests.Load())
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 8, 26, 2, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter("120", now); d != 2*time.Minute {
+		t.Errorf("Expected 2m, got %s", d)
+	}
+	if d := parseRetryAfter("Tue, 26 Aug 2025 02:00:30 GMT", now); d != 30*time.Second {
+		t.Errorf("Expected 30s, got %s", d)
+	}
+	if d := parseRetryAfter("soon", now); d != 0 {
+		t.Errorf("Expected 0 for an invalid value, got %s", d)
+	}
+
+	client := NewClient(config.ClientConfig{MaxRetries: 1})
+	if d := client.retryDelay(&HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}, 0); d != 7*time.Second {
+		t.Errorf("Expected the Retry-After delay, got %s", d)
+	}
+	if d := client.retryDelay(&HTTPError{StatusCode: http.StatusServiceUnavailable}, 2); d < 2*time.Second || d > 4*time.Second {
+		t.Errorf("Expected a jittered backoff between 2s and 4s, got %s", d)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	if IsRetryable(context.Canceled) {
+		t.Error("Expected a canceled request not to be retryable")
+	}
+	if IsRetryable(&HTTPError{StatusCode: http.StatusUnauthorized}) {
+		t.Error("Expected 401 not to be retryable")
+	}
+	if !IsRetryable(errors.Join(errors.New("chunk 3"), &HTTPError{StatusCode: http.StatusGatewayTimeout})) {
+		t.Error("Expected a wrapped 504 to be retryable")
+	}
+}
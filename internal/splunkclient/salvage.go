@@ -0,0 +1,85 @@
+package splunkclient
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// salvageJSONResults extracts every well-formed result from a malformed (e.g. truncated or corrupted)
+// JSON results payload. It walks the "results" array by bracket matching instead of using a decoder,
+// since encoding/json can't resynchronize after a syntax error. Returns the recovered results and
+// the number of records that had to be skipped.
+func salvageJSONResults(response string) ([]json.RawMessage, int) {
+	start := strings.Index(response, `"results":`)
+	if start == -1 {
+		return nil, 0
+	}
+	rest := strings.TrimLeft(response[start+len(`"results":`):], " \t\r\n")
+	if !strings.HasPrefix(rest, "[") {
+		return nil, 0
+	}
+	rest = rest[1:]
+
+	var results []json.RawMessage
+	skipped := 0
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n,")
+		if rest == "" || rest[0] == ']' {
+			return results, skipped
+		}
+		if rest[0] != '{' {
+			// Not the start of a record, so skip ahead to the next one
+			next := strings.IndexByte(rest, '{')
+			if next == -1 {
+				return results, skipped
+			}
+			rest = rest[next:]
+		}
+
+		end := matchingBrace(rest)
+		if end != -1 {
+			if record := []byte(rest[:end+1]); json.Valid(record) {
+				results = append(results, record)
+				rest = rest[end+1:]
+				continue
+			}
+		}
+
+		// The record is corrupted (e.g. a stray quote threw off the brace matching) or the payload
+		// ends in the middle of it. Splunk writes records without whitespace between them, so
+		// resume at the next record boundary if there is one.
+		skipped++
+		next := strings.Index(rest[1:], "},{")
+		if next == -1 {
+			return results, skipped
+		}
+		rest = rest[next+3:]
+	}
+}
+
+// matchingBrace returns the index of the brace closing the object s starts with, or -1 if it isn't closed
+func matchingBrace(s string) int {
+	depth := 0
+	inString := false
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,172 @@
+package splunkclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SavedSearch is a report or alert saved in Splunk
+type SavedSearch struct {
+	Name         string
+	App          string
+	Owner        string
+	Search       string
+	CronSchedule string // empty for reports that only run on demand
+	IsScheduled  bool
+}
+
+type savedSearchEntry struct {
+	Name    string       `json:"name"`
+	ACL     SearchJobACL `json:"acl"`
+	Content struct {
+		Search       string `json:"search"`
+		CronSchedule string `json:"cron_schedule"`
+		IsScheduled  bool   `json:"is_scheduled"`
+	} `json:"content"`
+}
+
+// savedSearchPath returns the path of a saved search in the namespace of owner and app, "-" matching
+// any of them
+func savedSearchPath(owner, app, name string) string {
+	return fmt.Sprintf("/servicesNS/%s/%s/saved/searches/%s", url.PathEscape(owner), url.PathEscape(app), url.PathEscape(name))
+}
+
+// GetSavedSearch looks up a saved search by name. Empty owner and app match any user and app, which
+// fails when more than one saved search has the name.
+func (c *Client) GetSavedSearch(name, owner, app string) (SavedSearch, error) {
+	response, err := c.Get(savedSearchPath(namespace(owner), namespace(app), name), map[string]string{
+		"output_mode": "json",
+	})
+	if err != nil {
+		return SavedSearch{}, err
+	}
+
+	var saved struct {
+		Entry []savedSearchEntry `json:"entry"`
+	}
+	err = json.Unmarshal([]byte(response), &saved)
+	if err != nil {
+		return SavedSearch{}, fmt.Errorf("error unmarshalling saved search: %w", err)
+	}
+
+	// Only exact matches count, whatever else the wildcard namespaces turned up
+	var matched []savedSearchEntry
+	for _, entry := range saved.Entry {
+		if entry.Name == name {
+			matched = append(matched, entry)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return SavedSearch{}, fmt.Errorf("no saved search named %q", name)
+	case 1:
+	default:
+		var places []string
+		for _, entry := range matched {
+			places = append(places, entry.ACL.Owner+"/"+entry.ACL.App)
+		}
+		return SavedSearch{}, fmt.Errorf("%d saved searches are named %q (owner/app: %s), narrow it down by owner or app", len(matched), name, strings.Join(places, ", "))
+	}
+
+	entry := matched[0]
+	return SavedSearch{
+		Name:         entry.Name,
+		App:          entry.ACL.App,
+		Owner:        entry.ACL.Owner,
+		Search:       entry.Content.Search,
+		CronSchedule: entry.Content.CronSchedule,
+		IsScheduled:  entry.Content.IsScheduled,
+	}, nil
+}
+
+func namespace(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// SavedSearchHistory retrieves the jobs of a saved search that Splunk still keeps, newest first
+func (c *Client) SavedSearchHistory(saved SavedSearch) ([]SearchJobEntry, error) {
+	response, err := c.Get(savedSearchPath(saved.Owner, saved.App, saved.Name)+"/history", map[string]string{
+		"output_mode": "json",
+		"count":       "0",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var history SplunkSearchResponse
+	err = json.Unmarshal([]byte(response), &history)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling saved search history: %w", err)
+	}
+
+	for i := range history.Entry {
+		// History entries are named after the job's sid
+		if history.Entry[i].Content.SID == "" {
+			history.Entry[i].Content.SID = history.Entry[i].Name
+		}
+	}
+	slices.SortStableFunc(history.Entry, func(a, b SearchJobEntry) int {
+		return b.Published.Compare(a.Published)
+	})
+	return history.Entry, nil
+}
+
+// IsScheduledRun reports whether the job sid was dispatched by the scheduler rather than run on demand
+func IsScheduledRun(sid string) bool {
+	return strings.HasPrefix(sid, "scheduler_")
+}
+
+// SavedSearchRun returns the job sid of a saved search, which must still be in its history
+func (c *Client) SavedSearchRun(saved SavedSearch, sid string) (SearchJobEntry, error) {
+	history, err := c.SavedSearchHistory(saved)
+	if err != nil {
+		return SearchJobEntry{}, err
+	}
+	for _, job := range history {
+		if job.Content.SID == sid {
+			return job, nil
+		}
+	}
+	return SearchJobEntry{}, fmt.Errorf("%s is not a run of saved search %q, or Splunk expired it", sid, saved.Name)
+}
+
+// LatestSavedSearchRun returns the newest job of a saved search that finished successfully. Runs that
+// are still going or failed are skipped.
+func (c *Client) LatestSavedSearchRun(saved SavedSearch) (SearchJobEntry, error) {
+	history, err := c.SavedSearchHistory(saved)
+	if err != nil {
+		return SearchJobEntry{}, err
+	}
+	for _, job := range history {
+		if !job.Content.IsDone || jobFailure(job.Content.SID, job.Content) != nil {
+			slog.Debug("Skipping run of saved search", "sid", job.Content.SID, "is_done", job.Content.IsDone, "dispatch_state", job.Content.DispatchState)
+			continue
+		}
+		return job, nil
+	}
+	return SearchJobEntry{}, fmt.Errorf("saved search %q has no finished run, Splunk may have expired its jobs", saved.Name)
+}
+
+// DispatchSavedSearch runs a saved search now and returns the sid of its job
+func (c *Client) DispatchSavedSearch(saved SavedSearch) (string, error) {
+	slog.Debug("Dispatching saved search", "name", saved.Name, "owner", saved.Owner, "app", saved.App)
+	response, err := c.Post(savedSearchPath(saved.Owner, saved.App, saved.Name)+"/dispatch", "application/x-www-form-urlencoded",
+		map[string]string{"output_mode": "json"}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var job NewSearchJob
+	err = json.Unmarshal([]byte(response), &job)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling dispatched job: %w", err)
+	}
+	return job.SID, nil
+}
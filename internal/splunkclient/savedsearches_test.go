@@ -0,0 +1,102 @@
+package splunkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestLatestSavedSearchRun(t *testing.T) {
+	responses := map[string]string{
+		"/servicesNS/-/-/saved/searches/Nightly Logins": `{"entry": [{"name": "Nightly Logins", "acl": {"owner": "admin", "app": "security"}, "content": {"search": "index=auth action=failure", "cron_schedule": "0 2 * * *", "is_scheduled": true}}]}`,
+		// Newest first once sorted: a run still going, a failed run, then the one to download
+		"/servicesNS/admin/security/saved/searches/Nightly Logins/history": `{"entry": [
+			{"name": "scheduler__admin__security_at_1700000000_1", "published": "2023-11-14T22:13:20Z", "content": {"isDone": true, "dispatchState": "DONE"}},
+			{"name": "scheduler__admin__security_at_1700172800_3", "published": "2023-11-16T22:13:20Z", "content": {"isDone": false, "dispatchState": "RUNNING"}},
+			{"name": "scheduler__admin__security_at_1700086400_2", "published": "2023-11-15T22:13:20Z", "content": {"isDone": true, "isFailed": true, "dispatchState": "FAILED"}},
+			{"name": "scheduler__admin__security_at_1700043200_4", "published": "2023-11-15T10:13:20Z", "content": {"isDone": true, "dispatchState": "DONE"}}
+		]}`,
+	}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	saved, err := client.GetSavedSearch("Nightly Logins", "", "")
+	if err != nil {
+		t.Fatalf("GetSavedSearch returned error: %v", err)
+	}
+	if saved.Owner != "admin" || saved.App != "security" || saved.Search != "index=auth action=failure" || !saved.IsScheduled {
+		t.Errorf("Unexpected saved search %+v", saved)
+	}
+
+	job, err := client.LatestSavedSearchRun(saved)
+	if err != nil {
+		t.Fatalf("LatestSavedSearchRun returned error: %v", err)
+	}
+	if job.Content.SID != "scheduler__admin__security_at_1700043200_4" {
+		t.Errorf("Expected the newest finished run, got %s", job.Content.SID)
+	}
+
+	job, err = client.SavedSearchRun(saved, "scheduler__admin__security_at_1700000000_1")
+	if err != nil || !job.Published.Equal(time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)) {
+		t.Errorf("Expected the run to be found in the history, got %+v, %v", job, err)
+	}
+	if _, err := client.SavedSearchRun(saved, "1700000000.42"); err == nil {
+		t.Error("Expected a job outside the history to be rejected")
+	}
+}
+
+func TestGetSavedSearchAmbiguous(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"entry": [
+			{"name": "Daily", "acl": {"owner": "nobody", "app": "search"}},
+			{"name": "Daily", "acl": {"owner": "analyst", "app": "security"}}
+		]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	_, err := client.GetSavedSearch("Daily", "", "")
+	if err == nil || !strings.Contains(err.Error(), "analyst/security") {
+		t.Errorf("Expected an error naming both saved searches, got %v", err)
+	}
+}
+
+func TestDispatchSavedSearch(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/servicesNS/admin/search/saved/searches/Daily/dispatch" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "admin__admin__search__Daily_at_1700000000_5"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{})
+	client.baseURL = testServer.URL
+
+	sid, err := client.DispatchSavedSearch(SavedSearch{Name: "Daily", Owner: "admin", App: "search"})
+	if err != nil {
+		t.Fatalf("DispatchSavedSearch returned error: %v", err)
+	}
+	if sid != "admin__admin__search__Daily_at_1700000000_5" {
+		t.Errorf("Unexpected sid %s", sid)
+	}
+}
@@ -0,0 +1,87 @@
+package splunkclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// sessionAuth authenticates with the session key a username and password were exchanged for at
+// /services/auth/login, so that the credentials are checked once instead of on every request. Splunk
+// expires idle sessions after an hour by default; a request rejected with the key logs in again.
+type sessionAuth struct {
+	client   *Client // logs in, with the context of the request being authenticated
+	username string
+	password string
+
+	mu  sync.Mutex
+	key string
+}
+
+// Apply sets the session key, logging in first when there is none. Concurrent requests wait for a
+// single login.
+func (a *sessionAuth) Apply(request *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.key == "" {
+		key, err := a.client.WithContext(request.Context()).login(a.username, a.password)
+		if err != nil {
+			return err
+		}
+		a.key = key
+	}
+	request.Header.Set("Authorization", "Splunk "+a.key)
+	slog.Debug("Using session key authentication")
+	return nil
+}
+
+// Refresh forgets the key Splunk rejected, unless another request has already logged in again, so that
+// the resent request logs in. A request without a key failed to log in, and trying the same
+// credentials again won't help.
+func (a *sessionAuth) Refresh(rejected *http.Request) (bool, error) {
+	key, ok := strings.CutPrefix(rejected.Header.Get("Authorization"), "Splunk ")
+	if !ok || key == "" {
+		return false, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.key == key {
+		a.key = ""
+	}
+	slog.Info("Splunk session expired, logging in again")
+	return true, nil
+}
+
+// login exchanges a username and password for a session key
+func (c *Client) login(username, password string) (string, error) {
+	// The login request itself carries the credentials in its body instead of an Authorization header
+	anonymous := *c
+	anonymous.auth = nil
+
+	data := url.Values{
+		"username":    {username},
+		"password":    {password},
+		"output_mode": {"json"},
+	}
+	response, err := anonymous.Post("/services/auth/login", "application/x-www-form-urlencoded", nil, []byte(data.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("login failed: %w", err)
+	}
+
+	var parsed struct {
+		SessionKey string `json:"sessionKey"`
+	}
+	if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+		return "", fmt.Errorf("error parsing login response: %w", err)
+	}
+	if parsed.SessionKey == "" {
+		return "", errors.New("login response has no session key")
+	}
+	slog.Debug("Logged in with a session key", "username", username)
+	return parsed.SessionKey, nil
+}
@@ -0,0 +1,84 @@
+package splunkclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestSessionAuth(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	valid := ""
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/services/auth/login" {
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("Expected no Authorization header on login, got %q", r.Header.Get("Authorization"))
+			}
+			if r.FormValue("username") != "admin" || r.FormValue("password") != "changeme" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			logins++
+			valid = []string{"", "key-1", "key-2"}[min(logins, 2)]
+			w.Write([]byte(`{"sessionKey":"` + valid + `"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Splunk "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"messages":[{"type":"WARN","text":"call not properly authenticated"}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{
+		Auth:        config.AuthConfig{Type: config.AuthHTTPBasic, Username: "admin", Password: "changeme"},
+		SessionAuth: true,
+	})
+	client.baseURL = testServer.URL
+
+	// Concurrent requests share a single login
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			if _, err := client.Get("/services/server/info", nil); err != nil {
+				t.Errorf("Get returned error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if logins != 1 {
+		t.Fatalf("Expected 1 login, got %d", logins)
+	}
+
+	// The session expires, so the next request logs in again and is resent
+	mu.Lock()
+	valid = "expired"
+	mu.Unlock()
+	if _, err := client.Get("/services/server/info", nil); err != nil {
+		t.Fatalf("Get after the session expired returned error: %v", err)
+	}
+	if logins != 2 {
+		t.Errorf("Expected 2 logins, got %d", logins)
+	}
+
+	// Wrong credentials fail the login
+	client = NewClient(config.ClientConfig{
+		Auth:        config.AuthConfig{Type: config.AuthHTTPBasic, Username: "admin", Password: "wrong"},
+		SessionAuth: true,
+	})
+	client.baseURL = testServer.URL
+	_, err := client.Get("/services/server/info", nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a 401 HTTPError, got %v", err)
+	}
+}
@@ -2,26 +2,74 @@ package splunkclient
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/cschmidt0121/spldl/internal/config"
 )
 
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	auth       config.AuthConfig
+	baseURL       string
+	httpClient    *http.Client
+	auth          Authenticator // shared by the copies made by WithContext, nil for unauthenticated requests
+	transferred   *transferCounter
+	maxRetries    int             // how often a request failing with a retryable error is sent again
+	retryBackoff  time.Duration   // delay before the first retry, doubled after every retry
+	limiter       *rateLimiter    // shared by the copies made by WithContext, nil without a rate limit
+	poller        *poller         // shared by the copies made by WithContext
+	waitTimeout   time.Duration   // how long WaitUntilJobIsDone waits, 0 for as long as the job runs
+	jobTTL        time.Duration   // how long Splunk keeps dispatched jobs after they're last accessed
+	namespace     string          // the /servicesNS/{owner}/{app} searches run in, empty for /services
+	dispatch      url.Values      // further parameters jobs are created with
+	compress      bool            // ask for gzip-compressed responses
+	correlationID string          // sent with every request and added to dispatched searches, empty to leave them out
+	jobs          *jobsAPI        // shared by the copies made by WithContext
+	ctx           context.Context // requests are canceled with it, nil for requests that can't be canceled
+}
+
+// WithContext returns a copy of the client whose requests are made with ctx, so that canceling ctx
+// aborts requests in flight and fails new ones
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// withoutDispatch returns a copy of the client that creates jobs without the further dispatch
+// parameters, for the searches spldl runs for its own checks
+func (c *Client) withoutDispatch() *Client {
+	clone := *c
+	clone.dispatch = nil
+	return &clone
+}
+
+// Context returns the context the client's requests are made with
+func (c *Client) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
 func (c *Client) Get(path string, queryParams map[string]string) (string, error) {
+	response, _, err := c.get(path, queryParams)
+	return response, err
+}
+
+// get is Get that also returns the size of the response before and after decompression
+func (c *Client) get(path string, queryParams map[string]string) (string, Transfer, error) {
 	url := c.baseURL + path
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequestWithContext(c.Context(), "GET", url, nil)
 	if err != nil {
-		return "", err
+		return "", Transfer{}, err
 	}
 
 	q := request.URL.Query()
@@ -34,10 +82,16 @@ func (c *Client) Get(path string, queryParams map[string]string) (string, error)
 }
 
 func (c *Client) Post(path string, contentType string, queryParams map[string]string, data []byte) (string, error) {
+	response, _, err := c.post(path, contentType, queryParams, data)
+	return response, err
+}
+
+// post is Post that also returns the size of the response before and after decompression
+func (c *Client) post(path string, contentType string, queryParams map[string]string, data []byte) (string, Transfer, error) {
 	url := c.baseURL + path
-	request, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	request, err := http.NewRequestWithContext(c.Context(), "POST", url, bytes.NewBuffer(data))
 	if err != nil {
-		return "", err
+		return "", Transfer{}, err
 	}
 
 	request.Header.Set("Content-Type", contentType)
@@ -53,7 +107,7 @@ func (c *Client) Post(path string, contentType string, queryParams map[string]st
 
 func (c *Client) Delete(path string, queryParams map[string]string) (string, error) {
 	url := c.baseURL + path
-	request, err := http.NewRequest("DELETE", url, nil)
+	request, err := http.NewRequestWithContext(c.Context(), "DELETE", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -64,42 +118,130 @@ func (c *Client) Delete(path string, queryParams map[string]string) (string, err
 	}
 	request.URL.RawQuery = q.Encode()
 
-	return c.doRequest(request)
+	response, _, err := c.doRequest(request)
+	return response, err
+}
+
+// doRequest sends a request and reads the whole response, retrying temporary failures up to the
+// client's MaxRetries
+func (c *Client) doRequest(request *http.Request) (string, Transfer, error) {
+	for retry := 0; ; retry++ {
+		body, transfer, err := c.doRequestOnce(request)
+		if err == nil || !c.shouldRetry(request, err, retry) {
+			return body, transfer, err
+		}
+		request, err = c.retryRequest(request, err, retry)
+		if err != nil {
+			return "", transfer, err
+		}
+	}
+}
+
+func (c *Client) doRequestOnce(request *http.Request) (string, Transfer, error) {
+	resp, err := c.sendRequest(request)
+	if err != nil {
+		return "", Transfer{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	transfer := transferOf(resp.Body)
+	if err != nil {
+		slog.Debug("Failed to read response body", "error", err)
+		return "", transfer, err
+	}
+
+	slog.Debug("HTTP request completed successfully", "response_size", len(body), "wire_size", transfer.WireBytes, "url", request.URL.String())
+	return string(body), transfer, nil
+}
+
+// sendRequest authenticates and sends a request, leaving the body of successful responses for the caller to read and close.
+// Requests to the v2 search job endpoints go to the v1 endpoints when the server predates them.
+func (c *Client) sendRequest(request *http.Request) (*http.Response, error) {
+	if !isV2JobsPath(request.URL.Path) {
+		return c.sendAuthenticated(request)
+	}
+	if c.usesV1Jobs() {
+		toV1Jobs(request)
+		return c.sendAuthenticated(request)
+	}
+	resp, err := c.sendAuthenticated(request)
+	if !c.fallBackToV1Jobs(err) {
+		return resp, err
+	}
+	v1, err := cloneRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	toV1Jobs(v1)
+	return c.sendAuthenticated(v1)
+}
+
+// sendAuthenticated is sendRequest for a path that is used as it is
+func (c *Client) sendAuthenticated(request *http.Request) (*http.Response, error) {
+	resp, err := c.send(request)
+	var httpErr *HTTPError
+	if c.auth == nil || !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The credentials may have expired, e.g. a session in the middle of a long download, so renew them
+	// and resend the request once
+	resend, refreshErr := c.auth.Refresh(request)
+	if refreshErr != nil {
+		return nil, fmt.Errorf("error renewing credentials: %w", refreshErr)
+	}
+	if !resend {
+		return resp, err
+	}
+	retried, err := cloneRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(retried)
 }
 
-func (c *Client) doRequest(request *http.Request) (string, error) {
+// send is sendRequest without renewing rejected credentials
+func (c *Client) send(request *http.Request) (*http.Response, error) {
+	if err := c.limiter.wait(request.Context()); err != nil {
+		return nil, err
+	}
 	slog.Debug("Making HTTP request", "method", request.Method, "url", request.URL.String())
+	if c.correlationID != "" {
+		request.Header.Set(CorrelationHeader, c.correlationID)
+	}
+	if c.auth != nil {
+		if err := c.auth.Apply(request); err != nil {
+			return nil, err
+		}
+	}
 
-	switch c.auth.Type {
-	case config.AuthHTTPBasic:
-		request.SetBasicAuth(c.auth.Username, c.auth.Password)
-		slog.Debug("Using HTTP Basic authentication")
-	case config.AuthToken:
-		request.Header.Set("Authorization", "Bearer "+c.auth.Token)
-		slog.Debug("Using Bearer token authentication")
+	// Ranges apply to the compressed bytes, so resumed downloads ask for uncompressed responses themselves
+	if c.compress && request.Header.Get("Range") == "" && request.Header.Get("Accept-Encoding") == "" {
+		request.Header.Set("Accept-Encoding", "gzip")
+	} else {
+		// Keeps the transport from asking for gzip on its own
+		request.Header.Set("Accept-Encoding", "identity")
 	}
 
 	resp, err := c.httpClient.Do(request)
 	if err != nil {
 		slog.Debug("HTTP request failed", "error", err, "url", request.URL.String())
-		return "", err
+		return nil, err
+	}
+	if err := c.wrapResponseBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error decompressing response: %w", err)
 	}
-	defer resp.Body.Close()
 
 	slog.Debug("HTTP response received", "status_code", resp.StatusCode, "url", request.URL.String())
 
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		slog.Debug("Failed to read response body", "error", err)
-		return "", err
+		defer resp.Body.Close()
+		return nil, newHTTPError(resp)
 	}
 
-	slog.Debug("HTTP request completed successfully", "response_size", len(body), "url", request.URL.String())
-	return string(body), nil
+	return resp, nil
 }
 
 func NewClient(config config.ClientConfig) *Client {
@@ -112,18 +254,59 @@ func NewClient(config config.ClientConfig) *Client {
 
 	var tlsConfig *tls.Config
 	if config.UseTLS {
-		tlsConfig = &tls.Config{InsecureSkipVerify: !config.VerifyTLS}
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: !config.VerifyTLS,
+			RootCAs:            config.RootCAs,
+			MinVersion:         config.TLSMinVersion,
+			ServerName:         config.TLSServerName,
+		}
 	}
 
-	return &Client{
+	client := &Client{
 		baseURL: baseURL,
 		httpClient: &http.Client{
 			Transport: &http.Transport{
 				TLSClientConfig: tlsConfig,
+				Proxy:           proxyFunc(config.Proxy),
 			},
 		},
-		auth: config.Auth,
+		transferred:   &transferCounter{},
+		maxRetries:    config.MaxRetries,
+		retryBackoff:  cmp.Or(config.RetryBackoff, time.Second),
+		limiter:       newRateLimiter(config.MaxRequestsPerSecond),
+		poller:        newPoller(config.PollInterval, config.MaxPollInterval),
+		waitTimeout:   config.WaitTimeout,
+		jobTTL:        cmp.Or(config.JobTTL, time.Hour),
+		namespace:     searchNamespace(config.Owner, config.App),
+		dispatch:      dispatchValues(config.DispatchParams),
+		compress:      !config.DisableCompression,
+		correlationID: config.CorrelationID,
+		jobs:          &jobsAPI{},
+	}
+	client.auth = newAuthenticator(config, client)
+	return client
+}
+
+// searchNamespace returns the path prefix of the owner and app's namespace, empty when neither is set
+func searchNamespace(owner, app string) string {
+	if owner == "" && app == "" {
+		return ""
+	}
+	return fmt.Sprintf("/servicesNS/%s/%s", url.PathEscape(cmp.Or(owner, "nobody")), url.PathEscape(cmp.Or(app, "search")))
+}
+
+// searchPath returns the path of a search endpoint, e.g. search/v2/jobs, in the client's namespace
+func (c *Client) searchPath(path string) string {
+	return cmp.Or(c.namespace, "/services") + "/" + path
+}
+
+// proxyFunc connects through proxy, or the proxy the environment's HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY select when it's nil. socks5 proxies are supported by net/http.
+func proxyFunc(proxy *url.URL) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return http.ProxyFromEnvironment
 	}
+	return http.ProxyURL(proxy)
 }
 
 func NewClientWithHTTPClient(config config.ClientConfig, httpClient *http.Client) *Client {
@@ -134,9 +317,22 @@ func NewClientWithHTTPClient(config config.ClientConfig, httpClient *http.Client
 		baseURL = fmt.Sprintf("http://%s:%d", config.Host, config.Port)
 	}
 
-	return &Client{
-		baseURL:    baseURL,
-		httpClient: httpClient,
-		auth:       config.Auth,
+	client := &Client{
+		baseURL:       baseURL,
+		httpClient:    httpClient,
+		transferred:   &transferCounter{},
+		maxRetries:    config.MaxRetries,
+		retryBackoff:  cmp.Or(config.RetryBackoff, time.Second),
+		limiter:       newRateLimiter(config.MaxRequestsPerSecond),
+		poller:        newPoller(config.PollInterval, config.MaxPollInterval),
+		waitTimeout:   config.WaitTimeout,
+		jobTTL:        cmp.Or(config.JobTTL, time.Hour),
+		namespace:     searchNamespace(config.Owner, config.App),
+		dispatch:      dispatchValues(config.DispatchParams),
+		compress:      !config.DisableCompression,
+		correlationID: config.CorrelationID,
+		jobs:          &jobsAPI{},
 	}
+	client.auth = newAuthenticator(config, client)
+	return client
 }
@@ -0,0 +1,60 @@
+package splunkclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestTLSOptions(t *testing.T) {
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	testServer.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	testServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	// The test server's certificate is valid for example.com and 127.0.0.1
+	roots := x509.NewCertPool()
+	roots.AddCert(testServer.Certificate())
+
+	tests := []struct {
+		name          string
+		minVersion    uint16
+		serverName    string
+		expectSuccess bool
+	}{
+		{name: "defaults", expectSuccess: true},
+		{name: "matching server name", serverName: "example.com", expectSuccess: true},
+		{name: "mismatched server name", serverName: "splunk.example.org"},
+		{name: "server below the minimum version", minVersion: tls.VersionTLS13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(config.ClientConfig{
+				UseTLS:        true,
+				VerifyTLS:     true,
+				RootCAs:       roots,
+				TLSMinVersion: tt.minVersion,
+				TLSServerName: tt.serverName,
+			})
+			client.baseURL = testServer.URL
+
+			err := client.DeleteSearchJob("1756172871.1180")
+			if tt.expectSuccess && err != nil {
+				t.Errorf("Expected the request to succeed, got %v", err)
+			}
+			if !tt.expectSuccess && err == nil {
+				t.Error("Expected the TLS handshake to fail")
+			}
+		})
+	}
+}
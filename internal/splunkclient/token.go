@@ -0,0 +1,77 @@
+package splunkclient
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenExpiry returns when a Splunk authentication token expires, or the zero time if it never does.
+// Splunk tokens are JWTs, so the expiry is read from the token's claims without verifying its signature.
+func TokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error decoding token claims: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error unmarshalling token claims: %w", err)
+	}
+
+	if claims.Exp <= 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// IssueToken creates an authentication token for user that expires after ttl. The token carries the
+// permissions of user, so issuing it for a dedicated export user limits what it can be used for.
+// Creating tokens for other users requires the edit_tokens_all capability.
+func (c *Client) IssueToken(user string, audience string, ttl time.Duration) (IssuedToken, error) {
+	if ttl < time.Second {
+		return IssuedToken{}, fmt.Errorf("token ttl must be at least a second, got %s", ttl)
+	}
+
+	path := "/services/authorization/tokens"
+	queryParams := map[string]string{
+		"output_mode": "json",
+	}
+	data := url.Values{
+		"name":       {user},
+		"audience":   {audience},
+		"expires_on": {"+" + strconv.Itoa(int(ttl.Seconds())) + "s"},
+	}
+
+	response, err := c.Post(path, "application/x-www-form-urlencoded", queryParams, []byte(data.Encode()))
+	if err != nil {
+		return IssuedToken{}, err
+	}
+
+	var entries entryResponse[IssuedToken]
+	err = json.Unmarshal([]byte(response), &entries)
+	if err != nil {
+		return IssuedToken{}, fmt.Errorf("error unmarshalling issued token: %w", err)
+	}
+	if len(entries.Entry) == 0 || entries.Entry[0].Content.Token == "" {
+		return IssuedToken{}, errors.New("no token found in response")
+	}
+
+	token := entries.Entry[0].Content
+	slog.Debug("Token issued", "id", token.ID, "user", user)
+	return token, nil
+}
@@ -0,0 +1,81 @@
+package splunkclient
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestTokenExpiry(t *testing.T) {
+	jwt := func(claims string) string {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"splunk.secret","alg":"HS512","ver":"v2","tttyp":"static"}`))
+		return header + "." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		expected      time.Time
+		expectedError bool
+	}{
+		{name: "expiring token", token: jwt(`{"iss":"admin from sh1","sub":"svc_export","aud":"exports","idp":"Splunk","jti":"abc","iat":1756000000,"exp":1758592000,"nbr":1756000000}`), expected: time.Unix(1758592000, 0)},
+		{name: "token without expiry", token: jwt(`{"sub":"svc_export","exp":0}`)},
+		{name: "session key", token: "a8Zy6Qw3^kT_Xd", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiry, err := TokenExpiry(tt.token)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if !expiry.Equal(tt.expected) {
+				t.Errorf("Expected expiry %s, got %s", tt.expected, expiry)
+			}
+		})
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/services/authorization/tokens" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		r.ParseForm()
+		if r.PostForm.Get("name") != "svc_export" || r.PostForm.Get("audience") != "spldl export" {
+			t.Errorf("Unexpected form %v", r.PostForm)
+		}
+		if expires := r.PostForm.Get("expires_on"); expires != "+3600s" {
+			t.Errorf("Expected expires_on +3600s, got %s", expires)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"entry":[{"name":"tokens","content":{"id":"f0c1d2","token":"eyJraWQiOiJzcGx1bmsuc2VjcmV0In0.e30.c2ln"}}]}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{
+		Auth: config.AuthConfig{
+			Type:     config.AuthHTTPBasic,
+			Username: "admin",
+			Password: "changeme",
+		},
+	})
+	client.baseURL = testServer.URL
+
+	token, err := client.IssueToken("svc_export", "spldl export", time.Hour)
+	if err != nil {
+		t.Fatalf("IssueToken returned error: %v", err)
+	}
+	if token.ID != "f0c1d2" || token.Token != "eyJraWQiOiJzcGx1bmsuc2VjcmV0In0.e30.c2ln" {
+		t.Errorf("Unexpected token %+v", token)
+	}
+
+	_, err = client.IssueToken("svc_export", "spldl export", 0)
+	if err == nil {
+		t.Error("Expected an error for a zero ttl")
+	}
+}
@@ -0,0 +1,102 @@
+package splunkclient
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Transfer is the amount of response data received, as sent over the network and after decompression
+type Transfer struct {
+	WireBytes int64 // bytes received over the network, compressed when the server gzipped the response
+	Bytes     int64 // bytes after decompression
+}
+
+// Ratio returns how many times larger the data is than what was sent over the network
+func (t Transfer) Ratio() float64 {
+	if t.WireBytes == 0 {
+		return 0
+	}
+	return float64(t.Bytes) / float64(t.WireBytes)
+}
+
+// transferCounter accumulates the transfers of every response of a client
+type transferCounter struct {
+	wireBytes atomic.Int64
+	bytes     atomic.Int64
+}
+
+// Transferred returns the response data received by the client so far
+func (c *Client) Transferred() Transfer {
+	return Transfer{WireBytes: c.transferred.wireBytes.Load(), Bytes: c.transferred.bytes.Load()}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// responseBody decompresses gzipped responses and counts the bytes read before and after
+// decompression, adding them to the client's totals as they are read
+type responseBody struct {
+	body    io.ReadCloser
+	wire    *countingReader
+	decoded io.Reader
+	bytes   int64
+	counter *transferCounter
+	counted int64 // wire bytes already added to counter
+}
+
+// wrapResponseBody replaces the body of resp with a responseBody. Compression is requested by
+// sendRequest itself, since the transport's transparent decompression hides the compressed size.
+func (c *Client) wrapResponseBody(resp *http.Response) error {
+	wire := &countingReader{reader: resp.Body}
+	body := &responseBody{body: resp.Body, wire: wire, decoded: wire, counter: c.transferred}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(wire)
+		if err != nil {
+			return err
+		}
+		body.decoded = gz
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = body
+	return nil
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	n, err := b.decoded.Read(p)
+	b.bytes += int64(n)
+	b.counter.bytes.Add(int64(n))
+	b.counter.wireBytes.Add(b.wire.n - b.counted)
+	b.counted = b.wire.n
+	return n, err
+}
+
+func (b *responseBody) Close() error {
+	return b.body.Close()
+}
+
+func (b *responseBody) transfer() Transfer {
+	return Transfer{WireBytes: b.wire.n, Bytes: b.bytes}
+}
+
+// transferOf returns the data read so far from a response body returned by sendRequest
+func transferOf(body io.Reader) Transfer {
+	if b, ok := body.(*responseBody); ok {
+		return b.transfer()
+	}
+	return Transfer{}
+}
@@ -0,0 +1,83 @@
+package splunkclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestCompressedTransfer(t *testing.T) {
+	raw := strings.Repeat("2025-08-26T01:00:00 host=web01 action=failure user=alice\n", 200)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(raw))
+	gz.Close()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected gzip to be requested, got %q", r.Header.Get("Accept-Encoding"))
+			w.Write([]byte(raw))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{
+		Auth: config.AuthConfig{
+			Type:  config.AuthToken,
+			Token: "testtoken",
+		},
+	})
+	client.baseURL = testServer.URL
+
+	page, err := client.GetJobResults("1756172871.1180", 10000, 0, "raw", ResultsFilter{})
+	if err != nil {
+		t.Fatalf("GetJobResults returned error: %v", err)
+	}
+	if page.Data != raw {
+		t.Errorf("Expected the decompressed results, got %d bytes", len(page.Data))
+	}
+
+	expected := Transfer{WireBytes: int64(compressed.Len()), Bytes: int64(len(raw))}
+	if page.Transfer != expected {
+		t.Errorf("Expected page transfer %+v, got %+v", expected, page.Transfer)
+	}
+	if transferred := client.Transferred(); transferred != expected {
+		t.Errorf("Expected client transfer %+v, got %+v", expected, transferred)
+	}
+	if ratio := expected.Ratio(); ratio <= 1 {
+		t.Errorf("Expected a compression ratio above 1, got %f", ratio)
+	}
+}
+
+func TestDisableCompression(t *testing.T) {
+	raw := "2025-08-26T01:00:00 host=web01 action=failure user=alice\n"
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if encoding := r.Header.Get("Accept-Encoding"); encoding != "identity" {
+			t.Errorf("Expected an uncompressed response to be requested, got %q", encoding)
+		}
+		w.Write([]byte(raw))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(config.ClientConfig{DisableCompression: true})
+	client.baseURL = testServer.URL
+
+	page, err := client.GetJobResults("1756172871.1180", 10000, 0, "raw", ResultsFilter{})
+	if err != nil {
+		t.Fatalf("GetJobResults returned error: %v", err)
+	}
+	if page.Data != raw {
+		t.Errorf("Expected the results, got %q", page.Data)
+	}
+	if ratio := client.Transferred().Ratio(); ratio != 1 {
+		t.Errorf("Expected a compression ratio of 1, got %f", ratio)
+	}
+}
@@ -1,6 +1,9 @@
 package splunkclient
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // SearchJobContent contains the essential search job information
 type SearchJobContent struct {
@@ -15,13 +18,31 @@ type SearchJobContent struct {
 	EventCount          int       `json:"eventCount"`
 	EventAvailableCount int       `json:"eventAvailableCount"`
 	RunDuration         float64   `json:"runDuration"`
+	ScanCount           int       `json:"scanCount"`
+	DiskUsage           int64     `json:"diskUsage"`
+	TTL                 int       `json:"ttl"` // seconds until Splunk deletes the job unless it's accessed again
+
+	IsPaused bool             `json:"isPaused"`
+	IsZombie bool             `json:"isZombie"` // the search process died without finishing the job
+	Messages []ResultsMessage `json:"messages"`
+}
+
+// SearchJobACL contains the ownership information of a search job
+type SearchJobACL struct {
+	Owner   string `json:"owner"`
+	App     string `json:"app"`
+	Sharing string `json:"sharing"`
 }
 
 // SearchJobEntry represents a search job entry from the API response
 type SearchJobEntry struct {
-	Name    string           `json:"name"`
-	ID      string           `json:"id"`
-	Content SearchJobContent `json:"content"`
+	Name      string           `json:"name"`
+	ID        string           `json:"id"`
+	Published time.Time        `json:"published"`
+	Updated   time.Time        `json:"updated"`
+	Author    string           `json:"author"`
+	ACL       SearchJobACL     `json:"acl"`
+	Content   SearchJobContent `json:"content"`
 }
 
 // SplunkSearchResponse represents the API response for job status
@@ -33,12 +54,100 @@ type NewSearchJob struct {
 	SID string `json:"sid"`
 }
 
+// ResultsMessage is a message Splunk attached to a results payload, e.g. about a missing lookup
+type ResultsMessage struct {
+	Type string `json:"type"` // DEBUG, INFO, WARN, ERROR or FATAL
+	Text string `json:"text"`
+}
+
+// IsProblem reports whether the message may explain missing or incomplete results
+func (m ResultsMessage) IsProblem() bool {
+	switch m.Type {
+	case "WARN", "ERROR", "FATAL":
+		return true
+	default:
+		return false
+	}
+}
+
+// ResultsPage is a page of job results converted to the requested output mode
+type ResultsPage struct {
+	Data      string
+	Warnings  []string         // non-fatal problems hit while converting the page
+	Messages  []ResultsMessage // warnings and errors Splunk attached to the page
+	Malformed bool             // the payload wasn't valid JSON and was salvaged record by record
+	Skipped   int              // results known to be dropped while converting the page
+	Transfer  Transfer         // size of the page's response before and after decompression
+}
+
+// FieldSummary is the distribution of a field's values in a job, as returned by the summary endpoint
+type FieldSummary struct {
+	Count         int `json:"count"`
+	DistinctCount int `json:"distinct_count"`
+	Modes         []struct {
+		Value string `json:"value"`
+		Count int    `json:"count"`
+	} `json:"modes"`
+}
+
 type SearchJobResults struct {
-	Preview    bool          `json:"preview"`
-	InitOffset int           `json:"init_offset"`
-	Messages   []interface{} `json:"messages"`
+	Preview    bool             `json:"preview"`
+	InitOffset int              `json:"init_offset"`
+	Messages   []ResultsMessage `json:"messages"`
 	Fields     []struct {
 		Name string `json:"name"`
 	} `json:"fields"`
-	Results []map[string]interface{} `json:"results"`
+	// Results are kept as raw JSON so they can be copied to ndjson in their original field order
+	Results []json.RawMessage `json:"results"`
+}
+
+// CurrentContext describes the authenticated user
+type CurrentContext struct {
+	Username     string   `json:"username"`
+	RealName     string   `json:"realname"`
+	Roles        []string `json:"roles"`
+	DefaultApp   string   `json:"defaultApp"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// RoleContent contains the search restrictions of a role
+type RoleContent struct {
+	ImportedRoles      []string `json:"imported_roles"`
+	SrchMaxTime        int      `json:"srchMaxTime"`
+	SrchTimeWin        int      `json:"srchTimeWin"`
+	SrchDiskQuota      int      `json:"srchDiskQuota"` // in MB
+	SrchJobsQuota      int      `json:"srchJobsQuota"`
+	SrchIndexesAllowed []string `json:"srchIndexesAllowed"`
+	SrchIndexesDefault []string `json:"srchIndexesDefault"`
+	SrchFilter         string   `json:"srchFilter"`
+}
+
+// Role is a named role
+type Role struct {
+	Name string
+	RoleContent
+}
+
+// RestAPILimits contains the [restapi] stanza of limits.conf. Conf endpoints return every value as a string.
+type RestAPILimits struct {
+	MaxResultRows string `json:"maxresultrows"`
+}
+
+// SearchLimitsConf contains the [search] stanza of limits.conf
+type SearchLimitsConf struct {
+	MaxCount string `json:"max_count"`
+}
+
+// IssuedToken is a newly created authentication token
+type IssuedToken struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+// entryResponse is the generic envelope of Splunk REST collection responses
+type entryResponse[T any] struct {
+	Entry []struct {
+		Name    string `json:"name"`
+		Content T      `json:"content"`
+	} `json:"entry"`
 }
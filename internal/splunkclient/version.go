@@ -0,0 +1,101 @@
+package splunkclient
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ServerInfo contains the version of the Splunk server
+type ServerInfo struct {
+	Version    string `json:"version"`
+	ServerName string `json:"serverName"`
+	Build      string `json:"build"`
+}
+
+// GetServerInfo retrieves the name and version of the Splunk server
+func (c *Client) GetServerInfo() (ServerInfo, error) {
+	var info ServerInfo
+	err := getEntryContent(c, "/services/server/info", &info)
+	return info, err
+}
+
+// Splunk added the v2 search job endpoints, search/v2/jobs, in this major version. Older servers
+// only have search/jobs and answer v2 paths with 404.
+const v2JobsMajorVersion = 9
+
+// jobsAPI records which search job endpoints the server has. The server's version is only looked up
+// once a v2 endpoint isn't found, so servers that have them cost no extra request.
+type jobsAPI struct {
+	mu      sync.Mutex
+	checked bool
+	v1      bool // the server predates the v2 endpoints
+}
+
+// hasV2Jobs reports whether Splunk version has the v2 search job endpoints. Versions that can't be
+// parsed are assumed to have them.
+func hasV2Jobs(version string) bool {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	return err != nil || n >= v2JobsMajorVersion
+}
+
+// isV2JobsPath reports whether path is a v2 search job endpoint, in any namespace
+func isV2JobsPath(path string) bool {
+	return strings.Contains(path, "/search/v2/jobs")
+}
+
+// v1JobsPath returns the v1 endpoint of a v2 search job path
+func v1JobsPath(path string) string {
+	return strings.Replace(path, "/search/v2/jobs", "/search/jobs", 1)
+}
+
+// usesV1Jobs reports whether the server was found to have only the v1 search job endpoints
+func (c *Client) usesV1Jobs() bool {
+	if c.jobs == nil {
+		return false
+	}
+	c.jobs.mu.Lock()
+	defer c.jobs.mu.Unlock()
+	return c.jobs.v1
+}
+
+// fallBackToV1Jobs looks up the server's version after a v2 search job endpoint wasn't found, and
+// reports whether the server predates them, so the request should go to the v1 endpoint instead.
+// Splunk answers an endpoint it doesn't have with "Not Found", unlike a job it doesn't know, which is
+// an "Unknown sid.".
+func (c *Client) fallBackToV1Jobs(err error) bool {
+	var httpErr *HTTPError
+	if c.jobs == nil || !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound || !strings.EqualFold(httpErr.Message, "Not Found") {
+		return false
+	}
+	c.jobs.mu.Lock()
+	defer c.jobs.mu.Unlock()
+	if c.jobs.checked {
+		// Another request looked the version up already
+		return c.jobs.v1
+	}
+	c.jobs.checked = true
+	info, err := c.GetServerInfo()
+	if err != nil {
+		slog.Debug("Failed to look up the Splunk version", "error", err)
+		return false
+	}
+	if hasV2Jobs(info.Version) {
+		return false
+	}
+	slog.Info("Splunk predates the v2 search job endpoints, using the v1 endpoints", "version", info.Version)
+	c.jobs.v1 = true
+	return true
+}
+
+// toV1Jobs points a request to a v2 search job endpoint at its v1 endpoint
+func toV1Jobs(request *http.Request) {
+	request.URL.Path = v1JobsPath(request.URL.Path)
+	if request.URL.RawPath != "" {
+		request.URL.RawPath = v1JobsPath(request.URL.RawPath)
+	}
+}
@@ -0,0 +1,101 @@
+package splunkclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+)
+
+func TestHasV2Jobs(t *testing.T) {
+	tests := map[string]bool{
+		"8.2.6":       false,
+		"7.3.9":       false,
+		"9.0.0":       true,
+		"9.0.2208.4":  true,
+		"10.0.1":      true,
+		"":            true,
+		"development": true,
+	}
+	for version, expected := range tests {
+		if got := hasV2Jobs(version); got != expected {
+			t.Errorf("hasV2Jobs(%q) = %v, expected %v", version, got, expected)
+		}
+	}
+}
+
+func TestV1JobsFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		expected []string // the requests made
+		err      bool
+	}{
+		{
+			name:    "splunk 8",
+			version: "8.2.6",
+			expected: []string{
+				"/services/search/v2/jobs/1756064805.1039",
+				"/services/server/info",
+				"/services/search/jobs/1756064805.1039",
+				"/services/search/jobs/1756064805.1039/results",
+			},
+		},
+		{
+			name:    "splunk 9",
+			version: "9.1.2",
+			expected: []string{
+				"/services/search/v2/jobs/1756064805.1039",
+				"/services/server/info",
+				"/services/search/v2/jobs/1756064805.1039/results",
+			},
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []string
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests = append(requests, r.URL.Path)
+				mu.Unlock()
+				switch r.URL.Path {
+				case "/services/server/info":
+					w.Write([]byte(`{"entry":[{"content":{"version":"` + tt.version + `","serverName":"sh1"}}]}`))
+				case "/services/search/jobs/1756064805.1039":
+					w.Write([]byte(`{"entry":[{"content":{"sid":"1756064805.1039","dispatchState":"DONE","isDone":true,"resultCount":1}}]}`))
+				case "/services/search/jobs/1756064805.1039/results":
+					w.Write([]byte("_raw\nevent\n"))
+				default:
+					// What splunkd answers for an endpoint it doesn't have
+					w.WriteHeader(http.StatusNotFound)
+					w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><response><messages><msg type="ERROR">Not Found</msg></messages></response>`))
+				}
+			}))
+			defer testServer.Close()
+
+			client := NewClient(config.ClientConfig{})
+			client.baseURL = testServer.URL
+
+			status, err := client.GetJobStatus("1756064805.1039")
+			if tt.err != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !tt.err && status.ResultCount != 1 {
+				t.Errorf("Expected the status of the v1 endpoint, got %+v", status)
+			}
+			// Later requests go to the endpoints found without looking the version up again
+			_, err = client.GetJobResults("1756064805.1039", 10, 0, "csv", ResultsFilter{})
+			if tt.err != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !reflect.DeepEqual(requests, tt.expected) {
+				t.Errorf("Expected requests %v, got %v", tt.expected, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Package yaml decodes spldl's configuration and pipeline files with gopkg.in/yaml.v3, rejecting
+// fields the target doesn't have so that a misspelled setting fails instead of being ignored.
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Unmarshal decodes a YAML document into v. Fields are matched by their yaml tags, and fields v
+// doesn't have are rejected. An empty document leaves v as it is.
+func Unmarshal(data []byte, v any) error {
+	decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Decode decodes a document read into nested maps and slices, e.g. to rewrite it first, into v the
+// way Unmarshal does
+func Decode(tree any, v any) error {
+	data, err := Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(data, v)
+}
+
+// Marshal encodes v as a YAML document
+func Marshal(v any) ([]byte, error) {
+	return yamlv3.Marshal(v)
+}
@@ -0,0 +1,72 @@
+package yaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	type step struct {
+		Type   string   `yaml:"type"`
+		Fields []string `yaml:"fields"`
+	}
+	var v struct {
+		Name    string  `yaml:"name"`
+		Count   int     `yaml:"count"`
+		Enabled bool    `yaml:"enabled"`
+		Ratio   float64 `yaml:"ratio"`
+		Query   string  `yaml:"query"`
+		Steps   []step  `yaml:"steps"`
+	}
+	input := `
+name: test
+count: 3
+enabled: true
+ratio: 0.5
+query: |
+  index=main
+  | head 10
+steps:
+  - &sink {type: sink, fields: [host, _raw]}
+  - {type: redact, fields: ["user # not a comment"]}
+  - *sink
+`
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	sink := step{Type: "sink", Fields: []string{"host", "_raw"}}
+	expected := []step{sink, {Type: "redact", Fields: []string{"user # not a comment"}}, sink}
+	if v.Name != "test" || v.Count != 3 || !v.Enabled || v.Ratio != 0.5 || v.Query != "index=main\n| head 10\n" || !reflect.DeepEqual(v.Steps, expected) {
+		t.Errorf("Unexpected result %+v", v)
+	}
+
+	err := Unmarshal([]byte("name: test\nnmae: typo\n"), &v)
+	if err == nil || !strings.Contains(err.Error(), "field nmae not found") {
+		t.Errorf("Expected an error for the unknown field, got %v", err)
+	}
+	if err := Unmarshal(nil, &v); err != nil {
+		t.Errorf("Expected an empty document to decode, got %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var tree map[string]any
+	if err := Unmarshal([]byte("version: 1\nprofiles:\n  prod:\n    port: 8089\n"), &tree); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	tree["version"] = 2
+
+	var v struct {
+		Version  int `yaml:"version"`
+		Profiles map[string]struct {
+			Port int `yaml:"port"`
+		} `yaml:"profiles"`
+	}
+	if err := Decode(tree, &v); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+	if v.Version != 2 || v.Profiles["prod"].Port != 8089 {
+		t.Errorf("Unexpected result %+v", v)
+	}
+}
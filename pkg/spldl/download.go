@@ -0,0 +1,170 @@
+package spldl
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// Format is the format results are written in
+type Format string
+
+// The formats DownloadTo writes out of the box. Formats added with RegisterParser or
+// RegisterFileFormat are named like the parser, or like the file format's extension without the dot.
+const (
+	FormatNDJSON  Format = "ndjson"  // one JSON object per result
+	FormatCSV     Format = "csv"     // CSV with a header row
+	FormatRaw     Format = "raw"     // the _raw field of each event
+	FormatJSON    Format = "json"    // a JSON array of the results
+	FormatXLSX    Format = "xlsx"    // an Excel workbook
+	FormatParquet Format = "parquet" // a Parquet file of string columns
+)
+
+// Downloader downloads the results of finished search jobs. It's safe to run several downloads with
+// the same Downloader at once.
+type Downloader struct {
+	client  *Client
+	options downloadOptions
+}
+
+// DownloadOption configures a Downloader
+type DownloadOption func(*downloadOptions)
+
+type downloadOptions struct {
+	format         Format
+	maxConnections int
+
+	failOnJobErrors bool
+	allowPartial    bool
+
+	delimiter rune
+	quoteAll  bool
+	crlf      bool
+	locale    string
+
+	fields     []string
+	postFilter string
+
+	stopAfter int
+}
+
+// WithFormat sets the format of the results, FormatNDJSON by default
+func WithFormat(format Format) DownloadOption {
+	return func(o *downloadOptions) { o.format = format }
+}
+
+// WithMaxConnections sets how many chunks of results are downloaded concurrently, 8 by default
+func WithMaxConnections(n int) DownloadOption {
+	return func(o *downloadOptions) { o.maxConnections = n }
+}
+
+// WithFailOnJobErrors fails downloads of jobs Splunk reported an error for, such as a failing lookup,
+// instead of only listing it in the Result's Warnings
+func WithFailOnJobErrors() DownloadOption {
+	return func(o *downloadOptions) { o.failOnJobErrors = true }
+}
+
+// WithAllowPartial keeps downloads whose rows don't add up to the job's results, listing the mismatch
+// in the Result's Warnings instead of failing with a *RowCountError
+func WithAllowPartial() DownloadOption {
+	return func(o *downloadOptions) { o.allowPartial = true }
+}
+
+// WithDelimiter separates the fields of FormatCSV results with delimiter instead of a comma, e.g. '\t'
+// for tab-separated output
+func WithDelimiter(delimiter rune) DownloadOption {
+	return func(o *downloadOptions) { o.delimiter = delimiter }
+}
+
+// WithQuoteAll quotes every field of FormatCSV results, not only those that need it
+func WithQuoteAll() DownloadOption {
+	return func(o *downloadOptions) { o.quoteAll = true }
+}
+
+// WithCRLF ends the records of FormatCSV results with \r\n, as Excel does, instead of \n
+func WithCRLF() DownloadOption {
+	return func(o *downloadOptions) { o.crlf = true }
+}
+
+// WithLocale writes the decimal numbers and _time of FormatCSV results the way spreadsheets set to the
+// locale read them, e.g. "de-DE" for 0,75 and 26.08.2025 02:00:00. Locales with a decimal comma use a
+// semicolon as delimiter unless WithDelimiter is given.
+func WithLocale(locale string) DownloadOption {
+	return func(o *downloadOptions) { o.locale = locale }
+}
+
+// WithFields downloads only the given fields of each result and writes them in this order
+func WithFields(fields ...string) DownloadOption {
+	return func(o *downloadOptions) { o.fields = fields }
+}
+
+// WithPostFilter has Splunk filter the job's results with a post-process search before sending them,
+// e.g. "error OR warn" or "| where status>=500". Fewer rows than the job has results are then expected.
+func WithPostFilter(filter string) DownloadOption {
+	return func(o *downloadOptions) { o.postFilter = filter }
+}
+
+// WithStopAfter downloads only the first n results of the job, at most 500000
+func WithStopAfter(n int) DownloadOption {
+	return func(o *downloadOptions) { o.stopAfter = n }
+}
+
+// NewDownloader returns a Downloader using the client
+func (c *Client) NewDownloader(opts ...DownloadOption) *Downloader {
+	o := downloadOptions{format: FormatNDJSON, maxConnections: 8}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Downloader{client: c, options: o}
+}
+
+// Result describes a download, including one that failed
+type Result struct {
+	Rows     int      // the rows written
+	Warnings []string // problems that didn't stop the download, such as events lost to malformed results or warnings Splunk attached to them
+}
+
+// DownloadTo writes the results of the finished job sid to w. Chunks of results are downloaded
+// concurrently and written in order. Canceling ctx stops the download.
+func (d *Downloader) DownloadTo(ctx context.Context, w io.Writer, sid string) (Result, error) {
+	outputMode, stdoutFormat := string(d.options.format), ""
+	if _, ok := splunkclient.LookupParser(outputMode); !ok {
+		format, ok := downloader.FileFormatFor("results." + outputMode)
+		if !ok {
+			return Result{}, fmt.Errorf("spldl: unknown format %q", d.options.format)
+		}
+		outputMode, stdoutFormat = format.Mode, format.Extension
+	}
+
+	dl := downloader.NewDownloader(d.client.client.WithContext(ctx), config.DownloaderConfig{
+		OutputMode:     outputMode,
+		MaxConnections: d.options.maxConnections,
+		SID:            sid,
+		Filename:       downloader.Stdout,
+		Stdout:         w,
+		StdoutFormat:   stdoutFormat,
+
+		FailOnJobErrors: d.options.failOnJobErrors,
+		AllowPartial:    d.options.allowPartial,
+
+		CSVDelimiter: d.options.delimiter,
+		CSVQuoteAll:  d.options.quoteAll,
+		CSVCRLF:      d.options.crlf,
+		CSVLocale:    d.options.locale,
+
+		Fields:     d.options.fields,
+		PostFilter: d.options.postFilter,
+
+		StopAfter: d.options.stopAfter,
+	})
+	err := dl.DownloadSearchResults()
+	return Result{Rows: dl.RowsWritten(), Warnings: dl.Warnings().Summary()}, err
+}
+
+// RowCountError is returned by DownloadTo when the rows written don't add up to the job's results,
+// e.g. because a response was truncated
+type RowCountError = downloader.RowCountError
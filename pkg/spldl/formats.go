@@ -0,0 +1,55 @@
+package spldl
+
+import (
+	"github.com/cschmidt0121/spldl/internal/downloader"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// Parser turns Splunk's responses to results requests into the data written for a Format. Registering
+// one with RegisterParser makes its name a Format of its own.
+type Parser = splunkclient.Parser
+
+// ParserFunc adapts a function converting responses of a Splunk output_mode to a Parser
+type ParserFunc = splunkclient.ParserFunc
+
+// StreamParser is a Parser that also reads the line by line responses of exports. Exports pass the
+// lines of other parsers through, a result each.
+type StreamParser = splunkclient.StreamParser
+
+// ResultsPage is a converted response: the data written and the problems met converting it
+type ResultsPage = splunkclient.ResultsPage
+
+// RegisterParser makes name a Format whose results are converted by parser, replacing the parser
+// registered before for the name
+func RegisterParser(name string, parser Parser) {
+	splunkclient.RegisterParser(name, parser)
+}
+
+// Formatter writes results in a file format of its own, converted from FormatCSV or FormatNDJSON
+// results as they're downloaded
+type Formatter = downloader.Formatter
+
+// FileFormat is a file format results are written in, which the spldl command picks by the output's
+// Extension and DownloadTo by the Format named like it without the dot
+type FileFormat = downloader.FileFormat
+
+// RegisterFileFormat adds a file format, replacing the one registered before for its extension
+func RegisterFileFormat(format FileFormat) {
+	downloader.RegisterFileFormat(format)
+}
+
+// Field is a field of a result read by a RecordCodec
+type Field = downloader.Field
+
+// Record is a result, or the header naming the fields of the results, read by a RecordCodec
+type Record = downloader.Record
+
+// RecordCodec reads and writes the results of a Format record by record, which WithFields and the
+// spldl command's clipping, dedupe and time buckets need. FormatNDJSON and FormatCSV have one.
+type RecordCodec = downloader.RecordCodec
+
+// RegisterRecordCodec has every download in the format read its results with a new codec from
+// newCodec, replacing the codec registered before for the format
+func RegisterRecordCodec(format Format, newCodec func() RecordCodec) {
+	downloader.RegisterRecordCodec(string(format), newCodec)
+}
@@ -0,0 +1,229 @@
+// Package spldl downloads the results of Splunk searches. It is the library behind the spldl command:
+// create a Client for a search head, run a search with it, and download the results of the job to any
+// io.Writer with a Downloader.
+//
+//	client, err := spldl.NewClient("splunk.example.com", spldl.WithToken(os.Getenv("SPLUNK_TOKEN")))
+//	if err != nil {
+//		return err
+//	}
+//	sid, err := client.Search(ctx, "index=main | table _time host _raw", "-24h", "now")
+//	if err != nil {
+//		return err
+//	}
+//	_, err = client.NewDownloader(spldl.WithFormat(spldl.FormatCSV)).DownloadTo(ctx, os.Stdout, sid)
+//
+// Progress and problems are logged with log/slog's default logger.
+package spldl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cschmidt0121/spldl/internal/config"
+	"github.com/cschmidt0121/spldl/internal/splunkclient"
+)
+
+// DefaultPort is Splunk's management port, which the REST API is served on
+const DefaultPort = 8089
+
+// Client talks to the REST API of a Splunk search head. It is safe for concurrent use.
+type Client struct {
+	client *splunkclient.Client
+}
+
+// Option configures a Client
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	config     config.ClientConfig
+	httpClient *http.Client
+
+	authenticator Authenticator
+}
+
+// WithPort sets the port of the REST API, DefaultPort by default
+func WithPort(port int) Option {
+	return func(o *clientOptions) { o.config.Port = port }
+}
+
+// WithToken authenticates with a Splunk authentication token
+func WithToken(token string) Option {
+	return func(o *clientOptions) {
+		o.config.Auth = config.AuthConfig{Type: config.AuthToken, Token: token}
+	}
+}
+
+// WithBasicAuth authenticates with a username and password
+func WithBasicAuth(username, password string) Option {
+	return func(o *clientOptions) {
+		o.config.Auth = config.AuthConfig{Type: config.AuthHTTPBasic, Username: username, Password: password}
+	}
+}
+
+// WithSessionAuth makes a client using WithBasicAuth log in once and authenticate with the session key it
+// gets, logging in again when the session expires, instead of sending the credentials with every request
+func WithSessionAuth() Option {
+	return func(o *clientOptions) { o.config.SessionAuth = true }
+}
+
+// Authenticator adds credentials to the requests of a Client, for schemes that WithToken and
+// WithBasicAuth don't cover, e.g. signed headers or a JWT a proxy in front of Splunk expects. Apply is
+// called before every request is sent. Refresh is called when Splunk rejects a request with 401
+// Unauthorized and reports whether to send it again with renewed credentials.
+type Authenticator = splunkclient.Authenticator
+
+// BasicAuth is the Authenticator behind WithBasicAuth
+type BasicAuth = splunkclient.BasicAuth
+
+// BearerToken is the Authenticator behind WithToken
+type BearerToken = splunkclient.BearerToken
+
+// ClientCertificate is the Authenticator behind WithClientCertificate
+type ClientCertificate = splunkclient.ClientCertificate
+
+// WithAuthenticator authenticates requests with auth, replacing WithToken and WithBasicAuth
+func WithAuthenticator(auth Authenticator) Option {
+	return func(o *clientOptions) { o.authenticator = auth }
+}
+
+// WithClientCertificate authenticates with a client certificate during the TLS handshake (mutual TLS),
+// e.g. one loaded with tls.LoadX509KeyPair. It needs a transport that is an *http.Transport when
+// combined with WithHTTPClient.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return WithAuthenticator(ClientCertificate{Certificate: cert})
+}
+
+// WithInsecureSkipVerify skips verification of the server's TLS certificate, for search heads using
+// Splunk's self-signed certificate
+func WithInsecureSkipVerify() Option {
+	return func(o *clientOptions) { o.config.VerifyTLS = false }
+}
+
+// WithRootCAs verifies the server's TLS certificate with pool instead of the system's CAs
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(o *clientOptions) { o.config.RootCAs = pool }
+}
+
+// WithTLSMinVersion refuses TLS versions older than version, a tls.Version* constant such as
+// tls.VersionTLS13. Go's default minimum, TLS 1.2, applies otherwise.
+func WithTLSMinVersion(version uint16) Option {
+	return func(o *clientOptions) { o.config.TLSMinVersion = version }
+}
+
+// WithTLSServerName verifies the server's certificate against name instead of the host, for connecting
+// by IP address or through a tunnel
+func WithTLSServerName(name string) Option {
+	return func(o *clientOptions) { o.config.TLSServerName = name }
+}
+
+// WithProxy connects through proxy, an http, https or socks5 proxy URL. By default the proxy is taken
+// from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func WithProxy(proxy *url.URL) Option {
+	return func(o *clientOptions) { o.config.Proxy = proxy }
+}
+
+// WithoutCompression asks Splunk for uncompressed responses instead of gzip
+func WithoutCompression() Option {
+	return func(o *clientOptions) { o.config.DisableCompression = true }
+}
+
+// WithCorrelationID sends id in the X-Correlation-ID header of every request and adds it as a comment
+// to the searches the client dispatches, so that Splunk admins can find them in _audit
+func WithCorrelationID(id string) Option {
+	return func(o *clientOptions) { o.config.CorrelationID = id }
+}
+
+// WithMaxRetries retries requests up to n times when Splunk is overloaded or restarting, or the
+// connection drops, honoring the Retry-After header. Requests aren't retried by default.
+func WithMaxRetries(n int) Option {
+	return func(o *clientOptions) { o.config.MaxRetries = n }
+}
+
+// WithPollInterval checks running jobs after interval, doubling the delay after every check up to
+// maxInterval. Jobs are checked after 3 seconds at first and at least every minute by default.
+func WithPollInterval(interval, maxInterval time.Duration) Option {
+	return func(o *clientOptions) {
+		o.config.PollInterval = interval
+		o.config.MaxPollInterval = maxInterval
+	}
+}
+
+// WithWaitTimeout makes waiting for a job give up after timeout with a *JobTimeoutError, leaving the
+// job running. Jobs are waited on as long as they run by default.
+func WithWaitTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) { o.config.WaitTimeout = timeout }
+}
+
+// WithHTTPClient makes requests with httpClient, whose transport then decides how TLS is verified.
+// The TLS options are ignored.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) { o.httpClient = httpClient }
+}
+
+// NewClient returns a client for the search head at host, connecting over TLS. One of WithToken,
+// WithBasicAuth, WithClientCertificate and WithAuthenticator is required.
+func NewClient(host string, opts ...Option) (*Client, error) {
+	o := clientOptions{config: config.ClientConfig{Host: host, Port: DefaultPort, UseTLS: true, VerifyTLS: true}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if host == "" {
+		return nil, errors.New("spldl: a host is required")
+	}
+	if o.config.Auth.Type == "" && o.authenticator == nil {
+		return nil, errors.New("spldl: credentials are required, use WithToken, WithBasicAuth or WithAuthenticator")
+	}
+
+	var client *splunkclient.Client
+	if o.httpClient != nil {
+		client = splunkclient.NewClientWithHTTPClient(o.config, o.httpClient)
+	} else {
+		client = splunkclient.NewClient(o.config)
+	}
+	if o.authenticator != nil {
+		client = client.WithAuthenticator(o.authenticator)
+	}
+	return &Client{client: client}, nil
+}
+
+// JobFailedError is returned by Search when the job fails, is paused or its search process dies. Its
+// Messages hold the errors Splunk reported, e.g. a syntax error in the search.
+type JobFailedError = splunkclient.JobFailedError
+
+// JobTimeoutError is returned by Search when the job isn't done within WithWaitTimeout
+type JobTimeoutError = splunkclient.JobTimeoutError
+
+// Search runs a search over the time range from earliest to latest, given in Splunk's time syntax
+// (e.g. -24h and now), and waits for it to be done. It returns the SID of the job holding the results.
+// Canceling ctx stops waiting but leaves the job running, see DeleteJob. A job that doesn't finish
+// returns a *JobFailedError or *JobTimeoutError along with its SID.
+func (c *Client) Search(ctx context.Context, query, earliest, latest string) (string, error) {
+	client := c.client.WithContext(ctx)
+	sid, err := client.NewSearchJob(query, earliest, latest)
+	if err != nil {
+		return "", err
+	}
+	return sid, client.WaitUntilJobIsDone(sid)
+}
+
+// SearchFirst is Search for searches too broad to run to the end. The job is finalized once it has
+// found count results, see WithStopAfter to download only those.
+func (c *Client) SearchFirst(ctx context.Context, query, earliest, latest string, count int) (string, error) {
+	client := c.client.WithContext(ctx)
+	sid, err := client.NewSearchJob(query, earliest, latest)
+	if err != nil {
+		return "", err
+	}
+	_, err = client.WaitForResults(sid, count)
+	return sid, err
+}
+
+// DeleteJob deletes a search job, stopping it if it's still running
+func (c *Client) DeleteJob(ctx context.Context, sid string) error {
+	return c.client.WithContext(ctx).DeleteSearchJob(sid)
+}
@@ -0,0 +1,156 @@
+package spldl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+	client, err := NewClient(serverURL.Hostname(), WithPort(port), WithToken("secret"), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("NewClient returned error: %v", err)
+	}
+	return client
+}
+
+func TestNewClient(t *testing.T) {
+	if _, err := NewClient("splunk.example.com"); err == nil {
+		t.Error("Expected an error without credentials")
+	}
+	if _, err := NewClient("", WithToken("secret")); err == nil {
+		t.Error("Expected an error without a host")
+	}
+	if _, err := NewClient("splunk.example.com", WithBasicAuth("admin", "changeme"), WithInsecureSkipVerify()); err != nil {
+		t.Errorf("NewClient returned error: %v", err)
+	}
+}
+
+func TestDownloadTo(t *testing.T) {
+	const sid = "1756172871.1180"
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write([]byte(`{"entry":[{"name":"search index=main","content":{"sid":"` + sid + `","isDone":true,"dispatchState":"DONE","resultCount":2}}]}`))
+		case "/services/search/v2/jobs/" + sid + "/results":
+			w.Write([]byte("_time,host\n2025-08-26T02:00:00.000+00:00,web01\n2025-08-26T02:00:01.000+00:00,web02\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	var buf bytes.Buffer
+	d := client.NewDownloader(WithFormat(FormatCSV), WithMaxConnections(2))
+	result, err := d.DownloadTo(context.Background(), &buf, sid)
+	if err != nil {
+		t.Fatalf("DownloadTo returned error: %v", err)
+	}
+	if result.Rows != 2 || len(result.Warnings) != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	expected := "_time,host\n2025-08-26T02:00:00.000+00:00,web01\n2025-08-26T02:00:01.000+00:00,web02\n"
+	if buf.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	_, err = client.NewDownloader(WithFormat("xml")).DownloadTo(context.Background(), &buf, sid)
+	if err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// tsvCodec reads and writes tab-separated results whose first line is the header
+type tsvCodec struct {
+	header []string
+}
+
+func (c *tsvCodec) Records(data string) ([]Record, error) {
+	var records []Record
+	for line := range strings.Lines(data) {
+		values := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+		record := Record{Text: line, Header: c.header == nil}
+		if record.Header {
+			c.header = values
+		}
+		for i, value := range values {
+			record.Fields = append(record.Fields, Field{Name: c.header[i], Value: value})
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (c *tsvCodec) Encode(fields []Field, header bool) (string, error) {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = field.Value
+		if header {
+			values[i] = field.Name
+		}
+	}
+	return strings.Join(values, "\t") + "\n", nil
+}
+
+func (c *tsvCodec) Count(data string) (int, error) {
+	rows := strings.Count(data, "\n")
+	if c.header == nil && rows > 0 {
+		c.header = []string{}
+		rows--
+	}
+	return rows, nil
+}
+
+func TestDownloadToRegisteredFormats(t *testing.T) {
+	const sid = "1756172871.1181"
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/search/v2/jobs/" + sid:
+			w.Write([]byte(`{"entry":[{"name":"search index=main","content":{"sid":"` + sid + `","isDone":true,"dispatchState":"DONE","resultCount":2}}]}`))
+		case "/services/search/v2/jobs/" + sid + "/results":
+			if r.URL.Query().Get("output_mode") == "json" {
+				w.Write([]byte(`{"results":[{"_time":"2025-08-26T02:00:00.000+00:00","host":"web01"},{"_time":"2025-08-26T02:00:01.000+00:00","host":"web02"}]}`))
+				return
+			}
+			w.Write([]byte("_time,host\n2025-08-26T02:00:00.000+00:00,web01\n2025-08-26T02:00:01.000+00:00,web02\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	RegisterParser("tsv", ParserFunc{Mode: "csv", Func: func(response string, offset int) ResultsPage {
+		return ResultsPage{Data: strings.ReplaceAll(response, ",", "\t")}
+	}})
+	RegisterRecordCodec("tsv", func() RecordCodec { return &tsvCodec{} })
+
+	var buf bytes.Buffer
+	if _, err := client.NewDownloader(WithFormat("tsv"), WithFields("host")).DownloadTo(context.Background(), &buf, sid); err != nil {
+		t.Fatalf("DownloadTo returned error: %v", err)
+	}
+	if expected := "host\nweb01\nweb02\n"; buf.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if _, err := client.NewDownloader(WithFormat(FormatJSON)).DownloadTo(context.Background(), &buf, sid); err != nil {
+		t.Fatalf("DownloadTo returned error: %v", err)
+	}
+	var results []map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil || len(results) != 2 || results[1]["host"] != "web02" {
+		t.Errorf("Expected a JSON array of 2 results, got %s (%v)", buf.String(), err)
+	}
+}